@@ -0,0 +1,8 @@
+// DDns_go 命令行程序，功能实现在 pkg/updater 中
+package main
+
+import "DDns_go/pkg/updater"
+
+func main() {
+	updater.Main()
+}
@@ -3,7 +3,20 @@ module DDns_go
 go 1.21.5
 
 require (
-	github.com/aliyun/alibaba-cloud-sdk-go v1.62.676 // indirect
+	github.com/BurntSushi/toml v1.3.2
+	github.com/aliyun/alibaba-cloud-sdk-go v1.62.676
+	github.com/oschwald/maxminddb-golang v1.12.0
+	github.com/zalando/go-keyring v0.2.5
+	go.etcd.io/bbolt v1.3.8
+	golang.org/x/sys v0.15.0
+	golang.org/x/term v0.15.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/alessio/shellescape v1.4.1 // indirect
+	github.com/danieljoos/wincred v1.2.0 // indirect
+	github.com/godbus/dbus/v5 v5.1.0 // indirect
 	github.com/jmespath/go-jmespath v0.0.0-20180206201540-c2b33e8439af // indirect
 	github.com/json-iterator/go v1.1.5 // indirect
 	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
@@ -0,0 +1,95 @@
+package ipdetect
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// 校验地址属于请求的协议族，IPv6 还需要是全局单播地址
+func CheckFamily(ip, network string) error {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return fmt.Errorf("invalid IP address: %q", ip)
+	}
+	if (network == "tcp4") != (parsed.To4() != nil) {
+		return fmt.Errorf("IP address %s does not match network %s", ip, network)
+	}
+	if network == "tcp6" && !parsed.IsGlobalUnicast() {
+		return fmt.Errorf("IP address %s is not a global IPv6 address", ip)
+	}
+	return nil
+}
+
+// 不能作为公网地址的网段：本网络、环回、链路本地、文档示例、基准测试、保留和组播地址
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("127.0.0.0/8"),
+	netip.MustParsePrefix("169.254.0.0/16"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("224.0.0.0/4"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("::/128"),
+	netip.MustParsePrefix("::1/128"),
+	netip.MustParsePrefix("fe80::/10"),
+	netip.MustParsePrefix("2001:db8::/32"),
+	netip.MustParsePrefix("ff00::/8"),
+}
+
+// 运营商级 NAT（CGNAT）使用的共享地址
+var CGNATPrefix = netip.MustParsePrefix("100.64.0.0/10")
+
+// 私有网段，allowPrivate 为 true 时可以使用
+var privatePrefixes = []netip.Prefix{
+	netip.MustParsePrefix("10.0.0.0/8"),
+	CGNATPrefix,
+	netip.MustParsePrefix("172.16.0.0/12"),
+	netip.MustParsePrefix("192.168.0.0/16"),
+	netip.MustParsePrefix("fc00::/7"),
+}
+
+// 校验地址可以写入 DNS：保留地址总是拒绝，私有地址只在 allowPrivate 时接受
+func CheckPublic(ip string, allowPrivate bool) error {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return fmt.Errorf("invalid IP address: %q", ip)
+	}
+	addr = addr.Unmap()
+	for _, prefix := range reservedPrefixes {
+		if prefix.Contains(addr) {
+			return fmt.Errorf("IP address %s is in the reserved range %s", ip, prefix)
+		}
+	}
+	if allowPrivate {
+		return nil
+	}
+	if CGNATPrefix.Contains(addr) {
+		return fmt.Errorf("IP address %s is in the carrier-grade NAT range %s, the network is probably behind CGNAT", ip, CGNATPrefix)
+	}
+	for _, prefix := range privatePrefixes {
+		if prefix.Contains(addr) {
+			return fmt.Errorf("IP address %s is in the private range %s, set allowPrivateIP to use it", ip, prefix)
+		}
+	}
+	return nil
+}
+
+// 判断地址是否属于运营商级 NAT 的共享地址
+func InCGNATRange(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	return err == nil && CGNATPrefix.Contains(addr.Unmap())
+}
+
+// 返回文本中第一个属于请求协议族的地址
+func PickIP(text, network string) (string, error) {
+	for _, field := range strings.Fields(text) {
+		if CheckFamily(field, network) == nil {
+			return field, nil
+		}
+	}
+	return "", fmt.Errorf("no valid address found in output %q", strings.TrimSpace(text))
+}
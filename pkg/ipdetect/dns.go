@@ -0,0 +1,80 @@
+package ipdetect
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// 默认的 DNS 查询，格式为 name@server，txt: 前缀表示查询 TXT 记录
+var (
+	DefaultDNSQueries = []string{
+		"myip.opendns.com@resolver1.opendns.com",
+		"txt:o-o.myaddr.l.google.com@ns1.google.com",
+	}
+	DefaultDNSQueriesV6 = []string{
+		"myip.opendns.com@resolver1.ipv6-sandbox.opendns.com",
+		"txt:o-o.myaddr.l.google.com@ns1.google.com",
+	}
+)
+
+// 单次 DNS 查询的超时
+const dnsQueryTimeout = 5 * time.Second
+
+// 向指定的 DNS 服务器查询特殊域名获取公网地址，例如 OpenDNS 的 myip.opendns.com
+// 会返回发起查询的地址，Google 的 o-o.myaddr.l.google.com 在 TXT 记录中返回该地址。
+// Query 的格式为 name@server，txt: 前缀表示查询 TXT 记录
+type DNS struct {
+	Query string
+}
+
+func (d DNS) Detect(ctx context.Context, network string) (string, error) {
+	txt := strings.HasPrefix(d.Query, "txt:")
+	name, server, found := strings.Cut(strings.TrimPrefix(d.Query, "txt:"), "@")
+	if !found || name == "" || server == "" {
+		return "", fmt.Errorf("invalid DNS query %q, expected name@server", d.Query)
+	}
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+
+	// 所有查询都发往指定的服务器，并且只使用请求的协议族
+	family := strings.TrimPrefix(network, "tcp")
+	dialer := &net.Dialer{}
+	resolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, proto, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, proto+family, server)
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dnsQueryTimeout)
+	defer cancel()
+
+	// 使用完整域名，避免追加搜索域
+	fqdn := strings.TrimSuffix(name, ".") + "."
+	if txt {
+		records, err := resolver.LookupTXT(ctx, fqdn)
+		if err != nil {
+			return "", err
+		}
+		for _, record := range records {
+			if net.ParseIP(record) != nil {
+				return record, nil
+			}
+		}
+		return "", errors.New("IP address not found in TXT records")
+	}
+
+	ips, err := resolver.LookupIP(ctx, "ip"+family, fqdn)
+	if err != nil {
+		return "", err
+	}
+	if len(ips) == 0 {
+		return "", errors.New("no address in DNS response")
+	}
+	return ips[0].String(), nil
+}
@@ -0,0 +1,199 @@
+package ipdetect
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// DNS 记录类型
+const (
+	dnsTypeA   = 1
+	dnsTypeTXT = 16
+)
+
+// 响应中的一条记录，名称与问题相同
+type dnsAnswer struct {
+	recordType uint16
+	data       []byte
+}
+
+func dnsA(ip string) dnsAnswer {
+	return dnsAnswer{recordType: dnsTypeA, data: net.ParseIP(ip).To4()}
+}
+
+func dnsTXT(values ...string) dnsAnswer {
+	var data []byte
+	for _, value := range values {
+		data = append(data, byte(len(value)))
+		data = append(data, value...)
+	}
+	return dnsAnswer{recordType: dnsTypeTXT, data: data}
+}
+
+// 返回请求中的问题部分以及查询的名称和类型
+func dnsQuestion(request []byte) (question []byte, name string, recordType uint16) {
+	var labels []string
+	i := 12
+	for i < len(request) && request[i] != 0 {
+		n := int(request[i])
+		labels = append(labels, string(request[i+1:i+1+n]))
+		i += n + 1
+	}
+	end := i + 5 // 结尾的 0 以及类型和类别
+	return request[12:end], strings.Join(labels, ".") + ".", binary.BigEndian.Uint16(request[i+1 : i+3])
+}
+
+// 按请求编码响应，rcode 为 3 时表示域名不存在
+func dnsResponse(request []byte, rcode uint16, answers ...dnsAnswer) []byte {
+	question, _, _ := dnsQuestion(request)
+	msg := make([]byte, 12)
+	copy(msg[0:2], request[0:2])
+	binary.BigEndian.PutUint16(msg[2:4], 0x8180|rcode) // 响应、期望递归、支持递归
+	binary.BigEndian.PutUint16(msg[4:6], 1)
+	binary.BigEndian.PutUint16(msg[6:8], uint16(len(answers)))
+	msg = append(msg, question...)
+	for _, answer := range answers {
+		msg = append(msg, 0xc0, 0x0c) // 指向问题中的名称
+		msg = binary.BigEndian.AppendUint16(msg, answer.recordType)
+		msg = binary.BigEndian.AppendUint16(msg, 1) // IN
+		msg = binary.BigEndian.AppendUint32(msg, 60)
+		msg = binary.BigEndian.AppendUint16(msg, uint16(len(answer.data)))
+		msg = append(msg, answer.data...)
+	}
+	return msg
+}
+
+// 查询的名称和类型
+type dnsQuery struct {
+	name       string
+	recordType uint16
+}
+
+// 在本机启动 UDP 的 DNS 服务器，respond 返回 nil 时不响应
+func newDNSServer(t *testing.T, respond func(request []byte) []byte) (string, <-chan dnsQuery) {
+	t.Helper()
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	queries := make(chan dnsQuery, 16)
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			request := append([]byte(nil), buf[:n]...)
+			_, name, recordType := dnsQuestion(request)
+			select {
+			case queries <- dnsQuery{name: name, recordType: recordType}:
+			default:
+			}
+			if response := respond(request); response != nil {
+				conn.WriteTo(response, addr)
+			}
+		}
+	}()
+	return conn.LocalAddr().String(), queries
+}
+
+func TestDNSDetect(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string // 其中的 SERVER 替换为本机 DNS 服务器的地址
+		respond   func(request []byte) []byte
+		want      string // 为空时应返回错误
+		wantErr   string // 错误中应包含的内容，为空时不检查
+		wantQuery dnsQuery
+	}{
+		{
+			name:      "myip A record",
+			query:     "myip.opendns.com@SERVER",
+			respond:   func(request []byte) []byte { return dnsResponse(request, 0, dnsA("203.0.113.7")) },
+			want:      "203.0.113.7",
+			wantQuery: dnsQuery{name: "myip.opendns.com.", recordType: dnsTypeA},
+		},
+		{
+			name:      "first of several A records",
+			query:     "myip.opendns.com.@SERVER",
+			respond:   func(request []byte) []byte { return dnsResponse(request, 0, dnsA("203.0.113.8"), dnsA("203.0.113.9")) },
+			want:      "203.0.113.8",
+			wantQuery: dnsQuery{name: "myip.opendns.com.", recordType: dnsTypeA},
+		},
+		{
+			name:  "myaddr TXT record",
+			query: "txt:o-o.myaddr.l.google.com@SERVER",
+			respond: func(request []byte) []byte {
+				return dnsResponse(request, 0, dnsTXT("edns0-client-subnet 198.51.100.0/24"), dnsTXT("203.0.113.10"))
+			},
+			want:      "203.0.113.10",
+			wantQuery: dnsQuery{name: "o-o.myaddr.l.google.com.", recordType: dnsTypeTXT},
+		},
+		{
+			name:    "TXT record without address",
+			query:   "txt:o-o.myaddr.l.google.com@SERVER",
+			respond: func(request []byte) []byte { return dnsResponse(request, 0, dnsTXT("not an address")) },
+			wantErr: "IP address not found in TXT records",
+		},
+		{
+			name:    "empty answer",
+			query:   "myip.opendns.com@SERVER",
+			respond: func(request []byte) []byte { return dnsResponse(request, 0) },
+		},
+		{
+			name:    "name does not exist",
+			query:   "myip.opendns.com@SERVER",
+			respond: func(request []byte) []byte { return dnsResponse(request, 3) },
+			wantErr: "no such host",
+		},
+		{
+			name:  "malformed A record",
+			query: "myip.opendns.com@SERVER",
+			respond: func(request []byte) []byte {
+				return dnsResponse(request, 0, dnsAnswer{recordType: dnsTypeA, data: []byte{203, 0, 113}})
+			},
+		},
+		{
+			name:    "malformed response",
+			query:   "myip.opendns.com@SERVER",
+			respond: func(request []byte) []byte { return []byte{request[0], request[1], 0x81} },
+		},
+		{
+			name:    "invalid query",
+			query:   "myip.opendns.com",
+			respond: func([]byte) []byte { return nil },
+			wantErr: "invalid DNS query",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, queries := newDNSServer(t, test.respond)
+			// 无效的响应会被忽略，直到超时
+			ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+			defer cancel()
+
+			got, err := DNS{Query: strings.Replace(test.query, "SERVER", server, 1)}.Detect(ctx, "tcp4")
+			if test.want == "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("Detect() = %q, %v, want error %q", got, err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Detect() error = %v", err)
+			}
+			if got != test.want {
+				t.Errorf("Detect() = %q, want %q", got, test.want)
+			}
+			if query := <-queries; query != test.wantQuery {
+				t.Errorf("server received %+v, want %+v", query, test.wantQuery)
+			}
+		})
+	}
+}
@@ -0,0 +1,19 @@
+package ipdetect
+
+import (
+	"context"
+	"os"
+)
+
+// 从文件中读取 IP，文件可以由其它程序定期写入，使用其中第一个属于请求协议族的地址
+type File struct {
+	Path string
+}
+
+func (d File) Detect(_ context.Context, network string) (string, error) {
+	content, err := os.ReadFile(d.Path)
+	if err != nil {
+		return "", err
+	}
+	return PickIP(string(content), network)
+}
@@ -0,0 +1,74 @@
+package ipdetect
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// 访问返回公网 IP 的 HTTP API，例如 https://api.ipify.org。
+// Client 需要只使用 network 对应的协议族连接，这样返回的才是该协议族的地址；为 nil 时使用 http.DefaultClient
+type HTTP struct {
+	URL    string
+	Client *http.Client
+}
+
+func (d HTTP) Detect(ctx context.Context, network string) (string, error) {
+	client := d.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.URL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("HTTP request failed with status: %s", resp.Status)
+	}
+
+	buf := bodyBuffers.Get().(*bytes.Buffer)
+	defer bodyBuffers.Put(buf)
+	buf.Reset()
+	if _, err := buf.ReadFrom(io.LimitReader(resp.Body, maxBodySize)); err != nil {
+		return "", err
+	}
+
+	return ParseResponse(buf.Bytes())
+}
+
+// 读取的响应内容的上限，返回 IP 的 API 响应通常只有几十个字节
+const maxBodySize = 64 * 1024
+
+// 读取响应时复用的缓冲区，每次检测不再分配新的缓冲区
+var bodyBuffers = sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+
+// 解析 API 的返回内容，支持 {"ip": "..."} 格式的 JSON 和纯文本
+func ParseResponse(body []byte) (string, error) {
+	text := strings.TrimSpace(string(body))
+	if !strings.HasPrefix(text, "{") {
+		return text, nil
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", err
+	}
+
+	ip, ok := result["ip"].(string)
+	if !ok {
+		return "", errors.New("IP address not found in JSON response")
+	}
+	return ip, nil
+}
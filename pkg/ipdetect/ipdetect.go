@@ -0,0 +1,21 @@
+// Package ipdetect 提供获取公网 IP 的各种方式以及地址校验，可以在其它 Go 程序中单独使用。
+//
+//	ip, err := ipdetect.STUN{Server: "stun:stun.cloudflare.com:3478"}.Detect(ctx, "tcp4")
+//	if err == nil {
+//		err = ipdetect.CheckPublic(ip, false)
+//	}
+package ipdetect
+
+import "context"
+
+// 一种获取公网 IP 的方式，network 为 tcp4 或 tcp6，返回的地址属于该协议族
+type Detector interface {
+	Detect(ctx context.Context, network string) (string, error)
+}
+
+// 把普通函数作为 Detector 使用
+type DetectorFunc func(ctx context.Context, network string) (string, error)
+
+func (f DetectorFunc) Detect(ctx context.Context, network string) (string, error) {
+	return f(ctx, network)
+}
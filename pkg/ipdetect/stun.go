@@ -0,0 +1,165 @@
+package ipdetect
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// 默认的 STUN 服务器
+var DefaultSTUNServers = []string{
+	"stun:stun.l.google.com:19302",
+	"stun:stun.cloudflare.com:3478",
+	"stun:stun.miwifi.com:3478",
+}
+
+// STUN 协议常量（RFC 5389）
+const (
+	stunBindingRequest   = 0x0001
+	stunBindingSuccess   = 0x0101
+	stunMagicCookie      = 0x2112A442
+	stunHeaderSize       = 20
+	stunMappedAddress    = 0x0001
+	stunXorMappedAddress = 0x0020
+)
+
+// STUN 请求的超时和重试次数，UDP 可能丢包所以需要重发
+const (
+	stunTimeout  = 2 * time.Second
+	stunAttempts = 3
+)
+
+// 通过 STUN Binding 请求获取公网地址，Server 例如 stun:stun.l.google.com:19302，未指定端口时使用 3478
+type STUN struct {
+	Server string
+}
+
+// network 为 tcp4 或 tcp6，对应使用 udp4 或 udp6
+func (d STUN) Detect(ctx context.Context, network string) (string, error) {
+	address := strings.TrimPrefix(d.Server, "stun:")
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, "3478")
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, strings.Replace(network, "tcp", "udp", 1), address)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	request := make([]byte, stunHeaderSize)
+	binary.BigEndian.PutUint16(request[0:2], stunBindingRequest)
+	binary.BigEndian.PutUint32(request[4:8], stunMagicCookie)
+	if _, err := rand.Read(request[8:20]); err != nil {
+		return "", err
+	}
+
+	response := make([]byte, 1500)
+	for attempt := 0; attempt < stunAttempts; attempt++ {
+		if _, err := conn.Write(request); err != nil {
+			return "", err
+		}
+
+		deadline := time.Now().Add(stunTimeout)
+		if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+			deadline = ctxDeadline
+		}
+		conn.SetReadDeadline(deadline)
+		n, err := conn.Read(response)
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() && ctx.Err() == nil {
+				continue
+			}
+			return "", err
+		}
+
+		// 忽略与本次请求无关的响应
+		if n < stunHeaderSize || !bytes.Equal(response[8:20], request[8:20]) {
+			continue
+		}
+		return parseSTUNResponse(response[:n])
+	}
+	return "", fmt.Errorf("no response after %d attempts", stunAttempts)
+}
+
+// 从 Binding 响应中解析映射地址，优先使用 XOR-MAPPED-ADDRESS
+func parseSTUNResponse(msg []byte) (string, error) {
+	if binary.BigEndian.Uint16(msg[0:2]) != stunBindingSuccess {
+		return "", fmt.Errorf("unexpected STUN message type 0x%04x", binary.BigEndian.Uint16(msg[0:2]))
+	}
+
+	length := int(binary.BigEndian.Uint16(msg[2:4]))
+	if stunHeaderSize+length > len(msg) {
+		return "", errors.New("truncated STUN message")
+	}
+	attrs := msg[stunHeaderSize : stunHeaderSize+length]
+
+	var mapped net.IP
+	for len(attrs) >= 4 {
+		attrType := binary.BigEndian.Uint16(attrs[0:2])
+		attrLen := int(binary.BigEndian.Uint16(attrs[2:4]))
+		if 4+attrLen > len(attrs) {
+			return "", errors.New("truncated STUN attribute")
+		}
+		value := attrs[4 : 4+attrLen]
+
+		switch attrType {
+		case stunXorMappedAddress:
+			ip, err := parseSTUNAddress(value, msg[4:20])
+			if err != nil {
+				return "", err
+			}
+			return ip.String(), nil
+		case stunMappedAddress:
+			ip, err := parseSTUNAddress(value, nil)
+			if err != nil {
+				return "", err
+			}
+			mapped = ip
+		}
+
+		// 属性按 4 字节对齐
+		attrs = attrs[4+(attrLen+3)&^3:]
+	}
+
+	if mapped == nil {
+		return "", errors.New("mapped address not found in STUN response")
+	}
+	return mapped.String(), nil
+}
+
+// 解析地址属性，xorKey 不为空时按 XOR-MAPPED-ADDRESS 的规则还原地址
+func parseSTUNAddress(value, xorKey []byte) (net.IP, error) {
+	if len(value) < 4 {
+		return nil, errors.New("invalid STUN address attribute")
+	}
+
+	var size int
+	switch value[1] {
+	case 0x01:
+		size = net.IPv4len
+	case 0x02:
+		size = net.IPv6len
+	default:
+		return nil, fmt.Errorf("unknown STUN address family 0x%02x", value[1])
+	}
+	if len(value) < 4+size {
+		return nil, errors.New("invalid STUN address attribute")
+	}
+
+	ip := make(net.IP, size)
+	copy(ip, value[4:4+size])
+	if xorKey != nil {
+		for i := range ip {
+			ip[i] ^= xorKey[i]
+		}
+	}
+	return ip, nil
+}
@@ -0,0 +1,222 @@
+package ipdetect
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 按 RFC 5389 编码一条属性，值按 4 字节对齐
+func stunAttr(attrType uint16, value []byte) []byte {
+	attr := make([]byte, 4, 4+len(value)+3)
+	binary.BigEndian.PutUint16(attr[0:2], attrType)
+	binary.BigEndian.PutUint16(attr[2:4], uint16(len(value)))
+	attr = append(attr, value...)
+	for len(attr)%4 != 0 {
+		attr = append(attr, 0)
+	}
+	return attr
+}
+
+// 编码 MAPPED-ADDRESS 的值，xorKey 为消息头的第 4 到 20 字节时编码为 XOR-MAPPED-ADDRESS
+func stunAddressValue(ip net.IP, port uint16, xorKey []byte) []byte {
+	family, raw := byte(0x02), ip.To16()
+	if ip4 := ip.To4(); ip4 != nil {
+		family, raw = 0x01, ip4
+	}
+	value := []byte{0, family, 0, 0}
+	binary.BigEndian.PutUint16(value[2:4], port)
+	address := append([]byte(nil), raw...)
+	if xorKey != nil {
+		value[2] ^= xorKey[0]
+		value[3] ^= xorKey[1]
+		for i := range address {
+			address[i] ^= xorKey[i]
+		}
+	}
+	return append(value, address...)
+}
+
+// 编码一条 STUN 消息
+func stunMessage(msgType uint16, transactionID []byte, attrs ...[]byte) []byte {
+	body := bytes.Join(attrs, nil)
+	msg := make([]byte, stunHeaderSize, stunHeaderSize+len(body))
+	binary.BigEndian.PutUint16(msg[0:2], msgType)
+	binary.BigEndian.PutUint16(msg[2:4], uint16(len(body)))
+	binary.BigEndian.PutUint32(msg[4:8], stunMagicCookie)
+	copy(msg[8:20], transactionID)
+	return append(msg, body...)
+}
+
+// XOR-MAPPED-ADDRESS 使用的密钥：magic cookie 加上事务 ID
+func stunXorKey(transactionID []byte) []byte {
+	key := binary.BigEndian.AppendUint32(nil, stunMagicCookie)
+	return append(key, transactionID...)
+}
+
+// 在本机启动 UDP 的 STUN 服务器，对每个请求依次发送 respond 返回的响应
+func newSTUNServer(t *testing.T, respond func(request []byte) [][]byte) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			request := append([]byte(nil), buf[:n]...)
+			for _, response := range respond(request) {
+				conn.WriteTo(response, addr)
+			}
+		}
+	}()
+	return "stun:" + conn.LocalAddr().String()
+}
+
+func TestSTUNDetect(t *testing.T) {
+	otherID := bytes.Repeat([]byte{0xab}, 12)
+	tests := []struct {
+		name    string
+		respond func(id []byte) [][]byte
+		want    string // 为空时应返回错误
+		wantErr string // 错误中应包含的内容，为空时不检查
+	}{
+		{
+			name: "XOR-MAPPED-ADDRESS",
+			respond: func(id []byte) [][]byte {
+				return [][]byte{stunMessage(stunBindingSuccess, id, stunAttr(stunXorMappedAddress, stunAddressValue(net.ParseIP("203.0.113.7"), 54321, stunXorKey(id))))}
+			},
+			want: "203.0.113.7",
+		},
+		{
+			name: "IPv6 XOR-MAPPED-ADDRESS",
+			respond: func(id []byte) [][]byte {
+				return [][]byte{stunMessage(stunBindingSuccess, id, stunAttr(stunXorMappedAddress, stunAddressValue(net.ParseIP("2001:db8::7"), 54321, stunXorKey(id))))}
+			},
+			want: "2001:db8::7",
+		},
+		{
+			name: "MAPPED-ADDRESS",
+			respond: func(id []byte) [][]byte {
+				return [][]byte{stunMessage(stunBindingSuccess, id, stunAttr(stunMappedAddress, stunAddressValue(net.ParseIP("198.51.100.2"), 3478, nil)))}
+			},
+			want: "198.51.100.2",
+		},
+		{
+			name: "XOR-MAPPED-ADDRESS preferred",
+			respond: func(id []byte) [][]byte {
+				return [][]byte{stunMessage(stunBindingSuccess, id,
+					stunAttr(stunMappedAddress, stunAddressValue(net.ParseIP("10.0.0.2"), 3478, nil)),
+					stunAttr(0x8022, []byte("fake server")), // SOFTWARE，长度需要对齐
+					stunAttr(stunXorMappedAddress, stunAddressValue(net.ParseIP("203.0.113.8"), 3478, stunXorKey(id))),
+				)}
+			},
+			want: "203.0.113.8",
+		},
+		{
+			name: "wrong transaction ID ignored",
+			respond: func(id []byte) [][]byte {
+				return [][]byte{
+					stunMessage(stunBindingSuccess, otherID, stunAttr(stunMappedAddress, stunAddressValue(net.ParseIP("192.0.2.66"), 3478, nil))),
+					stunMessage(stunBindingSuccess, id, stunAttr(stunMappedAddress, stunAddressValue(net.ParseIP("203.0.113.9"), 3478, nil))),
+				}
+			},
+			want: "203.0.113.9",
+		},
+		{
+			name: "only wrong transaction ID",
+			respond: func([]byte) [][]byte {
+				return [][]byte{stunMessage(stunBindingSuccess, otherID, stunAttr(stunMappedAddress, stunAddressValue(net.ParseIP("192.0.2.66"), 3478, nil)))}
+			},
+		},
+		{
+			name: "error response",
+			respond: func(id []byte) [][]byte {
+				return [][]byte{stunMessage(0x0111, id)}
+			},
+			wantErr: "unexpected STUN message type 0x0111",
+		},
+		{
+			name: "no address",
+			respond: func(id []byte) [][]byte {
+				return [][]byte{stunMessage(stunBindingSuccess, id, stunAttr(0x8022, []byte("fake")))}
+			},
+			wantErr: "mapped address not found",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			requests := make(chan []byte, stunAttempts)
+			server := newSTUNServer(t, func(request []byte) [][]byte {
+				requests <- request
+				if len(request) != stunHeaderSize {
+					return nil
+				}
+				return test.respond(request[8:20])
+			})
+			ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+			defer cancel()
+
+			got, err := STUN{Server: server}.Detect(ctx, "tcp4")
+			if test.want == "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("Detect() = %q, %v, want error %q", got, err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Detect() error = %v", err)
+			}
+			if got != test.want {
+				t.Errorf("Detect() = %q, want %q", got, test.want)
+			}
+			request := <-requests
+			if binary.BigEndian.Uint16(request[0:2]) != stunBindingRequest || binary.BigEndian.Uint32(request[4:8]) != stunMagicCookie {
+				t.Errorf("unexpected Binding request % x", request)
+			}
+		})
+	}
+}
+
+func TestParseSTUNResponseTruncated(t *testing.T) {
+	id := bytes.Repeat([]byte{0x01}, 12)
+	valid := stunMessage(stunBindingSuccess, id, stunAttr(stunXorMappedAddress, stunAddressValue(net.ParseIP("203.0.113.7"), 3478, stunXorKey(id))))
+	tests := []struct {
+		name    string
+		msg     []byte
+		wantErr string
+	}{
+		{name: "message shorter than its length", msg: valid[:len(valid)-4], wantErr: "truncated STUN message"},
+		{
+			name:    "attribute longer than the message",
+			msg:     stunMessage(stunBindingSuccess, id, []byte{0x00, 0x20, 0x00, 0x40, 0, 1, 0, 0}),
+			wantErr: "truncated STUN attribute",
+		},
+		{
+			name:    "short address",
+			msg:     stunMessage(stunBindingSuccess, id, stunAttr(stunXorMappedAddress, []byte{0, 1, 0, 0, 1, 2})),
+			wantErr: "invalid STUN address attribute",
+		},
+		{
+			name:    "unknown family",
+			msg:     stunMessage(stunBindingSuccess, id, stunAttr(stunMappedAddress, []byte{0, 3, 0, 0, 1, 2, 3, 4})),
+			wantErr: "unknown STUN address family 0x03",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got, err := parseSTUNResponse(test.msg); err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("parseSTUNResponse() = %q, %v, want error %q", got, err, test.wantErr)
+			}
+		})
+	}
+}
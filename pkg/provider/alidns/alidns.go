@@ -0,0 +1,301 @@
+// Package alidns 通过阿里云云解析 DNS 的 API 读写解析记录，实现了 provider.Provider。
+//
+//	client, err := sdk.NewClientWithAccessKey("cn-hangzhou", accessKeyID, accessKeySecret)
+//	if err == nil {
+//		records, err = alidns.New(client).ListRecords(ctx, "example.com")
+//	}
+package alidns
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	sdkerrors "github.com/aliyun/alibaba-cloud-sdk-go/sdk/errors"
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/requests"
+	sdk "github.com/aliyun/alibaba-cloud-sdk-go/services/alidns"
+
+	"DDns_go/pkg/provider"
+)
+
+// 阿里云的默认解析线路
+const DefaultLine = "default"
+
+// 用到的云解析 API，*sdk.Client 实现了该接口，也可以替换为其它实现（例如在测试中模拟分页和错误）
+type API interface {
+	DescribeDomainRecords(request *sdk.DescribeDomainRecordsRequest) (*sdk.DescribeDomainRecordsResponse, error)
+	DescribeDomainRecordInfo(request *sdk.DescribeDomainRecordInfoRequest) (*sdk.DescribeDomainRecordInfoResponse, error)
+	AddDomainRecord(request *sdk.AddDomainRecordRequest) (*sdk.AddDomainRecordResponse, error)
+	UpdateDomainRecord(request *sdk.UpdateDomainRecordRequest) (*sdk.UpdateDomainRecordResponse, error)
+	SetDomainRecordStatus(request *sdk.SetDomainRecordStatusRequest) (*sdk.SetDomainRecordStatusResponse, error)
+	DeleteDomainRecord(request *sdk.DeleteDomainRecordRequest) (*sdk.DeleteDomainRecordResponse, error)
+	UpdateDomainRecordRemark(request *sdk.UpdateDomainRecordRemarkRequest) (*sdk.UpdateDomainRecordRemarkResponse, error)
+	DescribeDomainInfo(request *sdk.DescribeDomainInfoRequest) (*sdk.DescribeDomainInfoResponse, error)
+	DescribeDomains(request *sdk.DescribeDomainsRequest) (*sdk.DescribeDomainsResponse, error)
+	GetReadTimeout() time.Duration
+}
+
+// 阿里云云解析 DNS
+type Provider struct {
+	client API
+}
+
+var _ provider.Provider = (*Provider)(nil)
+
+// client 通常为配置好凭证、地域和超时的 *sdk.Client
+func New(client API) *Provider {
+	return &Provider{client: client}
+}
+
+// 阿里云 API 返回错误时的请求 ID，网络错误等没有请求 ID 时为空
+func RequestID(err error) string {
+	if serverErr, ok := err.(*sdkerrors.ServerError); ok {
+		return serverErr.RequestId()
+	}
+	return ""
+}
+
+func (p *Provider) ListRecords(ctx context.Context, domainName string) ([]provider.Record, error) {
+	records, err := DescribeDomainRecords(ctx, p.client, domainName)
+	if err != nil {
+		return nil, err
+	}
+	converted := make([]provider.Record, len(records))
+	for i, record := range records {
+		converted[i] = provider.Record{ID: record.RecordId, RR: record.RR, Type: record.Type, Value: record.Value, TTL: int(record.TTL), Line: record.Line, Disabled: record.Status == "DISABLE", Remark: record.Remark}
+	}
+	return converted, nil
+}
+
+// 按 ID 查询单条记录，记录不属于 domainName 时返回错误
+func (p *Provider) GetRecord(ctx context.Context, domainName, recordID string) (provider.Record, error) {
+	infoRequest := sdk.CreateDescribeDomainRecordInfoRequest()
+	infoRequest.Scheme = "https"
+	infoRequest.RecordId = recordID
+
+	if err := withDeadline(ctx, p.client, infoRequest); err != nil {
+		return provider.Record{}, err
+	}
+	record, err := p.client.DescribeDomainRecordInfo(infoRequest)
+	if err != nil {
+		provider.NoteRequestID(ctx, RequestID(err))
+		return provider.Record{}, err
+	}
+	provider.NoteRequestID(ctx, record.RequestId)
+	if record.DomainName != domainName {
+		return provider.Record{}, fmt.Errorf("record %s belongs to %s", recordID, record.DomainName)
+	}
+	return provider.Record{ID: record.RecordId, RR: record.RR, Type: record.Type, Value: record.Value, TTL: int(record.TTL), Line: record.Line, Disabled: record.Status == "DISABLE", Remark: record.Remark}, nil
+}
+
+func (p *Provider) CreateRecord(ctx context.Context, domainName string, record provider.Record) (string, error) {
+	addRequest := sdk.CreateAddDomainRecordRequest()
+	addRequest.Scheme = "https"
+	addRequest.DomainName = domainName
+	addRequest.Type = record.Type
+	addRequest.RR = record.RR
+	addRequest.Value = record.Value
+	addRequest.Line = record.Line
+	if record.TTL > 0 {
+		addRequest.TTL = requests.NewInteger(record.TTL)
+	}
+
+	if err := withDeadline(ctx, p.client, addRequest); err != nil {
+		return "", err
+	}
+	response, err := p.client.AddDomainRecord(addRequest)
+	if err != nil {
+		provider.NoteRequestID(ctx, RequestID(err))
+		return "", err
+	}
+	provider.NoteRequestID(ctx, response.RequestId)
+	return response.RecordId, nil
+}
+
+func (p *Provider) UpdateRecord(ctx context.Context, _ string, record provider.Record) error {
+	updateRequest := sdk.CreateUpdateDomainRecordRequest()
+	updateRequest.Scheme = "https"
+	updateRequest.RecordId = record.ID
+	updateRequest.RR = record.RR
+	updateRequest.Type = record.Type
+	updateRequest.Value = record.Value
+	// 不传线路时会被改为默认线路
+	updateRequest.Line = record.Line
+	if record.TTL > 0 {
+		updateRequest.TTL = requests.NewInteger(record.TTL)
+	}
+
+	if err := withDeadline(ctx, p.client, updateRequest); err != nil {
+		return err
+	}
+	response, err := p.client.UpdateDomainRecord(updateRequest)
+	// 强制更新时记录的值没有变化，阿里云不接受与现有记录完全相同的修改，视为成功
+	if serverErr, ok := err.(*sdkerrors.ServerError); ok && serverErr.ErrorCode() == "DomainRecordDuplicate" {
+		provider.NoteRequestID(ctx, serverErr.RequestId())
+		return nil
+	}
+	if err != nil {
+		provider.NoteRequestID(ctx, RequestID(err))
+		return err
+	}
+	provider.NoteRequestID(ctx, response.RequestId)
+	return nil
+}
+
+// 启用或暂停记录
+func (p *Provider) SetRecordEnabled(ctx context.Context, _ string, record provider.Record, enabled bool) error {
+	statusRequest := sdk.CreateSetDomainRecordStatusRequest()
+	statusRequest.Scheme = "https"
+	statusRequest.RecordId = record.ID
+	statusRequest.Status = "Disable"
+	if enabled {
+		statusRequest.Status = "Enable"
+	}
+
+	if err := withDeadline(ctx, p.client, statusRequest); err != nil {
+		return err
+	}
+	response, err := p.client.SetDomainRecordStatus(statusRequest)
+	if err != nil {
+		provider.NoteRequestID(ctx, RequestID(err))
+		return err
+	}
+	provider.NoteRequestID(ctx, response.RequestId)
+	return nil
+}
+
+func (p *Provider) DeleteRecord(ctx context.Context, _ string, record provider.Record) error {
+	deleteRequest := sdk.CreateDeleteDomainRecordRequest()
+	deleteRequest.Scheme = "https"
+	deleteRequest.RecordId = record.ID
+
+	if err := withDeadline(ctx, p.client, deleteRequest); err != nil {
+		return err
+	}
+	response, err := p.client.DeleteDomainRecord(deleteRequest)
+	if err != nil {
+		provider.NoteRequestID(ctx, RequestID(err))
+		return err
+	}
+	provider.NoteRequestID(ctx, response.RequestId)
+	return nil
+}
+
+// 修改记录的备注
+func (p *Provider) SetRemark(ctx context.Context, _ string, record provider.Record, remark string) error {
+	remarkRequest := sdk.CreateUpdateDomainRecordRemarkRequest()
+	remarkRequest.Scheme = "https"
+	remarkRequest.RecordId = record.ID
+	remarkRequest.Remark = remark
+
+	if err := withDeadline(ctx, p.client, remarkRequest); err != nil {
+		return err
+	}
+	response, err := p.client.UpdateDomainRecordRemark(remarkRequest)
+	if err != nil {
+		provider.NoteRequestID(ctx, RequestID(err))
+		return err
+	}
+	provider.NoteRequestID(ctx, response.RequestId)
+	return nil
+}
+
+// 返回账号下全部域名的名称
+func (p *Provider) ListZones(ctx context.Context) ([]string, error) {
+	domains, err := DescribeDomains(ctx, p.client)
+	if err != nil {
+		return nil, err
+	}
+	zones := make([]string, len(domains))
+	for i, domain := range domains {
+		zones[i] = domain.DomainName
+	}
+	return zones, nil
+}
+
+// 查询域名所在版本允许的最小 TTL（免费版为 600 秒）
+func (p *Provider) MinTTL(ctx context.Context, domainName string) (int, error) {
+	infoRequest := sdk.CreateDescribeDomainInfoRequest()
+	infoRequest.Scheme = "https"
+	infoRequest.DomainName = domainName
+
+	if err := withDeadline(ctx, p.client, infoRequest); err != nil {
+		return 0, err
+	}
+	info, err := p.client.DescribeDomainInfo(infoRequest)
+	if err != nil {
+		return 0, err
+	}
+	return int(info.MinTtl), nil
+}
+
+// 每页获取的解析记录数，阿里云允许的最大值为 500
+const PageSize = 500
+
+// 获取域名的所有解析记录，记录较多时逐页获取
+func DescribeDomainRecords(ctx context.Context, client API, domainName string) ([]sdk.Record, error) {
+	var all []sdk.Record
+	for page := 1; ; page++ {
+		describeRequest := sdk.CreateDescribeDomainRecordsRequest()
+		describeRequest.Scheme = "https"
+		describeRequest.DomainName = domainName
+		describeRequest.PageNumber = requests.NewInteger(page)
+		describeRequest.PageSize = requests.NewInteger(PageSize)
+
+		if err := withDeadline(ctx, client, describeRequest); err != nil {
+			return nil, err
+		}
+		records, err := client.DescribeDomainRecords(describeRequest)
+		if err != nil {
+			provider.NoteRequestID(ctx, RequestID(err))
+			return nil, err
+		}
+		provider.NoteRequestID(ctx, records.RequestId)
+
+		all = append(all, records.DomainRecords.Record...)
+		if len(records.DomainRecords.Record) == 0 || int64(len(all)) >= records.TotalCount {
+			return all, nil
+		}
+	}
+}
+
+// 每页获取的域名数，阿里云允许的最大值为 100
+const DomainsPageSize = 100
+
+// 获取账号下的全部域名
+func DescribeDomains(ctx context.Context, client API) ([]sdk.DomainInDescribeDomains, error) {
+	var all []sdk.DomainInDescribeDomains
+	for page := 1; ; page++ {
+		describeRequest := sdk.CreateDescribeDomainsRequest()
+		describeRequest.Scheme = "https"
+		describeRequest.PageNumber = requests.NewInteger(page)
+		describeRequest.PageSize = requests.NewInteger(DomainsPageSize)
+
+		if err := withDeadline(ctx, client, describeRequest); err != nil {
+			return nil, err
+		}
+		response, err := client.DescribeDomains(describeRequest)
+		if err != nil {
+			return nil, err
+		}
+
+		all = append(all, response.Domains.Domain...)
+		if len(response.Domains.Domain) == 0 || int64(len(all)) >= response.TotalCount {
+			return all, nil
+		}
+	}
+}
+
+// 阿里云 SDK 不支持 context，只能在发送前检查：ctx 已结束时不再发送请求，剩余时间少于客户端的读取超时时缩短本次请求的读取超时。
+// 请求发出后取消 ctx 不会中断该请求，连接超时也不受 ctx 影响，请求仍要等到响应或（缩短后的）读取超时才返回
+func withDeadline(ctx context.Context, client API, request requests.AcsRequest) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		if remaining := time.Until(deadline); remaining < client.GetReadTimeout() {
+			request.SetReadTimeout(remaining)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+// Package provider 定义 DNS 服务商共用的解析记录结构和接口，各服务商的实现在子目录中，例如 provider/alidns
+package provider
+
+import (
+	"context"
+	"sync"
+)
+
+// 一条解析记录，各服务商返回的记录都转换为该结构
+type Record struct {
+	ID    string
+	RR    string // 主机记录，根域名为 @
+	Type  string
+	Value string
+	TTL   int // 为 0 时使用服务商的默认 TTL
+
+	Proxied  *bool  // 仅 Cloudflare：是否开启代理，为空表示不关心
+	Line     string // 阿里云和 DNSPod：解析线路，为空表示不关心
+	Disabled bool   // 记录已被暂停
+	Remark   string // 阿里云和 DNSPod：记录的备注
+}
+
+// DNS 服务商至少需要支持的操作
+type Provider interface {
+	// 返回域名下的全部解析记录
+	ListRecords(ctx context.Context, domainName string) ([]Record, error)
+	// 添加一条解析记录，返回新记录的 ID
+	CreateRecord(ctx context.Context, domainName string, record Record) (string, error)
+	// 按 record.ID 修改解析记录的值和 TTL
+	UpdateRecord(ctx context.Context, domainName string, record Record) error
+}
+
+// 收集一次更新中服务商返回的请求 ID，方便向服务商排查问题
+type RequestIDs struct {
+	mu  sync.Mutex
+	ids []string
+}
+
+type requestIDsKey struct{}
+
+// 返回携带请求 ID 收集器的 ctx，服务商通过 NoteRequestID 写入
+func WithRequestIDs(ctx context.Context) (context.Context, *RequestIDs) {
+	ids := &RequestIDs{}
+	return context.WithValue(ctx, requestIDsKey{}, ids), ids
+}
+
+// 记录一次 API 调用的请求 ID，ctx 中没有收集器或 id 为空时忽略
+func NoteRequestID(ctx context.Context, id string) {
+	ids, ok := ctx.Value(requestIDsKey{}).(*RequestIDs)
+	if !ok || id == "" {
+		return
+	}
+	ids.mu.Lock()
+	defer ids.mu.Unlock()
+	ids.ids = append(ids.ids, id)
+}
+
+// 最后一次调用的请求 ID，通常是修改或新建记录的请求
+func (r *RequestIDs) Last() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.ids) == 0 {
+		return ""
+	}
+	return r.ids[len(r.ids)-1]
+}
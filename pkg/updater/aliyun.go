@@ -0,0 +1,73 @@
+package updater
+
+import (
+	"context"
+	"log/slog"
+
+	sdkerrors "github.com/aliyun/alibaba-cloud-sdk-go/sdk/errors"
+
+	"DDns_go/pkg/provider/alidns"
+)
+
+// 阿里云的默认解析线路
+const aliyunDefaultLine = alidns.DefaultLine
+
+// 用到的云解析 API，见 alidns.API
+type alidnsAPI = alidns.API
+
+// 阿里云云解析 DNS，读写记录由 pkg/provider/alidns 实现，这里只适配 dnsProvider 等接口
+type aliyunProvider struct {
+	dns *alidns.Provider
+}
+
+// 阿里云 API 返回错误时的请求 ID，网络错误等没有请求 ID 时为空
+func aliyunRequestID(err error) string {
+	if serverErr, ok := err.(*sdkerrors.ServerError); ok {
+		return serverErr.RequestId()
+	}
+	return ""
+}
+
+func newAliyunProvider(config Config, logger *slog.Logger) (*aliyunProvider, error) {
+	client, err := newConfiguredClient(config, logger)
+	if err != nil {
+		return nil, err
+	}
+	return &aliyunProvider{dns: alidns.New(client)}, nil
+}
+
+func (p *aliyunProvider) listRecords(ctx context.Context, domainName string) ([]dnsRecord, error) {
+	return p.dns.ListRecords(ctx, domainName)
+}
+
+func (p *aliyunProvider) getRecord(ctx context.Context, domainName, recordID string) (dnsRecord, error) {
+	return p.dns.GetRecord(ctx, domainName, recordID)
+}
+
+func (p *aliyunProvider) createRecord(ctx context.Context, domainName string, record dnsRecord) (string, error) {
+	return p.dns.CreateRecord(ctx, domainName, record)
+}
+
+func (p *aliyunProvider) updateRecord(ctx context.Context, domainName string, record dnsRecord) error {
+	return p.dns.UpdateRecord(ctx, domainName, record)
+}
+
+func (p *aliyunProvider) setRecordEnabled(ctx context.Context, domainName string, record dnsRecord, enabled bool) error {
+	return p.dns.SetRecordEnabled(ctx, domainName, record, enabled)
+}
+
+func (p *aliyunProvider) deleteRecord(ctx context.Context, domainName string, record dnsRecord) error {
+	return p.dns.DeleteRecord(ctx, domainName, record)
+}
+
+func (p *aliyunProvider) setRemark(ctx context.Context, domainName string, record dnsRecord, remark string) error {
+	return p.dns.SetRemark(ctx, domainName, record, remark)
+}
+
+func (p *aliyunProvider) listZones(ctx context.Context) ([]string, error) {
+	return p.dns.ListZones(ctx)
+}
+
+func (p *aliyunProvider) minTTL(ctx context.Context, domainName string) (int, error) {
+	return p.dns.MinTTL(ctx, domainName)
+}
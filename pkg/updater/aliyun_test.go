@@ -0,0 +1,205 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	sdk "github.com/aliyun/alibaba-cloud-sdk-go/services/alidns"
+
+	"DDns_go/pkg/provider/alidns"
+)
+
+// 在内存中模拟云解析 API，记录每个接口的调用
+type fakeAlidns struct {
+	mu      sync.Mutex
+	records []sdk.Record
+	nextID  int
+
+	describes []int // 每次 DescribeDomainRecords 请求的页码
+	adds      []*sdk.AddDomainRecordRequest
+	updates   []*sdk.UpdateDomainRecordRequest
+}
+
+var errFakeAlidns = errors.New("not supported by fakeAlidns")
+
+func newFakeAlidns(records ...sdk.Record) *fakeAlidns {
+	return &fakeAlidns{records: records, nextID: 1000}
+}
+
+func (f *fakeAlidns) DescribeDomainRecords(request *sdk.DescribeDomainRecordsRequest) (*sdk.DescribeDomainRecordsResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	page, err := strconv.Atoi(string(request.PageNumber))
+	if err != nil {
+		return nil, err
+	}
+	size, err := strconv.Atoi(string(request.PageSize))
+	if err != nil {
+		return nil, err
+	}
+	f.describes = append(f.describes, page)
+
+	var matched []sdk.Record
+	for _, record := range f.records {
+		if record.DomainName == request.DomainName {
+			matched = append(matched, record)
+		}
+	}
+	start, end := min((page-1)*size, len(matched)), min(page*size, len(matched))
+	response := &sdk.DescribeDomainRecordsResponse{
+		TotalCount: int64(len(matched)),
+		PageNumber: int64(page),
+		PageSize:   int64(size),
+		RequestId:  fmt.Sprintf("describe-%d", page),
+	}
+	response.DomainRecords.Record = append([]sdk.Record(nil), matched[start:end]...)
+	return response, nil
+}
+
+func (f *fakeAlidns) DescribeDomainRecordInfo(request *sdk.DescribeDomainRecordInfoRequest) (*sdk.DescribeDomainRecordInfoResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, record := range f.records {
+		if record.RecordId == request.RecordId {
+			return &sdk.DescribeDomainRecordInfoResponse{RecordId: record.RecordId, DomainName: record.DomainName, RR: record.RR, Type: record.Type, Value: record.Value, TTL: record.TTL, Line: record.Line, Status: record.Status}, nil
+		}
+	}
+	return nil, fmt.Errorf("record %s not found", request.RecordId)
+}
+
+func (f *fakeAlidns) AddDomainRecord(request *sdk.AddDomainRecordRequest) (*sdk.AddDomainRecordResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.adds = append(f.adds, request)
+	f.nextID++
+	record := sdk.Record{RecordId: strconv.Itoa(f.nextID), DomainName: request.DomainName, RR: request.RR, Type: request.Type, Value: request.Value, Line: request.Line, Status: "ENABLE"}
+	if request.TTL != "" {
+		ttl, _ := strconv.ParseInt(string(request.TTL), 10, 64)
+		record.TTL = ttl
+	}
+	f.records = append(f.records, record)
+	return &sdk.AddDomainRecordResponse{RecordId: record.RecordId, RequestId: "add-" + record.RecordId}, nil
+}
+
+func (f *fakeAlidns) UpdateDomainRecord(request *sdk.UpdateDomainRecordRequest) (*sdk.UpdateDomainRecordResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.updates = append(f.updates, request)
+	for i, record := range f.records {
+		if record.RecordId == request.RecordId {
+			f.records[i].RR, f.records[i].Type, f.records[i].Value = request.RR, request.Type, request.Value
+			return &sdk.UpdateDomainRecordResponse{RecordId: record.RecordId, RequestId: "update-" + record.RecordId}, nil
+		}
+	}
+	return nil, fmt.Errorf("record %s not found", request.RecordId)
+}
+
+func (f *fakeAlidns) SetDomainRecordStatus(*sdk.SetDomainRecordStatusRequest) (*sdk.SetDomainRecordStatusResponse, error) {
+	return nil, errFakeAlidns
+}
+
+func (f *fakeAlidns) DeleteDomainRecord(*sdk.DeleteDomainRecordRequest) (*sdk.DeleteDomainRecordResponse, error) {
+	return nil, errFakeAlidns
+}
+
+func (f *fakeAlidns) UpdateDomainRecordRemark(*sdk.UpdateDomainRecordRemarkRequest) (*sdk.UpdateDomainRecordRemarkResponse, error) {
+	return nil, errFakeAlidns
+}
+
+func (f *fakeAlidns) DescribeDomainInfo(*sdk.DescribeDomainInfoRequest) (*sdk.DescribeDomainInfoResponse, error) {
+	return &sdk.DescribeDomainInfoResponse{MinTtl: 600}, nil
+}
+
+func (f *fakeAlidns) DescribeDomains(*sdk.DescribeDomainsRequest) (*sdk.DescribeDomainsResponse, error) {
+	return nil, errFakeAlidns
+}
+
+func (f *fakeAlidns) GetReadTimeout() time.Duration {
+	return 10 * time.Second
+}
+
+func TestDescribeDomainRecordsPaging(t *testing.T) {
+	tests := []struct {
+		name      string
+		records   int
+		wantPages []int
+	}{
+		{name: "no records", records: 0, wantPages: []int{1}},
+		{name: "single page", records: 3, wantPages: []int{1}},
+		{name: "exactly one page", records: alidns.PageSize, wantPages: []int{1}},
+		{name: "several pages", records: 2*alidns.PageSize + 1, wantPages: []int{1, 2, 3}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeAlidns()
+			for i := 0; i < test.records; i++ {
+				fake.records = append(fake.records, sdk.Record{RecordId: strconv.Itoa(i), DomainName: "example.com", RR: "host" + strconv.Itoa(i), Type: "A", Value: "1.2.3.4"})
+			}
+			// 其它域名的记录不能出现在结果中
+			fake.records = append(fake.records, sdk.Record{RecordId: "other", DomainName: "example.net", RR: "www", Type: "A", Value: "1.2.3.4"})
+
+			ctx, ids := withRequestIDs(context.Background())
+			records, err := alidns.DescribeDomainRecords(ctx, fake, "example.com")
+			if err != nil {
+				t.Fatalf("DescribeDomainRecords() error = %v", err)
+			}
+			if len(records) != test.records {
+				t.Errorf("got %d records, want %d", len(records), test.records)
+			}
+			seen := make(map[string]bool)
+			for _, record := range records {
+				if record.DomainName != "example.com" || seen[record.RecordId] {
+					t.Fatalf("unexpected record %+v", record)
+				}
+				seen[record.RecordId] = true
+			}
+			if fmt.Sprint(fake.describes) != fmt.Sprint(test.wantPages) {
+				t.Errorf("requested pages %v, want %v", fake.describes, test.wantPages)
+			}
+			if want := fmt.Sprintf("describe-%d", test.wantPages[len(test.wantPages)-1]); ids.Last() != want {
+				t.Errorf("last request ID = %q, want %q", ids.Last(), want)
+			}
+		})
+	}
+}
+
+func TestAliyunProviderListRecords(t *testing.T) {
+	fake := newFakeAlidns(
+		sdk.Record{RecordId: "1", DomainName: "example.com", RR: "www", Type: "A", Value: "1.2.3.4", TTL: 600, Line: "default", Status: "ENABLE"},
+		sdk.Record{RecordId: "2", DomainName: "example.com", RR: "nas", Type: "AAAA", Value: "2400:3200::1", TTL: 600, Line: "default", Status: "DISABLE", Remark: "managed by DDns_go"},
+	)
+	records, err := (&aliyunProvider{dns: alidns.New(fake)}).listRecords(context.Background(), "example.com")
+	if err != nil {
+		t.Fatalf("listRecords() error = %v", err)
+	}
+	want := []dnsRecord{
+		{ID: "1", RR: "www", Type: "A", Value: "1.2.3.4", TTL: 600, Line: "default"},
+		{ID: "2", RR: "nas", Type: "AAAA", Value: "2400:3200::1", TTL: 600, Line: "default", Disabled: true, Remark: "managed by DDns_go"},
+	}
+	if fmt.Sprintf("%+v", records) != fmt.Sprintf("%+v", want) {
+		t.Errorf("listRecords() = %+v, want %+v", records, want)
+	}
+}
+
+func TestAliyunProviderCreateRecord(t *testing.T) {
+	fake := newFakeAlidns()
+	id, err := (&aliyunProvider{dns: alidns.New(fake)}).createRecord(context.Background(), "example.com", dnsRecord{RR: "www", Type: "A", Value: "1.2.3.4", TTL: 600, Line: "default"})
+	if err != nil {
+		t.Fatalf("createRecord() error = %v", err)
+	}
+	if len(fake.adds) != 1 {
+		t.Fatalf("AddDomainRecord called %d times, want 1", len(fake.adds))
+	}
+	add := fake.adds[0]
+	if add.DomainName != "example.com" || add.RR != "www" || add.Type != "A" || add.Value != "1.2.3.4" || add.TTL != "600" || add.Line != "default" {
+		t.Errorf("unexpected AddDomainRecord request %+v", add)
+	}
+	if id != fake.records[0].RecordId {
+		t.Errorf("createRecord() = %q, want %q", id, fake.records[0].RecordId)
+	}
+}
@@ -0,0 +1,145 @@
+package updater
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 注册 REST API：GET /status、POST /update、GET /history 和 GET /logs，需要使用 apiToken 认证。
+// POST /update 通过 trigger 通知主循环立即检测，带 force=true 时强制重新提交全部记录，带 confirm=true 时恢复因 IP 频繁变化暂停的更新
+func registerAPI(mux *http.ServeMux, config Config, trigger chan updateTrigger) {
+	token := config.APIToken
+	redactor := newRedactor(config.secrets())
+	mux.Handle("/status", requireToken(token, http.MethodGet, func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, status.report(redactor))
+	}))
+	mux.Handle("/history", requireToken(token, http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseHistoryQuery(r.URL.Query())
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		events, err := status.recentHistory(redactor, filter)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": redactor.redact(err.Error())})
+			return
+		}
+		writeJSON(w, http.StatusOK, events)
+	}))
+	mux.Handle("/logs", requireToken(token, http.MethodGet, func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, recentLogs.recent())
+	}))
+	mux.Handle("/update", requireToken(token, http.MethodPost, updateHandler(trigger)))
+}
+
+// 通过 REST API 或控制接口触发的一次检测
+type updateTrigger struct {
+	force   bool // 本轮强制重新提交全部记录
+	confirm bool // 确认频繁变化的公网 IP，恢复暂停的更新
+}
+
+// POST /update 的处理函数，REST API 和控制接口共用。force 或 confirm 参数不是布尔值时返回 400
+func updateHandler(trigger chan updateTrigger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var request updateTrigger
+		for name, flag := range map[string]*bool{"force": &request.force, "confirm": &request.confirm} {
+			value := r.URL.Query().Get(name)
+			if value == "" {
+				continue
+			}
+			var err error
+			if *flag, err = strconv.ParseBool(value); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid %s %q", name, value)})
+				return
+			}
+		}
+		requestUpdate(trigger, request)
+		if request.force {
+			writeJSON(w, http.StatusAccepted, map[string]string{"status": "forced update triggered"})
+			return
+		}
+		writeJSON(w, http.StatusAccepted, map[string]string{"status": "update triggered"})
+	}
+}
+
+// 通知主循环立即检测。已有等待执行的检测时不重复触发，而是与它合并，
+// 因此强制检测和确认不会因为已有等待中的普通检测而丢失
+func requestUpdate(trigger chan updateTrigger, request updateTrigger) {
+	for {
+		select {
+		case trigger <- request:
+			return
+		case pending := <-trigger:
+			request.force = request.force || pending.force
+			request.confirm = request.confirm || pending.confirm
+		}
+	}
+}
+
+// 解析 GET /history 的查询参数：since（例如 720h）、domain、type=ip 和 limit
+func parseHistoryQuery(query url.Values) (historyFilter, error) {
+	var filter historyFilter
+	if since := query.Get("since"); since != "" {
+		duration, err := time.ParseDuration(since)
+		if err != nil || duration <= 0 {
+			return filter, fmt.Errorf("invalid since %q", since)
+		}
+		filter.since = time.Now().Add(-duration)
+	}
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 0 {
+			return filter, fmt.Errorf("invalid limit %q", limit)
+		}
+		filter.limit = n
+	}
+	switch query.Get("type") {
+	case "", "all":
+	case "ip":
+		filter.ipOnly = true
+	default:
+		return filter, fmt.Errorf("invalid type %q, expected ip or all", query.Get("type"))
+	}
+	filter.domain = query.Get("domain")
+	return filter, nil
+}
+
+// 校验请求方法和令牌，令牌可以放在 Authorization: Bearer 请求头或 token 参数中
+func requireToken(token, method string, next http.HandlerFunc) http.Handler {
+	return requireTokenMethods(token, []string{method}, next)
+}
+
+// 与 requireToken 相同，但允许多个请求方法
+func requireTokenMethods(token string, methods []string, next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !slices.Contains(methods, r.Method) {
+			w.Header().Set("Allow", strings.Join(methods, ", "))
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+
+		given := r.URL.Query().Get("token")
+		if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+			given = strings.TrimPrefix(auth, "Bearer ")
+		}
+		if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid or missing token"})
+			return
+		}
+		next(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, code int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(value)
+}
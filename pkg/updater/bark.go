@@ -0,0 +1,74 @@
+package updater
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Bark（iOS 推送）
+type BarkConfig struct {
+	Server    string `json:"server,omitempty"` // Bark 服务地址，默认 https://api.day.app，也可以使用自建服务
+	DeviceKey string `json:"deviceKey"`        // App 中显示的设备 key
+	Sound     string `json:"sound,omitempty"`  // 提示音，例如 alarm
+	Group     string `json:"group,omitempty"`  // 消息分组，默认 DDns
+	Icon      string `json:"icon,omitempty"`   // 图标地址
+	ChatConfig
+}
+
+// 默认的 Bark 服务地址
+const defaultBarkServer = "https://api.day.app"
+
+type barkNotifier struct {
+	config  BarkConfig
+	message *chatMessage
+}
+
+func (n *barkNotifier) send(ctx context.Context, client *http.Client, event notifyEvent) error {
+	title, text, err := n.message.render(event)
+	if err != nil {
+		return err
+	}
+	server := strings.TrimSuffix(n.config.Server, "/")
+	if server == "" {
+		server = defaultBarkServer
+	}
+	group := n.config.Group
+	if group == "" {
+		group = "DDns"
+	}
+	payload := map[string]string{
+		"device_key": n.config.DeviceKey,
+		"title":      title,
+		"body":       text,
+		"group":      group,
+	}
+	if n.config.Sound != "" {
+		payload["sound"] = n.config.Sound
+	}
+	if n.config.Icon != "" {
+		payload["icon"] = n.config.Icon
+	}
+	// 检测失败时使用时效性通知，专注模式下也会显示
+	if event.Event == eventUpdateFailed {
+		payload["level"] = "timeSensitive"
+	}
+
+	data, err := postNotifyJSON(ctx, client, server+"/push", payload)
+	if err != nil {
+		return err
+	}
+	var result struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return fmt.Errorf("invalid response %q: %v", data, err)
+	}
+	if result.Code != http.StatusOK {
+		return &apiError{message: fmt.Sprintf("code %d: %s", result.Code, result.Message)}
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// 通过 SO_BINDTODEVICE 把连接绑定到网卡，不论路由表如何都从该网卡发出，源地址为网卡的地址。
+// 需要 root 或 CAP_NET_RAW 权限
+func interfaceDialContext(dialer *net.Dialer, name, network string) func(ctx context.Context, _, addr string) (net.Conn, error) {
+	bound := *dialer
+	bound.Control = func(_, _ string, c syscall.RawConn) error {
+		var err error
+		if controlErr := c.Control(func(fd uintptr) {
+			err = syscall.SetsockoptString(int(fd), syscall.SOL_SOCKET, syscall.SO_BINDTODEVICE, name)
+		}); controlErr != nil {
+			return controlErr
+		}
+		if err != nil {
+			return fmt.Errorf("failed to bind to interface %s: %v", name, err)
+		}
+		return nil
+	}
+	return func(ctx context.Context, _, addr string) (net.Conn, error) {
+		return bound.DialContext(ctx, network, addr)
+	}
+}
@@ -0,0 +1,41 @@
+//go:build !linux
+
+package updater
+
+import (
+	"context"
+	"fmt"
+	"net"
+)
+
+// 其它系统不能把连接绑定到网卡，每次连接时改用网卡当前的地址作为源地址，PPPoE 重新拨号后使用新的地址。
+// 请求从哪个网卡发出取决于系统是否按源地址选择路由
+func interfaceDialContext(dialer *net.Dialer, name, network string) func(ctx context.Context, _, addr string) (net.Conn, error) {
+	return func(ctx context.Context, _, addr string) (net.Conn, error) {
+		ip, err := interfaceAddress(name, network)
+		if err != nil {
+			return nil, err
+		}
+		bound := *dialer
+		bound.LocalAddr = &net.TCPAddr{IP: ip}
+		return bound.DialContext(ctx, network, addr)
+	}
+}
+
+// 返回网卡上与 network 协议族相同的第一个全局单播地址，私有地址也可以使用，用于从该网卡发出请求
+func interfaceAddress(name, network string) (net.IP, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, err
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.IsGlobalUnicast() && (network == "tcp4") == (ipNet.IP.To4() != nil) {
+			return ipNet.IP, nil
+		}
+	}
+	return nil, fmt.Errorf("no global address on interface %s", name)
+}
@@ -0,0 +1,106 @@
+package updater
+
+import (
+	"fmt"
+	"time"
+)
+
+// 公网 IP 变化过于频繁时的告警设置
+type ChurnConfig struct {
+	MaxChanges int    `json:"maxChanges"`       // window 内允许的最多变化次数，超过时发送 ip_churn 通知
+	Window     string `json:"window,omitempty"` // 统计变化次数的时间窗口，默认 1h
+	Action     string `json:"action,omitempty"` // warn（默认，只告警）或 pause（同时暂停更新该协议族的记录，直到手动确认）
+}
+
+// churn.action 的取值
+const (
+	churnWarn  = "warn"
+	churnPause = "pause"
+)
+
+// 默认的统计窗口
+const defaultChurnWindow = time.Hour
+
+func (c *ChurnConfig) window() (time.Duration, error) {
+	if c.Window == "" {
+		return defaultChurnWindow, nil
+	}
+	window, err := time.ParseDuration(c.Window)
+	if err != nil || window <= 0 {
+		return 0, fmt.Errorf("invalid churn.window %q", c.Window)
+	}
+	return window, nil
+}
+
+// 一个协议族最近的公网 IP 变化
+type ipChurn struct {
+	changes []time.Time // 窗口内每次变化的时间
+	alerted bool        // 本次频繁变化是否已经告警，变化次数回到 maxChanges 以内后重新告警
+	paused  bool        // action 为 pause 时是否正在暂停更新，手动确认或重启后恢复
+}
+
+// 记录一次公网 IP 变化，窗口内的变化超过 churn.maxChanges 时记录警告并发送 ip_churn 通知，
+// action 为 pause 时暂停更新该协议族的记录。可能的原因包括检测的来源返回了随机的地址，或者线路不稳定
+func (u *updater) noteIPChange(family, ip, oldIP string) {
+	config := u.config.Churn
+	if config == nil || config.MaxChanges <= 0 {
+		return
+	}
+	window, err := config.window()
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	churn := u.churn[family]
+	if churn == nil {
+		churn = &ipChurn{}
+		u.churn[family] = churn
+	}
+	churn.changes = append(churn.changes, now)
+	for len(churn.changes) > 0 && now.Sub(churn.changes[0]) > window {
+		churn.changes = churn.changes[1:]
+	}
+	metrics.set("ddns_ip_changes_in_window", float64(len(churn.changes)), "family", family)
+	if len(churn.changes) <= config.MaxChanges {
+		churn.alerted = false
+		return
+	}
+	if churn.alerted {
+		return
+	}
+	churn.alerted = true
+	churn.paused = config.Action == churnPause
+	if churn.paused {
+		metrics.set("ddns_ip_churn_paused", 1, "family", family)
+	}
+	u.logger.Warn("Public IP changes too frequently", "family", family, "ip", ip, "old_ip", oldIP, "changes", len(churn.changes), "window", window.String(), "paused", churn.paused)
+	u.notify.emit(notifyEvent{Event: eventIPChurn, Family: family, PublicIP: ip, OldIP: oldIP, Changes: len(churn.changes), Window: window.String(), Paused: churn.paused})
+}
+
+// 该协议族的记录是否因为频繁变化而暂停更新。重新加载配置后不再是 pause 时直接恢复
+func (u *updater) churnPaused(family string) bool {
+	churn := u.churn[family]
+	if churn == nil || !churn.paused {
+		return false
+	}
+	if u.config.Churn == nil || u.config.Churn.Action != churnPause {
+		churn.paused = false
+		u.logger.Info("IP churn action is no longer pause, resuming updates", "family", family)
+		metrics.set("ddns_ip_churn_paused", 0, "family", family)
+		return false
+	}
+	return true
+}
+
+// 手动确认后恢复全部暂停的协议族，并重新开始统计变化次数
+func (u *updater) confirmChurn() {
+	for family, churn := range u.churn {
+		if churn.paused {
+			u.logger.Info("IP churn confirmed, resuming updates", "family", family)
+			metrics.set("ddns_ip_churn_paused", 0, "family", family)
+		}
+		delete(u.churn, family)
+		metrics.set("ddns_ip_changes_in_window", 0, "family", family)
+	}
+}
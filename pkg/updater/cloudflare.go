@@ -0,0 +1,256 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Cloudflare 配置，使用具有 Zone.DNS 编辑权限的 API 令牌
+type CloudflareConfig struct {
+	APIToken string `json:"apiToken"`
+	Proxied  *bool  `json:"proxied,omitempty"` // 新建记录是否开启代理（橙色云朵），默认关闭；已有记录未设置时保持不变
+}
+
+// Cloudflare API 地址
+const cloudflareAPIURL = "https://api.cloudflare.com/client/v4"
+
+// 每页获取的解析记录数
+const cloudflarePageSize = 100
+
+// 每页获取的 Zone 数，Cloudflare 允许的最大值为 50
+const cloudflareZonePageSize = 50
+
+// Cloudflare 的 TTL 为 1 时表示自动
+const cloudflareAutoTTL = 1
+
+type cloudflareProvider struct {
+	token   string
+	client  *http.Client
+	baseURL string
+
+	mu    sync.Mutex
+	zones map[string]string // 域名对应的 Zone ID
+}
+
+func newCloudflareProvider(config Config, logger *slog.Logger) (*cloudflareProvider, error) {
+	settings, err := config.httpSettings()
+	if err != nil {
+		return nil, err
+	}
+	// 与阿里云 API 使用相同的代理
+	client := &http.Client{
+		Timeout:   settings.timeout,
+		Transport: withDebugTransport(settings.newTransport("", settings.aliyunProxy), logger),
+	}
+	return &cloudflareProvider{
+		token:   config.Cloudflare.APIToken,
+		client:  client,
+		baseURL: cloudflareAPIURL,
+		zones:   make(map[string]string),
+	}, nil
+}
+
+// Cloudflare 返回的解析记录
+type cloudflareRecord struct {
+	ID      string `json:"id,omitempty"`
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Content string `json:"content"`
+	TTL     int    `json:"ttl"`
+	Proxied *bool  `json:"proxied,omitempty"`
+}
+
+// Cloudflare API 响应的公共部分
+type cloudflareResponse struct {
+	Success bool `json:"success"`
+	Errors  []struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"errors"`
+	ResultInfo struct {
+		Page       int `json:"page"`
+		TotalPages int `json:"total_pages"`
+	} `json:"result_info"`
+	Result json.RawMessage `json:"result"`
+}
+
+// 调用 Cloudflare API，解析 result 到 result 中
+func (p *cloudflareProvider) call(ctx context.Context, method, path string, query url.Values, body, result interface{}) (*cloudflareResponse, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	endpoint := p.baseURL + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	noteRequestID(ctx, resp.Header.Get("Cf-Ray"))
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var response cloudflareResponse
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, fmt.Errorf("invalid Cloudflare response (%s): %v", resp.Status, err)
+	}
+	if !response.Success || resp.StatusCode >= 300 {
+		var messages []string
+		for _, e := range response.Errors {
+			messages = append(messages, fmt.Sprintf("%d: %s", e.Code, e.Message))
+		}
+		return nil, &apiError{
+			message:   fmt.Sprintf("Cloudflare API %s %s failed with %s: %s", method, path, resp.Status, strings.Join(messages, "; ")),
+			retryable: resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests,
+			throttled: resp.StatusCode == http.StatusTooManyRequests,
+			auth:      resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden,
+		}
+	}
+	if result != nil {
+		if err := json.Unmarshal(response.Result, result); err != nil {
+			return nil, fmt.Errorf("invalid Cloudflare response: %v", err)
+		}
+	}
+	return &response, nil
+}
+
+// 查询域名的 Zone ID，结果会缓存
+func (p *cloudflareProvider) zoneID(ctx context.Context, domainName string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if id, ok := p.zones[domainName]; ok {
+		return id, nil
+	}
+
+	var zones []struct {
+		ID string `json:"id"`
+	}
+	if _, err := p.call(ctx, http.MethodGet, "/zones", url.Values{"name": {domainName}}, nil, &zones); err != nil {
+		return "", err
+	}
+	if len(zones) == 0 {
+		return "", &apiError{message: fmt.Sprintf("zone %s not found in the Cloudflare account", domainName)}
+	}
+	p.zones[domainName] = zones[0].ID
+	return zones[0].ID, nil
+}
+
+func (p *cloudflareProvider) listZones(ctx context.Context) ([]string, error) {
+	var all []string
+	for page := 1; ; page++ {
+		query := url.Values{
+			"page":     {fmt.Sprint(page)},
+			"per_page": {fmt.Sprint(cloudflareZonePageSize)},
+		}
+		var zones []struct {
+			Name string `json:"name"`
+		}
+		response, err := p.call(ctx, http.MethodGet, "/zones", query, nil, &zones)
+		if err != nil {
+			return nil, err
+		}
+		for _, zone := range zones {
+			all = append(all, zone.Name)
+		}
+		if len(zones) == 0 || page >= response.ResultInfo.TotalPages {
+			return all, nil
+		}
+	}
+}
+
+func (p *cloudflareProvider) listRecords(ctx context.Context, domainName string) ([]dnsRecord, error) {
+	zoneID, err := p.zoneID(ctx, domainName)
+	if err != nil {
+		return nil, err
+	}
+
+	var all []dnsRecord
+	for page := 1; ; page++ {
+		query := url.Values{
+			"page":     {fmt.Sprint(page)},
+			"per_page": {fmt.Sprint(cloudflarePageSize)},
+		}
+		var records []cloudflareRecord
+		response, err := p.call(ctx, http.MethodGet, "/zones/"+zoneID+"/dns_records", query, nil, &records)
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range records {
+			all = append(all, dnsRecord{
+				ID:      record.ID,
+				RR:      relativeRR(record.Name, domainName),
+				Type:    record.Type,
+				Value:   record.Content,
+				TTL:     record.TTL,
+				Proxied: record.Proxied,
+			})
+		}
+		if len(records) == 0 || page >= response.ResultInfo.TotalPages {
+			return all, nil
+		}
+	}
+}
+
+func (p *cloudflareProvider) createRecord(ctx context.Context, domainName string, record dnsRecord) (string, error) {
+	zoneID, err := p.zoneID(ctx, domainName)
+	if err != nil {
+		return "", err
+	}
+	body := cloudflareRecord{
+		Type:    record.Type,
+		Name:    fullRecordName(record.RR, domainName),
+		Content: record.Value,
+		TTL:     record.TTL,
+		Proxied: record.Proxied,
+	}
+	if body.TTL == 0 {
+		body.TTL = cloudflareAutoTTL
+	}
+
+	var created cloudflareRecord
+	if _, err := p.call(ctx, http.MethodPost, "/zones/"+zoneID+"/dns_records", nil, body, &created); err != nil {
+		return "", err
+	}
+	return created.ID, nil
+}
+
+func (p *cloudflareProvider) updateRecord(ctx context.Context, domainName string, record dnsRecord) error {
+	zoneID, err := p.zoneID(ctx, domainName)
+	if err != nil {
+		return err
+	}
+	// PATCH 只修改提交的字段，未设置 proxied 时保持原来的代理状态
+	body := map[string]interface{}{"content": record.Value}
+	if record.TTL > 0 {
+		body["ttl"] = record.TTL
+	}
+	if record.Proxied != nil {
+		body["proxied"] = *record.Proxied
+	}
+	_, err = p.call(ctx, http.MethodPatch, "/zones/"+zoneID+"/dns_records/"+record.ID, nil, body, nil)
+	return err
+}
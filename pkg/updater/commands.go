@@ -0,0 +1,738 @@
+package updater
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"golang.org/x/term"
+
+	"DDns_go/pkg/provider/alidns"
+)
+
+const usage = `Usage: DDns_go [command] [flags]
+
+Commands:
+  run              Keep checking the public IP and update DNS records (default)
+  once             Run a single check and update, then exit
+  config init      Generate a configuration file from flags or prompts
+  config validate  Check the configuration and the Aliyun credentials
+  config env       List the environment variables that override configuration fields
+  config encrypt   Encrypt the secrets in a configuration file in place
+  status           Show the public IP and record status of the running instance
+  update           Ask the running instance to check the public IP and sync records now
+  history          Show public IP changes and record updates saved in historyFile
+  plugins          List the plugins in pluginDir and the methods they support
+  keyring          Store, show or delete the AccessKey in the system keyring
+  selfupdate       Download the latest release from GitHub and replace this executable
+  version          Print the version and build information
+
+Run "DDns_go <command> -h" for the flags of a command.
+`
+
+// 命令行程序的入口，按 os.Args 中的子命令执行，由 cmd/DDns_go 调用
+func Main() {
+	// 未指定子命令时等同于 run，兼容旧的 DDns_go -config config.json 用法
+	args := os.Args[1:]
+	command := "run"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	switch command {
+	case "run":
+		// 由服务控制管理器启动时以 Windows 服务的方式运行
+		if isWindowsService() {
+			runService(args)
+			return
+		}
+		runDDNS(context.Background(), "run", args, false)
+	case "once":
+		runDDNS(context.Background(), "once", args, true)
+	case "config":
+		configCommand(args)
+	case "status":
+		statusCommand(args)
+	case "update":
+		updateCommand(args)
+	case "history":
+		historyCommand(args)
+	case "plugins":
+		pluginsCommand(args)
+	case "keyring":
+		keyringCommand(args)
+	case "selfupdate":
+		selfUpdateCommand(args)
+	case "version":
+		fmt.Println(versionString())
+	case "help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n%s", command, usage)
+		os.Exit(exitUsage)
+	}
+}
+
+// config 子命令
+func configCommand(args []string) {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(exitUsage)
+	}
+
+	switch args[0] {
+	case "init":
+		configInit(args[1:])
+	case "validate":
+		configValidate(args[1:])
+	case "env":
+		configEnvCommand(args[1:])
+	case "encrypt":
+		configEncrypt(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown config command %q\n\n%s", args[0], usage)
+		os.Exit(exitUsage)
+	}
+}
+
+// update 子命令：通过 controlSocket 让运行中的实例立即检测，-force 时值相同的记录也重新提交，-confirm 时恢复因 IP 频繁变化暂停的更新
+func updateCommand(args []string) {
+	flags := flag.NewFlagSet("update", flag.ExitOnError)
+	configFilePath := flags.String("config", "config.json", "Path to the configuration file")
+	configFormat := flags.String("format", "", "Configuration file format: json, yaml or toml (default: detected from the file extension)")
+	force := flags.Bool("force", false, "Overwrite every record with the detected IP, even if it already matches")
+	confirm := flags.Bool("confirm", false, "Confirm the frequently changing public IP and resume updates paused by churn")
+	flags.Parse(args)
+
+	config, err := loadConfig(*configFilePath, *configFormat)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to load configuration:", err)
+		os.Exit(exitConfig)
+	}
+	if config.ControlSocket == "" {
+		fmt.Fprintf(os.Stderr, "%s: controlSocket is not set\n", *configFilePath)
+		os.Exit(1)
+	}
+	if err := triggerControlUpdate(config.ControlSocket, *force, *confirm); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to reach the running instance at %s, is it running? %v\n", config.ControlSocket, err)
+		os.Exit(1)
+	}
+	if *force {
+		fmt.Println("Forced update triggered, see the log or run \"DDns_go status\" for the result.")
+		return
+	}
+	fmt.Println("Update triggered, see the log or run \"DDns_go status\" for the result.")
+}
+
+// 通过 controlSocket 查询运行中的实例，输出公网 IP、每条记录最近一次同步的结果和最后一次错误
+func statusCommand(args []string) {
+	flags := flag.NewFlagSet("status", flag.ExitOnError)
+	configFilePath := flags.String("config", "config.json", "Path to the configuration file")
+	configFormat := flags.String("format", "", "Configuration file format: json, yaml or toml (default: detected from the file extension)")
+	jsonOutput := flags.Bool("json", false, "Print the status as JSON")
+	flags.Parse(args)
+
+	config, err := loadConfig(*configFilePath, *configFormat)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to load configuration:", err)
+		os.Exit(exitConfig)
+	}
+	if config.ControlSocket == "" {
+		fmt.Fprintf(os.Stderr, "%s: controlSocket is not set\n", *configFilePath)
+		os.Exit(1)
+	}
+	report, err := queryControlStatus(config.ControlSocket)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to query the running instance at %s, is it running? %v\n", config.ControlSocket, err)
+		os.Exit(1)
+	}
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "    ")
+		encoder.Encode(report)
+		return
+	}
+
+	now := time.Now()
+	line := fmt.Sprintf("Status: %s (pid %d, up %s", report.Health.Status, report.PID, now.Sub(report.StartedAt).Round(time.Second))
+	if report.Health.LastCycle != nil {
+		line += ", last check " + ago(now, report.Health.LastCycle)
+	}
+	if report.NextCheck != nil {
+		line += ", next check in " + report.NextCheck.Sub(now).Round(time.Second).String()
+	}
+	fmt.Println(line + ")")
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "\nFAMILY\tPUBLIC IP\tPROVIDER\tCHECKED\tERROR")
+	for _, family := range []string{"IPv4", "IPv6"} {
+		ip, ok := report.PublicIPs[family]
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", family, dash(ip.IP), dash(ip.Provider), ago(now, &ip.CheckedAt), ip.Error)
+	}
+	fmt.Fprintln(w, "\nRECORD\tVALUE\tRESULT\tCHECKED\tCHANGED\tERROR")
+	for _, record := range report.Records {
+		name := record.Type + " " + record.RR + "." + record.Domain
+		if record.Line != "" {
+			name += " (" + record.Line + ")"
+		}
+		errText := record.Error
+		if errText != "" && record.RequestID != "" {
+			errText += " (request_id=" + record.RequestID + ")"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", name, dash(record.Value), record.Result, ago(now, record.CheckedAt), ago(now, record.ChangedAt), errText)
+	}
+	w.Flush()
+
+	if len(report.Health.FailingRecords) > 0 {
+		fmt.Println("\nFailing records:", strings.Join(report.Health.FailingRecords, ", "))
+	}
+	if report.LastError != "" {
+		fmt.Println("\nLast error:", report.LastError)
+	}
+}
+
+// 距离 t 的时间，例如 "3m12s ago"，t 为空时为 "-"
+func ago(now time.Time, t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return "-"
+	}
+	return now.Sub(*t).Round(time.Second).String() + " ago"
+}
+
+// 空值显示为 "-"
+func dash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// 查询 historyFile 中的历史，最后统计每个协议族公网 IP 变化的次数和平均间隔
+func historyCommand(args []string) {
+	flags := flag.NewFlagSet("history", flag.ExitOnError)
+	configFilePath := flags.String("config", "config.json", "Path to the configuration file")
+	configFormat := flags.String("format", "", "Configuration file format: json, yaml or toml (default: detected from the file extension)")
+	since := flags.String("since", "", "Only show events newer than this, such as 24h or 720h (default: all)")
+	domain := flags.String("domain", "", "Only show events of this domain or record name, such as www.example.com")
+	ipOnly := flags.Bool("ip", false, "Only show public IP changes")
+	limit := flags.Int("limit", 0, "Show at most this many events, newest first (default: all)")
+	jsonOutput := flags.Bool("json", false, "Print the events as JSON")
+	flags.Parse(args)
+
+	config, err := loadConfig(*configFilePath, *configFormat)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to load configuration:", err)
+		os.Exit(exitConfig)
+	}
+	if config.HistoryFile == "" {
+		fmt.Fprintf(os.Stderr, "%s: historyFile is not set, no history is saved\n", *configFilePath)
+		os.Exit(1)
+	}
+	filter := historyFilter{domain: *domain, ipOnly: *ipOnly, limit: *limit}
+	if *since != "" {
+		duration, err := time.ParseDuration(*since)
+		if err != nil || duration <= 0 {
+			fmt.Fprintf(os.Stderr, "Invalid -since %q\n", *since)
+			os.Exit(exitUsage)
+		}
+		filter.since = time.Now().Add(-duration)
+	}
+
+	events, err := readHistory(config.HistoryFile, filter)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read history file '%s': %v\n", config.HistoryFile, err)
+		os.Exit(1)
+	}
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "    ")
+		encoder.Encode(events)
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, event := range events {
+		name := event.Family
+		if event.Result != resultIPChanged {
+			name = event.Type + " " + event.RR + "." + event.Domain
+		}
+		change := event.NewIP
+		if event.OldIP != "" && event.OldIP != event.NewIP {
+			change = event.OldIP + " -> " + event.NewIP
+		}
+		var details []string
+		if event.RequestID != "" {
+			details = append(details, "request_id="+event.RequestID)
+		}
+		if event.Geo != "" {
+			details = append(details, event.Geo)
+		}
+		if event.Error != "" {
+			details = append(details, event.Error)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", event.Time.Local().Format(time.RFC3339), event.Result, name, change, strings.Join(details, " "))
+	}
+	w.Flush()
+	if len(events) == 0 {
+		fmt.Println("No events found")
+		return
+	}
+
+	// 事件按时间倒序，第一个为最新的
+	changes := make(map[string][]time.Time)
+	var families []string
+	for _, event := range events {
+		if event.Result != resultIPChanged || event.OldIP == "" {
+			continue
+		}
+		if changes[event.Family] == nil {
+			families = append(families, event.Family)
+		}
+		changes[event.Family] = append(changes[event.Family], event.Time)
+	}
+	for _, family := range families {
+		times := changes[family]
+		line := fmt.Sprintf("%s changed %d time(s)", family, len(times))
+		if len(times) > 1 {
+			average := times[0].Sub(times[len(times)-1]) / time.Duration(len(times)-1)
+			line += ", on average every " + average.Round(time.Minute).String()
+		}
+		fmt.Println(line)
+	}
+}
+
+// 列出 pluginDir 中的插件，调用每个插件的 describe 显示说明和支持的方法
+func pluginsCommand(args []string) {
+	flags := flag.NewFlagSet("plugins", flag.ExitOnError)
+	configFilePath := flags.String("config", "config.json", "Path to the configuration file")
+	configFormat := flags.String("format", "", "Configuration file format: json, yaml or toml (default: detected from the file extension)")
+	dir := flags.String("dir", "", "Plugin directory, overrides pluginDir in the configuration file")
+	jsonOutput := flags.Bool("json", false, "Print the plugins as JSON")
+	flags.Parse(args)
+
+	if *dir == "" {
+		config, err := loadConfig(*configFilePath, *configFormat)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to load configuration:", err)
+			os.Exit(exitConfig)
+		}
+		if config.PluginDir == "" {
+			fmt.Fprintf(os.Stderr, "%s: pluginDir is not set, use -dir to list a directory\n", *configFilePath)
+			os.Exit(exitConfig)
+		}
+		*dir = config.PluginDir
+	}
+
+	plugins, err := discoverPlugins(context.Background(), *dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read plugin directory '%s': %v\n", *dir, err)
+		os.Exit(1)
+	}
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "    ")
+		encoder.Encode(plugins)
+		return
+	}
+	if len(plugins) == 0 {
+		fmt.Printf("No plugins found in %s\n", *dir)
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tMETHODS\tDESCRIPTION")
+	for _, plugin := range plugins {
+		description := plugin.Description
+		if plugin.Error != "" {
+			description = "error: " + plugin.Error
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", plugin.Name, dash(strings.Join(plugin.Methods, ",")), description)
+	}
+	w.Flush()
+}
+
+// keyring 子命令：set 保存 AccessKey，show 显示保存的 AccessKey ID，delete 删除
+func keyringCommand(args []string) {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(exitUsage)
+	}
+	flags := flag.NewFlagSet("keyring "+args[0], flag.ExitOnError)
+	service := flags.String("service", defaultKeyringService, "Service name of the keyring entries, the same as credentials.service")
+	var accessKey *string
+	if args[0] == "set" {
+		accessKey = flags.String("access-key", "", "Aliyun AccessKey ID (default: prompt or "+accessKeyEnvs[0]+")")
+	}
+	flags.Parse(args[1:])
+
+	switch args[0] {
+	case "set":
+		// secret 只能输入或通过环境变量传入，不出现在命令行参数和 shell 历史中
+		prompt := newPrompter()
+		if *accessKey == "" {
+			*accessKey = firstEnv(accessKeyEnvs)
+		}
+		id := prompt.value("AccessKey ID", *accessKey, "")
+		secret := prompt.secret("AccessKey secret", firstEnv(accessSecretEnvs))
+		if id == "" || secret == "" {
+			fmt.Fprintln(os.Stderr, "AccessKey ID and secret are required")
+			os.Exit(exitUsage)
+		}
+		if err := writeKeyring(*service, id, secret); err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to write the system keyring:", err)
+			os.Exit(1)
+		}
+		fmt.Printf("AccessKey %s stored in the system keyring for service %q. Set \"credentials\": {\"type\": \"keyring\"} and remove accessKey/accessSecret from the configuration file.\n", maskAccessKey(id), *service)
+	case "show":
+		id, _, err := readKeyring(*service)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Printf("AccessKey %s is stored for service %q\n", maskAccessKey(id), *service)
+	case "delete":
+		if err := deleteKeyring(*service); err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to delete from the system keyring:", err)
+			os.Exit(1)
+		}
+		fmt.Printf("AccessKey for service %q deleted from the system keyring\n", *service)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown keyring command %q\n\n%s", args[0], usage)
+		os.Exit(exitUsage)
+	}
+}
+
+// 只显示 AccessKey ID 的前后几位，例如 LTAI****wxyz
+func maskAccessKey(id string) string {
+	if len(id) <= 8 {
+		return strings.Repeat("*", len(id))
+	}
+	return id[:4] + "****" + id[len(id)-4:]
+}
+
+// 根据命令行参数生成配置文件，缺少的必填项在终端中交互输入
+func configInit(args []string) {
+	flags := flag.NewFlagSet("config init", flag.ExitOnError)
+	configFilePath := flags.String("config", "config.json", "Path of the configuration file to write")
+	configFormat := flags.String("format", "", "Configuration file format: json, yaml or toml (default: detected from the file extension)")
+	accessKey := flags.String("access-key", "", "Aliyun AccessKey ID")
+	accessSecret := flags.String("access-secret", "", "Aliyun AccessKey secret")
+	domainName := flags.String("domain", "", "Domain name, for example example.com")
+	rr := flags.String("rr", "", "Host record, for example www, @ or * (default *)")
+	ipMode := flags.String("ip-mode", "", "ipv4, ipv6 or dual (default ipv4)")
+	interval := flags.String("interval", "", "Polling interval such as 30s or 5m")
+	force := flags.Bool("force", false, "Overwrite the configuration file if it already exists")
+	flags.Parse(args)
+
+	if _, err := os.Stat(*configFilePath); err == nil && !*force {
+		fmt.Fprintf(os.Stderr, "Configuration file '%s' already exists, use -force to overwrite it\n", *configFilePath)
+		os.Exit(1)
+	}
+
+	// 只有在终端中运行时才提示输入，脚本中缺少参数时直接报错
+	prompt := newPrompter()
+	config := defaultConfig
+	config.AccessKey = prompt.value("AccessKey ID", *accessKey, "")
+	config.AccessSecret = prompt.secret("AccessKey secret", *accessSecret)
+	config.DomainName = prompt.value("Domain name", *domainName, "")
+	config.RR = prompt.value("Host record", *rr, defaultConfig.RR)
+	config.IPMode = prompt.value("IP mode (ipv4, ipv6 or dual)", *ipMode, defaultConfig.IPMode)
+	config.Interval = *interval
+
+	if err := config.validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitConfig)
+	}
+	if err := saveConfig(*configFilePath, *configFormat, config); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to write configuration:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Configuration file '%s' created.\n", *configFilePath)
+}
+
+// 列出配置字段对应的环境变量，以及当前设置了哪些。不输出值，避免泄露密钥
+func configEnvCommand(args []string) {
+	flags := flag.NewFlagSet("config env", flag.ExitOnError)
+	flags.Parse(args)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VARIABLE\tFIELD\tSET")
+	for _, env := range configEnvs {
+		set := "-"
+		if os.Getenv(env.name) != "" {
+			set = "yes"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", env.name, env.field, set)
+	}
+	w.Flush()
+}
+
+// 加密配置文件中的明文密钥，没有密钥时生成新的密钥文件。原文件保存为 .bak，确认无误后需要手动删除
+func configEncrypt(args []string) {
+	flags := flag.NewFlagSet("config encrypt", flag.ExitOnError)
+	configFilePath := flags.String("config", "config.json", "Path to the configuration file")
+	configFormat := flags.String("format", "", "Configuration file format: json, yaml or toml (default: detected from the file extension)")
+	keyFile := flags.String("key-file", "", "Key file to use or create (default: "+configKeyFileEnv+" or the configuration file name with a .key extension)")
+	flags.Parse(args)
+
+	// 只读取文件本身，环境变量中的值不能写回文件
+	config, err := readConfigFile(*configFilePath, *configFormat)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to load configuration:", err)
+		os.Exit(exitConfig)
+	}
+
+	var key []byte
+	path := *keyFile
+	if path == "" {
+		path = configKeyFile(*configFilePath)
+		key, err = loadConfigKey(*configFilePath)
+	} else {
+		key, err = readConfigKeyFile(path)
+	}
+	if os.IsNotExist(err) {
+		if key, err = generateConfigKey(path); err == nil {
+			fmt.Printf("Key file '%s' created. Keep it out of shared storage and back it up, the secrets cannot be decrypted without it.\n", path)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to load the key:", err)
+		os.Exit(1)
+	}
+
+	// 已加密、通过 ${NAME} 引用环境变量以及引用 Vault 和 KMS 的值保持不变
+	var encrypted []string
+	for _, field := range config.secretFields() {
+		if *field.value == "" || isEncrypted(*field.value) || envPlaceholder.MatchString(*field.value) || isSecretRef(*field.value) {
+			continue
+		}
+		if *field.value, err = encryptValue(key, *field.value); err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to encrypt", field.name+":", err)
+			os.Exit(1)
+		}
+		encrypted = append(encrypted, field.name)
+	}
+	if len(encrypted) == 0 {
+		fmt.Println("No plaintext secrets found, nothing changed.")
+		return
+	}
+
+	original, err := os.ReadFile(*configFilePath)
+	if err == nil {
+		err = os.WriteFile(*configFilePath+".bak", original, 0600)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to back up the configuration file:", err)
+		os.Exit(1)
+	}
+	if err := saveConfig(*configFilePath, *configFormat, config); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to save configuration:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Encrypted %d secret(s) in '%s': %s\n", len(encrypted), *configFilePath, strings.Join(encrypted, ", "))
+	fmt.Printf("The original file was saved to '%s.bak', delete it once the new configuration works.\n", *configFilePath)
+}
+
+// 校验配置文件，并通过只读的 DescribeDomains 接口检查凭证和域名是否可用
+func configValidate(args []string) {
+	flags := flag.NewFlagSet("config validate", flag.ExitOnError)
+	configFilePath := flags.String("config", "config.json", "Path to the configuration file")
+	configFormat := flags.String("format", "", "Configuration file format: json, yaml or toml (default: detected from the file extension)")
+	offline := flags.Bool("offline", false, "Only check the configuration file, do not call the DNS provider API")
+	flags.Parse(args)
+
+	config, err := loadConfig(*configFilePath, *configFormat)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to load configuration:", err)
+		os.Exit(exitConfig)
+	}
+	if _, err := config.loadCredentials(context.Background()); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to load credentials:", err)
+		os.Exit(exitConfig)
+	}
+	if err := config.validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", *configFilePath, err)
+		os.Exit(exitConfig)
+	}
+	fmt.Printf("%s: configuration is valid\n", *configFilePath)
+	if *offline {
+		return
+	}
+
+	logger, err := newLogger(os.Stderr, "text", config.LogLevel, config.secrets())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	domains, err := config.domainList()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	redactor := newRedactor(config.secrets())
+
+	// names 中的完整名称先按账号下的域名拆分
+	providers := make(map[string]dnsProvider)
+	for _, domain := range domains {
+		key := domain.providerKey()
+		if len(domain.Names) == 0 || providers[key] != nil {
+			continue
+		}
+		if providers[key], err = newDomainProvider(domain, config, logger); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	if domains, err = splitNames(context.Background(), domains, providers); err != nil {
+		fmt.Fprintln(os.Stderr, redactor.redact(err.Error()))
+		os.Exit(startupExitCode(err))
+	}
+
+	// 凭证无效等失败按 exitCode 分类，多个失败时按 classifyFailures 选择退出码
+	var failures []error
+	for _, first := range providerDomains(domains) {
+		provider := first.providerKey()
+		if first.Provider == providerAliyun {
+			if err := validateAliyunDomains(config, first.Account, domains, logger, redactor); err != nil {
+				failures = append(failures, err)
+			}
+			continue
+		}
+		// 其它服务商逐个域名查询记录，确认凭证有权限访问对应的区域
+		p := providers[provider]
+		if p == nil {
+			if p, err = newDomainProvider(first, config, logger); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		}
+		checked := make(map[string]bool)
+		var providerFailures []error
+		for _, domain := range domains {
+			if domain.providerKey() != provider || checked[domain.DomainName] {
+				continue
+			}
+			checked[domain.DomainName] = true
+			if _, err := p.listRecords(context.Background(), domain.DomainName); err != nil {
+				fmt.Fprintf(os.Stderr, "Domain %s cannot be accessed with the %s credentials: %s\n", domain.DomainName, provider, redactor.redact(err.Error()))
+				providerFailures = append(providerFailures, err)
+			}
+		}
+		failures = append(failures, providerFailures...)
+		if len(providerFailures) == 0 {
+			fmt.Printf("%s credentials are valid, %d domain(s) checked\n", provider, len(checked))
+		}
+	}
+	if len(failures) > 0 {
+		os.Exit(exitCode(classifyFailures(failures)))
+	}
+}
+
+// 检查阿里云账号下是否包含配置中使用该账号的全部阿里云域名，account 为空时为顶层凭证的账号。
+// 问题已输出到标准错误，返回的错误用于确定退出码
+func validateAliyunDomains(config Config, account string, domains []DomainConfig, logger *slog.Logger, redactor *redactor) error {
+	name := "this Aliyun account"
+	if account != "" {
+		name = "Aliyun account " + account
+	}
+	config, err := config.forAccount(account)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return err
+	}
+	client, err := newConfiguredClient(config, logger)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to create Aliyun DNS client:", err)
+		return err
+	}
+	names, err := describeDomainNames(context.Background(), client)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to list domains of %s, please check the credentials: %s\n", name, redactor.redact(err.Error()))
+		return err
+	}
+
+	var missing []string
+	for _, domain := range domains {
+		if domain.Provider == providerAliyun && domain.Account == account && !names[strings.ToLower(domain.DomainName)] {
+			fmt.Fprintf(os.Stderr, "Domain %s is not managed by %s\n", domain.DomainName, name)
+			missing = append(missing, domain.DomainName)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("domains not managed by %s: %s", name, strings.Join(missing, ", "))
+	}
+	fmt.Printf("Credentials of %s are valid, %d domain(s) found in the account\n", name, len(names))
+	return nil
+}
+
+// 返回账号下全部域名的集合，域名统一为小写
+func describeDomainNames(ctx context.Context, client alidnsAPI) (map[string]bool, error) {
+	domains, err := alidns.DescribeDomains(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]bool, len(domains))
+	for _, domain := range domains {
+		names[strings.ToLower(domain.DomainName)] = true
+	}
+	return names, nil
+}
+
+// 从终端读取输入
+type prompter struct {
+	reader      *bufio.Reader
+	interactive bool
+}
+
+// 标准输入是终端时才允许交互
+func newPrompter() *prompter {
+	interactive := false
+	if info, err := os.Stdin.Stat(); err == nil {
+		interactive = info.Mode()&os.ModeCharDevice != 0
+	}
+	return &prompter{reader: bufio.NewReader(os.Stdin), interactive: interactive}
+}
+
+// 已通过参数指定时直接使用，否则提示输入，直接回车时使用默认值
+func (p *prompter) value(label, given, fallback string) string {
+	if given != "" {
+		return given
+	}
+	if !p.interactive {
+		return fallback
+	}
+
+	if fallback != "" {
+		fmt.Printf("%s [%s]: ", label, fallback)
+	} else {
+		fmt.Printf("%s: ", label)
+	}
+	line, _ := p.reader.ReadString('\n')
+	if line = strings.TrimSpace(line); line != "" {
+		return line
+	}
+	return fallback
+}
+
+// 与 value 相同，但输入时不回显，用于密钥，避免留在终端的滚动记录中
+func (p *prompter) secret(label, given string) string {
+	if given != "" || !p.interactive {
+		return given
+	}
+	fmt.Printf("%s: ", label)
+	line, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Println()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(line))
+}
@@ -0,0 +1,296 @@
+package updater
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// 根据 -format 参数或文件扩展名确定配置文件格式
+func configFileFormat(filePath, format string) (string, error) {
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(filePath)) {
+		case ".yaml", ".yml":
+			format = "yaml"
+		case ".toml":
+			format = "toml"
+		default:
+			format = "json"
+		}
+	}
+
+	switch format {
+	case "json", "yaml", "toml":
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown configuration format: %s", format)
+	}
+}
+
+// 从配置文件加载配置。YAML 和 TOML 先转换为 JSON，和 JSON 配置使用相同的字段。
+// 之后用 DDNS_ 开头的环境变量覆盖对应的字段；配置文件不存在但设置了这些环境变量时只使用环境变量。
+// 最后解密 enc:v1: 开头的加密值
+func loadConfig(filePath, format string) (Config, error) {
+	config, err := readConfigFile(filePath, format)
+	if os.IsNotExist(err) && envConfigured() {
+		config, err = envBaseConfig(), nil
+	}
+	if err != nil {
+		return config, err
+	}
+	if err := config.applyEnvConfig(); err != nil {
+		return config, err
+	}
+	expandEnv(reflect.ValueOf(&config).Elem())
+	if err := decryptConfig(&config, filePath); err != nil {
+		return config, err
+	}
+	return config, nil
+}
+
+// 只读取配置文件本身，不应用环境变量，也不解密
+func readConfigFile(filePath, format string) (Config, error) {
+	var config Config
+	format, err := configFileFormat(filePath, format)
+	if err != nil {
+		return config, err
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return config, err
+	}
+
+	if format != "json" {
+		var values map[string]interface{}
+		if format == "yaml" {
+			err = yaml.Unmarshal(data, &values)
+		} else {
+			err = toml.Unmarshal(data, &values)
+		}
+		if err != nil {
+			return config, err
+		}
+		if data, err = json.Marshal(values); err != nil {
+			return config, err
+		}
+	}
+
+	// 不认识的字段通常是拼写错误，直接报错而不是忽略
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&config); err != nil {
+		return config, err
+	}
+	return config, nil
+}
+
+// 配置中的 ${NAME} 占位符，只支持带花括号的形式，避免误替换密钥中的 $ 字符
+var envPlaceholder = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// 遍历配置中的所有字符串字段，包括 headers、options 等 map 的值，将 ${NAME} 替换为对应环境变量的值
+func expandEnv(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.String:
+		if v.CanSet() {
+			v.SetString(expandEnvString(v.String()))
+		}
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			expandEnv(v.Elem())
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			expandEnv(v.Field(i))
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			expandEnv(v.Index(i))
+		}
+	case reflect.Map:
+		// map 的元素不可寻址，只能替换后重新写入
+		if v.Type().Elem().Kind() != reflect.String {
+			return
+		}
+		for _, k := range v.MapKeys() {
+			v.SetMapIndex(k, reflect.ValueOf(expandEnvString(v.MapIndex(k).String())).Convert(v.Type().Elem()))
+		}
+	}
+}
+
+func expandEnvString(s string) string {
+	return envPlaceholder.ReplaceAllStringFunc(s, func(m string) string {
+		return os.Getenv(m[2 : len(m)-1])
+	})
+}
+
+// 将默认配置按对应的格式保存到文件
+func saveDefaultConfig(filePath, format string) error {
+	return saveConfig(filePath, format, defaultConfig)
+}
+
+// 将配置按对应的格式保存到文件
+func saveConfig(filePath, format string, config Config) error {
+	format, err := configFileFormat(filePath, format)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if format == "json" {
+		encoder := json.NewEncoder(file)
+		return encoder.Encode(config)
+	}
+
+	// 通过 JSON 转换为通用的 map，保证字段名与 JSON 配置一致
+	data, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var values map[string]interface{}
+	if err := decoder.Decode(&values); err != nil {
+		return err
+	}
+	values = convertNumbers(values).(map[string]interface{})
+
+	if format == "yaml" {
+		return yaml.NewEncoder(file).Encode(values)
+	}
+	return toml.NewEncoder(file).Encode(values)
+}
+
+// 将 json.Number 转换为整数或浮点数，避免整数字段被写成 1.0 这样的形式
+func convertNumbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			v[key] = convertNumbers(value)
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = convertNumbers(value)
+		}
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	}
+	return v
+}
+
+// 不在日志中显示具体值的敏感字段
+var secretConfigKeys = map[string]bool{
+	"accessKey":    true,
+	"accessSecret": true,
+	"credentials":  true,
+	"accounts":     true,
+	"apiToken":     true,
+	"notify":       true,
+	"cloudflare":   true,
+	"dnspod":       true,
+	"huawei":       true,
+	"dyndns":       true,
+	"proxy":        true,
+	"syslog":       true,
+	"otel":         true,
+	"geo":          true,
+}
+
+// 比较两份配置，返回发生变化的顶层字段，敏感字段只显示已修改
+func configChanges(old, new Config) []string {
+	oldValues, newValues := configValues(old), configValues(new)
+
+	keys := make(map[string]bool)
+	for key := range oldValues {
+		keys[key] = true
+	}
+	for key := range newValues {
+		keys[key] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	var changes []string
+	for _, key := range sorted {
+		before, after := oldValues[key], newValues[key]
+		if reflect.DeepEqual(before, after) {
+			continue
+		}
+		if secretConfigKeys[key] {
+			changes = append(changes, key+" changed")
+			continue
+		}
+		// 只有插件参数变化时隐藏后的值相同，同样只显示已修改
+		before, after = maskOptions(before), maskOptions(after)
+		if reflect.DeepEqual(before, after) {
+			changes = append(changes, key+" changed")
+			continue
+		}
+		changes = append(changes, fmt.Sprintf("%s: %s -> %s", key, configValueString(before), configValueString(after)))
+	}
+	return changes
+}
+
+// 隐藏 ipv4Source、uplinks 等获取方式中原样传给插件的 options，其中可能有路由器的密码等
+func maskOptions(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, field := range value {
+			if options, ok := field.(map[string]interface{}); ok && key == "options" {
+				for name := range options {
+					options[name] = redactedText
+				}
+				continue
+			}
+			value[key] = maskOptions(field)
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = maskOptions(item)
+		}
+	}
+	return value
+}
+
+// 将配置转换为以 JSON 字段名为键的 map
+func configValues(config Config) map[string]interface{} {
+	values := make(map[string]interface{})
+	data, err := json.Marshal(config)
+	if err == nil {
+		json.Unmarshal(data, &values)
+	}
+	return values
+}
+
+// 以 JSON 形式显示配置值，未设置时显示 <unset>
+func configValueString(value interface{}) string {
+	if value == nil {
+		return "<unset>"
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
@@ -0,0 +1,168 @@
+package updater
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// controlSocket 为 host:port 时监听本机的 TCP 端口，否则为 unix socket 的路径
+func controlNetwork(address string) string {
+	if _, _, err := net.SplitHostPort(address); err == nil && !strings.ContainsAny(address, `/\`) {
+		return "tcp"
+	}
+	return "unix"
+}
+
+// 控制接口只允许本机访问，TCP 地址必须是回环地址
+func checkControlSocket(address string) error {
+	if controlNetwork(address) == "unix" {
+		return nil
+	}
+	host, _, _ := net.SplitHostPort(address)
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+		return fmt.Errorf("must be a unix socket path or a loopback address such as 127.0.0.1:9679")
+	}
+	return nil
+}
+
+// 控制接口 GET /status 返回的内容
+type controlStatus struct {
+	PID       int          `json:"pid"`
+	StartedAt time.Time    `json:"startedAt"`
+	Health    healthReport `json:"health"`
+	statusReport
+}
+
+// 控制接口的 HTTP 服务以及退出时需要删除的 unix socket 文件
+type controlServer struct {
+	server *http.Server
+	path   string
+}
+
+// 在 controlSocket 上提供 GET /status 和 POST /update，供 status 和 update 子命令使用。unix socket 只允许当前用户访问，
+// 启动时删除上次异常退出残留的 socket 文件
+func startControlServer(config Config, threshold time.Duration, trigger chan updateTrigger, logger *slog.Logger) (*controlServer, error) {
+	address := config.ControlSocket
+	network := controlNetwork(address)
+	if network == "unix" {
+		if info, err := os.Lstat(address); err == nil && info.Mode()&os.ModeSocket != 0 {
+			os.Remove(address)
+		}
+	}
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		return nil, err
+	}
+	control := &controlServer{}
+	if network == "unix" {
+		control.path = address
+		// Windows 上的权限由所在目录的 ACL 控制
+		if err := os.Chmod(address, 0o600); err != nil && runtime.GOOS != "windows" {
+			listener.Close()
+			os.Remove(address)
+			return nil, err
+		}
+	}
+
+	redactor := newRedactor(config.secrets())
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		writeJSON(w, http.StatusOK, controlStatus{
+			PID:          os.Getpid(),
+			StartedAt:    health.started,
+			Health:       health.report(threshold),
+			statusReport: status.report(redactor),
+		})
+	})
+	mux.HandleFunc("/update", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		updateHandler(trigger)(w, r)
+	})
+	control.server = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := control.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("Control socket stopped", "address", address, "error", err)
+		}
+	}()
+	logger.Info("Control socket listening", "address", address)
+	return control, nil
+}
+
+// 关闭控制接口并删除 unix socket 文件
+func (c *controlServer) stop() {
+	if c == nil {
+		return
+	}
+	stopHTTPServer(c.server)
+	if c.path != "" {
+		os.Remove(c.path)
+	}
+}
+
+// 连接 controlSocket 的 HTTP 客户端
+func controlClient(address string) *http.Client {
+	network := controlNetwork(address)
+	return &http.Client{
+		Timeout: 5 * time.Second,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, network, address)
+			},
+		},
+	}
+}
+
+// 通过 controlSocket 查询运行中的实例
+func queryControlStatus(address string) (controlStatus, error) {
+	var result controlStatus
+	resp, err := controlClient(address).Get("http://ddns/status")
+	if err != nil {
+		return result, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return result, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return result, fmt.Errorf("invalid status response: %v", err)
+	}
+	return result, nil
+}
+
+// 通过 controlSocket 让运行中的实例立即检测，force 为 true 时强制重新提交全部记录，confirm 为 true 时恢复因 IP 频繁变化暂停的更新
+func triggerControlUpdate(address string, force, confirm bool) error {
+	query := url.Values{"force": {strconv.FormatBool(force)}, "confirm": {strconv.FormatBool(confirm)}}
+	resp, err := controlClient(address).Post("http://ddns/update?"+query.Encode(), "", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
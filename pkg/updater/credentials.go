@@ -0,0 +1,191 @@
+package updater
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk"
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/auth/credentials/provider"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/alidns"
+)
+
+// 凭证配置，未设置时使用 accessKey/accessSecret
+type CredentialConfig struct {
+	// access_key（默认）：使用 accessKey/accessSecret
+	// sts：使用 accessKey/accessSecret 和 securityToken 组成的 STS 临时凭证
+	// ecs_ram_role：通过 ECS 实例元数据服务获取实例 RAM 角色的临时凭证
+	// profile：读取阿里云 CLI 的凭证文件（~/.alibabacloud/credentials 或 ALIBABA_CLOUD_CREDENTIALS_FILE）
+	// chain：依次尝试 ALIBABA_CLOUD_ACCESS_KEY_ID 等环境变量、凭证文件和 ECS 实例角色
+	// keyring：从系统钥匙串（macOS 钥匙串、Windows 凭据管理器或 libsecret）读取 keyring set 子命令保存的 AccessKey
+	Type          string `json:"type"`
+	SecurityToken string `json:"securityToken,omitempty"` // sts 方式的 SecurityToken，也可以通过 ALICLOUD_SECURITY_TOKEN 环境变量设置
+	RoleName      string `json:"roleName,omitempty"`      // ecs_ram_role 方式的角色名称，未设置时从元数据服务读取
+	Profile       string `json:"profile,omitempty"`       // profile 方式使用的配置名称，默认 default
+	Service       string `json:"service,omitempty"`       // keyring 方式的服务名称，默认 DDns_go
+}
+
+// 一个有名称的阿里云账号，domains 中通过 account 引用，同一个账号的域名共用一个客户端
+type AccountConfig struct {
+	Name         string            `json:"name"`
+	AccessKey    string            `json:"accessKey,omitempty"`
+	AccessSecret string            `json:"accessSecret,omitempty"`
+	Credentials  *CredentialConfig `json:"credentials,omitempty"` // 与顶层的 credentials 相同
+	RegionID     string            `json:"regionId,omitempty"`    // 未设置时使用顶层的 regionId
+	Endpoint     string            `json:"endpoint,omitempty"`    // 未设置时使用顶层的 endpoint
+}
+
+// 使用 accounts 中名为 name 的账号代替顶层的凭证，name 为空时返回原配置
+func (c Config) forAccount(name string) (Config, error) {
+	if name == "" {
+		return c, nil
+	}
+	for _, account := range c.Accounts {
+		if account.Name != name {
+			continue
+		}
+		c.AccessKey, c.AccessSecret, c.Credentials = account.AccessKey, account.AccessSecret, account.Credentials
+		if account.RegionID != "" {
+			c.RegionID = account.RegionID
+		}
+		if account.Endpoint != "" {
+			c.Endpoint = account.Endpoint
+		}
+		return c, nil
+	}
+	return c, fmt.Errorf("unknown account %q", name)
+}
+
+// ECS 元数据服务中实例 RAM 角色的地址
+const ecsRAMRoleURL = "http://100.100.100.200/latest/meta-data/ram/security-credentials/"
+
+// 按凭证配置创建阿里云 DNS 客户端
+func newDNSClient(config Config, regionID string) (*alidns.Client, error) {
+	client := &alidns.Client{}
+	if err := initSDKClient(&client.Client, config, regionID); err != nil {
+		return nil, err
+	}
+	alidns.SetEndpointDataToClient(client)
+	return client, nil
+}
+
+// 按凭证配置初始化阿里云 SDK 客户端，云解析和内网 DNS 解析共用
+func initSDKClient(client *sdk.Client, config Config, regionID string) error {
+	credentialType := "access_key"
+	if config.Credentials != nil && config.Credentials.Type != "" {
+		credentialType = config.Credentials.Type
+	}
+
+	switch credentialType {
+	case "access_key", "keyring":
+		// keyring 方式在加载配置后已读取钥匙串中的 AccessKey，见 loadKeyringCredentials
+		return client.InitWithAccessKey(regionID, config.AccessKey, config.AccessSecret)
+	case "sts":
+		token := os.Getenv("ALICLOUD_SECURITY_TOKEN")
+		if token == "" {
+			token = config.Credentials.SecurityToken
+		}
+		if token == "" {
+			return fmt.Errorf("securityToken is required for sts credentials")
+		}
+		return client.InitWithStsToken(regionID, config.AccessKey, config.AccessSecret, token)
+	case "ecs_ram_role":
+		roleName := config.Credentials.RoleName
+		if roleName == "" {
+			discovered, err := discoverECSRAMRole()
+			if err != nil {
+				return fmt.Errorf("failed to get RAM role name from ECS metadata: %v", err)
+			}
+			roleName = discovered
+		}
+		return client.InitWithEcsRamRole(regionID, roleName)
+	case "profile":
+		profile := provider.NewProfileProvider(config.Credentials.profileName())
+		return client.InitWithProviderChain(regionID, provider.NewProviderChain([]provider.Provider{profile}))
+	case "chain":
+		return client.InitWithProviderChain(regionID, provider.DefaultChain)
+	default:
+		return fmt.Errorf("unknown credential type: %s", credentialType)
+	}
+}
+
+// 按配置创建阿里云 DNS 客户端，同时应用对外请求共享的超时、TLS 和代理设置。
+// logger 开启 debug 级别时记录获取 IP 和调用阿里云 API 的请求和响应
+func newConfiguredClient(config Config, logger *slog.Logger) (*alidns.Client, error) {
+	settings, err := config.httpSettings()
+	if err != nil {
+		return nil, err
+	}
+	client, err := newDNSClient(config, config.regionID())
+	if err != nil {
+		return nil, err
+	}
+	setDNSEndpoint(client, config.regionID(), config.Endpoint)
+	configureSDKClient(&client.Client, settings)
+	if debugEnabled(logger) {
+		// 包装后 SDK 不再修改 Transport，拨号、代理和 TLS 设置都由 newTransport 提供
+		client.SetTransport(withDebugTransport(settings.newTransport("", settings.aliyunProxy), logger))
+	}
+	return client, nil
+}
+
+// 默认的阿里云地域
+const defaultRegionID = "cn-hangzhou"
+
+// 返回配置的阿里云地域
+func (c Config) regionID() string {
+	if c.RegionID == "" {
+		return defaultRegionID
+	}
+	return c.RegionID
+}
+
+// 为客户端指定云解析 API 的地址，endpoint 为空时使用 SDK 内置的地址
+func setDNSEndpoint(client *alidns.Client, regionID, endpoint string) {
+	if endpoint == "" {
+		return
+	}
+	// EndpointMap 默认是 SDK 内所有客户端共享的，复制一份再修改
+	endpoints := make(map[string]string, len(client.EndpointMap)+1)
+	for region, value := range client.EndpointMap {
+		endpoints[region] = value
+	}
+	endpoints[regionID] = endpoint
+	client.EndpointMap = endpoints
+}
+
+// 凭证文件中使用的配置名称
+func (c *CredentialConfig) profileName() string {
+	if c.Profile == "" {
+		return "default"
+	}
+	return c.Profile
+}
+
+// 从 ECS 元数据服务读取实例绑定的 RAM 角色名称
+func discoverECSRAMRole() (string, error) {
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get(ecsRAMRoleURL)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("metadata request failed with status: %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return "", err
+	}
+
+	roleName := strings.TrimSpace(string(body))
+	if roleName == "" {
+		return "", fmt.Errorf("no RAM role attached to this instance")
+	}
+	return roleName, nil
+}
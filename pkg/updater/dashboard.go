@@ -0,0 +1,18 @@
+package updater
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// Web 控制台的静态文件，数据通过 REST API 获取
+//
+//go:embed web
+var dashboardFiles embed.FS
+
+// 在 / 上提供 Web 控制台
+func registerDashboard(mux *http.ServeMux) {
+	files, _ := fs.Sub(dashboardFiles, "web")
+	mux.Handle("/", http.FileServer(http.FS(files)))
+}
@@ -0,0 +1,1149 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// 配置文件结构
+type Config struct {
+	AccessKey    string   `json:"accessKey"`
+	AccessSecret string   `json:"accessSecret"`
+	DomainName   string   `json:"domainName"`
+	LogFileName  string   `json:"logFileName,omitempty"` // 日志文件，未设置且未设置 logTarget 时日志输出到标准输出
+	APIURL       string   `json:"apiURL,omitempty"`      // 旧版的单个 IPv4 API，设置 apiURLs 后忽略
+	APIURLs      []string `json:"apiURLs,omitempty"`     // 按顺序尝试的 IPv4 API 列表
+	RecordType   string   `json:"recordType"`
+	RR           string   `json:"rr"`
+	RRs          []string `json:"rrs,omitempty"` // 多个主机记录，设置后忽略 rr
+	Delay        int      `json:"delay"`
+	TimeUnit     string   `json:"timeUnit"`            // 延迟时间单位
+	Interval     string   `json:"interval,omitempty"`  // 检测间隔，例如 30s、5m，设置后忽略 delay/timeUnit
+	Schedule     string   `json:"schedule,omitempty"`  // cron 表达式，例如 "*/5 * * * *"，设置后忽略 interval 和 delay/timeUnit
+	Jitter       string   `json:"jitter,omitempty"`    // 每次等待额外增加的随机时间上限，例如 30s
+	IPMode       string   `json:"ipMode"`              // ipv4、ipv6 或 dual（双栈）
+	APIURLv6     string   `json:"apiURLv6,omitempty"`  // 旧版的单个 IPv6 API，设置 apiURLsV6 后忽略
+	APIURLsV6    []string `json:"apiURLsV6,omitempty"` // 按顺序尝试的 IPv6 API 列表
+
+	IPv4Source *SourceConfig  `json:"ipv4Source,omitempty"` // IPv4 地址的获取方式，默认通过 http 访问 apiURLs
+	IPv6Source *SourceConfig  `json:"ipv6Source,omitempty"` // IPv6 地址的获取方式，默认通过 http 访问 apiURLsV6
+	Uplinks    []UplinkConfig `json:"uplinks,omitempty"`    // 多线路时每条线路的获取方式，domains 中通过 uplink 引用
+
+	AllowPrivateIP bool `json:"allowPrivateIP,omitempty"` // 接受私有地址（10/8、172.16/12、192.168/16、100.64/10、fc00::/7），用于内网解析
+
+	WANSource *SourceConfig `json:"wanSource,omitempty"` // 路由器 WAN 口地址的获取方式，通常为 upnp 或 natpmp，设置后与公网 IPv4 比较以检测运营商级 NAT
+	CGNAT     string        `json:"cgnat,omitempty"`     // 检测到运营商级 NAT 时：warn（默认，记录警告并通知）或 skip（同时跳过 IPv4 记录，只更新 IPv6）
+	Geo       *GeoConfig    `json:"geo,omitempty"`       // 公网 IP 变化时查询新地址的 ASN、运营商和地区，写入日志、历史和通知
+	Churn     *ChurnConfig  `json:"churn,omitempty"`     // 公网 IP 在一段时间内变化过多时告警，可以同时暂停更新
+
+	Domains []DomainConfig `json:"domains,omitempty"` // 多个域名，设置后忽略 domainName
+
+	Credentials *CredentialConfig `json:"credentials,omitempty"` // 使用 STS、ECS 实例角色或凭证文件代替 accessKey/accessSecret
+	Accounts    []AccountConfig   `json:"accounts,omitempty"`    // 其它阿里云账号的凭证，domains 中通过 account 引用
+	RegionID    string            `json:"regionId,omitempty"`    // 阿里云地域，默认 cn-hangzhou
+	Endpoint    string            `json:"endpoint,omitempty"`    // 云解析 API 的地址，例如国际站的 alidns.ap-southeast-1.aliyuncs.com
+
+	Provider   string            `json:"provider,omitempty"`   // 默认的 DNS 服务商：aliyun（默认）、cloudflare、dnspod 或 huawei，每个域名也可以单独设置
+	Cloudflare *CloudflareConfig `json:"cloudflare,omitempty"` // Cloudflare 的 API 令牌和代理设置
+	DNSPod     *DNSPodConfig     `json:"dnspod,omitempty"`     // DNSPod（腾讯云）的 API 密钥和默认线路
+	Huawei     *HuaweiConfig     `json:"huawei,omitempty"`     // 华为云的 AK/SK 或委托凭证
+
+	AutoCreate  *bool `json:"autoCreate,omitempty"`  // 记录不存在时是否自动创建，默认开启
+	TTL         int   `json:"ttl,omitempty"`         // 更新和创建记录时使用的 TTL（秒），为 0 时使用阿里云默认值
+	Concurrency int   `json:"concurrency,omitempty"` // 同时查询和更新的记录数，默认为 4
+
+	DisabledRecords string `json:"disabledRecords,omitempty"` // 匹配的记录已被暂停时：skip（默认，跳过并记录警告）或 enable（先启用再更新）
+	MultipleRecords string `json:"multipleRecords,omitempty"` // 同一主机记录匹配到多条记录（例如不同线路）时：fail（默认，报错）、first（只更新第一条）或 all（全部更新）
+	TagRecords      bool   `json:"tagRecords,omitempty"`      // 把记录的备注设置为 "managed by ailiyunDDns @ 主机名"，方便在控制台区分
+	Prune           string `json:"prune,omitempty"`           // delete 或 disable：删除或暂停本机标记过但已不在配置中的记录，需要开启 tagRecords，默认不处理
+
+	Cooldown      string `json:"cooldown,omitempty"`      // 同一条记录两次修改之间的最短间隔，例如 10m，默认不限制
+	Confirmations int    `json:"confirmations,omitempty"` // 新的公网 IP 需要连续检测到的次数，默认为 1（立即使用）
+
+	WatchNetwork   bool   `json:"watchNetwork,omitempty"`   // 网络变化时立即检测（仅 Linux）
+	WatchInterface string `json:"watchInterface,omitempty"` // 只关注该网卡的变化，例如 pppoe-wan
+
+	StateFile      string `json:"stateFile,omitempty"`      // 保存上次推送的 IP 和 RecordId 的文件，IP 未变化时跳过查询解析记录
+	VerifyInterval string `json:"verifyInterval,omitempty"` // 即使 IP 未变化也重新查询解析记录的间隔，默认 1h
+	PIDFile        string `json:"pidFile,omitempty"`        // 保存进程 PID 的文件，同时作为单实例锁，防止多个实例同时更新同一个域名
+
+	RunOnStart         *bool `json:"runOnStart,omitempty"`         // 启动后立即检测一次，默认开启；关闭时先等待一个检测间隔
+	ForceUpdateOnStart bool  `json:"forceUpdateOnStart,omitempty"` // 启动后的第一次检测忽略本地状态，值相同的记录也重新提交
+
+	HistoryFile      string `json:"historyFile,omitempty"`      // 保存公网 IP 变化和记录更新历史的数据库文件，可以用 history 子命令查询
+	HistoryRetention string `json:"historyRetention,omitempty"` // 历史的保留时间，默认 8760h（一年）
+
+	LogRotate *LogRotateConfig `json:"logRotate,omitempty"` // 日志文件按大小轮转，未设置时不轮转
+	LogFormat string           `json:"logFormat,omitempty"` // 日志格式：text（默认）或 json
+	LogLevel  string           `json:"logLevel,omitempty"`  // 日志级别：debug、info（默认）、warn 或 error
+	LogTarget string           `json:"logTarget,omitempty"` // 日志输出：file（写入 logFileName）、stdout、stderr、both（同时写入文件和标准输出）、journald 或 syslog
+	Syslog    *SyslogConfig    `json:"syslog,omitempty"`    // logTarget 为 syslog 时的设置
+
+	MetricsListen   string `json:"metricsListen,omitempty"`   // 内置 HTTP 服务的监听地址，例如 :9678，提供 /metrics 和 /healthz
+	HealthThreshold string `json:"healthThreshold,omitempty"` // 持续失败超过该时间后 /healthz 返回 503，默认为检测间隔的 3 倍
+	APIToken        string `json:"apiToken,omitempty"`        // 设置后在内置 HTTP 服务上开启 REST API（/status、/update、/history），请求时需要携带该令牌
+	ControlSocket   string `json:"controlSocket,omitempty"`   // 供 status 子命令查询的 unix socket 路径或本机 TCP 地址，例如 /run/ddns.sock 或 127.0.0.1:9679
+	UpdateCheck     bool   `json:"updateCheck,omitempty"`     // 每天检查一次 GitHub 上是否有新版本，有新版本时记录日志，不会自动安装
+	Debug           bool   `json:"debug,omitempty"`           // 在内置 HTTP 服务上开启 /debug/pprof/ 和 /debug/vars，需要设置 apiToken
+	MemoryLimit     int    `json:"memoryLimit,omitempty"`     // Go 运行时的内存上限（MB），接近上限时更频繁地回收内存，0 表示不限制
+
+	PluginDir string `json:"pluginDir,omitempty"` // 插件所在的目录，供 plugin 方式的 IP 获取方式和 notify.plugins 使用
+
+	DynDNS *DynDNSConfig `json:"dyndns,omitempty"` // 提供 DynDNS2 兼容的 /nic/update 接口，路由器可以直接推送 WAN 口地址
+
+	Retry *RetryConfig `json:"retry,omitempty"` // 获取 IP 和调用阿里云 API 失败时的重试策略
+	HTTP  *HTTPConfig  `json:"http,omitempty"`  // 超时、长连接和 TLS 设置，用于全部对外的 HTTP 请求
+	Proxy *ProxyConfig `json:"proxy,omitempty"` // 获取 IP 和调用阿里云 API 使用的代理
+
+	Notify *NotifyConfig `json:"notify,omitempty"` // 记录更新、连续失败和启动时发送通知
+	OTel   *OTelConfig   `json:"otel,omitempty"`   // 通过 OTLP/HTTP 导出每轮检测的链路和指标
+}
+
+// 默认的配置文件内容
+var defaultConfig = Config{
+	AccessKey:    "your_access_key",
+	AccessSecret: "your_access_secret",
+	DomainName:   "your_domain_name",
+	LogFileName:  "DDns.log",
+	APIURLs: []string{
+		"https://api.ipify.org/?format=json",
+		"https://api-ipv4.ip.sb/ip",
+		"https://ifconfig.co/json",
+	},
+	RecordType: "A",
+	RR:         "*",
+	Delay:      1,
+	TimeUnit:   "minute",
+	IPMode:     "ipv4",
+	APIURLsV6: []string{
+		"https://api64.ipify.org/?format=json",
+		"https://api-ipv6.ip.sb/ip",
+		"https://ifconfig.co/json",
+	},
+}
+
+// 自定义的无需更新错误
+var ErrNoUpdateNeeded = errors.New("No update needed")
+
+// 未找到记录且未开启自动创建时返回的错误
+var ErrRecordNotFound = errors.New("DNS record not found")
+
+// 匹配的记录已被暂停且 disabledRecords 为 skip 时返回的错误
+var ErrRecordDisabled = errors.New("DNS record is disabled")
+
+// 阿里云解析允许的最大 TTL（秒）
+const maxTTL = 86400
+
+// 单个域名的配置
+type DomainConfig struct {
+	DomainName  string   `json:"domainName"`
+	RRs         []string `json:"rrs"`                // 未设置时使用顶层的 rr/rrs
+	Names       []string `json:"names,omitempty"`    // 完整的记录名称，例如 ["home.nas.example.com"]，启动时按账号下的域名拆分，不能与 domainName 和 rrs 同时设置
+	RecordTypes []string `json:"recordTypes"`        // 例如 ["A", "AAAA"] 或 ["TXT"]，未设置时由 ipMode 决定
+	TTL         int      `json:"ttl"`                // 未设置时使用顶层的 ttl
+	Provider    string   `json:"provider,omitempty"` // DNS 服务商：aliyun、cloudflare、dnspod 或 huawei，未设置时使用顶层的 provider
+	Proxied     *bool    `json:"proxied,omitempty"`  // 仅 Cloudflare：是否开启代理，未设置时使用 cloudflare.proxied
+	Line        string   `json:"line,omitempty"`     // 阿里云和 DNSPod：解析线路，阿里云默认为 default，DNSPod 默认使用 dnspod.line，* 匹配全部线路
+	Value       string   `json:"value,omitempty"`    // 记录值模板，例如 "last-ip={{.IP}}"，未设置时为公网 IP；A 和 AAAA 以外的记录类型必须设置
+	ZoneType    string   `json:"zoneType,omitempty"` // 仅阿里云：public（默认，云解析）或 private（内网 DNS 解析 PrivateZone）
+	ZoneID      string   `json:"zoneId,omitempty"`   // 仅阿里云 private：PrivateZone 的 Zone ID
+	Account     string   `json:"account,omitempty"`  // 仅阿里云：使用 accounts 中该名称的账号的凭证，未设置时使用顶层的凭证
+	Uplink      string   `json:"uplink,omitempty"`   // 使用 uplinks 中该名称的线路检测到的地址，未设置时使用顶层的 ipv4Source/ipv6Source
+	Interval    string   `json:"interval,omitempty"` // 该域名的检测间隔，例如 1m，未设置时按顶层的 interval 或 schedule 检测
+
+	ExtraValues  []string          `json:"extraValues,omitempty"`  // 固定的记录值，与动态的值一起组成主机记录的全部记录（多值轮询），多出的记录会被修改或删除
+	IPv6Hosts    map[string]string `json:"ipv6Hosts,omitempty"`    // 主机记录对应的局域网主机的接口标识或 MAC 地址，AAAA 记录使用检测到的前缀加上接口标识，未设置 rrs 时为其中的主机记录
+	PrefixLength int               `json:"prefixLength,omitempty"` // ipv6Hosts 使用的前缀长度，默认 64
+}
+
+// IP 地址的获取方式
+type SourceConfig struct {
+	Source  string   `json:"source"`            // http（默认）、stun、dns、interface、command、file、upnp、natpmp、plugin 或 kubernetes
+	URLs    []string `json:"urls,omitempty"`    // http 方式按顺序尝试的 API，未设置时使用 apiURLs/apiURLsV6；upnp 方式的设备描述地址，未设置时自动发现；kubernetes 方式的 API 地址，未设置时使用集群内的服务账号
+	Servers []string `json:"servers,omitempty"` // stun 方式按顺序尝试的服务器，例如 stun.l.google.com:19302；dns 方式的查询，例如 myip.opendns.com@resolver1.opendns.com；natpmp 方式的网关地址，未设置时使用默认网关
+	Name    string   `json:"name,omitempty"`    // interface 方式使用的网卡名称，例如 eth0
+	Command []string `json:"command,omitempty"` // command 方式运行的命令及参数，输出中的第一个地址作为公网 IP
+	Path    string   `json:"path,omitempty"`    // file 方式读取的文件
+	Timeout string   `json:"timeout,omitempty"` // command 和 plugin 方式的超时，默认 10s
+	Quorum  int      `json:"quorum,omitempty"`  // 大于 1 时依次查询多个来源，至少有 quorum 个来源返回相同的地址才使用
+
+	BindAddress   string `json:"bindAddress,omitempty"`   // http 方式访问 API 时使用的本机地址，例如第二条线路的 WAN 口地址，请求从该地址所在的线路发出
+	BindInterface string `json:"bindInterface,omitempty"` // http 方式访问 API 时使用的网卡，例如 pppoe-wan2，在 Linux 上不依赖路由表从该网卡发出
+
+	Plugin  string            `json:"plugin,omitempty"`  // plugin 方式使用的 pluginDir 中的插件名称
+	Options map[string]string `json:"options,omitempty"` // plugin 方式原样传给插件的参数
+
+	Node    string `json:"node,omitempty"`    // kubernetes 方式读取 ExternalIP 的节点，默认为 NODE_NAME 环境变量
+	Service string `json:"service,omitempty"` // kubernetes 方式读取入口地址的 LoadBalancer 类型的 Service，格式为 namespace/name，设置后忽略 node
+}
+
+// 一个 IP 协议族的检测参数
+type ipFamily struct {
+	name    string       // 日志中显示的名称，绑定了线路时为 IPv4/wan2 这样的形式
+	network string       // 访问 API 时使用的网络类型，tcp4 或 tcp6
+	source  SourceConfig // 获取方式
+	uplink  string       // 线路名称，使用顶层的获取方式时为空
+
+	allowPrivate bool   // 是否接受私有地址
+	pluginDir    string // plugin 方式查找插件的目录
+}
+
+// 根据记录类型返回对应的协议族，AAAA 记录使用 IPv6，A 记录使用 IPv4，
+// 其它类型在 ipMode 为 ipv6 时使用 IPv6，否则使用 IPv4
+func (c Config) familyFor(recordType string) ipFamily {
+	if recordType == "AAAA" || !addressRecordType(recordType) && c.IPMode == "ipv6" {
+		return ipFamily{name: "IPv6", network: "tcp6", source: resolveSource(c.IPv6Source, apiURLList(c.APIURLsV6, c.APIURLv6, defaultConfig.APIURLsV6)), allowPrivate: c.AllowPrivateIP, pluginDir: c.PluginDir}
+	}
+	return ipFamily{name: "IPv4", network: "tcp4", source: resolveSource(c.IPv4Source, apiURLList(c.APIURLs, c.APIURL, defaultConfig.APIURLs)), allowPrivate: c.AllowPrivateIP, pluginDir: c.PluginDir}
+}
+
+// 补全 IP 获取方式的配置，未设置时通过 http 方式访问 apiURLs
+func resolveSource(source *SourceConfig, apiURLs []string) SourceConfig {
+	var resolved SourceConfig
+	if source != nil {
+		resolved = *source
+	}
+	if resolved.Source == "" {
+		resolved.Source = "http"
+	}
+	if resolved.Source == "http" && len(resolved.URLs) == 0 {
+		resolved.URLs = apiURLs
+	}
+	return resolved
+}
+
+// 优先使用 API 列表，其次是旧版的单个 API，都未配置时使用默认列表
+func apiURLList(urls []string, legacy string, defaults []string) []string {
+	if len(urls) > 0 {
+		return urls
+	}
+	if legacy != "" {
+		return []string{legacy}
+	}
+	return defaults
+}
+
+// 根据 ipMode 返回默认需要更新的记录类型
+func (c Config) defaultRecordTypes() ([]string, error) {
+	recordType := c.RecordType
+	if recordType == "" {
+		recordType = "A"
+	}
+
+	switch c.IPMode {
+	case "", "ipv4":
+		return []string{recordType}, nil
+	case "ipv6":
+		return []string{"AAAA"}, nil
+	case "dual":
+		return []string{recordType, "AAAA"}, nil
+	default:
+		return nil, fmt.Errorf("unknown ip mode: %s", c.IPMode)
+	}
+}
+
+// 返回需要更新的全部域名，未配置 domains 时使用顶层的 domainName
+func (c Config) domainList() ([]DomainConfig, error) {
+	recordTypes, err := c.defaultRecordTypes()
+	if err != nil {
+		return nil, err
+	}
+
+	configured := c.Domains
+	if len(configured) == 0 {
+		configured = []DomainConfig{{DomainName: c.DomainName}}
+	}
+
+	domains := make([]DomainConfig, 0, len(configured))
+	for _, domain := range configured {
+		if len(domain.RRs) == 0 && len(domain.Names) == 0 {
+			domain.RRs = c.hostRecords()
+			if len(domain.IPv6Hosts) > 0 {
+				domain.RRs = domain.ipv6HostRRs()
+			}
+		}
+		if len(domain.RecordTypes) == 0 {
+			domain.RecordTypes = recordTypes
+			// 局域网主机只有 IPv6 地址是公网可达的
+			if len(domain.IPv6Hosts) > 0 {
+				domain.RecordTypes = []string{"AAAA"}
+			}
+		}
+		if domain.TTL == 0 {
+			domain.TTL = c.TTL
+		}
+		if domain.Provider == "" {
+			domain.Provider = c.provider()
+		}
+		if domain.Proxied == nil && domain.Provider == providerCloudflare && c.Cloudflare != nil {
+			domain.Proxied = c.Cloudflare.Proxied
+		}
+		if domain.Provider == providerAliyun && domain.ZoneType == zoneTypePrivate {
+			domain.Provider = providerPrivateZone
+		}
+		if domain.Line == "" {
+			switch domain.Provider {
+			case providerAliyun:
+				domain.Line = aliyunDefaultLine
+			case providerDNSPod:
+				domain.Line = dnspodDefaultLine
+				if c.DNSPod != nil && c.DNSPod.Line != "" {
+					domain.Line = c.DNSPod.Line
+				}
+			}
+		}
+		// 开启代理的 Cloudflare 记录 TTL 固定为自动
+		if domain.Proxied != nil && *domain.Proxied {
+			domain.TTL = 0
+		}
+		if domain.TTL < 0 || domain.TTL > maxTTL {
+			return nil, fmt.Errorf("ttl %d of %s is out of range 1-%d", domain.TTL, domain.DomainName, maxTTL)
+		}
+		domains = append(domains, domain)
+	}
+	return domains, nil
+}
+
+// 返回这些域名需要检测的协议族，每个协议族只出现一次
+func (c Config) families(domains []DomainConfig) []ipFamily {
+	var families []ipFamily
+	seen := make(map[string]bool)
+	for _, domain := range domains {
+		for _, recordType := range domain.RecordTypes {
+			family := c.domainFamily(domain, recordType)
+			if !seen[family.name] {
+				seen[family.name] = true
+				families = append(families, family)
+			}
+		}
+	}
+	return families
+}
+
+// 默认的 DNS 服务商
+func (c Config) provider() string {
+	if c.Provider == "" {
+		return providerAliyun
+	}
+	return c.Provider
+}
+
+// 日志输出目标，未设置 logTarget 时设置了 logFileName 为 file，否则为 stdout
+func (c Config) logTarget() string {
+	switch {
+	case c.LogTarget != "":
+		return c.LogTarget
+	case c.LogFileName != "":
+		return "file"
+	default:
+		return "stdout"
+	}
+}
+
+// 记录不存在时是否自动创建
+func (c Config) autoCreate() bool {
+	return c.AutoCreate == nil || *c.AutoCreate
+}
+
+// 启动后是否立即检测，而不是等待一个检测间隔
+func (c Config) runOnStart() bool {
+	return c.RunOnStart == nil || *c.RunOnStart
+}
+
+// tagRecords 开启时写入记录备注的前缀，后面跟运行程序的主机名
+const managedRemarkPrefix = "managed by ailiyunDDns"
+
+// 返回需要写入记录的备注，未开启 tagRecords 时为空
+func (c Config) recordRemark() string {
+	if !c.TagRecords {
+		return ""
+	}
+	if hostname, err := os.Hostname(); err == nil && hostname != "" {
+		return managedRemarkPrefix + " @ " + hostname
+	}
+	return managedRemarkPrefix
+}
+
+// 检测到运营商级 NAT 时的处理方式
+const (
+	cgnatWarn = "warn"
+	cgnatSkip = "skip"
+)
+
+// 过期记录的处理方式
+const (
+	pruneDelete  = "delete"
+	pruneDisable = "disable"
+)
+
+// 已暂停记录的处理方式
+const (
+	disabledRecordsSkip   = "skip"
+	disabledRecordsEnable = "enable"
+)
+
+// 返回已暂停记录的处理方式，默认为 skip
+func (c Config) disabledRecords() string {
+	if c.DisabledRecords == "" {
+		return disabledRecordsSkip
+	}
+	return c.DisabledRecords
+}
+
+// line 为该值时匹配全部线路的记录，新建的记录使用默认线路
+const anyLine = "*"
+
+// 查找记录时匹配的线路，为空时匹配全部线路
+func (d DomainConfig) recordLine() string {
+	if d.Line == anyLine {
+		return ""
+	}
+	return d.Line
+}
+
+// 匹配到多条记录时的处理方式
+const (
+	multipleRecordsFail  = "fail"
+	multipleRecordsFirst = "first"
+	multipleRecordsAll   = "all"
+)
+
+// 返回匹配到多条记录时的处理方式，默认为 fail
+func (c Config) multipleRecords() string {
+	if c.MultipleRecords == "" {
+		return multipleRecordsFail
+	}
+	return c.MultipleRecords
+}
+
+// 返回同时进行的 DNS API 请求数，默认为 4
+func (c Config) concurrency() int {
+	if c.Concurrency > 0 {
+		return c.Concurrency
+	}
+	return 4
+}
+
+// 返回需要更新的主机记录
+func (c Config) hostRecords() []string {
+	if len(c.RRs) > 0 {
+		return c.RRs
+	}
+	return []string{c.RR}
+}
+
+// 需要同步到 DNS 服务商的一条解析记录
+type recordSpec struct {
+	DomainName string
+	RR         string
+	Type       string
+	Value      string
+	TTL        int    // 为 0 时使用服务商的默认 TTL
+	Proxied    *bool  // 仅 Cloudflare，为空时新建的记录不开启代理，已有记录保持不变
+	Line       string // 阿里云和 DNSPod，只匹配该线路的记录
+	AutoCreate bool
+	Force      bool     // 值和 TTL 都不需要修改时仍然提交更新，见 forceUpdateOnStart
+	Enable     bool     // 匹配的记录已被暂停时先启用，否则返回 ErrRecordDisabled
+	Extra      []string // extraValues 中与记录类型对应的值，不为空时同步全部记录值
+	Multiple   string   // 匹配到多条记录时的处理方式，见 multipleRecords
+}
+
+// 判断解析记录是否为该配置对应的记录
+func (s recordSpec) matches(record dnsRecord) bool {
+	return record.Type == s.Type && record.RR == s.RR && (s.Line == "" || record.Line == s.Line)
+}
+
+// 返回 records 中该配置对应的记录，保持服务商返回的顺序
+func (s recordSpec) matching(records []dnsRecord) []dnsRecord {
+	var matched []dnsRecord
+	for _, record := range records {
+		if s.matches(record) {
+			matched = append(matched, record)
+		}
+	}
+	return matched
+}
+
+// 主机记录应有的全部值：动态的值在前，之后是 extraValues，去掉重复的值
+func (s recordSpec) values() []string {
+	values := []string{s.Value}
+	for _, value := range s.Extra {
+		if !slices.Contains(values, value) {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// extraValues 中与记录类型对应的值：A 记录只使用 IPv4 地址，AAAA 记录只使用 IPv6 地址，其它类型使用全部的值
+func (d DomainConfig) extraValuesFor(recordType string) []string {
+	if !addressRecordType(recordType) {
+		return d.ExtraValues
+	}
+	var values []string
+	for _, value := range d.ExtraValues {
+		if ip := net.ParseIP(value); ip != nil && (ip.To4() != nil) == (recordType == "A") {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// 记录 ID 列表，用于日志和错误信息
+func recordIDs(records []dnsRecord) string {
+	ids := make([]string, len(records))
+	for i, record := range records {
+		ids[i] = record.ID
+	}
+	return strings.Join(ids, ",")
+}
+
+// 同步一条解析记录，返回记录的 ID 以及更新前的值（新建的记录为空）。
+// 设置了 extraValues 时同步全部记录值，否则匹配到多条记录时按 multipleRecords 只修改第一条、全部修改或返回错误
+func updateDNSRecord(ctx context.Context, p dnsProvider, records []dnsRecord, spec recordSpec) (string, string, error) {
+	if len(spec.Extra) > 0 {
+		return updateValueSet(ctx, p, records, spec)
+	}
+	matched := spec.matching(records)
+	if len(matched) > 1 {
+		switch spec.Multiple {
+		case multipleRecordsFirst:
+			matched = matched[:1]
+		case multipleRecordsAll:
+			return updateAllMatches(ctx, p, matched, spec)
+		default:
+			return "", "", &apiError{message: fmt.Sprintf("found %d %s records for %s.%s (RecordIds %s), set extraValues to manage all values, set multipleRecords or remove the extra records", len(matched), spec.Type, spec.RR, spec.DomainName, recordIDs(matched))}
+		}
+	}
+
+	if len(matched) == 1 {
+		return syncExistingRecord(ctx, p, matched[0], spec, spec.Value)
+	}
+
+	// 如果未找到记录，按配置添加新的 DNS 记录
+	if !spec.AutoCreate {
+		return "", "", ErrRecordNotFound
+	}
+
+	recordID, err := p.createRecord(ctx, spec.DomainName, dnsRecord{RR: spec.RR, Type: spec.Type, Value: spec.Value, TTL: spec.TTL, Proxied: spec.Proxied, Line: spec.Line})
+	if err != nil {
+		return "", "", err
+	}
+	return recordID, "", nil
+}
+
+// 把一条已有的记录同步为 value，返回记录的 ID 以及更新前的值。值、TTL 和代理状态都不需要修改时返回 ErrNoUpdateNeeded
+func syncExistingRecord(ctx context.Context, p dnsProvider, record dnsRecord, spec recordSpec, value string) (string, string, error) {
+	// 直接修改已暂停的记录时服务商的行为不确定，按配置跳过或先启用
+	if record.Disabled {
+		if !spec.Enable {
+			return record.ID, record.Value, ErrRecordDisabled
+		}
+		setter, ok := p.(recordStatusSetter)
+		if !ok {
+			return record.ID, record.Value, fmt.Errorf("DNS provider cannot enable disabled record %s.%s", spec.RR, spec.DomainName)
+		}
+		if err := setter.setRecordEnabled(ctx, spec.DomainName, record, true); err != nil {
+			return record.ID, record.Value, err
+		}
+		// 记录值不变时只需要启用
+		if record.Value == value && (spec.TTL == 0 || record.TTL == spec.TTL) && !proxiedChanged(record.Proxied, spec.Proxied) {
+			return record.ID, record.Value, nil
+		}
+	}
+
+	// 只有当当前IP和记录IP（或配置的 TTL）不一样时才执行更新操作，强制更新时除外
+	if !spec.Force && record.Value == value && (spec.TTL == 0 || record.TTL == spec.TTL) && !proxiedChanged(record.Proxied, spec.Proxied) {
+		return record.ID, record.Value, ErrNoUpdateNeeded
+	}
+
+	// 找到需要更新的记录，执行更新操作
+	updated := record
+	updated.Value = value
+	if spec.TTL > 0 {
+		updated.TTL = spec.TTL
+	}
+	updated.Proxied = spec.Proxied
+	err := p.updateRecord(ctx, spec.DomainName, updated)
+	return record.ID, record.Value, err
+}
+
+// multipleRecords 为 all 时把匹配的每条记录都改为动态的值，返回第一条记录的 ID 以及原来的值。
+// 已暂停且未开启 enable 的记录跳过，其余记录都不需要修改时返回 ErrRecordDisabled
+func updateAllMatches(ctx context.Context, p dnsProvider, matched []dnsRecord, spec recordSpec) (string, string, error) {
+	changed, skipped := false, false
+	for _, record := range matched {
+		_, _, err := syncExistingRecord(ctx, p, record, spec, spec.Value)
+		switch err {
+		case nil:
+			changed = true
+		case ErrNoUpdateNeeded:
+		case ErrRecordDisabled:
+			skipped = true
+		default:
+			return matched[0].ID, matched[0].Value, err
+		}
+	}
+	switch {
+	case changed:
+		return matched[0].ID, matched[0].Value, nil
+	case skipped:
+		return matched[0].ID, matched[0].Value, ErrRecordDisabled
+	default:
+		return matched[0].ID, matched[0].Value, ErrNoUpdateNeeded
+	}
+}
+
+// 同步主机记录的全部值（动态的值加上 extraValues）：值已经正确的记录保留，其余的记录依次改为缺少的值，
+// 仍有多出的记录时删除，仍缺少的值新建。新建放在最后，避免中途失败重试时重复添加。
+// 返回动态的值所在记录的 ID 以及该记录原来的值，全部记录都不需要修改时返回 ErrNoUpdateNeeded
+func updateValueSet(ctx context.Context, p dnsProvider, records []dnsRecord, spec recordSpec) (string, string, error) {
+	want := spec.values()
+	kept := make(map[string]dnsRecord)
+	var surplus []dnsRecord
+	for _, record := range records {
+		if !spec.matches(record) {
+			continue
+		}
+		if record.Disabled && !spec.Enable {
+			return record.ID, record.Value, ErrRecordDisabled
+		}
+		if _, duplicate := kept[record.Value]; !duplicate && slices.Contains(want, record.Value) {
+			kept[record.Value] = record
+		} else {
+			surplus = append(surplus, record)
+		}
+	}
+
+	var recordID, oldValue string
+	var missing []string
+	changed := false
+	for _, value := range want {
+		record, ok := kept[value]
+		if !ok && len(surplus) == 0 {
+			missing = append(missing, value)
+			continue
+		}
+		if !ok {
+			record, surplus = surplus[0], surplus[1:]
+		}
+		id, old, err := syncExistingRecord(ctx, p, record, spec, value)
+		if err != nil && err != ErrNoUpdateNeeded {
+			return recordID, oldValue, err
+		}
+		changed = changed || err == nil
+		if value == spec.Value {
+			recordID, oldValue = id, old
+		}
+	}
+	if len(surplus) > 0 {
+		deleter, ok := p.(recordDeleter)
+		if !ok {
+			return recordID, oldValue, fmt.Errorf("DNS provider cannot delete the extra %s records of %s.%s (RecordIds %s)", spec.Type, spec.RR, spec.DomainName, recordIDs(surplus))
+		}
+		for _, record := range surplus {
+			if err := deleter.deleteRecord(ctx, spec.DomainName, record); err != nil {
+				return recordID, oldValue, err
+			}
+		}
+		changed = true
+	}
+	if len(missing) > 0 && !spec.AutoCreate {
+		return recordID, oldValue, ErrRecordNotFound
+	}
+	for _, value := range missing {
+		id, err := p.createRecord(ctx, spec.DomainName, dnsRecord{RR: spec.RR, Type: spec.Type, Value: value, TTL: spec.TTL, Proxied: spec.Proxied, Line: spec.Line})
+		if err != nil {
+			return recordID, oldValue, err
+		}
+		changed = true
+		if value == spec.Value {
+			recordID = id
+		}
+	}
+	if !changed {
+		return recordID, oldValue, ErrNoUpdateNeeded
+	}
+	return recordID, oldValue, nil
+}
+
+// 是否需要修改记录的代理状态，未配置时不修改
+func proxiedChanged(current, wanted *bool) bool {
+	return wanted != nil && (current == nil || *current != *wanted)
+}
+
+// 运行 DDNS，once 为 true 时只执行一次检测和更新，parent 取消后退出
+func runDDNS(parent context.Context, name string, args []string, once bool) {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	// 通过命令行参数指定配置文件路径，默认为当前目录下的 config.json
+	configFilePath := flags.String("config", "config.json", "Path to the configuration file")
+	configFormat := flags.String("format", "", "Configuration file format: json, yaml or toml (default: detected from the file extension)")
+	flags.BoolVar(&once, "once", once, "Run a single check and update, then exit with a code describing the result (0 on success)")
+	interval := flags.String("interval", "", "Polling interval such as 30s or 5m, overrides the configuration file")
+	setup := flags.Bool("setup", false, "Run the interactive setup wizard to create the configuration file")
+	service := flags.String("service", "", "Manage the Windows service: install, remove, start or stop")
+	force := flags.Bool("force", false, "Start even if another instance holds the pidFile lock")
+	forceUpdate := flags.Bool("force-update", false, "Overwrite every record with the detected IP on the first check, even if it already matches")
+	verbose := flags.Bool("v", false, "Enable debug logging, including raw IP API responses and Aliyun API traffic with secrets redacted")
+	showVersion := flags.Bool("version", false, "Print the version and build information, then exit")
+	flags.Parse(args)
+
+	if *showVersion {
+		fmt.Println(versionString())
+		os.Exit(0)
+	}
+
+	// 管理 Windows 服务，完成后退出
+	if *service != "" {
+		if err := controlService(*service, *configFilePath, *configFormat); err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to "+*service+" service:", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Service action %q completed.\n", *service)
+		os.Exit(0)
+	}
+
+	// 配置向导，完成后退出
+	if *setup {
+		if err := runSetup(*configFilePath, *configFormat); err != nil {
+			fmt.Fprintln(os.Stderr, "Setup failed:", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	// 检查配置文件是否存在，如果不存在则创建一个默认的配置。设置了 DDNS_ 环境变量时只通过环境变量配置，不创建文件
+	if _, err := os.Stat(*configFilePath); os.IsNotExist(err) && !envConfigured() {
+		saveDefaultConfig(*configFilePath, *configFormat)
+		fmt.Printf("Default configuration file '%s' created. Please edit it with your credentials and domain name, or run with -setup to use the setup wizard.\n", *configFilePath)
+		os.Exit(0)
+	}
+
+	// 从配置文件加载配置
+	config, err := loadConfig(*configFilePath, *configFormat)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to load configuration:", err)
+		os.Exit(exitConfig)
+	}
+	leases, err := config.loadCredentials(context.Background())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to load credentials:", err)
+		os.Exit(exitConfig)
+	}
+
+	// 检测间隔和日志级别，命令行参数优先于配置文件
+	if *interval != "" {
+		config.Interval, config.Schedule = *interval, ""
+	}
+	if *verbose {
+		config.LogLevel = "debug"
+	}
+
+	// 校验配置，列出全部有问题的字段后退出
+	if err := config.validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", *configFilePath, err)
+		os.Exit(exitConfig)
+	}
+
+	// 内存很小的路由器上限制堆的大小，超过上限前 GC 会更积极地回收
+	if config.MemoryLimit > 0 {
+		debug.SetMemoryLimit(int64(config.MemoryLimit) << 20)
+	}
+
+	// 单实例锁，防止多个实例同时更新同一个域名
+	releasePIDFile := func() {}
+	if config.PIDFile != "" {
+		releasePIDFile, err = acquirePIDFile(config.PIDFile, *force)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to start:", err)
+			os.Exit(1)
+		}
+	}
+	defer releasePIDFile()
+
+	// 打开日志文件或其它日志输出
+	output, err := openLogOutput(config)
+	if err != nil {
+		log.Fatal("Failed to open log output:", err)
+	}
+	defer output.Close()
+
+	fileLogger := output.logger
+	fileLogger.Info("Starting DDns_go", "version", version, "commit", commit, "build_date", buildDate)
+
+	// 加载上次推送的 IP 和 RecordId
+	state, err := loadState(config.StateFile)
+	if err != nil {
+		fatal(fileLogger, exitFailure, "Failed to load state file", "error", err)
+	}
+
+	// 公网 IP 变化和记录更新的历史
+	history, err := openHistoryStore(config, fileLogger)
+	if err != nil {
+		fatal(fileLogger, exitFailure, "Failed to open history file", "path", config.HistoryFile, "error", err)
+	}
+	status.persist(history)
+
+	u, err := newUpdater(config, state, fileLogger)
+	if err != nil {
+		fatal(fileLogger, startupExitCode(err), "Invalid configuration", "error", err)
+	}
+	u.leases = leases
+
+	// 收到 SIGINT 或 SIGTERM 时停止，正在进行的 API 调用完成后跳过剩余的记录
+	ctx, stop := signal.NotifyContext(parent, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	// 第一次收到信号后恢复默认的信号处理，再次收到信号时立即退出
+	context.AfterFunc(ctx, stop)
+
+	// 配置了 otel 时导出每轮检测的链路和指标
+	flushTelemetry, err := startTelemetry(ctx, config, fileLogger)
+	if err != nil {
+		fatal(fileLogger, exitConfig, "Invalid configuration", "error", err)
+	}
+
+	// 启动后的第一次检测忽略本地状态并重新提交全部记录，例如从备份恢复解析记录之后
+	u.force = config.ForceUpdateOnStart || *forceUpdate
+
+	// 单次模式：执行一次检测和更新后退出，方便配合 cron 或 systemd timer 使用
+	if once {
+		err := u.runCycle(ctx)
+		if err != nil {
+			fileLogger.Error("Single run failed", "exit_code", exitCode(err), "error", err)
+		}
+		u.notify.wait(notifyShutdownTimeout)
+		flushTelemetry(notifyShutdownTimeout)
+		output.Close()
+		releasePIDFile()
+		os.Exit(exitCode(err))
+	}
+	u.notify.emit(notifyEvent{Event: eventStartup})
+	startUpdateCheck(ctx, config, fileLogger)
+
+	// 在 Linux 上监听网络变化，PPPoE 重新拨号后立即检测，轮询作为兜底
+	var networkChanges <-chan struct{}
+	if config.WatchNetwork {
+		networkChanges, err = watchNetworkChanges(config.WatchInterface)
+		if err != nil {
+			fileLogger.Warn("Failed to watch network changes, falling back to polling", "error", err)
+		}
+	}
+
+	// 使用 kubernetes 获取方式时监听 Node 或 Service 的变化，外部地址变化后立即检测
+	var kubernetesChanges <-chan struct{}
+	if sources := config.kubernetesSources(); len(sources) > 0 {
+		kubernetesChanges = watchKubernetes(ctx, sources, fileLogger)
+	}
+
+	// 通过 REST API 或控制接口触发的立即检测，可以同时要求强制重新提交或确认频繁变化的公网 IP
+	trigger := make(chan updateTrigger, 1)
+
+	threshold, err := config.healthThreshold(u.period())
+	if err != nil {
+		fatal(fileLogger, exitConfig, "Invalid configuration", "error", err)
+	}
+	// Prometheus 指标、健康检查、REST API 和 Web 控制台
+	if config.MetricsListen != "" {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/metrics", metricsHandler)
+		mux.Handle("/healthz", healthHandler(threshold))
+		if config.APIToken != "" {
+			registerAPI(mux, config, trigger)
+			registerDashboard(mux)
+		}
+		if config.Debug {
+			registerDebug(mux, config.APIToken)
+		}
+		server, err := startHTTPServer(config.MetricsListen, mux, fileLogger)
+		if err != nil {
+			fatal(fileLogger, exitFailure, "Failed to start metrics listener", "listen", config.MetricsListen, "error", err)
+		}
+		defer stopHTTPServer(server)
+	}
+	// 供 status 子命令查询的控制接口
+	if config.ControlSocket != "" {
+		control, err := startControlServer(config, threshold, trigger, fileLogger)
+		if err != nil {
+			fatal(fileLogger, exitFailure, "Failed to start control socket", "address", config.ControlSocket, "error", err)
+		}
+		defer control.stop()
+	}
+	// 路由器推送的更新通过 pushes 交给主循环，与定时检测依次执行
+	pushes := make(chan dyndnsPush)
+	if config.DynDNS != nil {
+		server, err := startDynDNSServer(config, pushes, fileLogger)
+		if err != nil {
+			fatal(fileLogger, exitFailure, "Failed to start DynDNS server", "listen", config.DynDNS.Listen, "error", err)
+		}
+		defer stopHTTPServer(server)
+	}
+
+	// 在 systemd 下运行时，凭证检查通过后才通知启动完成
+	if os.Getenv("NOTIFY_SOCKET") != "" {
+		if err := u.checkCredentials(ctx); err != nil {
+			fatal(fileLogger, exitCode(err), "Failed to verify DNS provider credentials", "error", err)
+		}
+		if err := sdNotify("READY=1"); err != nil {
+			fileLogger.Warn("Failed to notify systemd", "error", err)
+		}
+	}
+
+	// 看门狗只在等待期间发送心跳，检测过程卡住时 systemd 会重启服务
+	var watchdog <-chan time.Time
+	if watchdogInterval := sdWatchdogInterval(); watchdogInterval > 0 {
+		ticker := time.NewTicker(watchdogInterval)
+		defer ticker.Stop()
+		watchdog = ticker.C
+	}
+
+	// 收到 SIGHUP 时重新加载配置
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	// 续期 Vault 租约，不能续期时同样通过 reload 重新加载配置，重新读取密钥
+	stopLeases := watchVaultLeases(ctx, u.leases, reload, fileLogger)
+	defer func() { stopLeases() }()
+
+	// 收到 SIGUSR1 时立即检测，例如 PPPoE 重新拨号后手动触发
+	manual := make(chan os.Signal, 1)
+	if len(triggerSignals) > 0 {
+		signal.Notify(manual, triggerSignals...)
+	}
+
+	// 关闭 runOnStart 时第一次检测在等待一个检测间隔之后进行，期间的触发和网络变化仍会提前检测
+	waitFirst := !config.runOnStart()
+	for {
+		if waitFirst {
+			waitFirst = false
+			u.reschedule(u.dueDomains(time.Now()), time.Now())
+			fileLogger.Info("Waiting one interval before the first check")
+		} else {
+			u.runCycle(ctx)
+			u.force = false
+		}
+		sdNotify("WATCHDOG=1")
+
+		// 等待到最早到期的域名的检测时间，期间网络发生变化时提前检测
+		next := u.nextCheck()
+		status.scheduled(next)
+		fileLogger.Debug("Next check scheduled", "at", next.Format(time.RFC3339))
+		timer := time.NewTimer(time.Until(next))
+		scheduled := false
+	wait:
+		for {
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				sdNotify("STOPPING=1")
+				fileLogger.Info("Received shutdown signal, exiting")
+				u.notify.wait(notifyShutdownTimeout)
+				flushTelemetry(notifyShutdownTimeout)
+				return
+			case <-watchdog:
+				sdNotify("WATCHDOG=1")
+			case request := <-trigger:
+				timer.Stop()
+				fileLogger.Info("Update triggered via API, checking public IP now", "force", request.force, "confirm", request.confirm)
+				u.force = u.force || request.force
+				if request.confirm {
+					u.confirmChurn()
+				}
+				break wait
+			case push := <-pushes:
+				// 推送只更新对应的记录，之后继续等待原定的下一次检测
+				push.reply <- u.applyPush(ctx, push)
+			case sig := <-manual:
+				timer.Stop()
+				fileLogger.Info("Update triggered by signal, checking public IP now", "signal", sig.String())
+				break wait
+			case <-timer.C:
+				scheduled = true
+				break wait
+			case <-reload:
+				timer.Stop()
+				sdNotify("RELOADING=1")
+				// 重新打开日志文件，logrotate 移走旧文件后继续写入新文件
+				if err := output.Reopen(); err != nil {
+					fmt.Fprintln(os.Stderr, "Failed to reopen log file:", err)
+				}
+				if reloaded, err := reloadUpdater(u, *configFilePath, *configFormat, *interval, *verbose); err != nil {
+					fileLogger.Error("Failed to reload configuration, keeping the current one", "error", err)
+				} else {
+					u = reloaded
+					stopLeases()
+					stopLeases = watchVaultLeases(ctx, u.leases, reload, fileLogger)
+				}
+				sdNotify("READY=1")
+				break wait
+			case <-kubernetesChanges:
+				timer.Stop()
+				fileLogger.Info("Kubernetes address change detected, checking public IP now")
+				break wait
+			case <-networkChanges:
+				timer.Stop()
+				// 等待地址和路由配置完成，并合并这段时间内的多次变化
+				time.Sleep(networkSettleDelay)
+				select {
+				case <-networkChanges:
+				default:
+				}
+				fileLogger.Info("Network change detected, checking public IP now")
+				break wait
+			}
+		}
+		// 到达检测时间时只同步到期的域名，其余情况提前检测全部域名
+		if !scheduled {
+			u.dueNow()
+		}
+	}
+}
+
+// 重新加载配置文件，校验通过后返回新的 updater，失败时保留当前配置
+func reloadUpdater(current *updater, configFilePath, configFormat, interval string, verbose bool) (*updater, error) {
+	config, err := loadConfig(configFilePath, configFormat)
+	if err != nil {
+		return nil, err
+	}
+	leases, err := config.loadCredentials(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	if interval != "" {
+		config.Interval, config.Schedule = interval, ""
+	}
+	if verbose {
+		config.LogLevel = "debug"
+	}
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+
+	if config.StateFile != current.config.StateFile {
+		return nil, fmt.Errorf("changing stateFile requires a restart")
+	}
+	if config.HistoryFile != current.config.HistoryFile || config.HistoryRetention != current.config.HistoryRetention {
+		return nil, fmt.Errorf("changing historyFile or historyRetention requires a restart")
+	}
+	reloaded, err := newUpdater(config, current.state, current.logger)
+	if err != nil {
+		return nil, err
+	}
+	reloaded.notify.inherit(current.notify)
+	reloaded.seenIPs = current.seenIPs
+	reloaded.pendingIPs = current.pendingIPs
+	reloaded.behindCGNAT = current.behindCGNAT
+	reloaded.churn = current.churn
+	reloaded.leases = leases
+
+	changes := configChanges(current.config, config)
+	if len(changes) == 0 {
+		current.logger.Info("Configuration reloaded, nothing changed")
+	} else {
+		current.logger.Info("Configuration reloaded", "changes", strings.Join(changes, ", "))
+	}
+	return reloaded, nil
+}
+
+// 读取 AccessKey 的环境变量，按顺序使用第一个非空的值
+var (
+	accessKeyEnvs    = []string{"DDNS_ACCESS_KEY_ID", "ALICLOUD_ACCESS_KEY_ID"}
+	accessSecretEnvs = []string{"DDNS_ACCESS_KEY_SECRET", "ALICLOUD_ACCESS_KEY_SECRET"}
+)
+
+// 使用环境变量中的 AccessKey 覆盖配置文件中的值，方便在容器中运行时不把密钥写入文件
+func (c *Config) applyEnvCredentials() {
+	if value := firstEnv(accessKeyEnvs); value != "" {
+		c.AccessKey = value
+	}
+	if value := firstEnv(accessSecretEnvs); value != "" {
+		c.AccessSecret = value
+	}
+}
+
+// 返回第一个非空的环境变量
+func firstEnv(names []string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// 最小检测间隔，避免过于频繁地调用 API
+const minInterval = 10 * time.Second
+
+// 返回强制核对间隔，IP 未变化时超过该时间也会重新查询解析记录
+func (c Config) verifyInterval() (time.Duration, error) {
+	if c.VerifyInterval == "" {
+		return defaultVerifyInterval, nil
+	}
+	duration, err := time.ParseDuration(c.VerifyInterval)
+	if err != nil {
+		return 0, fmt.Errorf("invalid verifyInterval %q: %v", c.VerifyInterval, err)
+	}
+	return duration, nil
+}
+
+// 返回同一条记录两次修改之间的最短间隔，未设置时为 0
+func (c Config) cooldown() (time.Duration, error) {
+	if c.Cooldown == "" {
+		return 0, nil
+	}
+	duration, err := time.ParseDuration(c.Cooldown)
+	if err != nil || duration < 0 {
+		return 0, fmt.Errorf("invalid cooldown %q", c.Cooldown)
+	}
+	return duration, nil
+}
+
+// 检测到网络变化后等待的时间，PPPoE 重新拨号时地址和路由会连续变化多次
+const networkSettleDelay = 2 * time.Second
+
+// 返回检测间隔，interval 优先于 delay/timeUnit
+func (c Config) pollInterval() (time.Duration, error) {
+	var duration time.Duration
+	if c.Interval != "" {
+		parsed, err := time.ParseDuration(c.Interval)
+		if err != nil {
+			return 0, fmt.Errorf("invalid interval %q: %v", c.Interval, err)
+		}
+		duration = parsed
+	} else {
+		parsed, err := getSleepDuration(c.Delay, c.TimeUnit)
+		if err != nil {
+			return 0, err
+		}
+		duration = parsed
+	}
+
+	if duration < minInterval {
+		return 0, fmt.Errorf("interval %s is shorter than the minimum %s", duration, minInterval)
+	}
+	return duration, nil
+}
+
+// 获取延迟的时间
+func getSleepDuration(delay int, timeUnit string) (time.Duration, error) {
+	switch timeUnit {
+	case "second":
+		return time.Duration(delay) * time.Second, nil
+	case "minute":
+		return time.Duration(delay) * time.Minute, nil
+	case "hour":
+		return time.Duration(delay) * time.Hour, nil
+	default:
+		return 0, errors.New("unknown time unit")
+	}
+}
@@ -0,0 +1,90 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+)
+
+// 调试日志中单个响应最多显示的字节数
+const debugBodyLimit = 4096
+
+// 请求地址中需要隐藏的参数，阿里云 API 的签名参数放在查询字符串里
+var redactedQueryParams = []string{"AccessKeyId", "Signature", "SecurityToken"}
+
+// 在 debug 级别记录每个 HTTP 请求和响应内容的 RoundTripper
+type debugTransport struct {
+	next   http.RoundTripper
+	logger *slog.Logger
+}
+
+// 是否开启了 debug 级别的日志
+func debugEnabled(logger *slog.Logger) bool {
+	return logger != nil && logger.Enabled(context.Background(), slog.LevelDebug)
+}
+
+// 开启 debug 级别时包装 Transport，否则原样返回
+func withDebugTransport(next http.RoundTripper, logger *slog.Logger) http.RoundTripper {
+	if !debugEnabled(logger) {
+		return next
+	}
+	return &debugTransport{next: next, logger: logger}
+}
+
+func (t *debugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	target := redactURL(req.URL)
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		t.logger.Debug("HTTP request failed", "method", req.Method, "url", target, "error", err)
+		return nil, err
+	}
+
+	// 读取响应内容后放回，调用方仍可以正常读取
+	body, err := io.ReadAll(io.LimitReader(resp.Body, debugBodyLimit))
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+	if err != nil {
+		t.logger.Debug("Failed to read HTTP response", "method", req.Method, "url", target, "error", err)
+		return resp, nil
+	}
+
+	// 阿里云 API 的响应中包含 RequestId，提交工单时需要提供
+	var aliyun struct {
+		RequestId string
+	}
+	json.Unmarshal(body, &aliyun)
+
+	args := []any{"method", req.Method, "url", target, "status", resp.StatusCode}
+	if aliyun.RequestId != "" {
+		args = append(args, "request_id", aliyun.RequestId)
+	}
+	args = append(args, "body", string(body))
+	t.logger.Debug("HTTP response", args...)
+	return resp, nil
+}
+
+// 隐藏地址中的 AccessKeyId、签名等参数以及用户名密码
+func redactURL(u *url.URL) string {
+	redacted := *u
+	if redacted.User != nil {
+		redacted.User = url.User("REDACTED")
+	}
+	query := redacted.Query()
+	changed := false
+	for _, name := range redactedQueryParams {
+		if query.Has(name) {
+			query.Set(name, "REDACTED")
+			changed = true
+		}
+	}
+	if changed {
+		redacted.RawQuery = query.Encode()
+	}
+	return redacted.String()
+}
@@ -0,0 +1,79 @@
+package updater
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// 钉钉群机器人，开启“加签”安全设置时需要填写 secret
+type DingTalkConfig struct {
+	Webhook string `json:"webhook"`          // https://oapi.dingtalk.com/robot/send?access_token=...
+	Secret  string `json:"secret,omitempty"` // 加签密钥，以 SEC 开头
+	ChatConfig
+}
+
+type dingTalkNotifier struct {
+	config  DingTalkConfig
+	message *chatMessage
+}
+
+func (n *dingTalkNotifier) send(ctx context.Context, client *http.Client, event notifyEvent) error {
+	title, text, err := n.message.render(event)
+	if err != nil {
+		return err
+	}
+	payload := map[string]interface{}{
+		"msgtype": "markdown",
+		"markdown": map[string]string{
+			"title": title,
+			"text":  "### " + title + "\n\n" + text,
+		},
+	}
+
+	webhook, err := n.signedURL(time.Now())
+	if err != nil {
+		return err
+	}
+	data, err := postNotifyJSON(ctx, client, webhook, payload)
+	if err != nil {
+		return err
+	}
+	return checkRobotResponse(data)
+}
+
+// 加签：把毫秒时间戳和 HMAC-SHA256 签名附加到地址中
+func (n *dingTalkNotifier) signedURL(now time.Time) (string, error) {
+	if n.config.Secret == "" {
+		return n.config.Webhook, nil
+	}
+	webhook, err := url.Parse(n.config.Webhook)
+	if err != nil {
+		return "", err
+	}
+	timestamp := strconv.FormatInt(now.UnixMilli(), 10)
+	query := webhook.Query()
+	query.Set("timestamp", timestamp)
+	query.Set("sign", hmacSHA256Base64(n.config.Secret, timestamp+"\n"+n.config.Secret))
+	webhook.RawQuery = query.Encode()
+	return webhook.String(), nil
+}
+
+// 钉钉和企业微信机器人出错时仍返回 200，错误码在响应内容中
+func checkRobotResponse(data []byte) error {
+	var result struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return fmt.Errorf("invalid response %q: %v", data, err)
+	}
+	if result.ErrCode != 0 {
+		return &apiError{message: fmt.Sprintf("errcode %d: %s", result.ErrCode, result.ErrMsg)}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package updater
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Discord 频道 Webhook
+type DiscordConfig struct {
+	Webhook string `json:"webhook"` // https://discord.com/api/webhooks/...
+	ChatConfig
+}
+
+type discordNotifier struct {
+	config  DiscordConfig
+	message *chatMessage
+}
+
+// Embed 左侧的颜色
+var discordEmbedColors = map[string]int{
+	eventIPChanged:    0x2ecc71,
+	eventUpdateFailed: 0xe74c3c,
+	eventRecovered:    0x1abc9c,
+	eventStartup:      0x3498db,
+	eventThrottled:    0xf39c12,
+	eventCGNAT:        0xf1c40f,
+	eventIPChurn:      0xe67e22,
+}
+
+func (n *discordNotifier) send(ctx context.Context, client *http.Client, event notifyEvent) error {
+	title, text, err := n.message.render(event)
+	if err != nil {
+		return err
+	}
+	payload := map[string]interface{}{
+		"embeds": []map[string]interface{}{{
+			"title":       title,
+			"description": text,
+			"color":       discordEmbedColors[event.Event],
+			"timestamp":   event.Time.Format(time.RFC3339),
+		}},
+	}
+	_, err = postNotifyJSON(ctx, client, n.config.Webhook, payload)
+	return err
+}
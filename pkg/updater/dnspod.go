@@ -0,0 +1,346 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DNSPod（腾讯云）配置，使用腾讯云 API 密钥
+type DNSPodConfig struct {
+	SecretID  string `json:"secretId"`
+	SecretKey string `json:"secretKey"`
+	Line      string `json:"line,omitempty"` // 默认的记录线路，未设置时为「默认」
+}
+
+// DNSPod API 地址和版本
+const (
+	dnspodAPIURL  = "https://dnspod.tencentcloudapi.com"
+	dnspodService = "dnspod"
+	dnspodVersion = "2021-03-23"
+)
+
+// 每次获取的解析记录数，DNSPod 允许的最大值为 3000
+const dnspodPageSize = 3000
+
+// 未指定线路时使用的记录线路
+const dnspodDefaultLine = "默认"
+
+type dnspodProvider struct {
+	secretID  string
+	secretKey string
+	client    *http.Client
+	baseURL   string
+}
+
+func newDNSPodProvider(config Config, logger *slog.Logger) (*dnspodProvider, error) {
+	settings, err := config.httpSettings()
+	if err != nil {
+		return nil, err
+	}
+	// 与阿里云 API 使用相同的代理
+	client := &http.Client{
+		Timeout:   settings.timeout,
+		Transport: withDebugTransport(settings.newTransport("", settings.aliyunProxy), logger),
+	}
+	return &dnspodProvider{
+		secretID:  config.DNSPod.SecretID,
+		secretKey: config.DNSPod.SecretKey,
+		client:    client,
+		baseURL:   dnspodAPIURL,
+	}, nil
+}
+
+// DNSPod 返回的解析记录
+type dnspodRecord struct {
+	RecordID uint64 `json:"RecordId"`
+	Name     string `json:"Name"`
+	Type     string `json:"Type"`
+	Value    string `json:"Value"`
+	TTL      int    `json:"TTL"`
+	Line     string `json:"Line"`
+	Status   string `json:"Status"` // ENABLE 或 DISABLE
+	Remark   string `json:"Remark"`
+}
+
+// 域名下没有任何记录时 DNSPod 返回错误而不是空列表
+var errDNSPodNoRecords = errors.New("no DNSPod records found")
+
+// DNSPod API 返回的错误
+type dnspodError struct {
+	Code    string `json:"Code"`
+	Message string `json:"Message"`
+}
+
+// 调用 DNSPod API，请求使用 TC3-HMAC-SHA256 签名，解析 Response 到 result 中
+func (p *dnspodProvider) call(ctx context.Context, action string, params, result interface{}) error {
+	payload, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	endpoint, err := url.Parse(p.baseURL)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("X-TC-Action", action)
+	req.Header.Set("X-TC-Version", dnspodVersion)
+	req.Header.Set("X-TC-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("Authorization", p.authorization(endpoint.Host, payload, timestamp))
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	var response struct {
+		Response struct {
+			RequestID string       `json:"RequestId"`
+			Error     *dnspodError `json:"Error"`
+		} `json:"Response"`
+	}
+	if err := json.Unmarshal(data, &response); err != nil {
+		return fmt.Errorf("invalid DNSPod response (%s): %v", resp.Status, err)
+	}
+	noteRequestID(ctx, response.Response.RequestID)
+	if e := response.Response.Error; e != nil {
+		if e.Code == "ResourceNotFound.NoDataOfRecord" {
+			return errDNSPodNoRecords
+		}
+		return &apiError{
+			message:   fmt.Sprintf("DNSPod API %s failed: %s: %s", action, e.Code, e.Message),
+			retryable: strings.HasPrefix(e.Code, "InternalError") || strings.HasPrefix(e.Code, "RequestLimitExceeded"),
+			throttled: strings.HasPrefix(e.Code, "RequestLimitExceeded"),
+			auth:      strings.HasPrefix(e.Code, "AuthFailure") || strings.HasPrefix(e.Code, "UnauthorizedOperation"),
+		}
+	}
+	if resp.StatusCode >= 300 {
+		return &apiError{
+			message:   fmt.Sprintf("DNSPod API %s failed with %s", action, resp.Status),
+			retryable: resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests,
+			throttled: resp.StatusCode == http.StatusTooManyRequests,
+			auth:      resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden,
+		}
+	}
+	if result != nil {
+		var wrapper struct {
+			Response json.RawMessage `json:"Response"`
+		}
+		if err := json.Unmarshal(data, &wrapper); err != nil {
+			return err
+		}
+		if err := json.Unmarshal(wrapper.Response, result); err != nil {
+			return fmt.Errorf("invalid DNSPod response: %v", err)
+		}
+	}
+	return nil
+}
+
+// 计算 TC3-HMAC-SHA256 签名的 Authorization 头
+func (p *dnspodProvider) authorization(host string, payload []byte, timestamp int64) string {
+	const signedHeaders = "content-type;host"
+	canonicalRequest := strings.Join([]string{
+		http.MethodPost,
+		"/",
+		"",
+		"content-type:application/json; charset=utf-8\nhost:" + host + "\n",
+		signedHeaders,
+		sha256Hex(payload),
+	}, "\n")
+
+	date := time.Unix(timestamp, 0).UTC().Format("2006-01-02")
+	scope := date + "/" + dnspodService + "/tc3_request"
+	stringToSign := strings.Join([]string{
+		"TC3-HMAC-SHA256",
+		strconv.FormatInt(timestamp, 10),
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("TC3"+p.secretKey), date)
+	key = hmacSHA256(key, dnspodService)
+	key = hmacSHA256(key, "tc3_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	return fmt.Sprintf("TC3-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", p.secretID, scope, signedHeaders, signature)
+}
+
+func (p *dnspodProvider) listRecords(ctx context.Context, domainName string) ([]dnsRecord, error) {
+	var all []dnsRecord
+	for offset := 0; ; offset += dnspodPageSize {
+		var response struct {
+			RecordCountInfo struct {
+				TotalCount int `json:"TotalCount"`
+			} `json:"RecordCountInfo"`
+			RecordList []dnspodRecord `json:"RecordList"`
+		}
+		params := map[string]interface{}{"Domain": domainName, "Offset": offset, "Limit": dnspodPageSize}
+		err := p.call(ctx, "DescribeRecordList", params, &response)
+		if errors.Is(err, errDNSPodNoRecords) {
+			return all, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range response.RecordList {
+			all = append(all, dnsRecord{
+				ID:       strconv.FormatUint(record.RecordID, 10),
+				RR:       record.Name,
+				Type:     record.Type,
+				Value:    record.Value,
+				TTL:      record.TTL,
+				Line:     record.Line,
+				Disabled: record.Status == "DISABLE",
+				Remark:   record.Remark,
+			})
+		}
+		if len(response.RecordList) == 0 || len(all) >= response.RecordCountInfo.TotalCount {
+			return all, nil
+		}
+	}
+}
+
+func (p *dnspodProvider) listZones(ctx context.Context) ([]string, error) {
+	var all []string
+	for offset := 0; ; offset += dnspodPageSize {
+		var response struct {
+			DomainCountInfo struct {
+				DomainTotal int `json:"DomainTotal"`
+			} `json:"DomainCountInfo"`
+			DomainList []struct {
+				Name string `json:"Name"`
+			} `json:"DomainList"`
+		}
+		params := map[string]interface{}{"Offset": offset, "Limit": dnspodPageSize}
+		if err := p.call(ctx, "DescribeDomainList", params, &response); err != nil {
+			return nil, err
+		}
+		for _, domain := range response.DomainList {
+			all = append(all, domain.Name)
+		}
+		if len(response.DomainList) == 0 || len(all) >= response.DomainCountInfo.DomainTotal {
+			return all, nil
+		}
+	}
+}
+
+// 新建和修改记录共用的参数
+func dnspodRecordParams(domainName string, record dnsRecord) map[string]interface{} {
+	line := record.Line
+	if line == "" {
+		line = dnspodDefaultLine
+	}
+	params := map[string]interface{}{
+		"Domain":     domainName,
+		"SubDomain":  record.RR,
+		"RecordType": record.Type,
+		"RecordLine": line,
+		"Value":      record.Value,
+	}
+	if record.TTL > 0 {
+		params["TTL"] = record.TTL
+	}
+	return params
+}
+
+func (p *dnspodProvider) createRecord(ctx context.Context, domainName string, record dnsRecord) (string, error) {
+	var response struct {
+		RecordID uint64 `json:"RecordId"`
+	}
+	if err := p.call(ctx, "CreateRecord", dnspodRecordParams(domainName, record), &response); err != nil {
+		return "", err
+	}
+	return strconv.FormatUint(response.RecordID, 10), nil
+}
+
+func (p *dnspodProvider) updateRecord(ctx context.Context, domainName string, record dnsRecord) error {
+	id, err := strconv.ParseUint(record.ID, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid DNSPod record id %q", record.ID)
+	}
+	params := dnspodRecordParams(domainName, record)
+	params["RecordId"] = id
+	return p.call(ctx, "ModifyRecord", params, nil)
+}
+
+func (p *dnspodProvider) setRecordEnabled(ctx context.Context, domainName string, record dnsRecord, enabled bool) error {
+	id, err := strconv.ParseUint(record.ID, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid DNSPod record id %q", record.ID)
+	}
+	params := map[string]interface{}{"Domain": domainName, "RecordId": id, "Status": "DISABLE"}
+	if enabled {
+		params["Status"] = "ENABLE"
+	}
+	return p.call(ctx, "ModifyRecordStatus", params, nil)
+}
+
+func (p *dnspodProvider) deleteRecord(ctx context.Context, domainName string, record dnsRecord) error {
+	id, err := strconv.ParseUint(record.ID, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid DNSPod record id %q", record.ID)
+	}
+	return p.call(ctx, "DeleteRecord", map[string]interface{}{"Domain": domainName, "RecordId": id}, nil)
+}
+
+func (p *dnspodProvider) setRemark(ctx context.Context, domainName string, record dnsRecord, remark string) error {
+	id, err := strconv.ParseUint(record.ID, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid DNSPod record id %q", record.ID)
+	}
+	params := map[string]interface{}{"Domain": domainName, "RecordId": id, "Remark": remark}
+	return p.call(ctx, "ModifyRecordRemark", params, nil)
+}
+
+// 从域名权限列表中读取允许的最小 TTL，未找到时返回 0
+func (p *dnspodProvider) minTTL(ctx context.Context, domainName string) (int, error) {
+	var response struct {
+		PurviewList []struct {
+			Name  string `json:"Name"`
+			Value string `json:"Value"`
+		} `json:"PurviewList"`
+	}
+	if err := p.call(ctx, "DescribeDomainPurview", map[string]interface{}{"Domain": domainName}, &response); err != nil {
+		return 0, err
+	}
+	for _, purview := range response.PurviewList {
+		if strings.Contains(strings.ToUpper(purview.Name), "TTL") {
+			if ttl, err := strconv.Atoi(strings.TrimSpace(purview.Value)); err == nil {
+				return ttl, nil
+			}
+		}
+	}
+	return 0, nil
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, message string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(message))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,282 @@
+package updater
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"DDns_go/pkg/ipdetect"
+)
+
+// DynDNS2 兼容的更新接口，OpenWrt、华硕等路由器可以在 WAN 口地址变化时直接推送，不需要等待下一次检测
+type DynDNSConfig struct {
+	Listen   string `json:"listen"`             // 监听地址，例如 :8245
+	Username string `json:"username"`           // 路由器中填写的用户名
+	Password string `json:"password"`           // 路由器中填写的密码
+	CertFile string `json:"certFile,omitempty"` // 证书文件，与 keyFile 同时设置时使用 HTTPS
+	KeyFile  string `json:"keyFile,omitempty"`  // 私钥文件
+}
+
+// 一次请求最多更新的主机名数，与 DynDNS2 的限制相同，超过时返回 numhost
+const dyndnsMaxHostnames = 20
+
+// 等待主循环处理推送的最长时间，主循环正在执行检测时需要等待本轮结束
+const dyndnsPushTimeout = 2 * time.Minute
+
+// 路由器推送的一次更新，由主循环处理，与定时检测不会同时进行
+type dyndnsPush struct {
+	hostnames []string
+	ips       map[string]string // 按协议族索引的地址
+	reply     chan []string     // 每个主机名的响应，例如 "good 203.0.113.7"
+}
+
+// 在 dyndns.listen 上提供 /nic/update，收到的更新交给 pushes 由主循环处理
+func startDynDNSServer(config Config, pushes chan<- dyndnsPush, logger *slog.Logger) (*http.Server, error) {
+	dyndns := config.DynDNS
+	listener, err := net.Listen("tcp", dyndns.Listen)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/nic/update", dyndnsHandler(*dyndns, config.AllowPrivateIP, pushes, logger))
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		var err error
+		if dyndns.CertFile != "" {
+			err = server.ServeTLS(listener, dyndns.CertFile, dyndns.KeyFile)
+		} else {
+			err = server.Serve(listener)
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("DynDNS server stopped", "listen", dyndns.Listen, "error", err)
+		}
+	}()
+	logger.Info("DynDNS server listening", "listen", listener.Addr().String(), "tls", dyndns.CertFile != "")
+	return server, nil
+}
+
+// 处理 /nic/update?hostname=a.example.com,b.example.com&myip=203.0.113.7，响应为每个主机名一行的 DynDNS2 结果。
+// 未提供 myip（或 myipv6）时使用请求的来源地址
+func dyndnsHandler(config DynDNSConfig, allowPrivate bool, pushes chan<- dyndnsPush, logger *slog.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		username, password, ok := r.BasicAuth()
+		if !ok || subtle.ConstantTimeCompare([]byte(username), []byte(config.Username)) != 1 || subtle.ConstantTimeCompare([]byte(password), []byte(config.Password)) != 1 {
+			if ok {
+				logger.Warn("DynDNS authentication failed", "remote", r.RemoteAddr, "username", username)
+			}
+			w.Header().Set("WWW-Authenticate", `Basic realm="DDns_go"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprintln(w, "badauth")
+			return
+		}
+
+		query := r.URL.Query()
+		var hostnames []string
+		for _, hostname := range strings.Split(query.Get("hostname"), ",") {
+			if hostname = strings.TrimSuffix(strings.TrimSpace(hostname), "."); hostname != "" {
+				hostnames = append(hostnames, hostname)
+			}
+		}
+		if len(hostnames) == 0 {
+			fmt.Fprintln(w, "notfqdn")
+			return
+		}
+		if len(hostnames) > dyndnsMaxHostnames {
+			fmt.Fprintln(w, "numhost")
+			return
+		}
+
+		ips, err := dyndnsAddresses(query.Get("myip"), query.Get("myipv6"), r.RemoteAddr, allowPrivate)
+		if err != nil {
+			logger.Warn("DynDNS update rejected", "remote", r.RemoteAddr, "hostname", strings.Join(hostnames, ","), "error", err)
+			writeDynDNSAnswers(w, hostnames, "dnserr")
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), dyndnsPushTimeout)
+		defer cancel()
+		push := dyndnsPush{hostnames: hostnames, ips: ips, reply: make(chan []string, 1)}
+		select {
+		case pushes <- push:
+		case <-ctx.Done():
+			writeDynDNSAnswers(w, hostnames, "911")
+			return
+		}
+		select {
+		case answers := <-push.reply:
+			fmt.Fprintln(w, strings.Join(answers, "\n"))
+		case <-ctx.Done():
+			writeDynDNSAnswers(w, hostnames, "911")
+		}
+	})
+}
+
+// 每个主机名返回相同的结果
+func writeDynDNSAnswers(w http.ResponseWriter, hostnames []string, answer string) {
+	for range hostnames {
+		fmt.Fprintln(w, answer)
+	}
+}
+
+// 解析 myip 和 myipv6，myip 可以是逗号分隔的 IPv4 和 IPv6 地址。地址需要通过与检测到的公网 IP 相同的检查
+func dyndnsAddresses(myip, myipv6, remoteAddr string, allowPrivate bool) (map[string]string, error) {
+	var values []string
+	for _, value := range strings.Split(myip+","+myipv6, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	if len(values) == 0 {
+		host, _, err := net.SplitHostPort(remoteAddr)
+		if err != nil {
+			return nil, err
+		}
+		values = []string{host}
+	}
+
+	ips := make(map[string]string)
+	for _, value := range values {
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid ip %q", value)
+		}
+		family := "IPv6"
+		if ip.To4() != nil {
+			family = "IPv4"
+		}
+		if err := ipdetect.CheckPublic(ip.String(), allowPrivate); err != nil {
+			return nil, err
+		}
+		if _, duplicate := ips[family]; duplicate {
+			return nil, fmt.Errorf("more than one %s address", family)
+		}
+		ips[family] = ip.String()
+	}
+	return ips, nil
+}
+
+// 按推送的地址更新主机名对应的记录，返回每个主机名的 DynDNS2 结果：
+// good（已更新）、nochg（无需更新）、nohost（不是配置中的记录，或者没有与地址协议族对应的记录类型）、dnserr（更新失败）或 911（正在退出、被限流或因 IP 频繁变化暂停而跳过）
+func (u *updater) applyPush(ctx context.Context, push dyndnsPush) []string {
+	ctx, pushSpan := startSpan(ctx, "ddns.push", "ddns.hostnames", strings.Join(push.hostnames, ","))
+	defer pushSpan.end(nil)
+	for family, ip := range push.ips {
+		status.publicIP(family, ip, "dyndns", nil)
+		status.ipUsed(family, ip, "")
+		metrics.publicIP(family, ip)
+	}
+
+	// 推送的地址同样用于绑定了线路的记录，由路由器决定每个主机名的地址
+	ips := make(map[string]string, len(push.ips))
+	for family, ip := range push.ips {
+		ips[family] = ip
+	}
+	for _, family := range u.families {
+		base, _, _ := strings.Cut(family.name, "/")
+		if ip, pushed := push.ips[base]; pushed && family.uplink != "" {
+			ips[family.name] = ip
+		}
+	}
+	// 与定时检测相同，因 IP 频繁变化暂停的协议族在确认之前不更新
+	paused := false
+	for _, family := range u.families {
+		if _, pushed := ips[family.name]; pushed && u.churnPaused(family.name) {
+			u.logger.Warn("DynDNS update skipped because the public IP changes too frequently, confirm with POST /update?confirm=true or DDns_go update -confirm", "family", family.name)
+			delete(ips, family.name)
+			paused = true
+		}
+	}
+
+	// 只查询包含这些主机名的域名
+	matches := func(domain DomainConfig, rr, hostname string) bool {
+		return strings.EqualFold(fullRecordName(rr, domain.DomainName), hostname)
+	}
+	described := make(map[int][]recordResult)
+	tasks := make(map[int][]recordTask)
+	for i, domain := range u.domains {
+		for _, hostname := range push.hostnames {
+			for _, rr := range domain.RRs {
+				if _, done := described[i]; !done && matches(domain, rr, hostname) {
+					_, tasks[i], described[i] = u.describeDomain(ctx, domain, ips)
+					if described[i] == nil {
+						described[i] = []recordResult{}
+					}
+				}
+			}
+		}
+	}
+
+	answers := make([]string, len(push.hostnames))
+	for h, hostname := range push.hostnames {
+		var results []recordResult
+		for i, domain := range u.domains {
+			for _, result := range described[i] {
+				// 没有推送对应协议族的地址的记录类型不计入结果
+				_, pushed := ips[u.config.domainFamily(domain, result.recordType).name]
+				if matches(domain, result.rr, hostname) && (pushed || result.result != resultSkipped) {
+					results = append(results, result)
+				}
+			}
+			for _, task := range tasks[i] {
+				if matches(domain, task.rr, hostname) {
+					results = append(results, u.syncRecord(ctx, task, ips))
+				}
+			}
+		}
+		answers[h] = dyndnsAnswer(results, push.ips)
+		// 只有暂停的协议族的记录时不是 nohost，而是让路由器稍后重试
+		if answers[h] == "nohost" && paused {
+			answers[h] = "911"
+		}
+		u.logger.Info("DynDNS update", "hostname", hostname, "ip", dyndnsIPs(push.ips), "result", answers[h])
+	}
+
+	if err := u.state.save(); err != nil {
+		u.logger.Error("Failed to save state file", "error", err)
+	}
+	return answers
+}
+
+// 一个主机名全部记录的结果对应的 DynDNS2 结果
+func dyndnsAnswer(results []recordResult, ips map[string]string) string {
+	// 记录类型都与推送的地址不匹配时 results 为空
+	answer := "nohost"
+	for _, result := range results {
+		switch result.result {
+		case resultFailed:
+			return "dnserr"
+		case resultUpdated:
+			answer = "good"
+		case resultUnchanged, resultCached:
+			if answer != "good" {
+				answer = "nochg"
+			}
+		default:
+			if answer == "nohost" {
+				answer = "911"
+			}
+		}
+	}
+	if answer == "good" || answer == "nochg" {
+		return answer + " " + dyndnsIPs(ips)
+	}
+	return answer
+}
+
+// 推送的地址，IPv4 在前，例如 "203.0.113.7,2001:db8::1"
+func dyndnsIPs(ips map[string]string) string {
+	var values []string
+	for _, family := range []string{"IPv4", "IPv6"} {
+		if ip, ok := ips[family]; ok {
+			values = append(values, ip)
+		}
+	}
+	return strings.Join(values, ",")
+}
@@ -0,0 +1,218 @@
+package updater
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	sdk "github.com/aliyun/alibaba-cloud-sdk-go/services/alidns"
+)
+
+// 在 httptest 上提供 /nic/update，推送与主循环相同由 u.applyPush 处理
+func newDynDNSTestServer(t *testing.T, u *updater) *httptest.Server {
+	t.Helper()
+	config := DynDNSConfig{Username: "router", Password: "testDynDNSPassword"}
+	pushes := make(chan dyndnsPush)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case push := <-pushes:
+				push.reply <- u.applyPush(ctx, push)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	server := httptest.NewServer(dyndnsHandler(config, false, pushes, slog.New(slog.NewTextHandler(io.Discard, nil))))
+	t.Cleanup(func() {
+		server.Close()
+		cancel()
+		<-done
+	})
+	return server
+}
+
+// 按路由器的方式请求 /nic/update，返回状态码和响应
+func dyndnsUpdate(t *testing.T, server *httptest.Server, username, password string, query url.Values) (int, string) {
+	t.Helper()
+	request, err := http.NewRequest(http.MethodGet, server.URL+"/nic/update?"+query.Encode(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if username != "" {
+		request.SetBasicAuth(username, password)
+	}
+	response, err := server.Client().Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response.StatusCode, strings.TrimSuffix(string(body), "\n")
+}
+
+func TestDynDNSHandler(t *testing.T) {
+	// 检测地址不会被请求，推送的地址直接用于更新
+	fake := newFakeAlidns(
+		sdk.Record{RecordId: "1", DomainName: "example.com", RR: "www", Type: "A", Value: "1.2.3.4", Line: aliyunDefaultLine, Status: "ENABLE"},
+		sdk.Record{RecordId: "2", DomainName: "example.com", RR: "nas", Type: "A", Value: "1.2.3.4", Line: aliyunDefaultLine, Status: "ENABLE"},
+	)
+	u := newTestUpdater(t, fake, "http://ip.invalid/", "www", "nas")
+	server := newDynDNSTestServer(t, u)
+
+	// 依次执行，后面的请求依赖前面更新后的记录
+	tests := []struct {
+		name        string
+		username    string
+		password    string
+		query       url.Values
+		wantStatus  int
+		want        string
+		wantUpdates int // 之后 UpdateDomainRecord 的总调用次数
+	}{
+		{
+			name:       "no credentials",
+			query:      url.Values{"hostname": {"www.example.com"}, "myip": {"1.2.3.5"}},
+			wantStatus: http.StatusUnauthorized,
+			want:       "badauth",
+		},
+		{
+			name:       "wrong password",
+			username:   "router",
+			password:   "wrong",
+			query:      url.Values{"hostname": {"www.example.com"}, "myip": {"1.2.3.5"}},
+			wantStatus: http.StatusUnauthorized,
+			want:       "badauth",
+		},
+		{
+			name:       "wrong username",
+			username:   "admin",
+			password:   "testDynDNSPassword",
+			query:      url.Values{"hostname": {"www.example.com"}, "myip": {"1.2.3.5"}},
+			wantStatus: http.StatusUnauthorized,
+			want:       "badauth",
+		},
+		{
+			name:       "no hostname",
+			username:   "router",
+			password:   "testDynDNSPassword",
+			query:      url.Values{"myip": {"1.2.3.5"}},
+			wantStatus: http.StatusOK,
+			want:       "notfqdn",
+		},
+		{
+			name:       "only separators",
+			username:   "router",
+			password:   "testDynDNSPassword",
+			query:      url.Values{"hostname": {" , ,"}, "myip": {"1.2.3.5"}},
+			wantStatus: http.StatusOK,
+			want:       "notfqdn",
+		},
+		{
+			name:       "too many hostnames",
+			username:   "router",
+			password:   "testDynDNSPassword",
+			query:      url.Values{"hostname": {strings.Repeat("www.example.com,", dyndnsMaxHostnames+1)}, "myip": {"1.2.3.5"}},
+			wantStatus: http.StatusOK,
+			want:       "numhost",
+		},
+		{
+			// 未提供 myip 时使用来源地址 127.0.0.1，不是公网地址
+			name:       "loopback remote address",
+			username:   "router",
+			password:   "testDynDNSPassword",
+			query:      url.Values{"hostname": {"www.example.com,nas.example.com"}},
+			wantStatus: http.StatusOK,
+			want:       "dnserr\ndnserr",
+		},
+		{
+			name:        "changed address",
+			username:    "router",
+			password:    "testDynDNSPassword",
+			query:       url.Values{"hostname": {"www.example.com."}, "myip": {"1.2.3.5"}},
+			wantStatus:  http.StatusOK,
+			want:        "good 1.2.3.5",
+			wantUpdates: 1,
+		},
+		{
+			name:        "unchanged address and unknown hostname",
+			username:    "router",
+			password:    "testDynDNSPassword",
+			query:       url.Values{"hostname": {"WWW.example.com,nas.example.com,mail.example.com"}, "myip": {"1.2.3.4"}},
+			wantStatus:  http.StatusOK,
+			want:        "good 1.2.3.4\nnochg 1.2.3.4\nnohost",
+			wantUpdates: 2,
+		},
+		{
+			name:        "same address again",
+			username:    "router",
+			password:    "testDynDNSPassword",
+			query:       url.Values{"hostname": {"www.example.com"}, "myip": {"1.2.3.4"}},
+			wantStatus:  http.StatusOK,
+			want:        "nochg 1.2.3.4",
+			wantUpdates: 2,
+		},
+		{
+			// 只有 A 记录，IPv6 地址没有对应的记录类型
+			name:        "no record for the family",
+			username:    "router",
+			password:    "testDynDNSPassword",
+			query:       url.Values{"hostname": {"www.example.com"}, "myipv6": {"2400:3200::1"}},
+			wantStatus:  http.StatusOK,
+			want:        "nohost",
+			wantUpdates: 2,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			status, body := dyndnsUpdate(t, server, test.username, test.password, test.query)
+			if status != test.wantStatus || body != test.want {
+				t.Errorf("got %d %q, want %d %q", status, body, test.wantStatus, test.want)
+			}
+			if len(fake.updates) != test.wantUpdates {
+				t.Errorf("UpdateDomainRecord called %d times, want %d", len(fake.updates), test.wantUpdates)
+			}
+		})
+	}
+	if fake.records[0].Value != "1.2.3.4" || fake.records[1].Value != "1.2.3.4" {
+		t.Errorf("records are %+v, want 1.2.3.4", fake.records)
+	}
+}
+
+func TestDynDNSHandlerChurnPaused(t *testing.T) {
+	fake := newFakeAlidns(sdk.Record{RecordId: "1", DomainName: "example.com", RR: "www", Type: "A", Value: "1.2.3.4", Line: aliyunDefaultLine, Status: "ENABLE"})
+	u := newTestUpdater(t, fake, "http://ip.invalid/", "www")
+	u.config.Churn = &ChurnConfig{MaxChanges: 1, Action: churnPause}
+	u.churn["IPv4"] = &ipChurn{paused: true}
+	server := newDynDNSTestServer(t, u)
+
+	// 暂停时不更新，让路由器稍后重试
+	status, body := dyndnsUpdate(t, server, "router", "testDynDNSPassword", url.Values{"hostname": {"www.example.com"}, "myip": {"1.2.3.5"}})
+	if status != http.StatusOK || body != "911" {
+		t.Errorf("got %d %q while paused, want 200 \"911\"", status, body)
+	}
+	if len(fake.updates) != 0 {
+		t.Fatalf("UpdateDomainRecord called %d times while paused, want 0", len(fake.updates))
+	}
+
+	// 确认后恢复更新
+	u.confirmChurn()
+	status, body = dyndnsUpdate(t, server, "router", "testDynDNSPassword", url.Values{"hostname": {"www.example.com"}, "myip": {"1.2.3.5"}})
+	if status != http.StatusOK || body != "good 1.2.3.5" {
+		t.Errorf("got %d %q after confirming, want 200 \"good 1.2.3.5\"", status, body)
+	}
+	if len(fake.updates) != 1 || fake.updates[0].Value != "1.2.3.5" {
+		t.Errorf("unexpected UpdateDomainRecord requests %+v", fake.updates)
+	}
+}
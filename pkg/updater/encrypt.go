@@ -0,0 +1,286 @@
+package updater
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// 加密值的前缀，之后是 base64 编码的 nonce 和 AES-256-GCM 密文
+const encryptedPrefix = "enc:v1:"
+
+// 读取密钥的环境变量：DDNS_CONFIG_KEY 为 base64 编码的密钥本身，DDNS_CONFIG_KEY_FILE 为密钥文件的路径
+const (
+	configKeyEnv     = "DDNS_CONFIG_KEY"
+	configKeyFileEnv = "DDNS_CONFIG_KEY_FILE"
+)
+
+// 密钥长度，AES-256
+const configKeySize = 32
+
+// 未设置环境变量时使用的密钥文件：与配置文件同目录、同名，扩展名为 .key，例如 config.json 对应 config.key
+func defaultKeyFile(configPath string) string {
+	return strings.TrimSuffix(configPath, filepath.Ext(configPath)) + ".key"
+}
+
+// 密钥文件的路径，DDNS_CONFIG_KEY_FILE 优先
+func configKeyFile(configPath string) string {
+	if path := os.Getenv(configKeyFileEnv); path != "" {
+		return path
+	}
+	return defaultKeyFile(configPath)
+}
+
+// 读取解密配置使用的密钥，依次使用 DDNS_CONFIG_KEY、DDNS_CONFIG_KEY_FILE 和默认的密钥文件
+func loadConfigKey(configPath string) ([]byte, error) {
+	if value := os.Getenv(configKeyEnv); value != "" {
+		key, err := parseConfigKey(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", configKeyEnv, err)
+		}
+		return key, nil
+	}
+	return readConfigKeyFile(configKeyFile(configPath))
+}
+
+// 读取密钥文件，文件中为 base64 编码的密钥
+func readConfigKeyFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := parseConfigKey(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid key file %s: %v", path, err)
+	}
+	return key, nil
+}
+
+// 解析 base64 编码的 32 字节密钥
+func parseConfigKey(value string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != configKeySize {
+		return nil, fmt.Errorf("expected a %d byte key, got %d bytes", configKeySize, len(key))
+	}
+	return key, nil
+}
+
+// 生成新的随机密钥并写入 path，文件已存在时返回错误，避免覆盖已经用于加密的密钥
+func generateConfigKey(path string) ([]byte, error) {
+	key := make([]byte, configKeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if _, err := fmt.Fprintln(file, base64.StdEncoding.EncodeToString(key)); err != nil {
+		return nil, err
+	}
+	return key, file.Close()
+}
+
+// 判断配置值是否已加密
+func isEncrypted(value string) bool {
+	return strings.HasPrefix(value, encryptedPrefix)
+}
+
+// 加密一个配置值，每次使用随机的 nonce，因此相同的值每次加密的结果不同
+func encryptValue(key []byte, plaintext string) (string, error) {
+	aead, err := newConfigAEAD(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// 解密 encryptValue 生成的值，密钥不对或内容被修改时返回错误
+func decryptValue(key []byte, value string) (string, error) {
+	aead, err := newConfigAEAD(key)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, encryptedPrefix))
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", errors.New("encrypted value is too short")
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return "", errors.New("wrong key or corrupted value")
+	}
+	return string(plaintext), nil
+}
+
+func newConfigAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// 解密配置中全部加密的字符串，包括插件参数和 Webhook 请求头等 map 中的值。
+// 没有加密值时不需要密钥
+func decryptConfig(config *Config, configPath string) error {
+	v := reflect.ValueOf(config).Elem()
+	found := false
+	walkStrings(v, func(value string) (string, error) {
+		found = found || isEncrypted(value)
+		return value, nil
+	})
+	if !found {
+		return nil
+	}
+
+	key, err := loadConfigKey(configPath)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("the configuration contains encrypted values, set %s or %s, or put the key in %s", configKeyEnv, configKeyFileEnv, defaultKeyFile(configPath))
+	}
+	if err != nil {
+		return err
+	}
+	return walkStrings(v, func(value string) (string, error) {
+		if !isEncrypted(value) {
+			return value, nil
+		}
+		plaintext, err := decryptValue(key, value)
+		if err != nil {
+			return "", fmt.Errorf("failed to decrypt configuration value: %v", err)
+		}
+		return plaintext, nil
+	})
+}
+
+// 遍历配置中的所有字符串字段和 map[string]string 中的值，用 fn 的返回值替换
+func walkStrings(v reflect.Value, fn func(string) (string, error)) error {
+	switch v.Kind() {
+	case reflect.String:
+		if v.CanSet() {
+			value, err := fn(v.String())
+			if err != nil {
+				return err
+			}
+			v.SetString(value)
+		}
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			return walkStrings(v.Elem(), fn)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if err := walkStrings(v.Field(i), fn); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := walkStrings(v.Index(i), fn); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if v.Type().Elem().Kind() != reflect.String {
+			return nil
+		}
+		for _, k := range v.MapKeys() {
+			value, err := fn(v.MapIndex(k).String())
+			if err != nil {
+				return err
+			}
+			v.SetMapIndex(k, reflect.ValueOf(value).Convert(v.Type().Elem()))
+		}
+	}
+	return nil
+}
+
+// config encrypt 加密的一个字段
+type secretField struct {
+	name  string // 配置中的字段名，例如 accounts[0].accessSecret
+	value *string
+}
+
+// config encrypt 需要加密的字段：各服务商的密钥、令牌以及包含令牌的通知地址。
+// AccessKey ID 等标识不加密，方便在配置文件中辨认
+func (c *Config) secretFields() []secretField {
+	fields := []secretField{{"accessSecret", &c.AccessSecret}, {"apiToken", &c.APIToken}}
+	if c.Credentials != nil {
+		fields = append(fields, secretField{"credentials.securityToken", &c.Credentials.SecurityToken})
+	}
+	for i := range c.Accounts {
+		account := &c.Accounts[i]
+		fields = append(fields, secretField{fmt.Sprintf("accounts[%d].accessSecret", i), &account.AccessSecret})
+		if account.Credentials != nil {
+			fields = append(fields, secretField{fmt.Sprintf("accounts[%d].credentials.securityToken", i), &account.Credentials.SecurityToken})
+		}
+	}
+	if c.Cloudflare != nil {
+		fields = append(fields, secretField{"cloudflare.apiToken", &c.Cloudflare.APIToken})
+	}
+	if c.DNSPod != nil {
+		fields = append(fields, secretField{"dnspod.secretKey", &c.DNSPod.SecretKey})
+	}
+	if c.Huawei != nil {
+		fields = append(fields, secretField{"huawei.secretKey", &c.Huawei.SecretKey})
+	}
+	if c.DynDNS != nil {
+		fields = append(fields, secretField{"dyndns.password", &c.DynDNS.Password})
+	}
+	if n := c.Notify; n != nil {
+		for i := range n.Webhooks {
+			fields = append(fields, secretField{fmt.Sprintf("notify.webhooks[%d].url", i), &n.Webhooks[i].URL})
+		}
+		for i := range n.DingTalk {
+			fields = append(fields,
+				secretField{fmt.Sprintf("notify.dingtalk[%d].webhook", i), &n.DingTalk[i].Webhook},
+				secretField{fmt.Sprintf("notify.dingtalk[%d].secret", i), &n.DingTalk[i].Secret})
+		}
+		for i := range n.WeCom {
+			fields = append(fields, secretField{fmt.Sprintf("notify.wecom[%d].webhook", i), &n.WeCom[i].Webhook})
+		}
+		for i := range n.Feishu {
+			fields = append(fields,
+				secretField{fmt.Sprintf("notify.feishu[%d].webhook", i), &n.Feishu[i].Webhook},
+				secretField{fmt.Sprintf("notify.feishu[%d].secret", i), &n.Feishu[i].Secret})
+		}
+		for i := range n.Telegram {
+			fields = append(fields, secretField{fmt.Sprintf("notify.telegram[%d].botToken", i), &n.Telegram[i].BotToken})
+		}
+		for i := range n.Slack {
+			fields = append(fields, secretField{fmt.Sprintf("notify.slack[%d].webhook", i), &n.Slack[i].Webhook})
+		}
+		for i := range n.Discord {
+			fields = append(fields, secretField{fmt.Sprintf("notify.discord[%d].webhook", i), &n.Discord[i].Webhook})
+		}
+		for i := range n.Bark {
+			fields = append(fields, secretField{fmt.Sprintf("notify.bark[%d].deviceKey", i), &n.Bark[i].DeviceKey})
+		}
+		for i := range n.Gotify {
+			fields = append(fields, secretField{fmt.Sprintf("notify.gotify[%d].token", i), &n.Gotify[i].Token})
+		}
+		for i := range n.Ntfy {
+			fields = append(fields, secretField{fmt.Sprintf("notify.ntfy[%d].token", i), &n.Ntfy[i].Token})
+		}
+	}
+	return fields
+}
@@ -0,0 +1,138 @@
+package updater
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+// 固定的测试密钥
+func testConfigKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, configKeySize)
+}
+
+// 修改加密值中 base64 解码后第 i 个字节，i 为负数时从末尾计算
+func tamperEncrypted(t *testing.T, value string, i int) string {
+	t.Helper()
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, encryptedPrefix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i < 0 {
+		i += len(sealed)
+	}
+	sealed[i] ^= 0x01
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed)
+}
+
+func TestEncryptDecryptRoundTrip(t *testing.T) {
+	key := testConfigKey(0x11)
+	for _, plaintext := range []string{"testAccessKeySecret", "", "https://oapi.dingtalk.com/robot/send?access_token=测试", strings.Repeat("x", 4096)} {
+		value, err := encryptValue(key, plaintext)
+		if err != nil {
+			t.Fatalf("encryptValue(%q) error = %v", plaintext, err)
+		}
+		if !isEncrypted(value) {
+			t.Errorf("encryptValue(%q) = %q, want the %s prefix", plaintext, value, encryptedPrefix)
+		}
+		if plaintext != "" && strings.Contains(value, plaintext) {
+			t.Errorf("encryptValue(%q) = %q contains the plaintext", plaintext, value)
+		}
+		got, err := decryptValue(key, value)
+		if err != nil {
+			t.Fatalf("decryptValue(%q) error = %v", value, err)
+		}
+		if got != plaintext {
+			t.Errorf("decryptValue(encryptValue(%q)) = %q", plaintext, got)
+		}
+	}
+
+	// 每次使用随机的 nonce
+	first, _ := encryptValue(key, "testAccessKeySecret")
+	second, _ := encryptValue(key, "testAccessKeySecret")
+	if first == second {
+		t.Errorf("encrypting the same value twice returned %q both times", first)
+	}
+}
+
+func TestDecryptValueRejects(t *testing.T) {
+	key := testConfigKey(0x11)
+	value, err := encryptValue(key, "testAccessKeySecret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		key     []byte
+		value   string
+		wantErr string
+	}{
+		{name: "wrong key", key: testConfigKey(0x22), value: value, wantErr: "wrong key or corrupted value"},
+		{name: "tampered tag", key: key, value: tamperEncrypted(t, value, -1), wantErr: "wrong key or corrupted value"},
+		{name: "tampered ciphertext", key: key, value: tamperEncrypted(t, value, 12), wantErr: "wrong key or corrupted value"},
+		{name: "tampered nonce", key: key, value: tamperEncrypted(t, value, 0), wantErr: "wrong key or corrupted value"},
+		{name: "too short", key: key, value: encryptedPrefix + base64.StdEncoding.EncodeToString([]byte("short")), wantErr: "encrypted value is too short"},
+		{name: "invalid base64", key: key, value: encryptedPrefix + "not base64!", wantErr: "illegal base64 data"},
+		{name: "invalid key size", key: key[:17], value: value, wantErr: "invalid key size"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := decryptValue(test.key, test.value)
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("decryptValue() = %q, %v, want error %q", got, err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseConfigKey(t *testing.T) {
+	key := testConfigKey(0x33)
+	got, err := parseConfigKey(" " + base64.StdEncoding.EncodeToString(key) + "\n")
+	if err != nil {
+		t.Fatalf("parseConfigKey() error = %v", err)
+	}
+	if !bytes.Equal(got, key) {
+		t.Errorf("parseConfigKey() = %x, want %x", got, key)
+	}
+	for _, value := range []string{base64.StdEncoding.EncodeToString(key[:16]), "not base64!"} {
+		if _, err := parseConfigKey(value); err == nil {
+			t.Errorf("parseConfigKey(%q) succeeded, want an error", value)
+		}
+	}
+}
+
+func TestDecryptConfig(t *testing.T) {
+	key := testConfigKey(0x44)
+	t.Setenv(configKeyEnv, base64.StdEncoding.EncodeToString(key))
+	t.Setenv(configKeyFileEnv, "")
+	encrypt := func(plaintext string) string {
+		value, err := encryptValue(key, plaintext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return value
+	}
+
+	config := validTestConfig()
+	config.AccessSecret = encrypt("testAccessKeySecret")
+	config.Notify = &NotifyConfig{Webhooks: []WebhookConfig{{URL: "https://example.com/hook", Headers: map[string]string{"Authorization": encrypt("Bearer testWebhookToken")}}}}
+	if err := decryptConfig(&config, "config.json"); err != nil {
+		t.Fatalf("decryptConfig() error = %v", err)
+	}
+	if config.AccessSecret != "testAccessKeySecret" {
+		t.Errorf("accessSecret = %q, want it decrypted", config.AccessSecret)
+	}
+	if got := config.Notify.Webhooks[0].Headers["Authorization"]; got != "Bearer testWebhookToken" {
+		t.Errorf("webhook header = %q, want it decrypted", got)
+	}
+	if config.Notify.Webhooks[0].URL != "https://example.com/hook" {
+		t.Errorf("unencrypted url changed to %q", config.Notify.Webhooks[0].URL)
+	}
+
+	// 被修改过的加密值
+	config.AccessSecret = tamperEncrypted(t, encrypt("testAccessKeySecret"), -1)
+	if err := decryptConfig(&config, "config.json"); err == nil || !strings.Contains(err.Error(), "wrong key or corrupted value") {
+		t.Errorf("decryptConfig() error = %v, want a decryption error", err)
+	}
+}
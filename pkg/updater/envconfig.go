@@ -0,0 +1,135 @@
+package updater
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// 配置字段对应的环境变量前缀，例如 domainName 对应 DDNS_DOMAIN_NAME
+const configEnvPrefix = "DDNS_"
+
+// 配置中的一个顶层字段和对应的环境变量
+type configEnv struct {
+	name  string // 环境变量名
+	field string // JSON 中的字段名
+	index int    // Config 中的字段下标
+}
+
+// 全部顶层字段对应的环境变量，按字段在 Config 中的顺序排列
+var configEnvs = func() []configEnv {
+	var envs []configEnv
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		field, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if field == "" || field == "-" {
+			continue
+		}
+		envs = append(envs, configEnv{name: configEnvName(field), field: field, index: i})
+	}
+	return envs
+}()
+
+// 把驼峰形式的字段名转换为环境变量名，例如 apiURLsV6 为 DDNS_API_URLS_V6、allowPrivateIP 为 DDNS_ALLOW_PRIVATE_IP
+func configEnvName(field string) string {
+	var b strings.Builder
+	b.WriteString(configEnvPrefix)
+	runes := []rune(field)
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1])) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
+
+// 是否设置了任意一个配置字段的环境变量，设置后配置文件不存在时只使用环境变量
+func envConfigured() bool {
+	for _, env := range configEnvs {
+		if os.Getenv(env.name) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// 只通过环境变量配置时的初始配置：默认配置去掉需要替换的占位值，未设置的必填字段在校验时报错。
+// 不使用默认的日志文件，容器中的日志直接输出到标准输出
+func envBaseConfig() Config {
+	config := defaultConfig
+	config.AccessKey, config.AccessSecret, config.DomainName = "", "", ""
+	config.LogFileName = ""
+	config.APIURLs = slices.Clone(defaultConfig.APIURLs)
+	config.APIURLsV6 = slices.Clone(defaultConfig.APIURLsV6)
+	return config
+}
+
+// 使用环境变量覆盖配置文件中的字段，空的环境变量视为未设置。
+// 字符串、数字和布尔值直接解析；字符串列表可以是逗号分隔的值或 JSON 数组；其它字段（domains、notify 等）为 JSON
+func (c *Config) applyEnvConfig() error {
+	v := reflect.ValueOf(c).Elem()
+	for _, env := range configEnvs {
+		value := os.Getenv(env.name)
+		if value == "" {
+			continue
+		}
+		if err := setEnvValue(v.Field(env.index), value); err != nil {
+			return fmt.Errorf("invalid %s (%s): %v", env.name, env.field, err)
+		}
+	}
+	return nil
+}
+
+// 按字段的类型解析环境变量的值
+func setEnvValue(v reflect.Value, value string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(n))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.String && !strings.HasPrefix(strings.TrimSpace(value), "[") {
+			var values []string
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					values = append(values, item)
+				}
+			}
+			v.Set(reflect.ValueOf(values))
+			return nil
+		}
+		return decodeEnvJSON(v, value)
+	default:
+		return decodeEnvJSON(v, value)
+	}
+	return nil
+}
+
+// 与配置文件相同，JSON 中不认识的字段直接报错
+func decodeEnvJSON(v reflect.Value, value string) error {
+	target := reflect.New(v.Type())
+	decoder := json.NewDecoder(bytes.NewReader([]byte(value)))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(target.Interface()); err != nil {
+		return err
+	}
+	v.Set(target.Elem())
+	return nil
+}
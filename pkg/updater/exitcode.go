@@ -0,0 +1,100 @@
+package updater
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	sdkerrors "github.com/aliyun/alibaba-cloud-sdk-go/sdk/errors"
+)
+
+// 退出码，脚本和 systemd 的 OnFailure 可以据此区分失败的原因。
+// 2 保留给命令行参数错误（flag 包的默认行为）
+const (
+	exitOK             = 0
+	exitFailure        = 1 // 其它错误
+	exitUsage          = 2 // 命令行参数错误
+	exitConfig         = 3 // 配置文件不存在、无法解析或校验失败
+	exitAuth           = 4 // DNS 服务商拒绝了凭证或没有权限
+	exitRecordNotFound = 5 // 解析记录不存在并且 autoCreate 为 false
+	exitIPDetection    = 6 // 无法获取公网 IP
+	exitThrottled      = 7 // DNS 服务商限流
+	exitPartial        = 8 // 部分记录更新成功，其它记录失败
+)
+
+// 带有退出码的错误
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+// 获取公网 IP 失败
+func ipDetectionError(family string, err error) error {
+	return &exitError{code: exitIPDetection, err: fmt.Errorf("get public %s: %w", family, err)}
+}
+
+// 判断错误是否表示凭证无效或没有权限，例如阿里云的 InvalidAccessKeyId.NotFound 和 Forbidden.RAM
+func authFailed(err error) bool {
+	var serverErr *sdkerrors.ServerError
+	if errors.As(err, &serverErr) {
+		code := serverErr.ErrorCode()
+		for _, prefix := range []string{"InvalidAccessKeyId", "SignatureDoesNotMatch", "IncompleteSignature", "InvalidSecurityToken", "Forbidden", "NoPermission"} {
+			if strings.HasPrefix(code, prefix) {
+				return true
+			}
+		}
+		return serverErr.HttpStatus() == http.StatusUnauthorized
+	}
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		return apiErr.auth
+	}
+	return false
+}
+
+// 错误对应的退出码，没有明确分类的错误为 1
+func exitCode(err error) int {
+	var exitErr *exitError
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &exitErr):
+		return exitErr.code
+	case authFailed(err):
+		return exitAuth
+	case errors.Is(err, ErrRecordNotFound):
+		return exitRecordNotFound
+	case throttled(err):
+		return exitThrottled
+	}
+	return exitFailure
+}
+
+// 从多个失败中选出决定退出码的一个：凭证错误最需要人工处理，其次是记录不存在、限流和获取公网 IP 失败
+func classifyFailures(failures []error) error {
+	for _, code := range []int{exitAuth, exitRecordNotFound, exitThrottled, exitIPDetection} {
+		for _, err := range failures {
+			if exitCode(err) == code {
+				return err
+			}
+		}
+	}
+	return failures[0]
+}
+
+// 启动时创建 updater 失败的退出码：服务商返回的凭证、限流等错误保留对应的退出码，其它错误均为配置错误
+func startupExitCode(err error) int {
+	if code := exitCode(err); code != exitFailure {
+		return code
+	}
+	return exitConfig
+}
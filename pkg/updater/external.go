@@ -0,0 +1,50 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"time"
+
+	"DDns_go/pkg/ipdetect"
+)
+
+// 外部命令的默认超时
+const defaultCommandTimeout = 10 * time.Second
+
+// 运行外部命令（例如查询路由器的脚本），从输出中读取 IP
+func getPublicIPFromCommand(ctx context.Context, source SourceConfig, network string) (string, error) {
+	if len(source.Command) == 0 {
+		return "", errors.New("command is required for command source")
+	}
+
+	timeout, err := sourceTimeout(source)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, source.Command[0], source.Command[1:]...).Output()
+	if ctx.Err() == context.DeadlineExceeded {
+		return "", fmt.Errorf("command timed out after %s", timeout)
+	}
+	if err != nil {
+		return "", err
+	}
+	return ipdetect.PickIP(string(output), network)
+}
+
+// command 和 plugin 方式的超时，未设置时为 10s
+func sourceTimeout(source SourceConfig) (time.Duration, error) {
+	if source.Timeout == "" {
+		return defaultCommandTimeout, nil
+	}
+	timeout, err := time.ParseDuration(source.Timeout)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timeout %q: %v", source.Timeout, err)
+	}
+	return timeout, nil
+}
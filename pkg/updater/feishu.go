@@ -0,0 +1,74 @@
+package updater
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// 飞书群机器人，开启“签名校验”安全设置时需要填写 secret
+type FeishuConfig struct {
+	Webhook string `json:"webhook"`          // https://open.feishu.cn/open-apis/bot/v2/hook/...
+	Secret  string `json:"secret,omitempty"` // 签名校验的密钥
+	ChatConfig
+}
+
+type feishuNotifier struct {
+	config  FeishuConfig
+	message *chatMessage
+}
+
+// 消息卡片标题的颜色
+var feishuCardColors = map[string]string{
+	eventIPChanged:    "green",
+	eventUpdateFailed: "red",
+	eventRecovered:    "turquoise",
+	eventStartup:      "blue",
+	eventThrottled:    "orange",
+	eventCGNAT:        "yellow",
+	eventIPChurn:      "orange",
+}
+
+func (n *feishuNotifier) send(ctx context.Context, client *http.Client, event notifyEvent) error {
+	title, text, err := n.message.render(event)
+	if err != nil {
+		return err
+	}
+	payload := map[string]interface{}{
+		"msg_type": "interactive",
+		"card": map[string]interface{}{
+			"header": map[string]interface{}{
+				"title":    map[string]string{"tag": "plain_text", "content": title},
+				"template": feishuCardColors[event.Event],
+			},
+			"elements": []map[string]string{
+				{"tag": "markdown", "content": text},
+			},
+		},
+	}
+	if n.config.Secret != "" {
+		// 签名：以“时间戳\n密钥”为 key 对空内容做 HMAC-SHA256
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		payload["timestamp"] = timestamp
+		payload["sign"] = hmacSHA256Base64(timestamp+"\n"+n.config.Secret, "")
+	}
+
+	data, err := postNotifyJSON(ctx, client, n.config.Webhook, payload)
+	if err != nil {
+		return err
+	}
+	var result struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return fmt.Errorf("invalid response %q: %v", data, err)
+	}
+	if result.Code != 0 {
+		return &apiError{message: fmt.Sprintf("code %d: %s", result.Code, result.Msg)}
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package updater
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"net"
+	"os"
+	"strings"
+)
+
+// 从路由表中读取默认网关地址
+func defaultGateway() (string, error) {
+	file, err := os.Open("/proc/net/route")
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// 格式：网卡 目标 网关 标志 ...，地址为小端序的十六进制
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || fields[1] != "00000000" {
+			continue
+		}
+		raw, err := hex.DecodeString(fields[2])
+		if err != nil || len(raw) != net.IPv4len {
+			continue
+		}
+		ip := make(net.IP, net.IPv4len)
+		binary.BigEndian.PutUint32(ip, binary.LittleEndian.Uint32(raw))
+		return ip.String(), nil
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", errors.New("default gateway not found")
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package updater
+
+import "errors"
+
+// 其它系统需要在 servers 中配置网关地址
+func defaultGateway() (string, error) {
+	return "", errors.New("gateway address is required in servers on this platform")
+}
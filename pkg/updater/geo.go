@@ -0,0 +1,334 @@
+package updater
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// 查询公网 IP 归属的方式：离线的 MMDB 数据库或在线的 API
+type GeoConfig struct {
+	MMDB     []string `json:"mmdb,omitempty"`     // 离线数据库，例如 GeoLite2-ASN.mmdb 和 GeoLite2-City.mmdb，按顺序查询并合并结果
+	URL      string   `json:"url,omitempty"`      // 在线查询的 API，{ip} 替换为地址，例如 https://ipinfo.io/{ip}/json，只补全离线数据库中没有的字段
+	Language string   `json:"language,omitempty"` // 地名使用的语言，默认 zh-CN，数据库中没有时使用 en
+}
+
+// 默认的地名语言
+const defaultGeoLanguage = "zh-CN"
+
+// 公网 IP 的归属：ASN、运营商和地区
+type ipGeo struct {
+	ASN     uint   `json:"asn,omitempty"`
+	Org     string `json:"org,omitempty"` // 运营商或 AS 的名称
+	Country string `json:"country,omitempty"`
+	Region  string `json:"region,omitempty"`
+	City    string `json:"city,omitempty"`
+}
+
+// 例如 "AS4134 CHINANET-BACKBONE, 中国 广东 深圳"，没有任何信息时为空
+func (g ipGeo) String() string {
+	var parts []string
+	if network := g.network(); network != "" {
+		parts = append(parts, network)
+	}
+	if location := g.location(); location != "" {
+		parts = append(parts, location)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ASN 和运营商，例如 "AS4134 CHINANET-BACKBONE"
+func (g ipGeo) network() string {
+	var parts []string
+	if g.ASN > 0 {
+		parts = append(parts, "AS"+strconv.FormatUint(uint64(g.ASN), 10))
+	}
+	if g.Org != "" {
+		parts = append(parts, g.Org)
+	}
+	return strings.Join(parts, " ")
+}
+
+// 国家、省份和城市，直辖市的省份和城市相同时只显示一次
+func (g ipGeo) location() string {
+	var parts []string
+	for _, part := range []string{g.Country, g.Region, g.City} {
+		if part != "" && !slices.Contains(parts, part) {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// 用 other 补全 g 中缺少的字段
+func (g ipGeo) merge(other ipGeo) ipGeo {
+	if g.ASN == 0 {
+		g.ASN = other.ASN
+	}
+	if g.Org == "" {
+		g.Org = other.Org
+	}
+	// 地区按整体补全，避免国家和城市来自不同的数据库
+	if g.location() == "" {
+		g.Country, g.Region, g.City = other.Country, other.Region, other.City
+	}
+	return g
+}
+
+// ASN 和地区都已经查到，不需要再查询其它来源
+func (g ipGeo) complete() bool {
+	return g.ASN > 0 && g.Org != "" && g.location() != ""
+}
+
+// 按 geo 配置查询公网 IP 的归属
+type geoLocator struct {
+	config GeoConfig
+	client *http.Client
+}
+
+// 未配置 geo 时返回 nil。启动和重新加载配置时打开全部数据库，文件不存在或格式错误时返回错误
+func newGeoLocator(config Config, settings httpSettings) (*geoLocator, error) {
+	if config.Geo == nil {
+		return nil, nil
+	}
+	for _, path := range config.Geo.MMDB {
+		if _, err := openGeoDatabase(path); err != nil {
+			return nil, fmt.Errorf("failed to open geo database: %v", err)
+		}
+	}
+	return &geoLocator{
+		config: *config.Geo,
+		client: &http.Client{Timeout: settings.timeout, Transport: settings.newTransport("", settings.ipProxy)},
+	}, nil
+}
+
+// 依次查询每个数据库，仍有缺少的字段并且设置了 url 时再查询 API
+func (l *geoLocator) lookup(ctx context.Context, ip string) (ipGeo, error) {
+	var geo ipGeo
+	for _, path := range l.config.MMDB {
+		found, err := lookupGeoDatabase(path, ip, l.language())
+		if err != nil {
+			return geo, err
+		}
+		geo = geo.merge(found)
+	}
+	if l.config.URL == "" || geo.complete() {
+		return geo, nil
+	}
+	found, err := l.lookupAPI(ctx, ip)
+	if err != nil {
+		// 离线数据库已经查到的信息仍然可用
+		if geo != (ipGeo{}) {
+			return geo, nil
+		}
+		return geo, err
+	}
+	return geo.merge(found), nil
+}
+
+func (l *geoLocator) language() string {
+	if l.config.Language == "" {
+		return defaultGeoLanguage
+	}
+	return l.config.Language
+}
+
+// 查询 url 配置的 API，支持 ipinfo.io、ip-api.com、ipapi.co 等返回 JSON 的服务
+func (l *geoLocator) lookupAPI(ctx context.Context, ip string) (ipGeo, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.ReplaceAll(l.config.URL, "{ip}", ip), nil)
+	if err != nil {
+		return ipGeo{}, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return ipGeo{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return ipGeo{}, fmt.Errorf("geo API returned %s", resp.Status)
+	}
+	var fields map[string]interface{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&fields); err != nil {
+		return ipGeo{}, fmt.Errorf("invalid geo API response: %v", err)
+	}
+	return geoFromFields(fields)
+}
+
+// 从常见的 IP 查询 API 的返回内容中取出归属。ipinfo.io 的 org 和 ip-api.com 的 as 为 "AS4134 CHINANET-BACKBONE" 的形式
+func geoFromFields(fields map[string]interface{}) (ipGeo, error) {
+	text := func(keys ...string) string {
+		for _, key := range keys {
+			switch value := fields[key].(type) {
+			case string:
+				if value != "" {
+					return value
+				}
+			case float64:
+				return strconv.FormatFloat(value, 'f', -1, 64)
+			}
+		}
+		return ""
+	}
+	// ip-api.com 查询失败时仍返回 200
+	if text("status") == "fail" {
+		return ipGeo{}, fmt.Errorf("geo API error: %s", text("message"))
+	}
+
+	var geo ipGeo
+	asn := text("asn", "as")
+	fromOrg := asn == ""
+	if fromOrg {
+		asn = text("org")
+	}
+	geo.ASN, geo.Org = parseASN(asn)
+	if org := text("isp", "as_name"); org != "" {
+		geo.Org = org
+	} else if geo.Org == "" && !fromOrg {
+		geo.Org = text("org")
+	}
+	geo.Country = text("country_name", "country")
+	geo.Region = text("regionName", "region")
+	geo.City = text("city")
+	return geo, nil
+}
+
+// 解析 "AS4134 CHINANET-BACKBONE"、"AS4134" 或 "4134" 形式的 ASN，返回编号和名称
+func parseASN(value string) (uint, string) {
+	number, name, _ := strings.Cut(strings.TrimSpace(value), " ")
+	number = strings.TrimPrefix(strings.ToUpper(number), "AS")
+	asn, err := strconv.ParseUint(number, 10, 32)
+	if err != nil {
+		return 0, ""
+	}
+	return uint(asn), strings.TrimSpace(name)
+}
+
+// MMDB 中 ASN 和城市数据库的字段，GeoLite2、GeoIP2 和 DB-IP 使用相同的结构
+type mmdbRecord struct {
+	ASN   uint   `maxminddb:"autonomous_system_number"`
+	ASOrg string `maxminddb:"autonomous_system_organization"`
+	ISP   string `maxminddb:"isp"`
+
+	Country struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"country"`
+	Subdivisions []struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"subdivisions"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+}
+
+// 打开的数据库以及打开时文件的修改时间，geoipupdate 等工具替换文件后重新打开
+type geoDatabase struct {
+	reader  *maxminddb.Reader
+	modTime time.Time
+}
+
+// 按路径缓存打开的数据库，重新加载配置时继续使用
+var geoDatabases = struct {
+	sync.Mutex
+	byPath map[string]*geoDatabase
+}{byPath: make(map[string]*geoDatabase)}
+
+// 返回路径对应的数据库，文件已被替换时关闭旧的并重新打开。调用时需要持有 geoDatabases 的锁
+func openGeoDatabaseLocked(path string) (*maxminddb.Reader, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if db, ok := geoDatabases.byPath[path]; ok && db.modTime.Equal(info.ModTime()) {
+		return db.reader, nil
+	}
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if db, ok := geoDatabases.byPath[path]; ok {
+		db.reader.Close()
+	}
+	geoDatabases.byPath[path] = &geoDatabase{reader: reader, modTime: info.ModTime()}
+	return reader, nil
+}
+
+func openGeoDatabase(path string) (*maxminddb.Reader, error) {
+	geoDatabases.Lock()
+	defer geoDatabases.Unlock()
+	return openGeoDatabaseLocked(path)
+}
+
+// 在一个数据库中查询地址，数据库中没有该地址时返回空的结果
+func lookupGeoDatabase(path, ip, language string) (ipGeo, error) {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return ipGeo{}, fmt.Errorf("invalid IP %q", ip)
+	}
+	geoDatabases.Lock()
+	defer geoDatabases.Unlock()
+	reader, err := openGeoDatabaseLocked(path)
+	if err != nil {
+		return ipGeo{}, err
+	}
+	var record mmdbRecord
+	if err := reader.Lookup(addr, &record); err != nil {
+		return ipGeo{}, fmt.Errorf("%s: %v", path, err)
+	}
+
+	name := func(names map[string]string) string {
+		if value := names[language]; value != "" {
+			return value
+		}
+		return names["en"]
+	}
+	geo := ipGeo{ASN: record.ASN, Org: record.ISP, Country: name(record.Country.Names), City: name(record.City.Names)}
+	if geo.Org == "" {
+		geo.Org = record.ASOrg
+	}
+	if len(record.Subdivisions) > 0 {
+		geo.Region = name(record.Subdivisions[0].Names)
+	}
+	return geo, nil
+}
+
+// 查询新的公网 IP 的归属并记录日志，ASN 与该协议族之前的地址不同时记录警告，
+// 例如运营商把线路迁移到了运营商级 NAT 或其它节点。未配置 geo 或查询失败时返回空的结果
+func (u *updater) locate(ctx context.Context, family, ip string) ipGeo {
+	if u.geo == nil {
+		return ipGeo{}
+	}
+	lookupCtx, cancel := context.WithTimeout(ctx, u.retry.timeout)
+	defer cancel()
+	geo, err := u.geo.lookup(lookupCtx, ip)
+	if err != nil {
+		u.logger.Warn("Failed to look up public IP location", "family", family, "ip", ip, "error", err)
+		return ipGeo{}
+	}
+	u.logger.Info("Public IP location", "family", family, "ip", ip, "asn", geo.ASN, "org", geo.Org, "location", geo.location())
+
+	previous := u.seenIPs[family]
+	if previous.geo.ASN > 0 && geo.ASN > 0 && previous.geo.ASN != geo.ASN {
+		u.logger.Warn("Public IP moved to a different network", "family", family, "ip", ip, "network", geo.network(), "old_ip", previous.ip, "old_network", previous.geo.network())
+	}
+	return geo
+}
+
+// 记录值为公网 IP 的记录对应的归属，用于 ip_changed 通知
+func (u *updater) recordGeo(domain DomainConfig, recordType string) string {
+	if !addressRecordType(recordType) {
+		return ""
+	}
+	return u.seenIPs[u.config.domainFamily(domain, recordType).name].geo.String()
+}
@@ -0,0 +1,58 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// Gotify 自建推送服务
+type GotifyConfig struct {
+	Server   string `json:"server"`             // Gotify 服务地址，例如 https://gotify.example.com
+	Token    string `json:"token"`              // 应用的令牌
+	Priority int    `json:"priority,omitempty"` // 消息优先级，默认检测失败为 8，其它为 5
+	ChatConfig
+}
+
+type gotifyNotifier struct {
+	config  GotifyConfig
+	message *chatMessage
+}
+
+func (n *gotifyNotifier) send(ctx context.Context, client *http.Client, event notifyEvent) error {
+	title, text, err := n.message.render(event)
+	if err != nil {
+		return err
+	}
+	priority := n.config.Priority
+	if priority == 0 {
+		priority = 5
+		if event.Event == eventUpdateFailed {
+			priority = 8
+		}
+	}
+	payload := map[string]interface{}{
+		"title":    title,
+		"message":  text,
+		"priority": priority,
+		"extras": map[string]interface{}{
+			"client::display": map[string]string{"contentType": "text/markdown"},
+		},
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(n.config.Server, "/")+"/message", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// 令牌放在请求头中，避免出现在错误信息的地址里
+	req.Header.Set("X-Gotify-Key", n.config.Token)
+	_, err = doNotifyRequest(client, req)
+	return err
+}
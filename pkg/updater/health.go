@@ -0,0 +1,101 @@
+package updater
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// 最近一次检测的结果，供健康检查使用
+type healthState struct {
+	mu          sync.Mutex
+	started     time.Time
+	lastCycle   time.Time
+	lastOK      bool
+	lastSuccess time.Time // 最近一次全部记录都同步成功（包括无需更新）的时间
+	failing     []string  // 连续同步失败达到 failureThreshold 的记录
+}
+
+// 进程内共享的健康状态
+var health = &healthState{started: time.Now()}
+
+// 记录一次检测的结果以及连续失败达到阈值的记录
+func (h *healthState) cycleFinished(ok bool, failing []string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastCycle = time.Now()
+	h.lastOK = ok
+	h.failing = failing
+	if ok {
+		h.lastSuccess = h.lastCycle
+	}
+}
+
+// /healthz 返回的内容
+type healthReport struct {
+	Status              string     `json:"status"` // ok、starting 或 failing
+	LastCycle           *time.Time `json:"lastCycle,omitempty"`
+	LastCycleOK         bool       `json:"lastCycleOk"`
+	LastSuccess         *time.Time `json:"lastSuccess,omitempty"`
+	SecondsSinceSuccess float64    `json:"secondsSinceSuccess"` // 从未成功时为启动后经过的时间
+	FailingRecords      []string   `json:"failingRecords,omitempty"`
+}
+
+// 生成健康报告，距离上次成功超过 threshold 或有记录连续失败达到阈值时为 failing
+func (h *healthState) report(threshold time.Duration) healthReport {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	report := healthReport{LastCycleOK: h.lastOK, FailingRecords: h.failing}
+	since := h.started
+	if !h.lastCycle.IsZero() {
+		lastCycle := h.lastCycle
+		report.LastCycle = &lastCycle
+	}
+	if !h.lastSuccess.IsZero() {
+		lastSuccess := h.lastSuccess
+		report.LastSuccess = &lastSuccess
+		since = lastSuccess
+	}
+	elapsed := time.Since(since)
+	report.SecondsSinceSuccess = elapsed.Round(time.Second).Seconds()
+
+	switch {
+	case elapsed > threshold, len(h.failing) > 0:
+		report.Status = "failing"
+	case h.lastSuccess.IsZero():
+		report.Status = "starting"
+	default:
+		report.Status = "ok"
+	}
+	return report
+}
+
+// 健康检查的 HTTP 处理函数，持续失败超过 threshold 或有记录连续失败时返回 503
+func healthHandler(threshold time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		report := health.report(threshold)
+		w.Header().Set("Content-Type", "application/json")
+		if report.Status == "failing" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(report)
+	}
+}
+
+// 默认的健康检查阈值为检测间隔的 3 倍，避免一次偶然的失败就重启
+const defaultHealthThresholdCycles = 3
+
+// 返回健康检查的阈值
+func (c Config) healthThreshold(interval time.Duration) (time.Duration, error) {
+	if c.HealthThreshold == "" {
+		return defaultHealthThresholdCycles * interval, nil
+	}
+	threshold, err := time.ParseDuration(c.HealthThreshold)
+	if err != nil {
+		return 0, fmt.Errorf("invalid healthThreshold %q: %v", c.HealthThreshold, err)
+	}
+	return threshold, nil
+}
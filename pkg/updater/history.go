@@ -0,0 +1,225 @@
+package updater
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// 默认保留一年的历史
+const defaultHistoryRetention = 365 * 24 * time.Hour
+
+// 等待其它进程（例如 history 子命令）释放历史文件的最长时间
+const historyLockTimeout = 5 * time.Second
+
+// 公网 IP 变化的事件的结果，其它事件的结果与记录同步的结果相同
+const resultIPChanged = "ip_changed"
+
+// 历史文件中的 bucket：events 按时间保存事件，ips 保存每个协议族最后使用的 IP
+var (
+	historyEventsBucket = []byte("events")
+	historyIPsBucket    = []byte("ips")
+)
+
+// 保存在 historyFile 中的历史事件。每次写入时打开文件，写完立即关闭，
+// 程序运行时也可以用 history 子命令查询
+type historyStore struct {
+	mu        sync.Mutex
+	path      string
+	retention time.Duration
+	lastIPs   map[string]string // 按协议族索引，启动时从文件中读取
+	redactor  *redactor
+	logger    *slog.Logger
+}
+
+// 返回历史的保留时间
+func (c Config) historyRetention() (time.Duration, error) {
+	if c.HistoryRetention == "" {
+		return defaultHistoryRetention, nil
+	}
+	retention, err := time.ParseDuration(c.HistoryRetention)
+	if err != nil || retention <= 0 {
+		return 0, fmt.Errorf("invalid historyRetention %q", c.HistoryRetention)
+	}
+	return retention, nil
+}
+
+// 打开 historyFile，文件不存在时创建，并删除超过保留时间的事件。未设置 historyFile 时返回 nil
+func openHistoryStore(config Config, logger *slog.Logger) (*historyStore, error) {
+	if config.HistoryFile == "" {
+		return nil, nil
+	}
+	retention, err := config.historyRetention()
+	if err != nil {
+		return nil, err
+	}
+	h := &historyStore{
+		path:      config.HistoryFile,
+		retention: retention,
+		lastIPs:   make(map[string]string),
+		redactor:  newRedactor(config.secrets()),
+		logger:    logger,
+	}
+	err = h.update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(historyEventsBucket); err != nil {
+			return err
+		}
+		ips, err := tx.CreateBucketIfNotExists(historyIPsBucket)
+		if err != nil {
+			return err
+		}
+		ips.ForEach(func(family, ip []byte) error {
+			h.lastIPs[string(family)] = string(ip)
+			return nil
+		})
+		return h.prune(tx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// 打开历史文件执行 fn，完成后关闭文件
+func (h *historyStore) update(fn func(tx *bolt.Tx) error) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	db, err := bolt.Open(h.path, 0o600, &bolt.Options{Timeout: historyLockTimeout})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.Update(fn)
+}
+
+// 事件的键为时间（纳秒）加上序号，按键的顺序即为时间顺序
+func historyKey(t time.Time, seq uint64) []byte {
+	key := make([]byte, 16)
+	binary.BigEndian.PutUint64(key, uint64(t.UnixNano()))
+	binary.BigEndian.PutUint64(key[8:], seq)
+	return key
+}
+
+// 删除超过保留时间的事件
+func (h *historyStore) prune(tx *bolt.Tx) error {
+	cutoff := historyKey(time.Now().Add(-h.retention), 0)
+	cursor := tx.Bucket(historyEventsBucket).Cursor()
+	for key, _ := cursor.First(); key != nil && string(key) < string(cutoff); key, _ = cursor.First() {
+		if err := cursor.Delete(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 写入一个事件，错误信息先去掉其中的密钥。失败时只记录日志，不影响更新
+func (h *historyStore) add(event historyEvent) {
+	event.Error = h.redactor.redact(event.Error)
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	err = h.update(func(tx *bolt.Tx) error {
+		events := tx.Bucket(historyEventsBucket)
+		seq, err := events.NextSequence()
+		if err != nil {
+			return err
+		}
+		if err := events.Put(historyKey(event.Time, seq), data); err != nil {
+			return err
+		}
+		if event.Result == resultIPChanged {
+			if err := tx.Bucket(historyIPsBucket).Put([]byte(event.Family), []byte(event.NewIP)); err != nil {
+				return err
+			}
+		}
+		return h.prune(tx)
+	})
+	if err != nil {
+		h.logger.Warn("Failed to write history file", "path", h.path, "error", err)
+		return
+	}
+	if event.Result == resultIPChanged {
+		h.mu.Lock()
+		h.lastIPs[event.Family] = event.NewIP
+		h.mu.Unlock()
+	}
+}
+
+// 协议族最后使用的 IP，包括上次运行时的记录
+func (h *historyStore) lastIP(family string) string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.lastIPs[family]
+}
+
+// 查询历史的条件，为零值的条件不限制
+type historyFilter struct {
+	since  time.Time
+	domain string // 域名或完整的记录名，例如 example.com 或 www.example.com
+	ipOnly bool   // 只返回公网 IP 变化的事件
+	limit  int
+}
+
+func (f historyFilter) matches(event historyEvent) bool {
+	if f.ipOnly && event.Result != resultIPChanged {
+		return false
+	}
+	if f.domain != "" {
+		domain := strings.ToLower(strings.TrimSuffix(f.domain, "."))
+		name := strings.ToLower(event.RR + "." + event.Domain)
+		if strings.ToLower(event.Domain) != domain && name != domain {
+			return false
+		}
+	}
+	return true
+}
+
+// 按条件查询历史，最新的在前
+func (h *historyStore) query(filter historyFilter) ([]historyEvent, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return readHistory(h.path, filter)
+}
+
+// 以只读方式打开历史文件并查询，供 history 子命令和 GET /history 使用
+func readHistory(path string, filter historyFilter) ([]historyEvent, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: historyLockTimeout, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	events := []historyEvent{}
+	err = db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(historyEventsBucket)
+		if bucket == nil {
+			return nil
+		}
+		cursor := bucket.Cursor()
+		for key, value := cursor.Last(); key != nil; key, value = cursor.Prev() {
+			var event historyEvent
+			if err := json.Unmarshal(value, &event); err != nil {
+				return fmt.Errorf("invalid history event: %v", err)
+			}
+			if !filter.since.IsZero() && event.Time.Before(filter.since) {
+				return nil
+			}
+			if !filter.matches(event) {
+				continue
+			}
+			events = append(events, event)
+			if filter.limit > 0 && len(events) >= filter.limit {
+				return nil
+			}
+		}
+		return nil
+	})
+	return events, err
+}
@@ -0,0 +1,385 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 华为云配置，使用 AK/SK 或 ECS 委托获取的临时凭证
+type HuaweiConfig struct {
+	AccessKey string `json:"accessKey,omitempty"`
+	SecretKey string `json:"secretKey,omitempty"`
+	Auth      string `json:"auth,omitempty"`   // aksk（默认）或 agency（从 ECS 元数据获取委托的临时凭证）
+	Region    string `json:"region,omitempty"` // 例如 cn-north-4，未设置时使用全局地址
+}
+
+// 华为云的凭证类型
+const (
+	huaweiAuthAKSK   = "aksk"
+	huaweiAuthAgency = "agency"
+)
+
+// 华为云 ECS 元数据中委托的临时凭证地址
+const huaweiSecurityKeyURL = "http://169.254.169.254/openstack/latest/securitykey"
+
+// 每次获取的记录集数，华为云允许的最大值为 500
+const huaweiPageSize = 500
+
+// 临时凭证在过期前多久重新获取
+const huaweiCredentialRefresh = 5 * time.Minute
+
+// 华为云的 AK/SK 和可选的安全令牌
+type huaweiCredential struct {
+	accessKey     string
+	secretKey     string
+	securityToken string
+	expiresAt     time.Time
+}
+
+type huaweiProvider struct {
+	auth    string
+	client  *http.Client
+	baseURL string
+
+	mu         sync.Mutex
+	credential huaweiCredential
+	zones      map[string]string // 域名对应的 zone_id
+}
+
+func newHuaweiProvider(config Config, logger *slog.Logger) (*huaweiProvider, error) {
+	settings, err := config.httpSettings()
+	if err != nil {
+		return nil, err
+	}
+	// 与阿里云 API 使用相同的代理
+	client := &http.Client{
+		Timeout:   settings.timeout,
+		Transport: withDebugTransport(settings.newTransport("", settings.aliyunProxy), logger),
+	}
+	baseURL := "https://dns.myhuaweicloud.com"
+	if config.Huawei.Region != "" {
+		baseURL = "https://dns." + config.Huawei.Region + ".myhuaweicloud.com"
+	}
+	return &huaweiProvider{
+		auth:    config.Huawei.auth(),
+		client:  client,
+		baseURL: baseURL,
+		credential: huaweiCredential{
+			accessKey: config.Huawei.AccessKey,
+			secretKey: config.Huawei.SecretKey,
+		},
+		zones: make(map[string]string),
+	}, nil
+}
+
+// 返回凭证类型，默认为 aksk
+func (c HuaweiConfig) auth() string {
+	if c.Auth == "" {
+		return huaweiAuthAKSK
+	}
+	return c.Auth
+}
+
+// 返回当前可用的凭证，委托凭证快过期时重新从元数据获取
+func (p *huaweiProvider) currentCredential(ctx context.Context) (huaweiCredential, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.auth != huaweiAuthAgency || time.Until(p.credential.expiresAt) > huaweiCredentialRefresh {
+		return p.credential, nil
+	}
+
+	// 元数据服务只能直接访问，不使用代理
+	client := &http.Client{Timeout: p.client.Timeout, Transport: &http.Transport{}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, huaweiSecurityKeyURL, nil)
+	if err != nil {
+		return huaweiCredential{}, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return huaweiCredential{}, fmt.Errorf("failed to get Huawei Cloud agency credential: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return huaweiCredential{}, fmt.Errorf("failed to get Huawei Cloud agency credential: %s", resp.Status)
+	}
+	var response struct {
+		Credential struct {
+			Access        string    `json:"access"`
+			Secret        string    `json:"secret"`
+			SecurityToken string    `json:"securitytoken"`
+			ExpiresAt     time.Time `json:"expires_at"`
+		} `json:"credential"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return huaweiCredential{}, fmt.Errorf("invalid Huawei Cloud agency credential: %v", err)
+	}
+	p.credential = huaweiCredential{
+		accessKey:     response.Credential.Access,
+		secretKey:     response.Credential.Secret,
+		securityToken: response.Credential.SecurityToken,
+		expiresAt:     response.Credential.ExpiresAt,
+	}
+	return p.credential, nil
+}
+
+// 调用华为云 DNS API，请求使用 SDK-HMAC-SHA256 签名，解析返回内容到 result 中
+func (p *huaweiProvider) call(ctx context.Context, method, path string, query url.Values, body, result interface{}) error {
+	credential, err := p.currentCredential(ctx)
+	if err != nil {
+		return err
+	}
+	var payload []byte
+	if body != nil {
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	endpoint := p.baseURL + path
+	if len(query) > 0 {
+		endpoint += "?" + huaweiCanonicalQuery(query)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Sdk-Date", time.Now().UTC().Format("20060102T150405Z"))
+	if credential.securityToken != "" {
+		req.Header.Set("X-Security-Token", credential.securityToken)
+	}
+	huaweiSign(req, query, payload, credential)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	noteRequestID(ctx, resp.Header.Get("X-Request-Id"))
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		var e struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		}
+		json.Unmarshal(data, &e)
+		return &apiError{
+			message:   fmt.Sprintf("Huawei Cloud DNS API %s %s failed with %s: %s %s", method, path, resp.Status, e.Code, e.Message),
+			retryable: resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests,
+			throttled: resp.StatusCode == http.StatusTooManyRequests,
+			auth:      resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden,
+		}
+	}
+	if result != nil {
+		if err := json.Unmarshal(data, result); err != nil {
+			return fmt.Errorf("invalid Huawei Cloud DNS response: %v", err)
+		}
+	}
+	return nil
+}
+
+// 按华为云 API 网关的规则签名，签名的请求头包括 Content-Type、Host 和 X-Sdk-Date 等
+func huaweiSign(req *http.Request, query url.Values, payload []byte, credential huaweiCredential) {
+	headers := map[string]string{"host": req.URL.Host}
+	for name := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(req.Header.Get(name))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	// 路径的每一段单独编码，并以 / 结尾
+	uri := req.URL.EscapedPath()
+	if !strings.HasSuffix(uri, "/") {
+		uri += "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uri,
+		huaweiCanonicalQuery(query),
+		canonicalHeaders.String(),
+		signedHeaders,
+		sha256Hex(payload),
+	}, "\n")
+	stringToSign := "SDK-HMAC-SHA256\n" + req.Header.Get("X-Sdk-Date") + "\n" + sha256Hex([]byte(canonicalRequest))
+	signature := hex.EncodeToString(hmacSHA256([]byte(credential.secretKey), stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("SDK-HMAC-SHA256 Access=%s, SignedHeaders=%s, Signature=%s", credential.accessKey, signedHeaders, signature))
+}
+
+// 按参数名排序并编码查询参数，空格编码为 %20
+func huaweiCanonicalQuery(query url.Values) string {
+	return strings.ReplaceAll(query.Encode(), "+", "%20")
+}
+
+// 查询公网域名的 zone_id，结果会缓存
+func (p *huaweiProvider) zoneID(ctx context.Context, domainName string) (string, error) {
+	p.mu.Lock()
+	id, ok := p.zones[domainName]
+	p.mu.Unlock()
+	if ok {
+		return id, nil
+	}
+
+	var response struct {
+		Zones []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"zones"`
+	}
+	query := url.Values{"type": {"public"}, "name": {domainName + "."}}
+	if err := p.call(ctx, http.MethodGet, "/v2/zones", query, nil, &response); err != nil {
+		return "", err
+	}
+	// name 参数是模糊匹配，需要再按完整域名筛选
+	for _, zone := range response.Zones {
+		if strings.EqualFold(strings.TrimSuffix(zone.Name, "."), domainName) {
+			p.mu.Lock()
+			p.zones[domainName] = zone.ID
+			p.mu.Unlock()
+			return zone.ID, nil
+		}
+	}
+	return "", &apiError{message: fmt.Sprintf("zone %s not found in the Huawei Cloud account", domainName)}
+}
+
+func (p *huaweiProvider) listZones(ctx context.Context) ([]string, error) {
+	var all []string
+	for offset := 0; ; offset += huaweiPageSize {
+		var response struct {
+			Zones []struct {
+				Name string `json:"name"`
+			} `json:"zones"`
+			Metadata struct {
+				TotalCount int `json:"total_count"`
+			} `json:"metadata"`
+		}
+		query := url.Values{"type": {"public"}, "limit": {fmt.Sprint(huaweiPageSize)}, "offset": {fmt.Sprint(offset)}}
+		if err := p.call(ctx, http.MethodGet, "/v2/zones", query, nil, &response); err != nil {
+			return nil, err
+		}
+		for _, zone := range response.Zones {
+			all = append(all, strings.TrimSuffix(zone.Name, "."))
+		}
+		if len(response.Zones) == 0 || len(all) >= response.Metadata.TotalCount {
+			return all, nil
+		}
+	}
+}
+
+// 华为云返回的记录集，一个记录集可以包含多个值
+type huaweiRecordset struct {
+	ID      string   `json:"id,omitempty"`
+	Name    string   `json:"name"`
+	Type    string   `json:"type"`
+	TTL     int      `json:"ttl,omitempty"`
+	Records []string `json:"records"`
+}
+
+func (p *huaweiProvider) listRecords(ctx context.Context, domainName string) ([]dnsRecord, error) {
+	zoneID, err := p.zoneID(ctx, domainName)
+	if err != nil {
+		return nil, err
+	}
+
+	var all []dnsRecord
+	for offset, count := 0, 0; ; offset += huaweiPageSize {
+		var response struct {
+			Recordsets []huaweiRecordset `json:"recordsets"`
+			Metadata   struct {
+				TotalCount int `json:"total_count"`
+			} `json:"metadata"`
+		}
+		query := url.Values{"limit": {fmt.Sprint(huaweiPageSize)}, "offset": {fmt.Sprint(offset)}}
+		if err := p.call(ctx, http.MethodGet, "/v2/zones/"+zoneID+"/recordsets", query, nil, &response); err != nil {
+			return nil, err
+		}
+		for _, recordset := range response.Recordsets {
+			record := dnsRecord{
+				ID:   recordset.ID,
+				RR:   relativeRR(strings.TrimSuffix(recordset.Name, "."), domainName),
+				Type: recordset.Type,
+				TTL:  recordset.TTL,
+			}
+			if len(recordset.Records) > 0 {
+				record.Value = huaweiValue(recordset.Type, recordset.Records[0])
+			}
+			all = append(all, record)
+		}
+		count += len(response.Recordsets)
+		if len(response.Recordsets) == 0 || count >= response.Metadata.TotalCount {
+			return all, nil
+		}
+	}
+}
+
+func (p *huaweiProvider) createRecord(ctx context.Context, domainName string, record dnsRecord) (string, error) {
+	zoneID, err := p.zoneID(ctx, domainName)
+	if err != nil {
+		return "", err
+	}
+	body := huaweiRecordset{
+		Name:    fullRecordName(record.RR, domainName) + ".",
+		Type:    record.Type,
+		TTL:     record.TTL,
+		Records: []string{huaweiRecordValue(record)},
+	}
+	var created huaweiRecordset
+	if err := p.call(ctx, http.MethodPost, "/v2/zones/"+zoneID+"/recordsets", nil, body, &created); err != nil {
+		return "", err
+	}
+	return created.ID, nil
+}
+
+// 修改记录集时用新的 IP 替换记录集中的全部值
+func (p *huaweiProvider) updateRecord(ctx context.Context, domainName string, record dnsRecord) error {
+	zoneID, err := p.zoneID(ctx, domainName)
+	if err != nil {
+		return err
+	}
+	body := huaweiRecordset{
+		Name:    fullRecordName(record.RR, domainName) + ".",
+		Type:    record.Type,
+		TTL:     record.TTL,
+		Records: []string{huaweiRecordValue(record)},
+	}
+	return p.call(ctx, http.MethodPut, "/v2/zones/"+zoneID+"/recordsets/"+record.ID, nil, body, nil)
+}
+
+// 华为云的 TXT 记录值需要用双引号括起来
+func huaweiRecordValue(record dnsRecord) string {
+	if record.Type == "TXT" {
+		return strconv.Quote(record.Value)
+	}
+	return record.Value
+}
+
+// 去掉 TXT 记录值两边的引号，便于和配置的值比较
+func huaweiValue(recordType, value string) string {
+	if recordType == "TXT" {
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			return unquoted
+		}
+	}
+	return value
+}
@@ -0,0 +1,41 @@
+package updater
+
+import (
+	"fmt"
+	"net"
+
+	"DDns_go/pkg/ipdetect"
+)
+
+// 从网卡上选择公网地址，跳过链路本地地址、私有地址（allowPrivate 时不跳过），以及 IPv6 的临时地址（隐私扩展）和已弃用地址
+func getPublicIPFromInterface(name, network string, allowPrivate bool) (string, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return "", err
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return "", err
+	}
+
+	unusable, err := unusableIPv6Addrs(name)
+	if err != nil {
+		return "", err
+	}
+
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		ip := ipNet.IP
+		if (network == "tcp4") != (ip.To4() != nil) {
+			continue
+		}
+		if !ip.IsGlobalUnicast() || ipdetect.CheckPublic(ip.String(), allowPrivate) != nil || unusable[ip.String()] {
+			continue
+		}
+		return ip.String(), nil
+	}
+	return "", fmt.Errorf("no usable global address on interface %s", name)
+}
@@ -0,0 +1,56 @@
+package updater
+
+import (
+	"bufio"
+	"encoding/hex"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// /proc/net/if_inet6 中地址标志的含义，见 linux/if_addr.h
+const (
+	ifaFlagTemporary  = 0x01
+	ifaFlagDadFailed  = 0x08
+	ifaFlagDeprecated = 0x20
+	ifaFlagTentative  = 0x40
+)
+
+// 返回网卡上不应发布到 DNS 的 IPv6 地址：临时地址、已弃用地址以及未通过重复地址检测的地址
+func unusableIPv6Addrs(name string) (map[string]bool, error) {
+	file, err := os.Open("/proc/net/if_inet6")
+	if os.IsNotExist(err) {
+		// 未启用 IPv6
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	unusable := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// 格式：地址 网卡序号 前缀长度 范围 标志 网卡名称
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 6 || fields[5] != name {
+			continue
+		}
+
+		flags, err := strconv.ParseUint(fields[4], 16, 32)
+		if err != nil {
+			continue
+		}
+		if flags&(ifaFlagTemporary|ifaFlagDadFailed|ifaFlagDeprecated|ifaFlagTentative) == 0 {
+			continue
+		}
+
+		raw, err := hex.DecodeString(fields[0])
+		if err != nil || len(raw) != net.IPv6len {
+			continue
+		}
+		unusable[net.IP(raw).String()] = true
+	}
+	return unusable, scanner.Err()
+}
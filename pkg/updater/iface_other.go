@@ -0,0 +1,8 @@
+//go:build !linux
+
+package updater
+
+// 其它系统无法直接获取地址标志，不过滤临时地址
+func unusableIPv6Addrs(name string) (map[string]bool, error) {
+	return nil, nil
+}
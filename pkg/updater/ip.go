@@ -0,0 +1,155 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"DDns_go/pkg/ipdetect"
+)
+
+// 按协议族配置的获取方式检测公网 IP，返回 IP 以及提供该 IP 的来源
+func detectPublicIP(ctx context.Context, family ipFamily, logger *slog.Logger) (string, string, error) {
+	switch family.source.Source {
+	case "http":
+		// 强制使用指定的协议族访问 API，这样返回的就是对应协议族的公网地址
+		get := detectWith(func(url, network string) ipdetect.Detector {
+			return ipdetect.HTTP{URL: url, Client: ipHTTPClients[family.source.binding(network).key()]}
+		})
+		return tryEach(ctx, family, family.source.URLs, get, logger)
+	case "stun":
+		servers := family.source.Servers
+		if len(servers) == 0 {
+			servers = ipdetect.DefaultSTUNServers
+		}
+		get := detectWith(func(server, _ string) ipdetect.Detector { return ipdetect.STUN{Server: server} })
+		return tryEach(ctx, family, servers, get, logger)
+	case "dns":
+		queries := family.source.Servers
+		if len(queries) == 0 {
+			queries = ipdetect.DefaultDNSQueries
+			if family.network == "tcp6" {
+				queries = ipdetect.DefaultDNSQueriesV6
+			}
+		}
+		get := detectWith(func(query, _ string) ipdetect.Detector { return ipdetect.DNS{Query: query} })
+		return tryEach(ctx, family, queries, get, logger)
+	case "interface":
+		if family.source.Name == "" {
+			return "", "", errors.New("interface name is required for interface source")
+		}
+		run := func(_ context.Context, name, network string) (string, error) {
+			return getPublicIPFromInterface(name, network, family.allowPrivate)
+		}
+		return tryEach(ctx, family, []string{family.source.Name}, run, logger)
+	case "command":
+		run := func(ctx context.Context, _, network string) (string, error) {
+			return getPublicIPFromCommand(ctx, family.source, network)
+		}
+		return tryEach(ctx, family, []string{strings.Join(family.source.Command, " ")}, run, logger)
+	case "kubernetes":
+		run := func(ctx context.Context, _, network string) (string, error) {
+			return getPublicIPFromKubernetes(ctx, family.source, network)
+		}
+		return tryEach(ctx, family, []string{kubernetesObjectName(family.source)}, run, logger)
+	case "plugin":
+		run := func(ctx context.Context, _, _ string) (string, error) {
+			return getPublicIPFromPlugin(ctx, family)
+		}
+		return tryEach(ctx, family, []string{family.source.Plugin}, run, logger)
+	case "file":
+		if family.source.Path == "" {
+			return "", "", errors.New("path is required for file source")
+		}
+		get := detectWith(func(path, _ string) ipdetect.Detector { return ipdetect.File{Path: path} })
+		return tryEach(ctx, family, []string{family.source.Path}, get, logger)
+	case "upnp":
+		locations := family.source.URLs
+		if len(locations) == 0 {
+			discovered, err := discoverUPnPGateways(ctx)
+			if err != nil {
+				return "", "", err
+			}
+			locations = discovered
+		}
+		return tryEach(ctx, family, locations, getPublicIPByUPnP, logger)
+	case "natpmp":
+		gateways := family.source.Servers
+		if len(gateways) == 0 {
+			gateway, err := defaultGateway()
+			if err != nil {
+				return "", "", err
+			}
+			gateways = []string{gateway}
+		}
+		return tryEach(ctx, family, gateways, getPublicIPByNATPMP, logger)
+	default:
+		return "", "", fmt.Errorf("unknown ip source: %s", family.source.Source)
+	}
+}
+
+// 按顺序尝试每个来源，返回第一个成功获取的 IP 以及对应的来源。
+// 设置了 quorum 时继续查询，直到有 quorum 个来源返回相同的地址，返回的来源为这些来源的列表
+func tryEach(ctx context.Context, family ipFamily, addrs []string, get func(ctx context.Context, addr, network string) (string, error), logger *slog.Logger) (string, string, error) {
+	quorum := family.source.Quorum
+	if quorum > 1 && quorum > len(addrs) {
+		return "", "", fmt.Errorf("quorum %d needs at least %d sources, only %d configured", quorum, quorum, len(addrs))
+	}
+
+	votes := make(map[string][]string) // 每个地址以及返回该地址的来源
+	var order []string
+	for _, addr := range addrs {
+		// 超时或正在退出时不再尝试剩余的来源
+		if err := ctx.Err(); err != nil {
+			return "", "", err
+		}
+		ip, err := get(ctx, addr, family.network)
+		if err == nil {
+			err = ipdetect.CheckFamily(ip, family.network)
+		}
+		if err == nil {
+			err = ipdetect.CheckPublic(ip, family.allowPrivate)
+		}
+		if err != nil {
+			logger.Warn("Failed to get public IP", "family", family.name, "provider", addr, "error", err)
+			metrics.inc("ddns_ip_detection_failures_total", "family", family.name, "provider", addr)
+			continue
+		}
+		if quorum <= 1 {
+			return ip, addr, nil
+		}
+
+		if _, seen := votes[ip]; !seen {
+			order = append(order, ip)
+		}
+		votes[ip] = append(votes[ip], addr)
+		if len(votes[ip]) >= quorum {
+			if len(order) > 1 {
+				logger.Warn("Public IP sources disagree", "family", family.name, "ip", ip, "votes", formatVotes(order, votes))
+			}
+			return ip, strings.Join(votes[ip], ","), nil
+		}
+	}
+	if quorum > 1 && len(order) > 0 {
+		return "", "", fmt.Errorf("no %d %s sources agree on the address: %s", quorum, family.source.Source, formatVotes(order, votes))
+	}
+	return "", "", fmt.Errorf("all %d %s sources failed", len(addrs), family.source.Source)
+}
+
+// 把每个地址以及返回该地址的来源格式化为 "1.2.3.4 (a, b); 5.6.7.8 (c)"
+func formatVotes(order []string, votes map[string][]string) string {
+	parts := make([]string, len(order))
+	for i, ip := range order {
+		parts[i] = ip + " (" + strings.Join(votes[ip], ", ") + ")"
+	}
+	return strings.Join(parts, "; ")
+}
+
+// 把 ipdetect 的获取方式转换为 tryEach 使用的函数，newDetector 根据来源地址创建 Detector
+func detectWith(newDetector func(addr, network string) ipdetect.Detector) func(ctx context.Context, addr, network string) (string, error) {
+	return func(ctx context.Context, addr, network string) (string, error) {
+		return newDetector(addr, network).Detect(ctx, network)
+	}
+}
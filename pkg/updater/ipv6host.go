@@ -0,0 +1,83 @@
+package updater
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"sort"
+)
+
+// ipv6Hosts 默认使用的前缀长度，与运营商下发的 /56、/60 前缀中分配给局域网的 /64 子网对应
+const defaultPrefixLength = 64
+
+// 域名使用的前缀长度，未设置 prefixLength 时为 64
+func (d DomainConfig) prefixLength() int {
+	if d.PrefixLength == 0 {
+		return defaultPrefixLength
+	}
+	return d.PrefixLength
+}
+
+// ipv6Hosts 中的主机记录，按名称排序
+func (d DomainConfig) ipv6HostRRs() []string {
+	rrs := make([]string, 0, len(d.IPv6Hosts))
+	for rr := range d.IPv6Hosts {
+		rrs = append(rrs, rr)
+	}
+	sort.Strings(rrs)
+	return rrs
+}
+
+// 解析局域网主机的接口标识：MAC 地址按 EUI-64 转换为低 64 位，例如 00:11:22:33:44:55 为 ::211:22ff:fe33:4455；
+// 否则为 IPv6 地址，例如 ::1a2b 或 SLAAC 生成的完整地址，使用其中前缀之后的部分
+func parseInterfaceID(value string) (netip.Addr, error) {
+	if mac, err := net.ParseMAC(value); err == nil {
+		if len(mac) != 6 {
+			return netip.Addr{}, fmt.Errorf("%q is not a 48-bit MAC address", value)
+		}
+		var b [16]byte
+		b[8], b[9], b[10], b[11] = mac[0]^0x02, mac[1], mac[2], 0xff
+		b[12], b[13], b[14], b[15] = 0xfe, mac[3], mac[4], mac[5]
+		return netip.AddrFrom16(b), nil
+	}
+	addr, err := netip.ParseAddr(value)
+	if err != nil || !addr.Is6() || addr.Is4In6() || addr.Zone() != "" {
+		return netip.Addr{}, fmt.Errorf("%q is neither a MAC address nor an IPv6 interface identifier", value)
+	}
+	return addr, nil
+}
+
+// 用 prefix 的前 bits 位和 id 之后的部分拼出局域网主机的地址。
+// prefix 为本次检测到的 IPv6 地址，运营商更换下发的前缀后所有主机的地址随之改变
+func withInterfaceID(prefix, id string, bits int) (string, error) {
+	addr, err := netip.ParseAddr(prefix)
+	if err != nil || !addr.Is6() {
+		return "", fmt.Errorf("detected address %q is not an IPv6 address", prefix)
+	}
+	suffix, err := parseInterfaceID(id)
+	if err != nil {
+		return "", err
+	}
+	p, s := addr.As16(), suffix.As16()
+	for i := range p {
+		// 每个字节中属于前缀的高位
+		var mask byte
+		switch n := bits - i*8; {
+		case n >= 8:
+			mask = 0xff
+		case n > 0:
+			mask = 0xff << (8 - n)
+		}
+		p[i] = p[i]&mask | s[i]&^mask
+	}
+	return netip.AddrFrom16(p).String(), nil
+}
+
+// 返回记录对应的地址：AAAA 记录在 ipv6Hosts 中配置了接口标识时为局域网主机的地址，否则为检测到的公网 IP
+func (d DomainConfig) hostAddress(rr, recordType, publicIP string) (string, error) {
+	id, ok := d.IPv6Hosts[rr]
+	if !ok || recordType != "AAAA" {
+		return publicIP, nil
+	}
+	return withInterfaceID(publicIP, id, d.prefixLength())
+}
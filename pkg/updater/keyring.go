@@ -0,0 +1,82 @@
+package updater
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/zalando/go-keyring"
+)
+
+// keyring 方式默认的服务名称，macOS 钥匙串、Windows 凭据管理器和 libsecret 中显示为该名称
+const defaultKeyringService = "DDns_go"
+
+// 同一个服务名称下保存 AccessKey ID 和 secret 的两个条目
+const (
+	keyringAccessKeyUser    = "accessKey"
+	keyringAccessSecretUser = "accessSecret"
+)
+
+// keyring 方式使用的服务名称
+func (c *CredentialConfig) keyringService() string {
+	if c.Service == "" {
+		return defaultKeyringService
+	}
+	return c.Service
+}
+
+// 从系统钥匙串读取 service 下保存的 AccessKey
+func readKeyring(service string) (string, string, error) {
+	accessKey, err := keyring.Get(service, keyringAccessKeyUser)
+	if err == nil {
+		var accessSecret string
+		if accessSecret, err = keyring.Get(service, keyringAccessSecretUser); err == nil {
+			return accessKey, accessSecret, nil
+		}
+	}
+	if errors.Is(err, keyring.ErrNotFound) {
+		return "", "", fmt.Errorf("no AccessKey stored in the system keyring for service %q, run the keyring set command first", service)
+	}
+	return "", "", fmt.Errorf("failed to read the system keyring: %v", err)
+}
+
+// 把 AccessKey 保存到系统钥匙串，已有的条目会被覆盖
+func writeKeyring(service, accessKey, accessSecret string) error {
+	if err := keyring.Set(service, keyringAccessKeyUser, accessKey); err != nil {
+		return err
+	}
+	return keyring.Set(service, keyringAccessSecretUser, accessSecret)
+}
+
+// 删除系统钥匙串中 service 下保存的 AccessKey
+func deleteKeyring(service string) error {
+	for _, user := range []string{keyringAccessKeyUser, keyringAccessSecretUser} {
+		if err := keyring.Delete(service, user); err != nil && !errors.Is(err, keyring.ErrNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// credentials.type 为 keyring 时从系统钥匙串读取 AccessKey，填入 accessKey/accessSecret，accounts 中的账号同样处理。
+// 只在需要调用 API 时读取，status 等子命令不会访问钥匙串
+func (c *Config) loadKeyringCredentials() error {
+	if c.Credentials != nil && c.Credentials.Type == "keyring" {
+		accessKey, accessSecret, err := readKeyring(c.Credentials.keyringService())
+		if err != nil {
+			return err
+		}
+		c.AccessKey, c.AccessSecret = accessKey, accessSecret
+	}
+	for i := range c.Accounts {
+		account := &c.Accounts[i]
+		if account.Credentials == nil || account.Credentials.Type != "keyring" {
+			continue
+		}
+		accessKey, accessSecret, err := readKeyring(account.Credentials.keyringService())
+		if err != nil {
+			return fmt.Errorf("account %s: %v", account.Name, err)
+		}
+		account.AccessKey, account.AccessSecret = accessKey, accessSecret
+	}
+	return nil
+}
@@ -0,0 +1,282 @@
+package updater
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"DDns_go/pkg/ipdetect"
+)
+
+// 在集群中运行时 Pod 挂载的服务账号目录
+const kubernetesServiceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// 未设置 node 和 service 时使用的节点名称，通常通过 Downward API 设置为 spec.nodeName
+const kubernetesNodeNameEnv = "NODE_NAME"
+
+// watch 断开后重新连接的最长等待时间
+const kubernetesWatchMaxDelay = time.Minute
+
+// 访问 Kubernetes API 的客户端
+type kubernetesClient struct {
+	base      string
+	client    *http.Client
+	tokenFile string // 服务账号的令牌，每次请求时读取，令牌会定期轮换。通过 kubectl proxy 访问时为空
+}
+
+// 集群内的客户端，使用服务账号的 CA 证书和令牌访问 KUBERNETES_SERVICE_HOST
+var inClusterKubernetesClient = sync.OnceValues(func() (*kubernetesClient, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("not running in a Kubernetes cluster, set urls to the API server address, for example kubectl proxy")
+	}
+	ca, err := os.ReadFile(filepath.Join(kubernetesServiceAccountDir, "ca.crt"))
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.New("invalid service account ca.crt")
+	}
+	transport := &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}}
+	return &kubernetesClient{
+		base:      "https://" + net.JoinHostPort(host, port),
+		client:    &http.Client{Transport: transport},
+		tokenFile: filepath.Join(kubernetesServiceAccountDir, "token"),
+	}, nil
+})
+
+// 通过 urls 访问 API 时使用的客户端，通常是本机的 kubectl proxy，不需要认证
+var kubernetesProxyClient = &http.Client{}
+
+// urls 指定 API 地址时直接访问该地址，否则使用集群内的服务账号
+func kubernetesClientFor(source SourceConfig) (*kubernetesClient, error) {
+	if len(source.URLs) > 0 {
+		return &kubernetesClient{base: strings.TrimSuffix(source.URLs[0], "/"), client: kubernetesProxyClient}, nil
+	}
+	return inClusterKubernetesClient()
+}
+
+// 发送 GET 请求，返回的 Body 需要由调用方关闭
+func (k *kubernetesClient) get(ctx context.Context, path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, k.base+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if k.tokenFile != "" {
+		token, err := os.ReadFile(k.tokenFile)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		var e struct {
+			Message string `json:"message"`
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		json.Unmarshal(data, &e)
+		return nil, &apiError{
+			message:   fmt.Sprintf("Kubernetes API GET %s failed with %s: %s", path, resp.Status, e.Message),
+			retryable: resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests,
+			throttled: resp.StatusCode == http.StatusTooManyRequests,
+			auth:      resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden,
+		}
+	}
+	return resp, nil
+}
+
+// Node 或 Service 中与地址有关的字段
+type kubernetesObject struct {
+	Status struct {
+		Addresses []struct {
+			Type    string `json:"type"`
+			Address string `json:"address"`
+		} `json:"addresses"`
+		LoadBalancer struct {
+			Ingress []struct {
+				IP string `json:"ip"`
+			} `json:"ingress"`
+		} `json:"loadBalancer"`
+	} `json:"status"`
+}
+
+// Node 的 ExternalIP 地址，或 LoadBalancer 类型的 Service 的入口地址
+func (o kubernetesObject) addresses() []string {
+	var addresses []string
+	for _, address := range o.Status.Addresses {
+		if address.Type == "ExternalIP" {
+			addresses = append(addresses, address.Address)
+		}
+	}
+	for _, ingress := range o.Status.LoadBalancer.Ingress {
+		if ingress.IP != "" {
+			addresses = append(addresses, ingress.IP)
+		}
+	}
+	return addresses
+}
+
+// 读取的对象：service 为 namespace/name 时为该 Service，否则为 node 指定的节点，都未设置时为 NODE_NAME 环境变量中的节点。
+// 返回对象所在的集合的路径和对象名称
+func kubernetesObjectPath(source SourceConfig) (string, string, error) {
+	if source.Service != "" {
+		namespace, name, ok := strings.Cut(source.Service, "/")
+		if !ok || namespace == "" || name == "" {
+			return "", "", fmt.Errorf("invalid service %q, expected namespace/name", source.Service)
+		}
+		return "/api/v1/namespaces/" + url.PathEscape(namespace) + "/services", name, nil
+	}
+	node := source.Node
+	if node == "" {
+		node = os.Getenv(kubernetesNodeNameEnv)
+	}
+	if node == "" {
+		return "", "", fmt.Errorf("node is required for kubernetes source when %s is not set", kubernetesNodeNameEnv)
+	}
+	return "/api/v1/nodes", node, nil
+}
+
+// 日志和状态中显示的来源，例如 node/k3s-master 或 service/default/traefik
+func kubernetesObjectName(source SourceConfig) string {
+	if source.Service != "" {
+		return "service/" + source.Service
+	}
+	if source.Node != "" {
+		return "node/" + source.Node
+	}
+	return "node/" + os.Getenv(kubernetesNodeNameEnv)
+}
+
+// 读取 Node 或 Service 的外部地址，返回与 network 协议族相同的第一个地址
+func getPublicIPFromKubernetes(ctx context.Context, source SourceConfig, network string) (string, error) {
+	client, err := kubernetesClientFor(source)
+	if err != nil {
+		return "", err
+	}
+	collection, name, err := kubernetesObjectPath(source)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.get(ctx, collection+"/"+url.PathEscape(name))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var object kubernetesObject
+	if err := json.NewDecoder(resp.Body).Decode(&object); err != nil {
+		return "", fmt.Errorf("invalid Kubernetes response: %v", err)
+	}
+	for _, address := range object.addresses() {
+		if ipdetect.CheckFamily(address, network) == nil {
+			return address, nil
+		}
+	}
+	return "", fmt.Errorf("%s has no external address for %s", kubernetesObjectName(source), network)
+}
+
+// 配置中使用 kubernetes 方式的 IP 获取方式
+func (c Config) kubernetesSources() []SourceConfig {
+	var sources []SourceConfig
+	for _, source := range []*SourceConfig{c.IPv4Source, c.IPv6Source} {
+		if source != nil && source.Source == "kubernetes" {
+			sources = append(sources, *source)
+		}
+	}
+	return sources
+}
+
+// 通过 watch 接口监听 Node 或 Service 的变化，外部地址变化时发送一次通知。连接断开后按指数退避重新连接，ctx 取消后停止
+func watchKubernetes(ctx context.Context, sources []SourceConfig, logger *slog.Logger) <-chan struct{} {
+	changes := make(chan struct{}, 1)
+	for _, source := range sources {
+		go func(source SourceConfig) {
+			var last []string
+			seen := false
+			delay := time.Second
+			for ctx.Err() == nil {
+				err := watchKubernetesObject(ctx, source, func(addresses []string) {
+					if seen && !reflect.DeepEqual(addresses, last) {
+						logger.Info("Kubernetes address changed", "object", kubernetesObjectName(source), "addresses", strings.Join(addresses, ","), "old_addresses", strings.Join(last, ","))
+						select {
+						case changes <- struct{}{}:
+						default:
+						}
+					}
+					last, seen = addresses, true
+					delay = time.Second
+				})
+				if ctx.Err() != nil {
+					return
+				}
+				if err != nil {
+					logger.Warn("Kubernetes watch failed, reconnecting", "object", kubernetesObjectName(source), "wait", delay.String(), "error", err)
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(delay):
+				}
+				delay = min(delay*2, kubernetesWatchMaxDelay)
+			}
+		}(source)
+	}
+	return changes
+}
+
+// 建立一次 watch 连接，每收到一个事件用对象当前的地址调用 changed，服务端结束连接时返回 nil
+func watchKubernetesObject(ctx context.Context, source SourceConfig, changed func(addresses []string)) error {
+	client, err := kubernetesClientFor(source)
+	if err != nil {
+		return err
+	}
+	collection, name, err := kubernetesObjectPath(source)
+	if err != nil {
+		return err
+	}
+	query := url.Values{"watch": {"1"}, "fieldSelector": {"metadata.name=" + name}, "timeoutSeconds": {"300"}}
+	resp, err := client.get(ctx, collection+"?"+query.Encode())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var event struct {
+			Type   string           `json:"type"`
+			Object kubernetesObject `json:"object"`
+		}
+		if err := decoder.Decode(&event); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		switch event.Type {
+		case "ADDED", "MODIFIED":
+			changed(event.Object.addresses())
+		case "ERROR":
+			return errors.New("watch returned an error event")
+		}
+	}
+}
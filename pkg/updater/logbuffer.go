@@ -0,0 +1,102 @@
+package updater
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// 内存中保留的日志行数
+const recentLogLimit = 200
+
+// 一行日志，供 Web 控制台显示
+type logEntry struct {
+	Time    time.Time `json:"time"`
+	Level   string    `json:"level"`
+	Message string    `json:"message"`
+}
+
+// 保存最近的日志，超过 recentLogLimit 行后覆盖最早的。写满后不再分配新的切片
+type logBuffer struct {
+	mu      sync.Mutex
+	entries []logEntry // 环形缓冲区
+	next    int        // 下一条日志写入的位置
+}
+
+// 进程内共享的最近日志
+var recentLogs = &logBuffer{}
+
+func (b *logBuffer) writeLine(level slog.Level, t time.Time, line []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	entry := logEntry{Time: t, Level: level.String(), Message: string(line)}
+	if len(b.entries) < recentLogLimit {
+		b.entries = append(b.entries, entry)
+	} else {
+		b.entries[b.next] = entry
+	}
+	b.next = (b.next + 1) % recentLogLimit
+	return nil
+}
+
+// 返回最近的日志，最新的在前
+func (b *logBuffer) recent() []logEntry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	n := len(b.entries)
+	entries := make([]logEntry, n)
+	for i := range entries {
+		entries[i] = b.entries[(b.next-1-i+n)%n]
+	}
+	return entries
+}
+
+// 把每条日志同时交给多个 Handler 处理
+type teeHandler struct {
+	handlers []slog.Handler
+}
+
+func newTeeHandler(handlers ...slog.Handler) *teeHandler {
+	return &teeHandler{handlers: handlers}
+}
+
+func (h *teeHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, handler := range h.handlers {
+		if handler.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *teeHandler) Handle(ctx context.Context, record slog.Record) error {
+	var firstErr error
+	for _, handler := range h.handlers {
+		if !handler.Enabled(ctx, record.Level) {
+			continue
+		}
+		if err := handler.Handle(ctx, record.Clone()); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (h *teeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make([]slog.Handler, len(h.handlers))
+	for i, handler := range h.handlers {
+		handlers[i] = handler.WithAttrs(attrs)
+	}
+	return &teeHandler{handlers: handlers}
+}
+
+func (h *teeHandler) WithGroup(name string) slog.Handler {
+	handlers := make([]slog.Handler, len(h.handlers))
+	for i, handler := range h.handlers {
+		handlers[i] = handler.WithGroup(name)
+	}
+	return &teeHandler{handlers: handlers}
+}
@@ -0,0 +1,212 @@
+package updater
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 日志轮转配置
+type LogRotateConfig struct {
+	MaxSize    int  `json:"maxSize"`    // 单个日志文件的最大大小（MB），超过后轮转，为 0 时不按大小轮转
+	MaxBackups int  `json:"maxBackups"` // 保留的旧日志文件数，为 0 时不限制
+	MaxAge     int  `json:"maxAge"`     // 旧日志文件保留的天数，为 0 时不限制
+	Compress   bool `json:"compress"`   // 是否使用 gzip 压缩旧日志文件
+}
+
+// 轮转后的旧日志文件名中的时间格式
+const logBackupTimeFormat = "20060102-150405"
+
+// 支持按大小轮转和重新打开的日志文件，可以安全地被多个 goroutine 使用
+type logFile struct {
+	mu     sync.Mutex
+	path   string
+	rotate LogRotateConfig
+	file   *os.File
+	size   int64
+}
+
+// 打开日志文件，rotate 为 nil 时不轮转
+func openLogFile(path string, rotate *LogRotateConfig) (*logFile, error) {
+	l := &logFile{path: path}
+	if rotate != nil {
+		l.rotate = *rotate
+	}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *logFile) open() error {
+	file, err := os.OpenFile(l.path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	l.file = file
+	l.size = info.Size()
+	return nil
+}
+
+func (l *logFile) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return 0, os.ErrClosed
+	}
+	maxSize := int64(l.rotate.MaxSize) * 1024 * 1024
+	if maxSize > 0 && l.size > 0 && l.size+int64(len(p)) > maxSize {
+		// 轮转失败时继续写入当前文件，避免丢失日志
+		if err := l.rotateLocked(); err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to rotate log file:", err)
+		}
+	}
+
+	n, err := l.file.Write(p)
+	l.size += int64(n)
+	return n, err
+}
+
+// 关闭并重新打开日志文件，配合 logrotate 等外部工具使用
+func (l *logFile) Reopen() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file != nil {
+		l.file.Close()
+		l.file = nil
+	}
+	return l.open()
+}
+
+func (l *logFile) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// 将当前日志文件重命名为带时间的备份文件，然后打开新的日志文件
+func (l *logFile) rotateLocked() error {
+	// 同一秒内多次轮转时追加递增的序号
+	backup := l.path + "." + time.Now().Format(logBackupTimeFormat)
+	if existing, _ := filepath.Glob(backup + "*"); len(existing) > 0 {
+		seq := 0
+		for _, path := range existing {
+			seq = max(seq, backupSeq(strings.TrimPrefix(path, backup)))
+		}
+		backup = fmt.Sprintf("%s.%d", backup, seq+1)
+	}
+
+	if err := os.Rename(l.path, backup); err != nil {
+		return err
+	}
+	l.file.Close()
+	l.file = nil
+	if err := l.open(); err != nil {
+		return err
+	}
+
+	if l.rotate.Compress {
+		if err := compressFile(backup); err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to compress log file:", err)
+		}
+	}
+	l.removeOldBackups()
+	return nil
+}
+
+// 解析备份文件名中时间之后的部分（例如 .2.gz）得到序号，没有序号时返回 0
+func backupSeq(suffix string) int {
+	seq, _ := strconv.Atoi(strings.TrimPrefix(strings.TrimSuffix(suffix, ".gz"), "."))
+	return seq
+}
+
+// 按 maxBackups 和 maxAge 删除多余的旧日志文件
+func (l *logFile) removeOldBackups() {
+	if l.rotate.MaxBackups == 0 && l.rotate.MaxAge == 0 {
+		return
+	}
+
+	type backup struct {
+		path string
+		time time.Time
+		seq  int // 同一秒内轮转的序号
+	}
+	matches, _ := filepath.Glob(l.path + ".*")
+	var backups []backup
+	for _, match := range matches {
+		suffix := strings.TrimPrefix(match, l.path+".")
+		if len(suffix) < len(logBackupTimeFormat) {
+			continue
+		}
+		t, err := time.ParseInLocation(logBackupTimeFormat, suffix[:len(logBackupTimeFormat)], time.Local)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backup{path: match, time: t, seq: backupSeq(suffix[len(logBackupTimeFormat):])})
+	}
+	// 按轮转的先后从新到旧排序
+	sort.Slice(backups, func(i, j int) bool {
+		if backups[i].time.Equal(backups[j].time) {
+			return backups[i].seq > backups[j].seq
+		}
+		return backups[i].time.After(backups[j].time)
+	})
+
+	cutoff := time.Now().AddDate(0, 0, -l.rotate.MaxAge)
+	for i, b := range backups {
+		if (l.rotate.MaxBackups > 0 && i >= l.rotate.MaxBackups) || (l.rotate.MaxAge > 0 && b.time.Before(cutoff)) {
+			os.Remove(b.path)
+		}
+	}
+}
+
+// 使用 gzip 压缩文件，成功后删除原文件
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); err != nil {
+		gz.Close()
+		dst.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		dst.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	src.Close()
+	return os.Remove(path)
+}
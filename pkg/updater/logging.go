@@ -0,0 +1,326 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 解析日志级别，为空时使用 info
+func parseLogLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(name) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return 0, fmt.Errorf("unknown log level: %s", name)
+	}
+}
+
+// 按 logFormat 创建日志，text 为与旧版相同的单行格式，json 为每行一个 JSON 对象，方便导入 Loki、ELK 等。
+// 写入前会隐藏 secrets 以及看起来像 AccessKey 的内容
+func newLogger(w io.Writer, format, levelName string, secrets []string) (*slog.Logger, error) {
+	level, err := parseLogLevel(levelName)
+	if err != nil {
+		return nil, err
+	}
+
+	var handler slog.Handler
+	switch format {
+	case "", "text":
+		handler = newTextHandler(textLineWriter{w}, level)
+	case "json":
+		handler = slog.NewJSONHandler(w, &slog.HandlerOptions{
+			Level: level,
+			ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
+				// 使用 timestamp 作为时间字段的名称
+				if len(groups) == 0 && attr.Key == slog.TimeKey {
+					attr.Key = "timestamp"
+				}
+				return attr
+			},
+		})
+	default:
+		return nil, fmt.Errorf("unknown log format: %s", format)
+	}
+	return slog.New(newRedactHandler(handler, newRedactor(secrets))), nil
+}
+
+// 日志的输出目标
+type logOutput struct {
+	logger *slog.Logger
+	file   *logFile  // 输出到文件时不为空，收到 SIGHUP 时重新打开
+	closer io.Closer // 退出时需要关闭的文件或 syslog 连接
+}
+
+// 按 logTarget 打开日志输出，最近的日志同时保存在内存中供 Web 控制台显示
+func openLogOutput(config Config) (*logOutput, error) {
+	level, err := parseLogLevel(config.LogLevel)
+	if err != nil {
+		return nil, err
+	}
+	output, err := openLogTarget(config, level)
+	if err != nil {
+		return nil, err
+	}
+	recent := newRedactHandler(newTextHandler(recentLogs, level), newRedactor(config.secrets()))
+	output.logger = slog.New(newTeeHandler(output.logger.Handler(), recent))
+	return output, nil
+}
+
+// 打开 logTarget 指定的输出：file、stdout、stderr、both、journald 或 syslog
+func openLogTarget(config Config, level slog.Level) (*logOutput, error) {
+	switch target := config.logTarget(); target {
+	case "file", "both":
+		file, err := openLogFile(filepath.Join(config.LogFileName), config.LogRotate)
+		if err != nil {
+			return nil, err
+		}
+		logger, err := newLogger(file, config.LogFormat, config.LogLevel, config.secrets())
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		// 同时写入标准输出，文件写入失败时不影响标准输出
+		if target == "both" {
+			stdout, _ := newLogger(os.Stdout, config.LogFormat, config.LogLevel, config.secrets())
+			logger = slog.New(newTeeHandler(logger.Handler(), stdout.Handler()))
+		}
+		return &logOutput{logger: logger, file: file, closer: file}, nil
+	case "stdout", "stderr":
+		w := os.Stdout
+		if target == "stderr" {
+			w = os.Stderr
+		}
+		logger, err := newLogger(w, config.LogFormat, config.LogLevel, config.secrets())
+		if err != nil {
+			return nil, err
+		}
+		return &logOutput{logger: logger}, nil
+	case "journald":
+		// systemd 会把标准错误输出写入 journal，并按前缀识别优先级
+		handler := newTextHandler(journaldLineWriter{os.Stderr}, level)
+		return &logOutput{logger: slog.New(newRedactHandler(handler, newRedactor(config.secrets())))}, nil
+	case "syslog":
+		writer, err := newSyslogLineWriter(config.Syslog)
+		if err != nil {
+			return nil, err
+		}
+		handler := newTextHandler(writer, level)
+		return &logOutput{logger: slog.New(newRedactHandler(handler, newRedactor(config.secrets()))), closer: writer}, nil
+	default:
+		return nil, fmt.Errorf("unknown log target: %s", config.LogTarget)
+	}
+}
+
+// 重新打开日志文件，其它目标不需要处理
+func (o *logOutput) Reopen() error {
+	if o.file == nil {
+		return nil
+	}
+	return o.file.Reopen()
+}
+
+func (o *logOutput) Close() error {
+	if o.closer == nil {
+		return nil
+	}
+	return o.closer.Close()
+}
+
+// 记录错误日志后退出
+func fatal(logger *slog.Logger, code int, msg string, args ...any) {
+	logger.Error(msg, args...)
+	os.Exit(code)
+}
+
+// 格式化日志时复用的缓冲区，每条日志不再分配新的缓冲区
+var logBufferPool = sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+
+// 超过该大小的缓冲区用完后不放回 logBufferPool，避免一条很长的日志让缓冲区一直占用内存
+const maxPooledLogBuffer = 16 * 1024
+
+func getLogBuffer() *bytes.Buffer {
+	buf := logBufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+func putLogBuffer(buf *bytes.Buffer) {
+	if buf.Cap() <= maxPooledLogBuffer {
+		logBufferPool.Put(buf)
+	}
+}
+
+// 输出一条已格式化好的日志（消息和字段），不同的目标使用不同的前缀。line 只在调用期间有效，不能保留
+type lineWriter interface {
+	writeLine(level slog.Level, t time.Time, line []byte) error
+}
+
+// 写入文件或标准输出的日志：DDns: 日期 时间 级别 消息 key=value ...
+type textLineWriter struct {
+	w io.Writer
+}
+
+func (t textLineWriter) writeLine(level slog.Level, tm time.Time, line []byte) error {
+	buf := getLogBuffer()
+	defer putLogBuffer(buf)
+	buf.WriteString("DDns: ")
+	buf.WriteString(tm.Format("2006/01/02 15:04:05.000000"))
+	buf.WriteByte(' ')
+	buf.WriteString(level.String())
+	buf.WriteByte(' ')
+	buf.Write(line)
+	buf.WriteByte('\n')
+	_, err := t.w.Write(buf.Bytes())
+	return err
+}
+
+// journald（以及 systemd 管理的其它服务）可以识别的格式：<优先级>消息，时间由 journald 记录
+type journaldLineWriter struct {
+	w io.Writer
+}
+
+func (j journaldLineWriter) writeLine(level slog.Level, _ time.Time, line []byte) error {
+	buf := getLogBuffer()
+	defer putLogBuffer(buf)
+	buf.WriteString("<" + strconv.Itoa(syslogPriority(level)) + ">")
+	buf.Write(line)
+	buf.WriteByte('\n')
+	_, err := j.w.Write(buf.Bytes())
+	return err
+}
+
+// 日志级别对应的 syslog 优先级
+func syslogPriority(level slog.Level) int {
+	switch {
+	case level >= slog.LevelError:
+		return 3 // err
+	case level >= slog.LevelWarn:
+		return 4 // warning
+	case level >= slog.LevelInfo:
+		return 6 // info
+	default:
+		return 7 // debug
+	}
+}
+
+// 文本格式的日志 Handler，消息后附带 key=value 形式的字段
+type textHandler struct {
+	mu     *sync.Mutex
+	out    lineWriter
+	level  slog.Leveler
+	attrs  string // WithAttrs 预先格式化好的字段
+	prefix string // WithGroup 设置的字段名前缀
+}
+
+func newTextHandler(out lineWriter, level slog.Leveler) *textHandler {
+	return &textHandler{mu: &sync.Mutex{}, out: out, level: level}
+}
+
+func (h *textHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *textHandler) Handle(_ context.Context, record slog.Record) error {
+	buf := getLogBuffer()
+	defer putLogBuffer(buf)
+	buf.WriteString(record.Message)
+	buf.WriteString(h.attrs)
+	record.Attrs(func(attr slog.Attr) bool {
+		appendTextAttr(buf, h.prefix, attr)
+		return true
+	})
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.out.writeLine(record.Level, record.Time, buf.Bytes())
+}
+
+func (h *textHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var buf bytes.Buffer
+	for _, attr := range attrs {
+		appendTextAttr(&buf, h.prefix, attr)
+	}
+	clone := *h
+	clone.attrs += buf.String()
+	return &clone
+}
+
+func (h *textHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := *h
+	clone.prefix += name + "."
+	return &clone
+}
+
+// 以 key=value 的形式追加字段，值中包含空格等字符时加引号
+func appendTextAttr(buf *bytes.Buffer, prefix string, attr slog.Attr) {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return
+	}
+	if attr.Value.Kind() == slog.KindGroup {
+		groupPrefix := prefix
+		if attr.Key != "" {
+			groupPrefix += attr.Key + "."
+		}
+		for _, groupAttr := range attr.Value.Group() {
+			appendTextAttr(buf, groupPrefix, groupAttr)
+		}
+		return
+	}
+
+	var value string
+	if attr.Value.Kind() == slog.KindTime {
+		value = attr.Value.Time().Format(time.RFC3339)
+	} else {
+		value = attr.Value.String()
+	}
+	if value == "" || strings.ContainsAny(value, " \t\n\"=") {
+		value = strconv.Quote(value)
+	}
+
+	buf.WriteByte(' ')
+	buf.WriteString(prefix)
+	buf.WriteString(attr.Key)
+	buf.WriteByte('=')
+	buf.WriteString(value)
+}
+
+// syslog 输出的设置
+type SyslogConfig struct {
+	Network  string `json:"network,omitempty"`  // 为空时连接本机的 syslog，远程时为 udp 或 tcp
+	Address  string `json:"address,omitempty"`  // 远程 syslog 的地址，例如 192.168.1.1:514
+	Facility string `json:"facility,omitempty"` // 默认 daemon
+	Tag      string `json:"tag,omitempty"`      // 默认 DDns
+}
+
+func (c SyslogConfig) facility() string {
+	if c.Facility == "" {
+		return "daemon"
+	}
+	return c.Facility
+}
+
+func (c SyslogConfig) tag() string {
+	if c.Tag == "" {
+		return "DDns"
+	}
+	return c.Tag
+}
@@ -0,0 +1,197 @@
+package updater
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 一个指标及其所有标签组合的值
+type metricFamily struct {
+	name   string
+	help   string
+	kind   string              // counter 或 gauge
+	values map[string]float64  // 格式化好的标签 → 值
+	labels map[string][]string // 格式化好的标签 → key、value 交替排列的标签，导出 OpenTelemetry 指标时使用
+}
+
+// 简单的 Prometheus 指标集合，按文本格式输出
+type metricsRegistry struct {
+	mu       sync.Mutex
+	families []*metricFamily
+	byName   map[string]*metricFamily
+}
+
+func newMetricsRegistry() *metricsRegistry {
+	r := &metricsRegistry{byName: make(map[string]*metricFamily)}
+	r.register("ddns_update_attempts_total", "counter", "Number of attempts to sync a DNS record.")
+	r.register("ddns_update_successes_total", "counter", "Number of DNS record syncs that succeeded, including records that needed no update.")
+	r.register("ddns_update_failures_total", "counter", "Number of DNS record syncs that failed.")
+	r.register("ddns_ip_detection_failures_total", "counter", "Number of failures to get the public IP, per provider.")
+	r.register("ddns_last_change_timestamp_seconds", "gauge", "Unix time of the last change of a DNS record.")
+	r.register("ddns_public_ip_info", "gauge", "Current public IP address of each family.")
+	r.register("ddns_last_cycle_timestamp_seconds", "gauge", "Unix time of the last check cycle.")
+	r.register("ddns_last_cycle_success", "gauge", "Whether the last check cycle succeeded (1) or not (0).")
+	r.register("ddns_api_throttled_total", "counter", "Number of times a DNS provider rejected requests because of rate limits.")
+	r.register("ddns_api_throttled", "gauge", "Whether API calls to a DNS provider are paused because of rate limits (1) or not (0).")
+	r.register("ddns_cgnat", "gauge", "Whether the public IPv4 address is behind carrier-grade NAT (1) or not (0).")
+	r.register("ddns_last_failed_request_info", "gauge", "Request ID of the last failed DNS API call of a record, removed once the record syncs again.")
+	r.register("ddns_ip_changes_in_window", "gauge", "Number of public IP changes within churn.window at the last change.")
+	r.register("ddns_ip_churn_paused", "gauge", "Whether updates of a family are paused because the public IP changes too frequently (1) or not (0).")
+	r.register("ddns_heap_alloc_bytes", "gauge", "Bytes of allocated heap objects at the end of the last check cycle.")
+	r.register("ddns_heap_sys_bytes", "gauge", "Bytes of heap memory obtained from the OS at the end of the last check cycle.")
+	r.register("ddns_goroutines", "gauge", "Number of goroutines at the end of the last check cycle.")
+	return r
+}
+
+// 进程内共享的指标
+var metrics = newMetricsRegistry()
+
+func (r *metricsRegistry) register(name, kind, help string) {
+	family := &metricFamily{name: name, help: help, kind: kind, values: make(map[string]float64), labels: make(map[string][]string)}
+	r.families = append(r.families, family)
+	r.byName[name] = family
+}
+
+// 计数器加 1
+func (r *metricsRegistry) inc(name string, labels ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := formatLabels(labels)
+	r.byName[name].values[key]++
+	r.byName[name].labels[key] = labels
+}
+
+// 设置指标的值
+func (r *metricsRegistry) set(name string, value float64, labels ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := formatLabels(labels)
+	r.byName[name].values[key] = value
+	r.byName[name].labels[key] = labels
+}
+
+// 删除指标中包含指定标签的值，例如 IP 变化后删除旧 IP 的信息指标
+func (r *metricsRegistry) deleteMatching(name string, labels ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	match := strings.TrimSuffix(formatLabels(labels), "}")
+	for key := range r.byName[name].values {
+		if strings.HasPrefix(key, match) {
+			delete(r.byName[name].values, key)
+			delete(r.byName[name].labels, key)
+		}
+	}
+}
+
+// 按 Prometheus 文本格式输出全部指标
+func (r *metricsRegistry) writeTo(w io.Writer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, family := range r.families {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", family.name, family.help, family.name, family.kind)
+		keys := make([]string, 0, len(family.values))
+		for key := range family.values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Fprintf(w, "%s%s %s\n", family.name, key, strconv.FormatFloat(family.values[key], 'f', -1, 64))
+		}
+	}
+}
+
+// 一个指标某个标签组合的值
+type metricPoint struct {
+	labels []string
+	value  float64
+}
+
+// 某一时刻一个指标的全部值
+type metricSnapshot struct {
+	name, help, kind string
+	points           []metricPoint
+}
+
+// 复制全部指标的当前值，供 OpenTelemetry 导出使用
+func (r *metricsRegistry) snapshot() []metricSnapshot {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	snapshots := make([]metricSnapshot, len(r.families))
+	for i, family := range r.families {
+		snapshots[i] = metricSnapshot{name: family.name, help: family.help, kind: family.kind}
+		for key, value := range family.values {
+			snapshots[i].points = append(snapshots[i].points, metricPoint{labels: family.labels[key], value: value})
+		}
+	}
+	return snapshots
+}
+
+// 记录一次检测结束的时间和结果
+func (r *metricsRegistry) cycleFinished(ok bool) {
+	r.set("ddns_last_cycle_timestamp_seconds", float64(time.Now().Unix()))
+	if ok {
+		r.set("ddns_last_cycle_success", 1)
+	} else {
+		r.set("ddns_last_cycle_success", 0)
+	}
+
+	// 每轮检测结束时记录一次内存占用，用于观察长期运行时堆是否稳定。
+	// ReadMemStats 会短暂暂停程序，检测间隔通常为几分钟，开销可以忽略
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	r.set("ddns_heap_alloc_bytes", float64(stats.HeapAlloc))
+	r.set("ddns_heap_sys_bytes", float64(stats.HeapSys))
+	r.set("ddns_goroutines", float64(runtime.NumGoroutine()))
+}
+
+// 记录检测到的公网 IP，只保留当前的 IP
+func (r *metricsRegistry) publicIP(family, ip string) {
+	r.deleteMatching("ddns_public_ip_info", "family", family)
+	r.set("ddns_public_ip_info", 1, "family", family, "ip", ip)
+}
+
+// 记录一条记录最近一次失败的 API 请求的 ID，requestID 为空时（同步成功或没有请求 ID）删除
+func (r *metricsRegistry) failedRequest(domain, rr, recordType, requestID string) {
+	r.deleteMatching("ddns_last_failed_request_info", "domain", domain, "rr", rr, "type", recordType)
+	if requestID != "" {
+		r.set("ddns_last_failed_request_info", 1, "domain", domain, "rr", rr, "type", recordType, "request_id", requestID)
+	}
+}
+
+// 将 key、value 交替排列的标签格式化为 {key="value",...}
+func formatLabels(labels []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i := 0; i+1 < len(labels); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(labels[i])
+		b.WriteString(`="`)
+		b.WriteString(labelValueEscaper.Replace(labels[i+1]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// 标签值中需要转义的字符
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// 输出指标的 HTTP 处理函数
+func metricsHandler(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	metrics.writeTo(w)
+}
@@ -0,0 +1,68 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// 把 names 中的完整名称按账号下的域名拆分为主机记录和域名，同一个域名下的名称合并为一项。
+// 每个服务商（或账号）只列出一次域名
+func splitNames(ctx context.Context, domains []DomainConfig, providers map[string]dnsProvider) ([]DomainConfig, error) {
+	zonesByProvider := make(map[string][]string)
+	var split []DomainConfig
+	for _, domain := range domains {
+		if len(domain.Names) == 0 {
+			split = append(split, domain)
+			continue
+		}
+
+		zones, listed := zonesByProvider[domain.providerKey()]
+		if !listed {
+			lister, ok := providers[domain.providerKey()].(zoneLister)
+			if !ok {
+				return nil, fmt.Errorf("DNS provider %s cannot split names into domain and rr", domain.Provider)
+			}
+			var err error
+			if zones, err = lister.listZones(ctx); err != nil {
+				return nil, fmt.Errorf("failed to list %s domains to split names: %v", domain.providerKey(), err)
+			}
+			zonesByProvider[domain.providerKey()] = zones
+		}
+
+		// 按域名第一次出现的顺序输出
+		index := make(map[string]int)
+		for _, name := range domain.Names {
+			rr, zone, ok := splitName(name, zones)
+			if !ok {
+				return nil, fmt.Errorf("%s does not belong to any domain of the %s account", name, domain.providerKey())
+			}
+			i, seen := index[zone]
+			if !seen {
+				i = len(split)
+				index[zone] = i
+				entry := domain
+				entry.DomainName, entry.RRs, entry.Names = zone, nil, nil
+				split = append(split, entry)
+			}
+			split[i].RRs = append(split[i].RRs, rr)
+		}
+	}
+	return split, nil
+}
+
+// 找到 name 所属的最长的域名，返回相对该域名的主机记录和域名
+func splitName(name string, zones []string) (string, string, bool) {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	best := ""
+	for _, zone := range zones {
+		zone = strings.ToLower(strings.TrimSuffix(zone, "."))
+		if (name == zone || strings.HasSuffix(name, "."+zone)) && len(zone) > len(best) {
+			best = zone
+		}
+	}
+	if best == "" {
+		return "", "", false
+	}
+	return relativeRR(name, best), best, true
+}
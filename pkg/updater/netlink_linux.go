@@ -0,0 +1,102 @@
+package updater
+
+import (
+	"encoding/binary"
+	"net"
+	"syscall"
+)
+
+// 需要订阅的 netlink 组播组，见 linux/rtnetlink.h
+const (
+	rtmgrpLink       = 0x1
+	rtmgrpIPv4IfAddr = 0x10
+	rtmgrpIPv4Route  = 0x40
+	rtmgrpIPv6IfAddr = 0x100
+	rtmgrpIPv6Route  = 0x400
+)
+
+// 订阅网卡、地址和路由变化的 netlink 消息，ifaceName 不为空时只关注该网卡。
+// 返回的 channel 在检测到变化时收到通知，多次变化会合并为一次
+func watchNetworkChanges(ifaceName string) (<-chan struct{}, error) {
+	var index uint32
+	if ifaceName != "" {
+		iface, err := net.InterfaceByName(ifaceName)
+		if err != nil {
+			return nil, err
+		}
+		index = uint32(iface.Index)
+	}
+
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, syscall.NETLINK_ROUTE)
+	if err != nil {
+		return nil, err
+	}
+	addr := &syscall.SockaddrNetlink{
+		Family: syscall.AF_NETLINK,
+		Groups: rtmgrpLink | rtmgrpIPv4IfAddr | rtmgrpIPv4Route | rtmgrpIPv6IfAddr | rtmgrpIPv6Route,
+	}
+	if err := syscall.Bind(fd, addr); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+
+	changes := make(chan struct{}, 1)
+	notify := func() {
+		select {
+		case changes <- struct{}{}:
+		default:
+		}
+	}
+
+	go func() {
+		defer syscall.Close(fd)
+		buf := make([]byte, 64*1024)
+		for {
+			n, _, err := syscall.Recvfrom(fd, buf, 0)
+			if err == syscall.EINTR {
+				continue
+			}
+			if err == syscall.ENOBUFS {
+				// 消息太多被丢弃，当作发生了变化
+				notify()
+				continue
+			}
+			if err != nil {
+				return
+			}
+
+			msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+			if err != nil {
+				continue
+			}
+			for _, msg := range msgs {
+				if isRelevantNetlinkMessage(msg, index) {
+					notify()
+				}
+			}
+		}
+	}()
+	return changes, nil
+}
+
+// 判断 netlink 消息是否表示需要重新检测 IP 的变化
+func isRelevantNetlinkMessage(msg syscall.NetlinkMessage, index uint32) bool {
+	switch msg.Header.Type {
+	case syscall.RTM_NEWADDR, syscall.RTM_DELADDR:
+		// ifaddrmsg：family(1) prefixlen(1) flags(1) scope(1) index(4)
+		if len(msg.Data) < syscall.SizeofIfAddrmsg {
+			return false
+		}
+		return index == 0 || binary.NativeEndian.Uint32(msg.Data[4:8]) == index
+	case syscall.RTM_NEWLINK, syscall.RTM_DELLINK:
+		// ifinfomsg：family(1) pad(1) type(2) index(4) ...
+		if len(msg.Data) < syscall.SizeofIfInfomsg {
+			return false
+		}
+		return index == 0 || binary.NativeEndian.Uint32(msg.Data[4:8]) == index
+	case syscall.RTM_NEWROUTE, syscall.RTM_DELROUTE:
+		// 只关注默认路由，rtmsg 的第二个字节为目标前缀长度
+		return len(msg.Data) >= syscall.SizeofRtMsg && msg.Data[1] == 0
+	}
+	return false
+}
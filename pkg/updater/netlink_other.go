@@ -0,0 +1,10 @@
+//go:build !linux
+
+package updater
+
+import "errors"
+
+// 其它系统不支持 netlink，只能按间隔轮询
+func watchNetworkChanges(ifaceName string) (<-chan struct{}, error) {
+	return nil, errors.New("network change events are only supported on Linux")
+}
@@ -0,0 +1,620 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// 通知配置
+type NotifyConfig struct {
+	FailureThreshold int              `json:"failureThreshold,omitempty"` // 连续多少次检测失败后发送 update_failed 通知，默认 3
+	Retry            *RetryConfig     `json:"retry,omitempty"`            // 发送失败时的重试策略，默认与 retry 相同
+	Webhooks         []WebhookConfig  `json:"webhooks,omitempty"`
+	DingTalk         []DingTalkConfig `json:"dingtalk,omitempty"`
+	WeCom            []WeComConfig    `json:"wecom,omitempty"`
+	Feishu           []FeishuConfig   `json:"feishu,omitempty"`
+	Telegram         []TelegramConfig `json:"telegram,omitempty"`
+	Slack            []SlackConfig    `json:"slack,omitempty"`
+	Discord          []DiscordConfig  `json:"discord,omitempty"`
+	Bark             []BarkConfig     `json:"bark,omitempty"`
+	Gotify           []GotifyConfig   `json:"gotify,omitempty"`
+	Ntfy             []NtfyConfig     `json:"ntfy,omitempty"`
+	Plugins          []PluginConfig   `json:"plugins,omitempty"` // 通过 pluginDir 中的插件发送
+}
+
+// 每个通知渠道都有的配置：名称、订阅的事件和限流
+type ChannelConfig struct {
+	Name        string   `json:"name,omitempty"`        // 日志中显示的名称，默认为类型和序号，例如 dingtalk[0]
+	Events      []string `json:"events,omitempty"`      // 需要发送的事件，取值见 notifyEvents，默认全部
+	MinInterval string   `json:"minInterval,omitempty"` // 同一事件两次通知的最短间隔，例如 10m，期间的事件只计数
+}
+
+// 聊天机器人通知的公共配置
+type ChatConfig struct {
+	ChannelConfig
+	Message  string `json:"message,omitempty"`  // Markdown 消息模板，默认使用内置模板
+	Language string `json:"language,omitempty"` // 内置模板和标题的语言：zh 或 en，钉钉、企业微信和飞书默认 zh，其它默认 en
+}
+
+// 通知事件的类型
+const (
+	eventIPChanged    = "ip_changed"    // 记录更新为新的 IP
+	eventUpdateFailed = "update_failed" // 连续获取公网 IP 失败或一条记录连续同步失败达到 failureThreshold
+	eventRecovered    = "recovered"     // 发送过 update_failed 后重新检测或同步成功
+	eventStartup      = "startup"       // 程序启动
+	eventThrottled    = "throttled"     // DNS 服务商限流，暂停调用
+	eventCGNAT        = "cgnat"         // 检测到运营商级 NAT，公网 IPv4 无法从外部访问
+	eventIPChurn      = "ip_churn"      // 公网 IP 在 churn.window 内的变化次数超过 churn.maxChanges
+)
+
+// 全部事件类型，通知未设置 events 时发送全部事件
+var notifyEvents = []string{eventIPChanged, eventUpdateFailed, eventRecovered, eventStartup, eventThrottled, eventCGNAT, eventIPChurn}
+
+// 旧版本的事件名称
+var notifyEventAliases = map[string]string{
+	"update":  eventIPChanged,
+	"failure": eventUpdateFailed,
+}
+
+// 返回事件的正式名称，不认识的事件返回空字符串
+func notifyEventName(name string) string {
+	if alias, ok := notifyEventAliases[name]; ok {
+		return alias
+	}
+	for _, event := range notifyEvents {
+		if event == name {
+			return event
+		}
+	}
+	return ""
+}
+
+// 默认的连续失败次数
+const defaultFailureThreshold = 3
+
+// 退出时等待未发送完的通知的最长时间
+const notifyShutdownTimeout = 5 * time.Second
+
+// 一次通知的内容，字段可以在消息模板中使用，例如 {{.Domain}}、{{.NewIP}}
+type notifyEvent struct {
+	Event      string    `json:"event"`
+	Time       time.Time `json:"time"`
+	Hostname   string    `json:"hostname"`
+	Domain     string    `json:"domain,omitempty"`
+	RR         string    `json:"rr,omitempty"`
+	Type       string    `json:"type,omitempty"`
+	OldIP      string    `json:"oldIp,omitempty"`
+	NewIP      string    `json:"newIp,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	RequestID  string    `json:"requestId,omitempty"`  // 记录的事件中服务商返回的请求 ID，失败时为失败的请求
+	Geo        string    `json:"geo,omitempty"`        // ip_changed 事件中新地址的 ASN、运营商和地区，需要配置 geo
+	Provider   string    `json:"provider,omitempty"`   // throttled 事件的 DNS 服务商
+	Pause      string    `json:"pause,omitempty"`      // throttled 事件暂停调用的时长，例如 1m0s
+	PublicIP   string    `json:"publicIp,omitempty"`   // cgnat 事件检测到的公网 IPv4
+	WANIP      string    `json:"wanIp,omitempty"`      // cgnat 事件中路由器的 WAN 口地址
+	Failures   int       `json:"failures,omitempty"`   // 连续失败的次数，记录的事件中为该记录的次数
+	Family     string    `json:"family,omitempty"`     // ip_churn 事件的协议族
+	Changes    int       `json:"changes,omitempty"`    // ip_churn 事件中 Window 内的变化次数
+	Window     string    `json:"window,omitempty"`     // ip_churn 事件统计变化次数的时间窗口，例如 1h0m0s
+	Paused     bool      `json:"paused,omitempty"`     // ip_churn 事件中是否已暂停更新该协议族的记录
+	Suppressed int       `json:"suppressed,omitempty"` // 因 minInterval 限流未发送的同类事件数
+}
+
+// 一种通知渠道
+type notifier interface {
+	send(ctx context.Context, client *http.Client, event notifyEvent) error
+}
+
+// 一个配置好的通知渠道以及需要发送的事件
+type notifyTarget struct {
+	name        string
+	notifier    notifier
+	events      map[string]bool
+	minInterval time.Duration
+
+	// 以下字段由 notifyDispatcher.mu 保护
+	lastSent   map[string]time.Time // 每种事件最后一次发送的时间
+	suppressed map[string]int       // 每种事件被限流的次数
+}
+
+// 把事件分发给配置的通知渠道，发送在后台进行，不阻塞记录更新
+type notifyDispatcher struct {
+	targets          []*notifyTarget
+	client           *http.Client
+	redactor         *redactor
+	retry            retryPolicy
+	logger           *slog.Logger
+	failureThreshold int
+
+	mu             sync.Mutex
+	failures       int            // 连续获取公网 IP 失败的检测次数
+	recordFailures map[string]int // 每条记录连续同步失败的次数
+	pending        sync.WaitGroup
+}
+
+// 按配置创建通知分发器，未配置通知时返回的分发器不发送任何内容
+func newNotifyDispatcher(config Config, settings httpSettings, logger *slog.Logger) (*notifyDispatcher, error) {
+	d := &notifyDispatcher{
+		// 通知使用与获取 IP 相同的代理
+		client:           &http.Client{Timeout: settings.timeout, Transport: settings.newTransport("", settings.ipProxy)},
+		redactor:         newRedactor(config.secrets()),
+		logger:           logger,
+		failureThreshold: defaultFailureThreshold,
+	}
+	retry, err := config.notifyRetryPolicy()
+	if err != nil {
+		return nil, err
+	}
+	d.retry = retry
+	if config.Notify == nil {
+		return d, nil
+	}
+	if config.Notify.FailureThreshold > 0 {
+		d.failureThreshold = config.Notify.FailureThreshold
+	}
+
+	for i, webhook := range config.Notify.Webhooks {
+		n, err := newWebhookNotifier(webhook)
+		if err != nil {
+			return nil, fmt.Errorf("invalid notify.webhooks[%d]: %v", i, err)
+		}
+		if err := d.add(fmt.Sprintf("webhook[%d]", i), n, webhook.ChannelConfig); err != nil {
+			return nil, fmt.Errorf("invalid notify.webhooks[%d]: %v", i, err)
+		}
+	}
+	for i, robot := range config.Notify.DingTalk {
+		err := d.addChat("dingtalk", i, robot.ChatConfig, "zh", func(message *chatMessage) notifier {
+			return &dingTalkNotifier{config: robot, message: message}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	for i, robot := range config.Notify.WeCom {
+		err := d.addChat("wecom", i, robot.ChatConfig, "zh", func(message *chatMessage) notifier {
+			return &weComNotifier{config: robot, message: message}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	for i, robot := range config.Notify.Feishu {
+		err := d.addChat("feishu", i, robot.ChatConfig, "zh", func(message *chatMessage) notifier {
+			return &feishuNotifier{config: robot, message: message}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	for i, robot := range config.Notify.Telegram {
+		err := d.addChat("telegram", i, robot.ChatConfig, "en", func(message *chatMessage) notifier {
+			return &telegramNotifier{config: robot, message: message}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	for i, robot := range config.Notify.Slack {
+		err := d.addChat("slack", i, robot.ChatConfig, "en", func(message *chatMessage) notifier {
+			return &slackNotifier{config: robot, message: message}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	for i, robot := range config.Notify.Discord {
+		err := d.addChat("discord", i, robot.ChatConfig, "en", func(message *chatMessage) notifier {
+			return &discordNotifier{config: robot, message: message}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	for i, push := range config.Notify.Bark {
+		err := d.addChat("bark", i, push.ChatConfig, "zh", func(message *chatMessage) notifier {
+			return &barkNotifier{config: push, message: message}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	for i, push := range config.Notify.Gotify {
+		err := d.addChat("gotify", i, push.ChatConfig, "en", func(message *chatMessage) notifier {
+			return &gotifyNotifier{config: push, message: message}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	for i, push := range config.Notify.Ntfy {
+		err := d.addChat("ntfy", i, push.ChatConfig, "en", func(message *chatMessage) notifier {
+			return &ntfyNotifier{config: push, message: message}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	for i, plugin := range config.Notify.Plugins {
+		n, err := newPluginNotifier(config.PluginDir, plugin)
+		if err != nil {
+			return nil, fmt.Errorf("invalid notify.plugins[%d]: %v", i, err)
+		}
+		if err := d.add(fmt.Sprintf("%s[%d]", plugin.Plugin, i), n, plugin.ChannelConfig); err != nil {
+			return nil, fmt.Errorf("invalid notify.plugins[%d]: %v", i, err)
+		}
+	}
+	return d, nil
+}
+
+// 添加一个聊天机器人，build 使用解析好的消息模板创建通知渠道
+func (d *notifyDispatcher) addChat(field string, i int, chat ChatConfig, language string, build func(*chatMessage) notifier) error {
+	message, err := newChatMessage(chat, language)
+	if err == nil {
+		err = d.add(fmt.Sprintf("%s[%d]", field, i), build(message), chat.ChannelConfig)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid notify.%s[%d]: %v", field, i, err)
+	}
+	return nil
+}
+
+// 添加通知渠道，name 为未设置名称时使用的默认名称
+func (d *notifyDispatcher) add(name string, n notifier, channel ChannelConfig) error {
+	if channel.Name != "" {
+		name = channel.Name
+	}
+	target := &notifyTarget{
+		name:       name,
+		notifier:   n,
+		events:     make(map[string]bool),
+		lastSent:   make(map[string]time.Time),
+		suppressed: make(map[string]int),
+	}
+	events := channel.Events
+	if len(events) == 0 {
+		events = notifyEvents
+	}
+	for _, event := range events {
+		name := notifyEventName(event)
+		if name == "" {
+			return fmt.Errorf("unknown event %q", event)
+		}
+		target.events[name] = true
+	}
+	if channel.MinInterval != "" {
+		interval, err := time.ParseDuration(channel.MinInterval)
+		if err != nil {
+			return fmt.Errorf("invalid minInterval %q: %v", channel.MinInterval, err)
+		}
+		target.minInterval = interval
+	}
+	d.targets = append(d.targets, target)
+	return nil
+}
+
+// 判断渠道是否可以发送该事件，被限流时计数，可以发送时返回之前被限流的次数
+func (d *notifyDispatcher) allow(target *notifyTarget, event notifyEvent) (int, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if last, ok := target.lastSent[event.Event]; ok && event.Time.Sub(last) < target.minInterval {
+		target.suppressed[event.Event]++
+		return 0, false
+	}
+	suppressed := target.suppressed[event.Event]
+	target.lastSent[event.Event] = event.Time
+	target.suppressed[event.Event] = 0
+	return suppressed, true
+}
+
+// 在后台把事件发送给订阅了该事件的通知渠道
+func (d *notifyDispatcher) emit(event notifyEvent) {
+	event.Time = time.Now()
+	event.Hostname, _ = os.Hostname()
+	// 错误信息中可能包含签名后的请求地址
+	event.Error = d.redactor.redact(event.Error)
+
+	for _, target := range d.targets {
+		if !target.events[event.Event] {
+			continue
+		}
+		suppressed, ok := d.allow(target, event)
+		if !ok {
+			d.logger.Debug("Notification rate limited", "notifier", target.name, "event", event.Event)
+			continue
+		}
+		event := event
+		event.Suppressed = suppressed
+
+		d.pending.Add(1)
+		go func(target *notifyTarget) {
+			defer d.pending.Done()
+			err := d.retry.do(context.Background(), d.logger, "send "+target.name+" notification", func(ctx context.Context) error {
+				ctx, cancel := context.WithTimeout(ctx, d.client.Timeout)
+				defer cancel()
+				return target.notifier.send(ctx, d.client, event)
+			})
+			if err != nil {
+				d.logger.Warn("Failed to send notification", "notifier", target.name, "event", event.Event, "error", err)
+			}
+		}(target)
+	}
+}
+
+// 记录一次检测的结果，连续失败次数达到 failureThreshold 时发送一次 update_failed 通知，
+// 之后第一次成功时发送 recovered 通知
+func (d *notifyDispatcher) cycleFinished(ok bool, lastError string) {
+	d.mu.Lock()
+	failures := d.failures
+	if ok {
+		d.failures = 0
+	} else {
+		d.failures++
+	}
+	d.mu.Unlock()
+
+	switch {
+	case ok && failures >= d.failureThreshold:
+		d.emit(notifyEvent{Event: eventRecovered, Failures: failures})
+	case !ok && failures+1 == d.failureThreshold:
+		d.emit(notifyEvent{Event: eventUpdateFailed, Error: lastError, Failures: failures + 1})
+	}
+}
+
+// 记录一轮中每条记录的同步结果，一条记录连续失败达到 failureThreshold 时发送一次该记录的 update_failed 通知，
+// 之后第一次成功时发送 recovered 通知；跳过的记录不改变计数。返回连续失败达到阈值的记录
+func (d *notifyDispatcher) recordsFinished(results []recordResult) []string {
+	var events []notifyEvent
+	var failing []string
+	d.mu.Lock()
+	recordFailures := make(map[string]int, len(results))
+	for _, result := range results {
+		key := result.name()
+		failures := d.recordFailures[key]
+		switch result.result {
+		case resultFailed:
+			failures++
+			if failures == d.failureThreshold {
+				event := notifyEvent{Event: eventUpdateFailed, Domain: result.domain, RR: result.rr, Type: result.recordType, Failures: failures, RequestID: result.requestID}
+				if result.err != nil {
+					event.Error = result.err.Error()
+				}
+				events = append(events, event)
+			}
+		case resultUpdated, resultUnchanged, resultCached:
+			if failures >= d.failureThreshold {
+				events = append(events, notifyEvent{Event: eventRecovered, Domain: result.domain, RR: result.rr, Type: result.recordType, Failures: failures})
+			}
+			failures = 0
+		}
+		if failures > 0 {
+			recordFailures[key] = failures
+		}
+		if failures >= d.failureThreshold {
+			failing = append(failing, key)
+		}
+	}
+	// 不在本轮结果中的记录已从配置中删除，不再保留计数
+	d.recordFailures = recordFailures
+	d.mu.Unlock()
+
+	for _, event := range events {
+		d.emit(event)
+	}
+	return failing
+}
+
+// 重新加载配置后沿用原来的连续失败次数
+func (d *notifyDispatcher) inherit(previous *notifyDispatcher) {
+	previous.mu.Lock()
+	defer previous.mu.Unlock()
+	d.failures = previous.failures
+	d.recordFailures = previous.recordFailures
+}
+
+// 等待正在发送的通知，最多等待 timeout
+func (d *notifyDispatcher) wait(timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		d.pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		d.logger.Warn("Timed out waiting for notifications to be sent")
+	}
+}
+
+// 内置的 Markdown 消息模板，标题由各渠道按自己的格式显示
+var defaultChatMessages = map[string]string{
+	"zh": `{{if eq .Event "ip_changed"}}- 记录：{{.RR}}.{{.Domain}}（{{.Type}}）
+- 原 IP：{{if .OldIP}}{{.OldIP}}{{else}}无（新建记录）{{end}}
+- 新 IP：{{.NewIP}}
+{{if .Geo}}- 归属：{{.Geo}}
+{{end}}{{else if eq .Event "update_failed"}}{{if .Domain}}- 记录：{{.RR}}.{{.Domain}}（{{.Type}}）
+{{end}}- 连续失败：{{.Failures}} 次
+- 错误：{{.Error}}
+{{if .RequestID}}- 请求 ID：{{.RequestID}}
+{{end}}{{else if eq .Event "recovered"}}{{if .Domain}}- 记录：{{.RR}}.{{.Domain}}（{{.Type}}）
+{{end}}- 此前连续失败：{{.Failures}} 次
+{{else if eq .Event "throttled"}}- 服务商：{{.Provider}}
+- 暂停调用：{{.Pause}}
+- 错误：{{.Error}}
+{{else if eq .Event "cgnat"}}- 公网 IPv4：{{.PublicIP}}
+{{if .WANIP}}- WAN 口地址：{{.WANIP}}
+{{end}}- 原因：{{.Error}}
+{{else if eq .Event "ip_churn"}}- 协议族：{{.Family}}
+- 公网 IP：{{.OldIP}} -> {{.PublicIP}}
+- {{.Window}} 内变化：{{.Changes}} 次
+{{if .Paused}}- 已暂停更新，确认后执行 DDns_go update -confirm 恢复
+{{end}}{{end}}{{if .Suppressed}}- 期间省略了 {{.Suppressed}} 条同类通知
+{{end}}- 主机：{{.Hostname}}
+- 时间：{{.Time.Format "2006-01-02 15:04:05"}}`,
+	"en": `{{if eq .Event "ip_changed"}}- Record: {{.RR}}.{{.Domain}} ({{.Type}})
+- Old IP: {{if .OldIP}}{{.OldIP}}{{else}}none (new record){{end}}
+- New IP: {{.NewIP}}
+{{if .Geo}}- Network: {{.Geo}}
+{{end}}{{else if eq .Event "update_failed"}}{{if .Domain}}- Record: {{.RR}}.{{.Domain}} ({{.Type}})
+{{end}}- Consecutive failures: {{.Failures}}
+- Error: {{.Error}}
+{{if .RequestID}}- Request ID: {{.RequestID}}
+{{end}}{{else if eq .Event "recovered"}}{{if .Domain}}- Record: {{.RR}}.{{.Domain}} ({{.Type}})
+{{end}}- Failed checks before recovery: {{.Failures}}
+{{else if eq .Event "throttled"}}- Provider: {{.Provider}}
+- Paused for: {{.Pause}}
+- Error: {{.Error}}
+{{else if eq .Event "cgnat"}}- Public IPv4: {{.PublicIP}}
+{{if .WANIP}}- WAN address: {{.WANIP}}
+{{end}}- Reason: {{.Error}}
+{{else if eq .Event "ip_churn"}}- Family: {{.Family}}
+- Public IP: {{.OldIP}} -> {{.PublicIP}}
+- Changes within {{.Window}}: {{.Changes}}
+{{if .Paused}}- Updates are paused, run DDns_go update -confirm to resume
+{{end}}{{end}}{{if .Suppressed}}- {{.Suppressed}} similar notifications were suppressed
+{{end}}- Host: {{.Hostname}}
+- Time: {{.Time.Format "2006-01-02 15:04:05 MST"}}`,
+}
+
+// 聊天机器人消息的标题和正文模板
+type chatMessage struct {
+	text     *template.Template
+	language string
+}
+
+// language 为配置的语言（zh 或 en），未设置时使用 fallback
+func newChatMessage(config ChatConfig, fallback string) (*chatMessage, error) {
+	language := config.Language
+	if language == "" {
+		language = fallback
+	}
+	if _, ok := defaultChatMessages[language]; !ok {
+		return nil, fmt.Errorf("unknown language %q, expected zh or en", language)
+	}
+	text := config.Message
+	if text == "" {
+		text = defaultChatMessages[language]
+	}
+	tmpl, err := parseNotifyTemplate(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid message template: %v", err)
+	}
+	return &chatMessage{text: tmpl, language: language}, nil
+}
+
+// 返回事件的标题和 Markdown 正文
+func (m *chatMessage) render(event notifyEvent) (string, string, error) {
+	text, err := renderNotifyTemplate(m.text, event)
+	if err != nil {
+		return "", "", err
+	}
+	return notifyTitle(event, m.language), text, nil
+}
+
+// 事件的标题
+func notifyTitle(event notifyEvent, language string) string {
+	english := language == "en"
+	switch event.Event {
+	case eventIPChanged:
+		if english {
+			return "DDns record updated: " + event.RR + "." + event.Domain
+		}
+		return "DDns 记录已更新：" + event.RR + "." + event.Domain
+	case eventUpdateFailed:
+		if event.Domain != "" {
+			if english {
+				return fmt.Sprintf("DDns record %s.%s failed %d times in a row", event.RR, event.Domain, event.Failures)
+			}
+			return fmt.Sprintf("DDns 记录 %s.%s 连续 %d 次同步失败", event.RR, event.Domain, event.Failures)
+		}
+		if english {
+			return fmt.Sprintf("DDns failed %d checks in a row", event.Failures)
+		}
+		return fmt.Sprintf("DDns 连续 %d 次检测失败", event.Failures)
+	case eventRecovered:
+		if event.Domain != "" {
+			if english {
+				return "DDns record recovered: " + event.RR + "." + event.Domain
+			}
+			return "DDns 记录已恢复正常：" + event.RR + "." + event.Domain
+		}
+		if english {
+			return "DDns recovered"
+		}
+		return "DDns 已恢复正常"
+	case eventStartup:
+		if english {
+			return "DDns started"
+		}
+		return "DDns 已启动"
+	case eventThrottled:
+		if english {
+			return "DDns is rate limited by " + event.Provider
+		}
+		return "DDns 被 " + event.Provider + " 限流"
+	case eventCGNAT:
+		if english {
+			return "DDns is behind carrier-grade NAT"
+		}
+		return "DDns 位于运营商级 NAT 之后"
+	case eventIPChurn:
+		if english {
+			return "DDns public " + event.Family + " changes too frequently"
+		}
+		return "DDns 公网 " + event.Family + " 变化过于频繁"
+	default:
+		return "DDns " + event.Event
+	}
+}
+
+// 以 JSON 格式发送通知，返回响应内容供各渠道检查错误码
+func postNotifyJSON(ctx context.Context, client *http.Client, url string, payload interface{}) ([]byte, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return doNotifyRequest(client, req)
+}
+
+// 签名使用的 HMAC-SHA256，结果为 base64 编码
+func hmacSHA256Base64(key, message string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(message))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// 解析通知的消息模板，可以使用 json 函数把字段转换为 JSON 字符串
+func parseNotifyTemplate(text string) (*template.Template, error) {
+	return template.New("notify").Funcs(template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			data, err := json.Marshal(v)
+			return string(data), err
+		},
+	}).Parse(text)
+}
+
+// 使用模板生成消息内容
+func renderNotifyTemplate(tmpl *template.Template, event notifyEvent) (string, error) {
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, event); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,71 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// ntfy 推送，可以使用 ntfy.sh 或自建服务
+type NtfyConfig struct {
+	Server   string   `json:"server,omitempty"`   // ntfy 服务地址，默认 https://ntfy.sh
+	Topic    string   `json:"topic"`              // 订阅的主题
+	Token    string   `json:"token,omitempty"`    // 访问令牌，主题需要认证时填写
+	Priority int      `json:"priority,omitempty"` // 消息优先级 1-5，默认检测失败为 4，其它为 3
+	Tags     []string `json:"tags,omitempty"`     // 标签或 emoji 短代码，例如 globe_with_meridians
+	ChatConfig
+}
+
+// 默认的 ntfy 服务地址
+const defaultNtfyServer = "https://ntfy.sh"
+
+type ntfyNotifier struct {
+	config  NtfyConfig
+	message *chatMessage
+}
+
+func (n *ntfyNotifier) send(ctx context.Context, client *http.Client, event notifyEvent) error {
+	title, text, err := n.message.render(event)
+	if err != nil {
+		return err
+	}
+	server := strings.TrimSuffix(n.config.Server, "/")
+	if server == "" {
+		server = defaultNtfyServer
+	}
+	priority := n.config.Priority
+	if priority == 0 {
+		priority = 3
+		if event.Event == eventUpdateFailed {
+			priority = 4
+		}
+	}
+	// 使用 JSON 发布，标题中的中文不需要按请求头编码
+	payload := map[string]interface{}{
+		"topic":    n.config.Topic,
+		"title":    title,
+		"message":  text,
+		"priority": priority,
+		"markdown": true,
+	}
+	if len(n.config.Tags) > 0 {
+		payload["tags"] = n.config.Tags
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, server, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if n.config.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+n.config.Token)
+	}
+	_, err = doNotifyRequest(client, req)
+	return err
+}
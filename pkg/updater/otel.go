@@ -0,0 +1,377 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OpenTelemetry 导出设置，通过 OTLP/HTTP（JSON 编码）把每轮检测的链路和 /metrics 中的指标发送到 Collector
+type OTelConfig struct {
+	Endpoint        string            `json:"endpoint,omitempty"`        // OTLP/HTTP 的地址，例如 http://localhost:4318，未设置时使用 OTEL_EXPORTER_OTLP_ENDPOINT，仍未设置时为 http://localhost:4318
+	Headers         map[string]string `json:"headers,omitempty"`         // 导出时附加的请求头，例如认证需要的 Authorization，与 OTEL_EXPORTER_OTLP_HEADERS 合并
+	ServiceName     string            `json:"serviceName,omitempty"`     // 资源属性 service.name，未设置时使用 OTEL_SERVICE_NAME，仍未设置时为 ddns
+	Traces          *bool             `json:"traces,omitempty"`          // 是否导出链路，默认开启
+	Metrics         *bool             `json:"metrics,omitempty"`         // 是否导出指标，默认开启
+	MetricsInterval string            `json:"metricsInterval,omitempty"` // 导出指标的间隔，默认 60s
+}
+
+// 默认的 OTLP/HTTP 地址和导出指标的间隔
+const (
+	defaultOTLPEndpoint        = "http://localhost:4318"
+	defaultOTelMetricsInterval = time.Minute
+)
+
+// 导出一批数据的超时
+const otlpExportTimeout = 10 * time.Second
+
+// OTLP 中 span 的类型和状态码
+const (
+	otlpSpanKindInternal = 1
+	otlpStatusError      = 2
+)
+
+// 返回 OTLP/HTTP 的地址，去掉末尾的 /
+func (o *OTelConfig) endpoint() string {
+	endpoint := o.Endpoint
+	if endpoint == "" {
+		endpoint = os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	}
+	if endpoint == "" {
+		endpoint = defaultOTLPEndpoint
+	}
+	return strings.TrimSuffix(endpoint, "/")
+}
+
+// 返回导出时附加的请求头：OTEL_EXPORTER_OTLP_HEADERS（格式为 key1=value1,key2=value2）加上配置中的 headers
+func (o *OTelConfig) headers() map[string]string {
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"), ",") {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		if unescaped, err := url.QueryUnescape(strings.TrimSpace(value)); err == nil {
+			value = unescaped
+		}
+		headers[strings.TrimSpace(key)] = value
+	}
+	for key, value := range o.Headers {
+		headers[key] = value
+	}
+	return headers
+}
+
+// 返回资源属性 service.name
+func (o *OTelConfig) serviceName() string {
+	if o.ServiceName != "" {
+		return o.ServiceName
+	}
+	if name := os.Getenv("OTEL_SERVICE_NAME"); name != "" {
+		return name
+	}
+	return "ddns"
+}
+
+// 返回导出指标的间隔
+func (o *OTelConfig) metricsInterval() (time.Duration, error) {
+	if o.MetricsInterval == "" {
+		return defaultOTelMetricsInterval, nil
+	}
+	duration, err := time.ParseDuration(o.MetricsInterval)
+	if err != nil || duration < time.Second {
+		return 0, fmt.Errorf("invalid otel.metricsInterval %q, expected a duration of at least 1s", o.MetricsInterval)
+	}
+	return duration, nil
+}
+
+// 通过 OTLP/HTTP 导出链路和指标
+type otelExporter struct {
+	endpoint string
+	headers  map[string]string
+	resource map[string]interface{}
+	traces   bool
+	started  time.Time // 计数器的起始时间
+	client   *http.Client
+	logger   *slog.Logger
+
+	mu    sync.Mutex
+	spans []otlpSpan // 已结束、等待导出的 span，每轮检测结束后一起导出
+	wg    sync.WaitGroup
+}
+
+// 进程内共享的导出器，未配置 otel 时为 nil，此时 startSpan 不做任何事
+var telemetry *otelExporter
+
+// 配置了 otel 时启动导出器：开启 metrics 时每隔 metricsInterval 导出一次指标，ctx 取消后停止。
+// 返回的函数导出剩余的数据，等待最多 timeout
+func startTelemetry(ctx context.Context, config Config, logger *slog.Logger) (func(timeout time.Duration), error) {
+	o := config.OTel
+	if o == nil {
+		return func(time.Duration) {}, nil
+	}
+	settings, err := config.httpSettings()
+	if err != nil {
+		return nil, err
+	}
+	interval, err := o.metricsInterval()
+	if err != nil {
+		return nil, err
+	}
+	hostname, _ := os.Hostname()
+	e := &otelExporter{
+		endpoint: o.endpoint(),
+		headers:  o.headers(),
+		resource: map[string]interface{}{"attributes": otlpAttributes("service.name", o.serviceName(), "service.version", version, "host.name", hostname)},
+		traces:   o.Traces == nil || *o.Traces,
+		started:  time.Now(),
+		client:   &http.Client{Timeout: otlpExportTimeout, Transport: settings.newTransport("", settings.ipProxy)},
+		logger:   logger,
+	}
+	telemetry = e
+	logger.Info("Exporting OpenTelemetry data", "endpoint", e.endpoint, "traces", e.traces, "metrics", o.Metrics == nil || *o.Metrics)
+
+	exportMetrics := o.Metrics == nil || *o.Metrics
+	if exportMetrics {
+		go func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					e.exportMetrics(context.Background())
+				}
+			}
+		}()
+	}
+	return func(timeout time.Duration) {
+		done := make(chan struct{})
+		go func() {
+			e.wg.Wait()
+			exportCtx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			e.exportTraces(exportCtx)
+			if exportMetrics {
+				e.exportMetrics(exportCtx)
+			}
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(timeout):
+			logger.Warn("Timed out exporting OpenTelemetry data")
+		}
+	}, nil
+}
+
+// OTLP JSON 编码的一个 span
+type otlpSpan struct {
+	TraceID           string                   `json:"traceId"`
+	SpanID            string                   `json:"spanId"`
+	ParentSpanID      string                   `json:"parentSpanId,omitempty"`
+	Name              string                   `json:"name"`
+	Kind              int                      `json:"kind"`
+	StartTimeUnixNano string                   `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string                   `json:"endTimeUnixNano"`
+	Attributes        []map[string]interface{} `json:"attributes,omitempty"`
+	Status            otlpStatus               `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// 正在进行的一个 span，未启用链路时为 nil，方法都可以在 nil 上调用
+type span struct {
+	exporter *otelExporter
+	data     otlpSpan
+	root     bool
+}
+
+type spanKey struct{}
+
+// 开始一个 span，ctx 中已有 span 时作为它的子 span，否则开始一条新的链路。
+// attrs 为 key、value 交替排列的属性，与 slog 相同
+func startSpan(ctx context.Context, name string, attrs ...interface{}) (context.Context, *span) {
+	e := telemetry
+	if e == nil || !e.traces {
+		return ctx, nil
+	}
+	s := &span{exporter: e, data: otlpSpan{Name: name, Kind: otlpSpanKindInternal, SpanID: randomHex(8), StartTimeUnixNano: unixNano(time.Now())}}
+	if parent, ok := ctx.Value(spanKey{}).(*span); ok {
+		s.data.TraceID, s.data.ParentSpanID = parent.data.TraceID, parent.data.SpanID
+	} else {
+		s.data.TraceID, s.root = randomHex(16), true
+	}
+	s.set(attrs...)
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// 添加属性
+func (s *span) set(attrs ...interface{}) {
+	if s == nil {
+		return
+	}
+	s.data.Attributes = append(s.data.Attributes, otlpAttributes(attrs...)...)
+}
+
+// 结束 span，err 不为空时状态为错误。链路的根 span 结束后在后台导出整条链路
+func (s *span) end(err error) {
+	if s == nil {
+		return
+	}
+	s.data.EndTimeUnixNano = unixNano(time.Now())
+	if err != nil {
+		s.data.Status = otlpStatus{Code: otlpStatusError, Message: err.Error()}
+	}
+	e := s.exporter
+	e.mu.Lock()
+	e.spans = append(e.spans, s.data)
+	e.mu.Unlock()
+	if s.root {
+		e.wg.Add(1)
+		go func() {
+			defer e.wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), otlpExportTimeout)
+			defer cancel()
+			e.exportTraces(ctx)
+		}()
+	}
+}
+
+// 导出已结束的 span，失败时只记录日志，丢弃这些 span
+func (e *otelExporter) exportTraces(ctx context.Context) {
+	e.mu.Lock()
+	spans := e.spans
+	e.spans = nil
+	e.mu.Unlock()
+	if len(spans) == 0 {
+		return
+	}
+	payload := map[string]interface{}{"resourceSpans": []interface{}{map[string]interface{}{
+		"resource":   e.resource,
+		"scopeSpans": []interface{}{map[string]interface{}{"scope": e.scope(), "spans": spans}},
+	}}}
+	if err := e.post(ctx, "/v1/traces", payload); err != nil {
+		e.logger.Warn("Failed to export traces", "endpoint", e.endpoint, "spans", len(spans), "error", err)
+	}
+}
+
+// 导出 /metrics 中的全部指标：计数器为累计的单调 Sum，其余为 Gauge
+func (e *otelExporter) exportMetrics(ctx context.Context) {
+	now := unixNano(time.Now())
+	start := unixNano(e.started)
+	var exported []interface{}
+	for _, family := range metrics.snapshot() {
+		if len(family.points) == 0 {
+			continue
+		}
+		points := make([]interface{}, len(family.points))
+		for i, point := range family.points {
+			pairs := make([]interface{}, len(point.labels))
+			for j, label := range point.labels {
+				pairs[j] = label
+			}
+			points[i] = map[string]interface{}{"attributes": otlpAttributes(pairs...), "startTimeUnixNano": start, "timeUnixNano": now, "asDouble": point.value}
+		}
+		metric := map[string]interface{}{"name": family.name, "description": family.help}
+		if family.kind == "counter" {
+			metric["sum"] = map[string]interface{}{"dataPoints": points, "aggregationTemporality": 2, "isMonotonic": true}
+		} else {
+			metric["gauge"] = map[string]interface{}{"dataPoints": points}
+		}
+		exported = append(exported, metric)
+	}
+	payload := map[string]interface{}{"resourceMetrics": []interface{}{map[string]interface{}{
+		"resource":     e.resource,
+		"scopeMetrics": []interface{}{map[string]interface{}{"scope": e.scope(), "metrics": exported}},
+	}}}
+	if err := e.post(ctx, "/v1/metrics", payload); err != nil {
+		e.logger.Warn("Failed to export metrics", "endpoint", e.endpoint, "error", err)
+	}
+}
+
+// 导出数据的 instrumentation scope
+func (e *otelExporter) scope() map[string]interface{} {
+	return map[string]interface{}{"name": "DDns_go", "version": version}
+}
+
+// 以 JSON 编码发送一批数据到 endpoint+path
+func (e *otelExporter) post(ctx context.Context, path string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range e.headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("OTLP endpoint returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// 把 key、value 交替排列的属性转换为 OTLP 的 KeyValue 列表，值为空字符串的属性省略
+func otlpAttributes(kv ...interface{}) []map[string]interface{} {
+	var attrs []map[string]interface{}
+	for i := 0; i+1 < len(kv); i += 2 {
+		var value map[string]interface{}
+		switch v := kv[i+1].(type) {
+		case string:
+			if v == "" {
+				continue
+			}
+			value = map[string]interface{}{"stringValue": v}
+		case bool:
+			value = map[string]interface{}{"boolValue": v}
+		case int:
+			value = map[string]interface{}{"intValue": strconv.Itoa(v)}
+		case float64:
+			value = map[string]interface{}{"doubleValue": v}
+		case time.Duration:
+			value = map[string]interface{}{"stringValue": v.String()}
+		default:
+			value = map[string]interface{}{"stringValue": fmt.Sprint(v)}
+		}
+		attrs = append(attrs, map[string]interface{}{"key": fmt.Sprint(kv[i]), "value": value})
+	}
+	return attrs
+}
+
+// OTLP JSON 编码中 64 位整数的时间戳为十进制字符串
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// 随机的 trace ID 和 span ID，OTLP JSON 编码中为十六进制字符串
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
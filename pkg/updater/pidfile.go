@@ -0,0 +1,34 @@
+package updater
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// 将当前进程的 PID 写入已打开的 PID 文件
+func writePID(file *os.File) error {
+	if err := file.Truncate(0); err != nil {
+		return err
+	}
+	_, err := file.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	return err
+}
+
+// 读取 PID 文件中记录的进程号，用于提示正在运行的实例
+func readPID(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "unknown"
+	}
+	if pid := strings.TrimSpace(string(data)); pid != "" {
+		return pid
+	}
+	return "unknown"
+}
+
+// 另一个实例正在运行时返回的错误
+func errAlreadyRunning(path string) error {
+	return fmt.Errorf("another instance (pid %s) is already running with pid file %s, use -force to start anyway", readPID(path), path)
+}
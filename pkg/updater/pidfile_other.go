@@ -0,0 +1,31 @@
+//go:build !(linux || darwin || freebsd || openbsd || netbsd || dragonfly)
+
+package updater
+
+import "os"
+
+// 不支持 flock 的平台上以独占方式创建 PID 文件，文件已存在时说明有实例正在运行。
+// 进程异常退出后会残留 PID 文件，此时需要删除它或使用 -force 启动
+func acquirePIDFile(path string, force bool) (func(), error) {
+	flags := os.O_RDWR | os.O_CREATE | os.O_EXCL
+	if force {
+		flags = os.O_RDWR | os.O_CREATE
+	}
+	file, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		if os.IsExist(err) {
+			return nil, errAlreadyRunning(path)
+		}
+		return nil, err
+	}
+
+	if err := writePID(file); err != nil {
+		file.Close()
+		os.Remove(path)
+		return nil, err
+	}
+	return func() {
+		file.Close()
+		os.Remove(path)
+	}, nil
+}
@@ -0,0 +1,42 @@
+//go:build linux || darwin || freebsd || openbsd || netbsd || dragonfly
+
+package updater
+
+import (
+	"os"
+	"syscall"
+)
+
+// 通过 flock 获取单实例锁并写入 PID，进程退出时锁会自动释放，不会残留过期的锁。
+// force 为 true 时即使锁被占用也继续运行，但不会删除其它实例的 PID 文件
+func acquirePIDFile(path string, force bool) (func(), error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	locked := true
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		if err != syscall.EWOULDBLOCK || !force {
+			file.Close()
+			if err == syscall.EWOULDBLOCK {
+				return nil, errAlreadyRunning(path)
+			}
+			return nil, err
+		}
+		locked = false
+	}
+
+	if locked {
+		if err := writePID(file); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	return func() {
+		if locked {
+			os.Remove(path)
+		}
+		file.Close()
+	}, nil
+}
@@ -0,0 +1,261 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 插件是 pluginDir 中的可执行文件，每次调用启动一个进程：从标准输入读取一个 JSON 请求，
+// 向标准输出写一个 JSON 响应后退出。标准错误的内容只在调用失败时出现在错误信息中
+
+// 插件协议的版本，请求中的 protocol 字段和环境变量 DDNS_PLUGIN_PROTOCOL
+const pluginProtocol = 1
+
+// 插件的方法
+const (
+	pluginDescribe = "describe" // 返回插件的说明和支持的方法
+	pluginDetect   = "detect"   // 获取公网 IP，用作 IP 获取方式
+	pluginNotify   = "notify"   // 发送通知
+)
+
+// 默认的插件调用超时
+const defaultPluginTimeout = 10 * time.Second
+
+// 通过插件发送的通知
+type PluginConfig struct {
+	Plugin  string            `json:"plugin"`            // pluginDir 中的插件名称，即去掉扩展名的文件名
+	Options map[string]string `json:"options,omitempty"` // 原样传给插件的参数
+	Timeout string            `json:"timeout,omitempty"` // 每次调用的超时，默认 10s
+	ChannelConfig
+}
+
+// 写入插件标准输入的请求
+type pluginRequest struct {
+	Protocol int               `json:"protocol"`
+	Method   string            `json:"method"`
+	Options  map[string]string `json:"options,omitempty"`
+	Family   string            `json:"family,omitempty"`  // detect：IPv4、IPv6 或 WAN
+	Network  string            `json:"network,omitempty"` // detect：tcp4 或 tcp6
+	Event    *notifyEvent      `json:"event,omitempty"`   // notify：通知事件，字段与 Webhook 发送的 JSON 相同
+}
+
+// 插件写到标准输出的响应
+type pluginResponse struct {
+	Description string   `json:"description,omitempty"` // describe：一句话说明
+	Methods     []string `json:"methods,omitempty"`     // describe：支持的方法，detect 和/或 notify
+	IP          string   `json:"ip,omitempty"`          // detect：获取到的地址
+	Error       string   `json:"error,omitempty"`       // 失败的原因，插件仍应以退出码 0 结束
+	Retryable   bool     `json:"retryable,omitempty"`   // 失败时是否值得重试
+}
+
+// 插件名称为去掉扩展名的文件名，例如 fritzbox.py 和 fritzbox.exe 的名称都是 fritzbox
+func pluginName(file string) string {
+	return strings.TrimSuffix(file, filepath.Ext(file))
+}
+
+// 判断文件能否作为插件：普通文件（可以是符号链接），非 Windows 系统上需要有执行权限。隐藏文件被忽略
+func isPluginFile(path string) bool {
+	if strings.HasPrefix(filepath.Base(path), ".") {
+		return false
+	}
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	return runtime.GOOS == "windows" || info.Mode().Perm()&0o111 != 0
+}
+
+// 在 dir 中查找名为 name 的插件，返回可执行文件的路径
+func findPlugin(dir, name string) (string, error) {
+	if dir == "" {
+		return "", errors.New("pluginDir is not set")
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if (entry.Name() == name || pluginName(entry.Name()) == name) && isPluginFile(path) {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("plugin %s not found in %s", name, dir)
+}
+
+// 调用插件，超时或 ctx 取消时结束插件进程。插件返回 error 时为 apiError，是否重试由插件决定
+func callPlugin(ctx context.Context, path string, timeout time.Duration, request pluginRequest) (pluginResponse, error) {
+	var response pluginResponse
+	name := pluginName(filepath.Base(path))
+	request.Protocol = pluginProtocol
+	input, err := json.Marshal(request)
+	if err != nil {
+		return response, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, path)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Env = append(os.Environ(), fmt.Sprintf("DDNS_PLUGIN_PROTOCOL=%d", pluginProtocol))
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if ctx.Err() == context.DeadlineExceeded {
+		return response, fmt.Errorf("plugin %s timed out after %s", name, timeout)
+	}
+	if err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return response, fmt.Errorf("plugin %s failed: %v: %s", name, err, lastLine(message))
+		}
+		return response, fmt.Errorf("plugin %s failed: %v", name, err)
+	}
+	if err := json.Unmarshal(bytes.TrimSpace(output), &response); err != nil {
+		return response, fmt.Errorf("invalid response from plugin %s: %v", name, err)
+	}
+	if response.Error != "" {
+		return response, &apiError{message: fmt.Sprintf("plugin %s: %s", name, response.Error), retryable: response.Retryable}
+	}
+	return response, nil
+}
+
+// 多行输出的最后一行，通常是脚本报错的原因
+func lastLine(text string) string {
+	if i := strings.LastIndexByte(text, '\n'); i >= 0 {
+		return text[i+1:]
+	}
+	return text
+}
+
+// 查找插件并确认支持 method，返回可执行文件的路径
+func checkPlugin(ctx context.Context, dir, name, method string) (string, error) {
+	path, err := findPlugin(dir, name)
+	if err != nil {
+		return "", err
+	}
+	response, err := callPlugin(ctx, path, defaultPluginTimeout, pluginRequest{Method: pluginDescribe})
+	if err != nil {
+		return "", err
+	}
+	for _, supported := range response.Methods {
+		if supported == method {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("plugin %s does not support %s, it supports: %s", name, method, strings.Join(response.Methods, ", "))
+}
+
+// 配置中使用插件的 IP 获取方式，包括 uplinks 中各条线路的
+func (c Config) pluginSources() []*SourceConfig {
+	var sources []*SourceConfig
+	candidates := []*SourceConfig{c.IPv4Source, c.IPv6Source, c.WANSource}
+	for _, uplink := range c.Uplinks {
+		candidates = append(candidates, uplink.IPv4Source, uplink.IPv6Source)
+	}
+	for _, source := range candidates {
+		if source != nil && source.Source == "plugin" {
+			sources = append(sources, source)
+		}
+	}
+	return sources
+}
+
+// 通过插件获取公网 IP，超时使用 source 的 timeout
+func getPublicIPFromPlugin(ctx context.Context, family ipFamily) (string, error) {
+	path, err := findPlugin(family.pluginDir, family.source.Plugin)
+	if err != nil {
+		return "", err
+	}
+	timeout, err := sourceTimeout(family.source)
+	if err != nil {
+		return "", err
+	}
+	response, err := callPlugin(ctx, path, timeout, pluginRequest{
+		Method:  pluginDetect,
+		Options: family.source.Options,
+		Family:  family.name,
+		Network: family.network,
+	})
+	if err != nil {
+		return "", err
+	}
+	if response.IP == "" {
+		return "", fmt.Errorf("plugin %s returned no ip", family.source.Plugin)
+	}
+	return strings.TrimSpace(response.IP), nil
+}
+
+// 通过插件发送的通知
+type pluginNotifier struct {
+	path    string
+	options map[string]string
+	timeout time.Duration
+}
+
+// 启动时确认插件存在并且支持 notify
+func newPluginNotifier(dir string, config PluginConfig) (*pluginNotifier, error) {
+	n := &pluginNotifier{options: config.Options, timeout: defaultPluginTimeout}
+	if config.Timeout != "" {
+		timeout, err := time.ParseDuration(config.Timeout)
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("invalid timeout %q", config.Timeout)
+		}
+		n.timeout = timeout
+	}
+	path, err := checkPlugin(context.Background(), dir, config.Plugin, pluginNotify)
+	if err != nil {
+		return nil, err
+	}
+	n.path = path
+	return n, nil
+}
+
+func (n *pluginNotifier) send(ctx context.Context, _ *http.Client, event notifyEvent) error {
+	_, err := callPlugin(ctx, n.path, n.timeout, pluginRequest{Method: pluginNotify, Options: n.options, Event: &event})
+	return err
+}
+
+// plugins 子命令列出的一个插件
+type pluginInfo struct {
+	Name        string   `json:"name"`
+	Path        string   `json:"path"`
+	Description string   `json:"description,omitempty"`
+	Methods     []string `json:"methods,omitempty"`
+	Error       string   `json:"error,omitempty"` // describe 调用失败的原因
+}
+
+// 列出 dir 中的全部插件，并调用 describe 获取说明和支持的方法
+func discoverPlugins(ctx context.Context, dir string) ([]pluginInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var plugins []pluginInfo
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if !isPluginFile(path) {
+			continue
+		}
+		info := pluginInfo{Name: pluginName(entry.Name()), Path: path}
+		response, err := callPlugin(ctx, path, defaultPluginTimeout, pluginRequest{Method: pluginDescribe})
+		if err != nil {
+			info.Error = err.Error()
+		} else {
+			info.Description, info.Methods = response.Description, response.Methods
+		}
+		plugins = append(plugins, info)
+	}
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i].Name < plugins[j].Name })
+	return plugins, nil
+}
@@ -0,0 +1,33 @@
+package updater
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// expvar 中的变量只能发布一次
+var publishDebugVars sync.Once
+
+// 开启 debug 时在内置 HTTP 服务上提供 /debug/pprof/ 和 /debug/vars，与 REST API 使用相同的令牌。
+// 用于在长期运行的路由器上排查内存增长和 goroutine 泄漏
+func registerDebug(mux *http.ServeMux, token string) {
+	started := time.Now()
+	publishDebugVars.Do(func() {
+		expvar.Publish("version", expvar.Func(func() interface{} { return versionString() }))
+		expvar.Publish("goroutines", expvar.Func(func() interface{} { return runtime.NumGoroutine() }))
+		expvar.Publish("uptimeSeconds", expvar.Func(func() interface{} { return int64(time.Since(started).Seconds()) }))
+	})
+
+	mux.Handle("/debug/vars", requireToken(token, http.MethodGet, expvar.Handler().ServeHTTP))
+	// Index 根据路径中的名称输出 heap、goroutine、allocs 等 profile
+	mux.Handle("/debug/pprof/", requireToken(token, http.MethodGet, pprof.Index))
+	mux.Handle("/debug/pprof/cmdline", requireToken(token, http.MethodGet, pprof.Cmdline))
+	mux.Handle("/debug/pprof/profile", requireToken(token, http.MethodGet, pprof.Profile))
+	// go tool pprof 通过 POST 查询远程 profile 的符号
+	mux.Handle("/debug/pprof/symbol", requireTokenMethods(token, []string{http.MethodGet, http.MethodPost}, pprof.Symbol))
+	mux.Handle("/debug/pprof/trace", requireToken(token, http.MethodGet, pprof.Trace))
+}
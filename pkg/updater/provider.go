@@ -0,0 +1,159 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"DDns_go/pkg/provider"
+)
+
+// 一条解析记录，各服务商返回的记录都转换为该结构
+type dnsRecord = provider.Record
+
+// DNS 服务商，更新循环只通过该接口读写解析记录
+type dnsProvider interface {
+	// 返回域名下的全部解析记录
+	listRecords(ctx context.Context, domainName string) ([]dnsRecord, error)
+	// 添加一条解析记录，返回新记录的 ID
+	createRecord(ctx context.Context, domainName string, record dnsRecord) (string, error)
+	// 按 record.ID 修改解析记录的值和 TTL
+	updateRecord(ctx context.Context, domainName string, record dnsRecord) error
+}
+
+// 可以查询域名允许的最小 TTL 的服务商，启动时用来检查 ttl 配置
+type minTTLProvider interface {
+	minTTL(ctx context.Context, domainName string) (int, error)
+}
+
+// 可以按 ID 查询单条记录的服务商，stateFile 中保存了 RecordId 时用来代替查询域名下的全部记录
+type recordGetter interface {
+	getRecord(ctx context.Context, domainName, recordID string) (dnsRecord, error)
+}
+
+// 可以列出账号下全部域名的服务商，用来把 names 中的完整名称拆分为主机记录和域名
+type zoneLister interface {
+	listZones(ctx context.Context) ([]string, error)
+}
+
+// 可以修改记录备注的服务商，tagRecords 开启时用来标记由本程序管理的记录
+type remarkProvider interface {
+	setRemark(ctx context.Context, domainName string, record dnsRecord, remark string) error
+}
+
+// 可以启用和暂停记录的服务商，disabledRecords 为 enable 时在更新前启用，prune 为 disable 时暂停过期的记录
+type recordStatusSetter interface {
+	setRecordEnabled(ctx context.Context, domainName string, record dnsRecord, enabled bool) error
+}
+
+// 可以删除记录的服务商，prune 为 delete 时删除过期的记录
+type recordDeleter interface {
+	deleteRecord(ctx context.Context, domainName string, record dnsRecord) error
+}
+
+// 支持的 DNS 服务商
+const (
+	providerAliyun     = "aliyun"
+	providerCloudflare = "cloudflare"
+	providerDNSPod     = "dnspod"
+	providerHuawei     = "huawei"
+
+	// 阿里云 zoneType 为 private 的域名使用内网 DNS 解析，不能直接在配置中指定
+	providerPrivateZone = "pvtz"
+)
+
+// 按名称创建 DNS 服务商
+func newProvider(name string, config Config, logger *slog.Logger) (dnsProvider, error) {
+	switch name {
+	case providerAliyun:
+		provider, err := newAliyunProvider(config, logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Aliyun DNS client: %v", err)
+		}
+		return provider, nil
+	case providerCloudflare:
+		provider, err := newCloudflareProvider(config, logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Cloudflare client: %v", err)
+		}
+		return provider, nil
+	case providerDNSPod:
+		provider, err := newDNSPodProvider(config, logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create DNSPod client: %v", err)
+		}
+		return provider, nil
+	case providerHuawei:
+		provider, err := newHuaweiProvider(config, logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Huawei Cloud DNS client: %v", err)
+		}
+		return provider, nil
+	case providerPrivateZone:
+		provider, err := newPrivateZoneProvider(config, logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Aliyun PrivateZone client: %v", err)
+		}
+		return provider, nil
+	default:
+		return nil, fmt.Errorf("unknown DNS provider: %s", name)
+	}
+}
+
+// 为域名创建服务商客户端，引用了 accounts 中的账号时使用该账号的凭证
+func newDomainProvider(domain DomainConfig, config Config, logger *slog.Logger) (dnsProvider, error) {
+	config, err := config.forAccount(domain.Account)
+	if err != nil {
+		return nil, err
+	}
+	return newProvider(domain.Provider, config, logger)
+}
+
+// 服务商客户端的名称，引用了 accounts 中的账号时为 aliyun/work 这样的 服务商/账号 形式。
+// 每个名称创建一个客户端，限流状态也按该名称分别记录
+func (d DomainConfig) providerKey() string {
+	if d.Account == "" {
+		return d.Provider
+	}
+	return d.Provider + "/" + d.Account
+}
+
+// 返回每个服务商客户端的第一个域名，每个客户端只出现一次
+func providerDomains(domains []DomainConfig) []DomainConfig {
+	var first []DomainConfig
+	seen := make(map[string]bool)
+	for _, domain := range domains {
+		if key := domain.providerKey(); !seen[key] {
+			seen[key] = true
+			first = append(first, domain)
+		}
+	}
+	return first
+}
+
+// 把完整域名转换为主机记录，根域名为 @
+func relativeRR(name, domainName string) string {
+	if strings.EqualFold(name, domainName) {
+		return "@"
+	}
+	return strings.TrimSuffix(name, "."+domainName)
+}
+
+// 把主机记录转换为完整域名
+func fullRecordName(rr, domainName string) string {
+	if rr == "@" {
+		return domainName
+	}
+	return rr + "." + domainName
+}
+
+// 请求 ID 的收集器在 pkg/provider 中，pkg/provider/alidns 等服务商包也通过它写入
+func withRequestIDs(ctx context.Context) (context.Context, *provider.RequestIDs) {
+	return provider.WithRequestIDs(ctx)
+}
+
+// 记录一次 API 调用的请求 ID，ctx 中没有收集器或 id 为空时忽略
+func noteRequestID(ctx context.Context, id string) {
+	provider.NoteRequestID(ctx, id)
+}
@@ -0,0 +1,208 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/requests"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/pvtz"
+)
+
+// 域名所在的区域类型
+const (
+	zoneTypePublic  = "public"
+	zoneTypePrivate = "private"
+)
+
+// 阿里云内网 DNS 解析（PrivateZone），按 Zone ID 读写记录
+type privateZoneProvider struct {
+	client *pvtz.Client
+	zones  map[string]string // 域名对应的 Zone ID
+}
+
+// 每页获取的记录数，PrivateZone 允许的最大值为 100
+const privateZonePageSize = 100
+
+func newPrivateZoneProvider(config Config, logger *slog.Logger) (*privateZoneProvider, error) {
+	settings, err := config.httpSettings()
+	if err != nil {
+		return nil, err
+	}
+	client := &pvtz.Client{}
+	if err := initSDKClient(&client.Client, config, config.regionID()); err != nil {
+		return nil, err
+	}
+	pvtz.SetEndpointDataToClient(client)
+	configureSDKClient(&client.Client, settings)
+	if debugEnabled(logger) {
+		client.SetTransport(withDebugTransport(settings.newTransport("", settings.aliyunProxy), logger))
+	}
+
+	domains, err := config.domainList()
+	if err != nil {
+		return nil, err
+	}
+	zones := make(map[string]string)
+	for _, domain := range domains {
+		if domain.Provider == providerPrivateZone {
+			zones[domain.DomainName] = domain.ZoneID
+		}
+	}
+	return &privateZoneProvider{client: client, zones: zones}, nil
+}
+
+func (p *privateZoneProvider) zoneID(domainName string) (string, error) {
+	zoneID, ok := p.zones[domainName]
+	if !ok {
+		return "", fmt.Errorf("no zoneId configured for private zone %s", domainName)
+	}
+	return zoneID, nil
+}
+
+func (p *privateZoneProvider) listRecords(ctx context.Context, domainName string) ([]dnsRecord, error) {
+	zoneID, err := p.zoneID(domainName)
+	if err != nil {
+		return nil, err
+	}
+
+	var all []dnsRecord
+	for page := 1; ; page++ {
+		describeRequest := pvtz.CreateDescribeZoneRecordsRequest()
+		describeRequest.Scheme = "https"
+		describeRequest.ZoneId = zoneID
+		describeRequest.PageNumber = requests.NewInteger(page)
+		describeRequest.PageSize = requests.NewInteger(privateZonePageSize)
+
+		if err := withDeadline(ctx, p.client, describeRequest); err != nil {
+			return nil, err
+		}
+		response, err := p.client.DescribeZoneRecords(describeRequest)
+		if err != nil {
+			noteRequestID(ctx, aliyunRequestID(err))
+			return nil, err
+		}
+		noteRequestID(ctx, response.RequestId)
+		for _, record := range response.Records.Record {
+			all = append(all, dnsRecord{
+				ID:       strconv.FormatInt(record.RecordId, 10),
+				RR:       record.Rr,
+				Type:     record.Type,
+				Value:    record.Value,
+				TTL:      record.Ttl,
+				Disabled: record.Status == "DISABLE",
+				Remark:   record.Remark,
+			})
+		}
+		if len(response.Records.Record) == 0 || len(all) >= response.TotalItems {
+			return all, nil
+		}
+	}
+}
+
+func (p *privateZoneProvider) createRecord(ctx context.Context, domainName string, record dnsRecord) (string, error) {
+	zoneID, err := p.zoneID(domainName)
+	if err != nil {
+		return "", err
+	}
+	addRequest := pvtz.CreateAddZoneRecordRequest()
+	addRequest.Scheme = "https"
+	addRequest.ZoneId = zoneID
+	addRequest.Rr = record.RR
+	addRequest.Type = record.Type
+	addRequest.Value = record.Value
+	if record.TTL > 0 {
+		addRequest.Ttl = requests.NewInteger(record.TTL)
+	}
+
+	if err := withDeadline(ctx, p.client, addRequest); err != nil {
+		return "", err
+	}
+	response, err := p.client.AddZoneRecord(addRequest)
+	if err != nil {
+		noteRequestID(ctx, aliyunRequestID(err))
+		return "", err
+	}
+	noteRequestID(ctx, response.RequestId)
+	return strconv.FormatInt(response.RecordId, 10), nil
+}
+
+func (p *privateZoneProvider) updateRecord(ctx context.Context, _ string, record dnsRecord) error {
+	updateRequest := pvtz.CreateUpdateZoneRecordRequest()
+	updateRequest.Scheme = "https"
+	updateRequest.RecordId = requests.Integer(record.ID)
+	updateRequest.Rr = record.RR
+	updateRequest.Type = record.Type
+	updateRequest.Value = record.Value
+	if record.TTL > 0 {
+		updateRequest.Ttl = requests.NewInteger(record.TTL)
+	}
+
+	if err := withDeadline(ctx, p.client, updateRequest); err != nil {
+		return err
+	}
+	response, err := p.client.UpdateZoneRecord(updateRequest)
+	if err != nil {
+		noteRequestID(ctx, aliyunRequestID(err))
+		return err
+	}
+	noteRequestID(ctx, response.RequestId)
+	return nil
+}
+
+func (p *privateZoneProvider) setRemark(ctx context.Context, _ string, record dnsRecord, remark string) error {
+	remarkRequest := pvtz.CreateUpdateRecordRemarkRequest()
+	remarkRequest.Scheme = "https"
+	remarkRequest.RecordId = requests.Integer(record.ID)
+	remarkRequest.Remark = remark
+
+	if err := withDeadline(ctx, p.client, remarkRequest); err != nil {
+		return err
+	}
+	response, err := p.client.UpdateRecordRemark(remarkRequest)
+	if err != nil {
+		noteRequestID(ctx, aliyunRequestID(err))
+		return err
+	}
+	noteRequestID(ctx, response.RequestId)
+	return nil
+}
+
+func (p *privateZoneProvider) setRecordEnabled(ctx context.Context, _ string, record dnsRecord, enabled bool) error {
+	statusRequest := pvtz.CreateSetZoneRecordStatusRequest()
+	statusRequest.Scheme = "https"
+	statusRequest.RecordId = requests.Integer(record.ID)
+	statusRequest.Status = "DISABLE"
+	if enabled {
+		statusRequest.Status = "ENABLE"
+	}
+
+	if err := withDeadline(ctx, p.client, statusRequest); err != nil {
+		return err
+	}
+	response, err := p.client.SetZoneRecordStatus(statusRequest)
+	if err != nil {
+		noteRequestID(ctx, aliyunRequestID(err))
+		return err
+	}
+	noteRequestID(ctx, response.RequestId)
+	return nil
+}
+
+func (p *privateZoneProvider) deleteRecord(ctx context.Context, _ string, record dnsRecord) error {
+	deleteRequest := pvtz.CreateDeleteZoneRecordRequest()
+	deleteRequest.Scheme = "https"
+	deleteRequest.RecordId = requests.Integer(record.ID)
+
+	if err := withDeadline(ctx, p.client, deleteRequest); err != nil {
+		return err
+	}
+	response, err := p.client.DeleteZoneRecord(deleteRequest)
+	if err != nil {
+		noteRequestID(ctx, aliyunRequestID(err))
+		return err
+	}
+	noteRequestID(ctx, response.RequestId)
+	return nil
+}
@@ -0,0 +1,233 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// 替换敏感信息使用的文本
+const redactedText = "******"
+
+// 看起来像密钥的内容：请求地址中的签名参数、AccessKey ID、STS 临时凭证和 30 位的 AccessKey secret
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`((?:AccessKeyId|AccessKeySecret|Signature|SecurityToken)=)[^&\s"]+`),
+	regexp.MustCompile(`\bLTAI[0-9A-Za-z]{12,28}\b`),
+	regexp.MustCompile(`\bSTS\.[0-9A-Za-z]{16,}\b`),
+	regexp.MustCompile(`\b[0-9A-Za-z]{30}\b`),
+}
+
+// 配置中的密钥太短时不单独替换，避免误伤普通的日志内容
+const minSecretLength = 6
+
+// 从日志内容中去掉配置的密钥以及看起来像 AccessKey 的内容
+type redactor struct {
+	secrets []string
+}
+
+func newRedactor(secrets []string) *redactor {
+	r := &redactor{}
+	for _, secret := range secrets {
+		if len(secret) >= minSecretLength {
+			r.secrets = append(r.secrets, secret)
+			// 出现在请求地址中的密钥会被转义
+			if escaped := url.QueryEscape(secret); escaped != secret {
+				r.secrets = append(r.secrets, escaped)
+			}
+		}
+	}
+	// 先替换较长的密钥，避免其中包含的较短密钥被先替换
+	sort.Slice(r.secrets, func(i, j int) bool { return len(r.secrets[i]) > len(r.secrets[j]) })
+	return r
+}
+
+func (r *redactor) redact(s string) string {
+	for _, secret := range r.secrets {
+		s = strings.ReplaceAll(s, secret, redactedText)
+	}
+	for i, pattern := range secretPatterns {
+		if i == 0 {
+			s = pattern.ReplaceAllString(s, "${1}"+redactedText)
+		} else {
+			s = pattern.ReplaceAllString(s, redactedText)
+		}
+	}
+	return s
+}
+
+// 配置中需要在日志中隐藏的内容
+func (c Config) secrets() []string {
+	secrets := []string{c.AccessKey, c.AccessSecret, c.APIToken}
+	if c.DynDNS != nil {
+		secrets = append(secrets, c.DynDNS.Password)
+	}
+	if c.Cloudflare != nil {
+		secrets = append(secrets, c.Cloudflare.APIToken)
+	}
+	if c.DNSPod != nil {
+		secrets = append(secrets, c.DNSPod.SecretID, c.DNSPod.SecretKey)
+	}
+	if c.Huawei != nil {
+		secrets = append(secrets, c.Huawei.AccessKey, c.Huawei.SecretKey)
+	}
+	if c.Credentials != nil {
+		secrets = append(secrets, c.Credentials.SecurityToken)
+	}
+	for _, account := range c.Accounts {
+		secrets = append(secrets, account.AccessKey, account.AccessSecret)
+		if account.Credentials != nil {
+			secrets = append(secrets, account.Credentials.SecurityToken)
+		}
+	}
+	if c.Proxy != nil {
+		for _, raw := range []string{c.Proxy.IP, c.Proxy.Aliyun} {
+			if proxyURL, err := url.Parse(raw); err == nil && proxyURL.User != nil {
+				password, _ := proxyURL.User.Password()
+				secrets = append(secrets, password)
+			}
+		}
+	}
+	if c.Notify != nil {
+		// 通知地址中通常包含机器人的令牌，整个地址都需要隐藏
+		for _, webhook := range c.Notify.Webhooks {
+			secrets = append(secrets, webhook.URL)
+			for key, value := range webhook.Headers {
+				if isSecretHeader(key) {
+					secrets = append(secrets, value)
+				}
+			}
+		}
+		for _, robot := range c.Notify.DingTalk {
+			secrets = append(secrets, robot.Webhook, robot.Secret)
+		}
+		for _, robot := range c.Notify.WeCom {
+			secrets = append(secrets, robot.Webhook)
+		}
+		for _, robot := range c.Notify.Feishu {
+			secrets = append(secrets, robot.Webhook, robot.Secret)
+		}
+		for _, robot := range c.Notify.Telegram {
+			secrets = append(secrets, robot.BotToken)
+		}
+		for _, robot := range c.Notify.Slack {
+			secrets = append(secrets, robot.Webhook)
+		}
+		for _, robot := range c.Notify.Discord {
+			secrets = append(secrets, robot.Webhook)
+		}
+		for _, push := range c.Notify.Bark {
+			secrets = append(secrets, push.DeviceKey)
+		}
+		for _, push := range c.Notify.Gotify {
+			secrets = append(secrets, push.Token)
+		}
+		for _, push := range c.Notify.Ntfy {
+			secrets = append(secrets, push.Token)
+		}
+		for _, plugin := range c.Notify.Plugins {
+			secrets = append(secrets, secretOptions(plugin.Options)...)
+		}
+	}
+	for _, source := range c.pluginSources() {
+		secrets = append(secrets, secretOptions(source.Options)...)
+	}
+	if c.OTel != nil {
+		// 导出使用的请求头通常是 Authorization 等认证信息
+		for _, value := range c.OTel.Headers {
+			secrets = append(secrets, value)
+		}
+	}
+	if c.Geo != nil {
+		// 在线查询的 API 通常把令牌放在查询参数中，例如 ipinfo.io 的 token
+		if geoURL, err := url.Parse(c.Geo.URL); err == nil {
+			for _, values := range geoURL.Query() {
+				secrets = append(secrets, values...)
+			}
+			if geoURL.User != nil {
+				password, _ := geoURL.User.Password()
+				secrets = append(secrets, password)
+			}
+		}
+	}
+	return secrets
+}
+
+// 插件参数中名称像密码或令牌的值，例如 password、token、apiKey
+func secretOptions(options map[string]string) []string {
+	var secrets []string
+	for key, value := range options {
+		if isSecretHeader(key) || strings.Contains(strings.ToLower(key), "pass") {
+			secrets = append(secrets, value)
+		}
+	}
+	return secrets
+}
+
+// 可能包含令牌的请求头，例如 Authorization、X-Api-Key
+func isSecretHeader(key string) bool {
+	key = strings.ToLower(key)
+	for _, word := range []string{"auth", "token", "key", "secret"} {
+		if strings.Contains(key, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// 在写入日志前隐藏消息和字段中的敏感信息
+type redactHandler struct {
+	next     slog.Handler
+	redactor *redactor
+}
+
+func newRedactHandler(next slog.Handler, r *redactor) *redactHandler {
+	return &redactHandler{next: next, redactor: r}
+}
+
+func (h *redactHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *redactHandler) Handle(ctx context.Context, record slog.Record) error {
+	redacted := slog.NewRecord(record.Time, record.Level, h.redactor.redact(record.Message), record.PC)
+	record.Attrs(func(attr slog.Attr) bool {
+		redacted.AddAttrs(h.redactAttr(attr))
+		return true
+	})
+	return h.next.Handle(ctx, redacted)
+}
+
+func (h *redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, attr := range attrs {
+		redacted[i] = h.redactAttr(attr)
+	}
+	return &redactHandler{next: h.next.WithAttrs(redacted), redactor: h.redactor}
+}
+
+func (h *redactHandler) WithGroup(name string) slog.Handler {
+	return &redactHandler{next: h.next.WithGroup(name), redactor: h.redactor}
+}
+
+// 字符串、错误和其它任意类型的值都转换为字符串后再隐藏敏感信息
+func (h *redactHandler) redactAttr(attr slog.Attr) slog.Attr {
+	attr.Value = attr.Value.Resolve()
+	switch attr.Value.Kind() {
+	case slog.KindString:
+		attr.Value = slog.StringValue(h.redactor.redact(attr.Value.String()))
+	case slog.KindAny:
+		attr.Value = slog.StringValue(h.redactor.redact(fmt.Sprint(attr.Value.Any())))
+	case slog.KindGroup:
+		group := attr.Value.Group()
+		redacted := make([]slog.Attr, len(group))
+		for i, groupAttr := range group {
+			redacted[i] = h.redactAttr(groupAttr)
+		}
+		attr.Value = slog.GroupValue(redacted...)
+	}
+	return attr
+}
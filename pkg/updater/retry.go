@@ -0,0 +1,157 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"strings"
+	"time"
+
+	sdkerrors "github.com/aliyun/alibaba-cloud-sdk-go/sdk/errors"
+)
+
+// 重试配置
+type RetryConfig struct {
+	MaxAttempts  int    `json:"maxAttempts"`  // 最多尝试次数（包括第一次），默认 3，设置为 1 表示不重试
+	InitialDelay string `json:"initialDelay"` // 第一次重试前的等待时间，默认 1s，之后每次翻倍
+	MaxDelay     string `json:"maxDelay"`     // 最长等待时间，默认 30s
+	Timeout      string `json:"timeout"`      // 每次尝试的最长时间，默认 1m，超时后按可重试的错误处理
+}
+
+// 解析后的重试策略
+type retryPolicy struct {
+	attempts     int
+	initialDelay time.Duration
+	maxDelay     time.Duration
+	timeout      time.Duration
+}
+
+// 默认的重试策略
+var defaultRetryPolicy = retryPolicy{attempts: 3, initialDelay: time.Second, maxDelay: 30 * time.Second, timeout: time.Minute}
+
+// 返回重试策略，未设置的字段使用默认值
+func (c Config) retryPolicy() (retryPolicy, error) {
+	return parseRetryPolicy("retry", c.Retry)
+}
+
+// 发送通知的重试策略，未设置 notify.retry 时与 retry 相同
+func (c Config) notifyRetryPolicy() (retryPolicy, error) {
+	if c.Notify == nil || c.Notify.Retry == nil {
+		return c.retryPolicy()
+	}
+	return parseRetryPolicy("notify.retry", c.Notify.Retry)
+}
+
+// 解析 field 字段的重试配置
+func parseRetryPolicy(field string, config *RetryConfig) (retryPolicy, error) {
+	policy := defaultRetryPolicy
+	if config == nil {
+		return policy, nil
+	}
+
+	if config.MaxAttempts < 0 {
+		return policy, fmt.Errorf("invalid %s.maxAttempts %d", field, config.MaxAttempts)
+	}
+	if config.MaxAttempts > 0 {
+		policy.attempts = config.MaxAttempts
+	}
+	if config.InitialDelay != "" {
+		delay, err := time.ParseDuration(config.InitialDelay)
+		if err != nil || delay < 0 {
+			return policy, fmt.Errorf("invalid %s.initialDelay %q", field, config.InitialDelay)
+		}
+		policy.initialDelay = delay
+	}
+	if config.MaxDelay != "" {
+		delay, err := time.ParseDuration(config.MaxDelay)
+		if err != nil || delay < 0 {
+			return policy, fmt.Errorf("invalid %s.maxDelay %q", field, config.MaxDelay)
+		}
+		policy.maxDelay = delay
+	}
+	// 只设置其中一个时也与另一个的默认值比较
+	if policy.maxDelay < policy.initialDelay {
+		return policy, fmt.Errorf("invalid %s: maxDelay %s is less than initialDelay %s", field, policy.maxDelay, policy.initialDelay)
+	}
+	if config.Timeout != "" {
+		timeout, err := time.ParseDuration(config.Timeout)
+		if err != nil || timeout <= 0 {
+			return policy, fmt.Errorf("invalid %s.timeout %q", field, config.Timeout)
+		}
+		policy.timeout = timeout
+	}
+	return policy, nil
+}
+
+// 执行 fn，遇到可重试的错误时按指数退避（带随机抖动）重试，ctx 取消后不再重试。
+// 每次尝试传给 fn 的 context 在 timeout 后到期
+func (p retryPolicy) do(ctx context.Context, logger *slog.Logger, what string, fn func(ctx context.Context) error) error {
+	delay := p.initialDelay
+	for attempt := 1; ; attempt++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, p.timeout)
+		err := fn(attemptCtx)
+		cancel()
+		if err == nil || attempt >= p.attempts || !retryable(err) || ctx.Err() != nil {
+			return err
+		}
+
+		// 被限流时直接等待最长时间，过快重试只会继续被拒绝
+		if throttled(err) {
+			delay = p.maxDelay
+		}
+		// 等待时间在 delay/2 到 delay 之间随机，避免多个实例同时重试
+		wait := delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+		logger.Warn("Failed to "+what+", retrying", "attempt", attempt, "max_attempts", p.attempts, "wait", wait.Round(time.Millisecond).String(), "error", err)
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+
+		delay *= 2
+		if delay > p.maxDelay {
+			delay = p.maxDelay
+		}
+	}
+}
+
+// 判断错误是否值得重试：网络错误、服务端 5xx 错误和限流可以重试，参数或权限错误重试也不会成功
+func retryable(err error) bool {
+	if err == ErrNoUpdateNeeded || err == ErrRecordNotFound || err == ErrRecordDisabled {
+		return false
+	}
+	if serverErr, ok := err.(*sdkerrors.ServerError); ok {
+		// SignatureNonceUsed 表示随机数重复，重新发送时 SDK 会生成新的随机数
+		return serverErr.HttpStatus() >= 500 || throttled(err) || serverErr.ErrorCode() == "SignatureNonceUsed"
+	}
+	if apiErr, ok := err.(*apiError); ok {
+		return apiErr.retryable
+	}
+	return true
+}
+
+// 判断错误是否表示调用频率超过了服务商的限制，例如阿里云的 Throttling.User
+func throttled(err error) bool {
+	if serverErr, ok := err.(*sdkerrors.ServerError); ok {
+		return strings.HasPrefix(serverErr.ErrorCode(), "Throttling")
+	}
+	if apiErr, ok := err.(*apiError); ok {
+		return apiErr.throttled
+	}
+	return false
+}
+
+// HTTP 接口（通知服务、Cloudflare 等）拒绝了请求，通常只有服务端错误和限流值得重试
+type apiError struct {
+	message   string
+	retryable bool
+	throttled bool // 调用频率超过限制
+	auth      bool // 凭证无效或没有权限
+}
+
+func (e *apiError) Error() string {
+	return e.message
+}
@@ -0,0 +1,91 @@
+// Package updater 实现 DDns_go 的检测和更新：获取公网 IP、同步各服务商的解析记录，以及通知、指标和各个子命令。
+// 命令行程序在 cmd/DDns_go 中，只调用 Main。其它 Go 程序可以通过 New 和 Run 直接嵌入检测和更新的循环：
+//
+//	config, err := updater.LoadConfig("config.json", "")
+//	if err != nil {
+//		return err
+//	}
+//	u, err := updater.New(ctx, config, slog.Default())
+//	if err != nil {
+//		return err
+//	}
+//	return u.Run(ctx)
+package updater
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// 嵌入其它程序时使用的更新器，按配置的 interval 或 schedule 检测公网 IP 并同步解析记录。
+// 状态、指标和获取 IP 使用的 HTTP 客户端在进程内共享，同一进程中只应运行一个 Updater
+type Updater struct {
+	u *updater
+}
+
+// 读取配置文件，与命令行程序相同应用 DDNS_ 开头的环境变量并解密 enc:v1: 开头的值，format 为空时按扩展名判断
+func LoadConfig(path, format string) (Config, error) {
+	return loadConfig(path, format)
+}
+
+// 按配置创建 Updater：读取钥匙串、Vault 和 KMS 中的凭证，校验配置并加载 stateFile，logger 为 nil 时使用 slog.Default()。
+// 只执行检测和更新，不启动 metricsListen、controlSocket 和 dyndns 等服务，也不续期 Vault 租约
+func New(ctx context.Context, config Config, logger *slog.Logger) (*Updater, error) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	leases, err := config.loadCredentials(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	state, err := loadState(config.StateFile)
+	if err != nil {
+		return nil, err
+	}
+	u, err := newUpdater(config, state, logger)
+	if err != nil {
+		return nil, err
+	}
+	u.leases = leases
+	u.quiet = true
+	u.force = config.ForceUpdateOnStart
+	return &Updater{u: u}, nil
+}
+
+// 执行一次检测和更新，全部成功（包括无需更新）时返回 nil，与 once 子命令相同
+func (u *Updater) RunOnce(ctx context.Context) error {
+	err := u.u.runCycle(ctx)
+	u.u.force = false
+	return err
+}
+
+// 按配置定时检测和更新，直到 ctx 结束。单次检测失败只记录日志，到下一次检测时重试；
+// ctx 结束后等待尚未发送完的通知，返回 ctx.Err()
+func (u *Updater) Run(ctx context.Context) error {
+	u.u.notify.emit(notifyEvent{Event: eventStartup})
+	// 与命令行程序相同，关闭 runOnStart 时先等待一个检测间隔
+	waitFirst := !u.u.config.runOnStart()
+	for {
+		if waitFirst {
+			waitFirst = false
+			u.u.reschedule(u.u.dueDomains(time.Now()), time.Now())
+		} else {
+			u.RunOnce(ctx)
+		}
+
+		next := u.u.nextCheck()
+		status.scheduled(next)
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			u.u.notify.wait(notifyShutdownTimeout)
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	sdk "github.com/aliyun/alibaba-cloud-sdk-go/services/alidns"
+
+	"DDns_go/pkg/provider/alidns"
+)
+
+// 通过导出的 API 创建、从 ipURL 获取公网 IP 的 Updater，阿里云客户端替换为 fake
+func newTestExportedUpdater(t *testing.T, fake alidnsAPI, ipURL string) *Updater {
+	t.Helper()
+	config := validTestConfig()
+	config.RR = "www"
+	config.APIURLs = []string{ipURL}
+	config.LogFileName = ""
+	config.Interval = "1h"
+	u, err := New(context.Background(), config, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	u.u.providers[providerAliyun] = &aliyunProvider{dns: alidns.New(fake)}
+	return u
+}
+
+func TestNewInvalidConfig(t *testing.T) {
+	config := validTestConfig()
+	config.IPMode = "ipv5"
+	var errs validationErrors
+	if _, err := New(context.Background(), config, nil); !errors.As(err, &errs) {
+		t.Errorf("New() error = %v, want validation errors", err)
+	}
+}
+
+func TestUpdaterRunOnce(t *testing.T) {
+	server, _ := newIPServer(t, "1.2.3.5")
+	fake := newFakeAlidns(sdk.Record{RecordId: "1", DomainName: "example.com", RR: "www", Type: "A", Value: "1.2.3.4", Line: aliyunDefaultLine, Status: "ENABLE"})
+	u := newTestExportedUpdater(t, fake, server.URL)
+
+	if err := u.RunOnce(context.Background()); err != nil {
+		t.Fatalf("RunOnce() error = %v", err)
+	}
+	if len(fake.updates) != 1 || fake.updates[0].Value != "1.2.3.5" {
+		t.Errorf("unexpected UpdateDomainRecord requests %+v", fake.updates)
+	}
+}
+
+func TestUpdaterRun(t *testing.T) {
+	server, requests := newIPServer(t, "1.2.3.4")
+	fake := newFakeAlidns()
+	u := newTestExportedUpdater(t, fake, server.URL)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- u.Run(ctx) }()
+
+	// 第一次检测立即进行，之后等待 1h
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		fake.mu.Lock()
+		adds := len(fake.adds)
+		fake.mu.Unlock()
+		if adds > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Run() did not create the missing record")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Run() error = %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run() did not return after ctx was canceled")
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("public IP requested %d times, want 1", got)
+	}
+}
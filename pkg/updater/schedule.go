@@ -0,0 +1,306 @@
+package updater
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 检测的时间安排：固定间隔或 cron 表达式，每次等待再加上不超过 jitter 的随机时间，
+// 避免大量设备在同一秒请求 IP API
+type schedule struct {
+	interval time.Duration // cron 为 nil 时使用的固定间隔
+	cron     *cronSchedule
+	jitter   time.Duration
+}
+
+// 返回检测的时间安排，schedule 优先于 interval 和 delay/timeUnit
+func (c Config) pollSchedule() (schedule, error) {
+	var s schedule
+	if c.Jitter != "" {
+		jitter, err := time.ParseDuration(c.Jitter)
+		if err != nil || jitter < 0 {
+			return s, fmt.Errorf("invalid jitter %q", c.Jitter)
+		}
+		s.jitter = jitter
+	}
+	if c.Schedule != "" {
+		cron, err := parseCron(c.Schedule)
+		if err != nil {
+			return s, fmt.Errorf("invalid schedule %q: %v", c.Schedule, err)
+		}
+		s.cron = cron
+		return s, nil
+	}
+	interval, err := c.pollInterval()
+	if err != nil {
+		return s, err
+	}
+	s.interval = interval
+	return s, nil
+}
+
+// 返回 now 之后下一次检测的时间
+func (s schedule) next(now time.Time) time.Time {
+	next := now.Add(s.interval)
+	if s.cron != nil {
+		next = s.cron.next(now)
+	}
+	if s.jitter > 0 {
+		next = next.Add(time.Duration(rand.Int63n(int64(s.jitter))))
+	}
+	return next
+}
+
+// 两次检测之间的最长间隔，用于计算默认的健康检查阈值。cron 取接下来若干次检测中最长的间隔
+func (s schedule) period() time.Duration {
+	if s.cron == nil {
+		return s.interval + s.jitter
+	}
+	var longest time.Duration
+	previous := s.cron.next(time.Now())
+	for i := 0; i < 16; i++ {
+		next := s.cron.next(previous)
+		longest = max(longest, next.Sub(previous))
+		previous = next
+	}
+	return longest + s.jitter
+}
+
+// 域名自己的检测间隔，未设置时为 0，按顶层的 interval 或 schedule 检测
+func (d DomainConfig) pollInterval() (time.Duration, error) {
+	if d.Interval == "" {
+		return 0, nil
+	}
+	interval, err := time.ParseDuration(d.Interval)
+	if err != nil {
+		return 0, fmt.Errorf("invalid interval %q: %v", d.Interval, err)
+	}
+	if interval < minInterval {
+		return 0, fmt.Errorf("interval %s is shorter than the minimum %s", interval, minInterval)
+	}
+	return interval, nil
+}
+
+// 返回 now 时到期需要同步的域名，按 domains 的下标
+func (u *updater) dueDomains(now time.Time) []bool {
+	due := make([]bool, len(u.domains))
+	for i := range u.domains {
+		due[i] = !u.due[i].After(now)
+	}
+	return due
+}
+
+// 安排本轮同步过的域名的下一次检测：设置了 interval 的域名按自己的间隔，其余的按顶层的 schedule，
+// 后者共用同一个时间，jitter 对两者都生效
+func (u *updater) reschedule(synced []bool, now time.Time) {
+	global := u.schedule.next(now)
+	for i, interval := range u.intervals {
+		switch {
+		case !synced[i]:
+		case interval > 0:
+			u.due[i] = schedule{interval: interval, jitter: u.schedule.jitter}.next(now)
+		default:
+			u.due[i] = global
+		}
+	}
+}
+
+// 本轮未到期的域名的每条记录，结果均为跳过
+func (u *updater) notDueResults(due []bool) []recordResult {
+	var results []recordResult
+	for i, domain := range u.domains {
+		if due[i] {
+			continue
+		}
+		for _, rr := range domain.RRs {
+			for _, recordType := range domain.RecordTypes {
+				results = append(results, newRecordResult(domain, rr, recordType, resultSkipped))
+			}
+		}
+	}
+	return results
+}
+
+// 下一轮同步全部域名，用于手动触发、网络变化等提前的检测
+func (u *updater) dueNow() {
+	clear(u.due)
+}
+
+// 下一次检测的时间，即最早到期的域名的时间
+func (u *updater) nextCheck() time.Time {
+	if len(u.due) == 0 {
+		return u.schedule.next(time.Now())
+	}
+	next := u.due[0]
+	for _, due := range u.due[1:] {
+		if due.Before(next) {
+			next = due
+		}
+	}
+	return next
+}
+
+// 两次检测之间的最长间隔，用于计算默认的健康检查阈值。每个域名按自己的计划检测时，
+// 取其中最频繁的计划，只要有一个域名到期就会检测一轮
+func (u *updater) period() time.Duration {
+	var period time.Duration
+	for _, interval := range u.intervals {
+		p := u.schedule.period()
+		if interval > 0 {
+			p = interval + u.schedule.jitter
+		}
+		if period == 0 || p < period {
+			period = p
+		}
+	}
+	if period == 0 {
+		return u.schedule.period()
+	}
+	return period
+}
+
+// 解析后的 cron 表达式，每个字段用位表示允许的值
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	domAny, dowAny                bool // 日期和星期是否为 *，两者都有限制时满足任意一个即可
+}
+
+// 预定义的 cron 表达式
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// cron 表达式中每个字段的取值范围
+var cronFields = []struct {
+	name     string
+	min, max int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// 解析标准的 5 字段 cron 表达式（分 时 日 月 周），支持 *、a-b、a,b、*/n、a-b/n 以及 @hourly 等预定义表达式，使用本地时区
+func parseCron(expr string) (*cronSchedule, error) {
+	expr = strings.TrimSpace(expr)
+	if strings.HasPrefix(expr, "@") {
+		descriptor, ok := cronDescriptors[expr]
+		if !ok {
+			return nil, fmt.Errorf("unknown descriptor %s", expr)
+		}
+		expr = descriptor
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("expected %d fields, got %d", len(cronFields), len(fields))
+	}
+
+	var bits [5]uint64
+	for i, field := range fields {
+		parsed, err := parseCronField(field, cronFields[i].min, cronFields[i].max)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", cronFields[i].name, err)
+		}
+		bits[i] = parsed
+	}
+	// 星期中的 7 与 0 相同，都表示星期日
+	if bits[4]&(1<<7) != 0 {
+		bits[4] = bits[4]&^(1<<7) | 1
+	}
+	cron := &cronSchedule{
+		minute: bits[0], hour: bits[1], dom: bits[2], month: bits[3], dow: bits[4],
+		domAny: strings.HasPrefix(fields[2], "*"), dowAny: strings.HasPrefix(fields[4], "*"),
+	}
+	if cron.next(time.Now()).IsZero() {
+		return nil, fmt.Errorf("never matches a date")
+	}
+	return cron, nil
+}
+
+// 解析 cron 的一个字段，返回允许的值的位
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		values, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+			values, step = part[:i], n
+		}
+
+		low, high := min, max
+		if values != "*" {
+			bounds := strings.SplitN(values, "-", 2)
+			var err error
+			if low, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, fmt.Errorf("invalid value %q", part)
+			}
+			high = low
+			if len(bounds) == 2 {
+				if high, err = strconv.Atoi(bounds[1]); err != nil {
+					return 0, fmt.Errorf("invalid value %q", part)
+				}
+			} else if step > 1 {
+				// a/n 表示从 a 开始到最大值
+				high = max
+			}
+		}
+		if low < min || high > max || low > high {
+			return 0, fmt.Errorf("%q is out of range %d-%d", part, min, max)
+		}
+		for v := low; v <= high; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// 返回 after 之后第一个满足表达式的整分钟，5 年内都不满足时返回零值
+func (c *cronSchedule) next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Year() + 5
+	for t.Year() <= limit {
+		var next time.Time
+		switch {
+		case c.month&(1<<uint(t.Month())) == 0:
+			next = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.dayMatches(t):
+			next = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case c.hour&(1<<uint(t.Hour())) == 0:
+			next = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case c.minute&(1<<uint(t.Minute())) == 0:
+			next = t.Add(time.Minute)
+		default:
+			return t
+		}
+		// 夏令时开始时跳过的时间（例如 2:00）会被 time.Date 换算为之前的时间，此时逐分钟前进，否则会一直停在原地
+		if !next.After(t) {
+			next = t.Add(time.Minute)
+		}
+		t = next
+	}
+	return time.Time{}
+}
+
+// 与 cron 相同，日期和星期都有限制时满足任意一个即可
+func (c *cronSchedule) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domAny || c.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
@@ -0,0 +1,240 @@
+package updater
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// 由允许的值组成的位，与 parseCronField 的结果相同
+func cronBits(values ...int) uint64 {
+	var bits uint64
+	for _, v := range values {
+		bits |= 1 << uint(v)
+	}
+	return bits
+}
+
+func TestParseCronField(t *testing.T) {
+	tests := []struct {
+		field    string
+		min, max int
+		want     uint64
+		wantErr  string
+	}{
+		{field: "*", min: 1, max: 12, want: cronBits(1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12)},
+		{field: "*/15", min: 0, max: 59, want: cronBits(0, 15, 30, 45)},
+		{field: "5/20", min: 0, max: 59, want: cronBits(5, 25, 45)},
+		{field: "9-17", min: 0, max: 23, want: cronBits(9, 10, 11, 12, 13, 14, 15, 16, 17)},
+		{field: "1-10/3", min: 1, max: 31, want: cronBits(1, 4, 7, 10)},
+		{field: "1,15,30", min: 1, max: 31, want: cronBits(1, 15, 30)},
+		{field: "0,30-32", min: 0, max: 59, want: cronBits(0, 30, 31, 32)},
+		{field: "7", min: 0, max: 7, want: cronBits(7)},
+		{field: "60", min: 0, max: 59, wantErr: "out of range"},
+		{field: "0", min: 1, max: 31, wantErr: "out of range"},
+		{field: "5-1", min: 0, max: 59, wantErr: "out of range"},
+		{field: "*/0", min: 0, max: 59, wantErr: "invalid step"},
+		{field: "*/x", min: 0, max: 59, wantErr: "invalid step"},
+		{field: "mon", min: 0, max: 7, wantErr: "invalid value"},
+		{field: "1-b", min: 0, max: 59, wantErr: "invalid value"},
+		{field: "", min: 0, max: 59, wantErr: "invalid value"},
+	}
+	for _, test := range tests {
+		t.Run(test.field, func(t *testing.T) {
+			got, err := parseCronField(test.field, test.min, test.max)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("parseCronField(%q) error = %v, want %q", test.field, err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseCronField(%q) error = %v", test.field, err)
+			}
+			if got != test.want {
+				t.Errorf("parseCronField(%q) = %b, want %b", test.field, got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseCron(t *testing.T) {
+	tests := []struct {
+		expr    string
+		wantErr string
+	}{
+		{expr: "*/5 * * * *"},
+		{expr: "  0 3 * * 1-5  "},
+		{expr: "@weekly"},
+		{expr: "@hourly"},
+		{expr: "0 0 29 2 *"},
+		{expr: "0 0 * * 7"},
+		{expr: "@every 5m", wantErr: "unknown descriptor"},
+		{expr: "* * * *", wantErr: "expected 5 fields, got 4"},
+		{expr: "0 0 * * * *", wantErr: "expected 5 fields, got 6"},
+		{expr: "", wantErr: "expected 5 fields, got 0"},
+		{expr: "61 * * * *", wantErr: "minute:"},
+		{expr: "0 24 * * *", wantErr: "hour:"},
+		{expr: "0 0 32 * *", wantErr: "day of month:"},
+		{expr: "0 0 * 13 *", wantErr: "month:"},
+		{expr: "0 0 * * 8", wantErr: "day of week:"},
+		// 2 月没有 31 日
+		{expr: "0 0 31 2 *", wantErr: "never matches a date"},
+	}
+	for _, test := range tests {
+		t.Run(test.expr, func(t *testing.T) {
+			_, err := parseCron(test.expr)
+			if test.wantErr == "" {
+				if err != nil {
+					t.Errorf("parseCron(%q) error = %v", test.expr, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("parseCron(%q) error = %v, want %q", test.expr, err, test.wantErr)
+			}
+		})
+	}
+
+	// 星期中的 7 与 0 相同
+	cron, err := parseCron("0 0 * * 7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cron.dow != cronBits(0) {
+		t.Errorf("day of week 7 parsed as %b, want %b", cron.dow, cronBits(0))
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	// 2026 年 3 月 8 日 2:00 纽约进入夏令时，2:00 到 3:00 之间的时间不存在
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone data is not available: %v", err)
+	}
+	tests := []struct {
+		name  string
+		expr  string
+		after time.Time
+		want  time.Time
+	}{
+		{
+			name:  "every 15 minutes",
+			expr:  "*/15 * * * *",
+			after: time.Date(2026, 10, 14, 10, 7, 30, 0, time.UTC),
+			want:  time.Date(2026, 10, 14, 10, 15, 0, 0, time.UTC),
+		},
+		{
+			name:  "strictly after a matching minute",
+			expr:  "*/15 * * * *",
+			after: time.Date(2026, 10, 14, 10, 15, 0, 0, time.UTC),
+			want:  time.Date(2026, 10, 14, 10, 30, 0, 0, time.UTC),
+		},
+		{
+			name:  "start and step",
+			expr:  "5/20 * * * *",
+			after: time.Date(2026, 10, 14, 10, 46, 0, 0, time.UTC),
+			want:  time.Date(2026, 10, 14, 11, 5, 0, 0, time.UTC),
+		},
+		{
+			name:  "weekly",
+			expr:  "@weekly",
+			after: time.Date(2026, 10, 14, 10, 0, 0, 0, time.UTC), // 星期三
+			want:  time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC),  // 星期日
+		},
+		{
+			name:  "day of month or day of week, day of month first",
+			expr:  "0 0 1,15 * 1",
+			after: time.Date(2026, 10, 14, 10, 0, 0, 0, time.UTC),
+			want:  time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC), // 15 日，星期四
+		},
+		{
+			name:  "day of month or day of week, day of week first",
+			expr:  "0 0 1,15 * 1",
+			after: time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC),
+			want:  time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC), // 星期一
+		},
+		{
+			name:  "next year",
+			expr:  "0 12 * 2 *",
+			after: time.Date(2026, 10, 14, 10, 0, 0, 0, time.UTC),
+			want:  time.Date(2027, 2, 1, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "leap day",
+			expr:  "0 0 29 2 *",
+			after: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+			want:  time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "skipped by daylight saving time",
+			expr:  "30 2 * * *",
+			after: time.Date(2026, 3, 8, 1, 0, 0, 0, newYork),
+			want:  time.Date(2026, 3, 9, 2, 30, 0, 0, newYork),
+		},
+		{
+			name:  "daily on the day daylight saving time starts",
+			expr:  "0 3 * * *",
+			after: time.Date(2026, 3, 8, 0, 30, 0, 0, newYork),
+			want:  time.Date(2026, 3, 8, 3, 0, 0, 0, newYork),
+		},
+		{
+			name:  "hourly across daylight saving time",
+			expr:  "0 * * * *",
+			after: time.Date(2026, 3, 8, 1, 30, 0, 0, newYork),
+			want:  time.Date(2026, 3, 8, 3, 0, 0, 0, newYork),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cron, err := parseCron(test.expr)
+			if err != nil {
+				t.Fatalf("parseCron(%q) error = %v", test.expr, err)
+			}
+			if got := cron.next(test.after); !got.Equal(test.want) {
+				t.Errorf("next(%s) = %s, want %s", test.after, got, test.want)
+			}
+		})
+	}
+
+	// 越过夏令时的整点只间隔一个小时
+	cron, _ := parseCron("0 * * * *")
+	before := time.Date(2026, 3, 8, 1, 0, 0, 0, newYork)
+	if got := cron.next(before).Sub(before); got != time.Hour {
+		t.Errorf("hourly schedule across daylight saving time waits %s, want 1h", got)
+	}
+}
+
+func TestCronDayMatches(t *testing.T) {
+	tests := []struct {
+		expr string
+		day  time.Time
+		want bool
+	}{
+		// 日期和星期都有限制时满足任意一个即可
+		{expr: "0 0 13 * 5", day: time.Date(2026, 11, 13, 0, 0, 0, 0, time.UTC), want: true},  // 13 日，星期五
+		{expr: "0 0 13 * 5", day: time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC), want: true},  // 星期五
+		{expr: "0 0 13 * 5", day: time.Date(2026, 10, 13, 0, 0, 0, 0, time.UTC), want: true},  // 13 日，星期二
+		{expr: "0 0 13 * 5", day: time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC), want: false}, // 星期三
+		// 其中一个为 * 时只看另一个
+		{expr: "0 0 13 * *", day: time.Date(2026, 10, 13, 0, 0, 0, 0, time.UTC), want: true},
+		{expr: "0 0 13 * *", day: time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC), want: false},
+		{expr: "0 0 * * 5", day: time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC), want: true},
+		{expr: "0 0 * * 5", day: time.Date(2026, 10, 13, 0, 0, 0, 0, time.UTC), want: false},
+		// 与 cron 相同，以 * 开头的 */2 也算作 *，此时两者都要满足
+		{expr: "0 0 */2 * 5", day: time.Date(2026, 10, 9, 0, 0, 0, 0, time.UTC), want: true},
+		{expr: "0 0 */2 * 5", day: time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC), want: false},
+		{expr: "0 0 */2 * 5", day: time.Date(2026, 10, 13, 0, 0, 0, 0, time.UTC), want: false},
+	}
+	for _, test := range tests {
+		t.Run(test.expr+" "+test.day.Format("2006-01-02"), func(t *testing.T) {
+			cron, err := parseCron(test.expr)
+			if err != nil {
+				t.Fatalf("parseCron(%q) error = %v", test.expr, err)
+			}
+			if got := cron.dayMatches(test.day); got != test.want {
+				t.Errorf("dayMatches(%s, %s) = %v, want %v", test.day.Format("2006-01-02"), test.day.Weekday(), got, test.want)
+			}
+		})
+	}
+}
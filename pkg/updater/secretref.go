@@ -0,0 +1,358 @@
+package updater
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/kms"
+)
+
+// 配置中引用外部密钥的前缀：vault:路径#键 读取 HashiCorp Vault 中的密钥，kms:密文 通过阿里云 KMS 解密
+const (
+	vaultRefPrefix = "vault:"
+	kmsRefPrefix   = "kms:"
+)
+
+// Vault 租约剩余时间少于该值时不再续期，改为重新读取
+const vaultMinLease = time.Minute
+
+// 是否为 vault: 或 kms: 引用
+func isSecretRef(value string) bool {
+	return strings.HasPrefix(value, vaultRefPrefix) || strings.HasPrefix(value, kmsRefPrefix)
+}
+
+// 可以填写 vault: 和 kms: 引用的字段：各服务商的 AccessKey ID 以及 config encrypt 加密的全部字段
+func (c *Config) secretRefFields() []secretField {
+	fields := []secretField{{"accessKey", &c.AccessKey}}
+	for i := range c.Accounts {
+		fields = append(fields, secretField{fmt.Sprintf("accounts[%d].accessKey", i), &c.Accounts[i].AccessKey})
+	}
+	if c.DNSPod != nil {
+		fields = append(fields, secretField{"dnspod.secretId", &c.DNSPod.SecretID})
+	}
+	if c.Huawei != nil {
+		fields = append(fields, secretField{"huawei.accessKey", &c.Huawei.AccessKey})
+	}
+	return append(fields, c.secretFields()...)
+}
+
+// 读取外部密钥后需要续期的 Vault 租约，例如 Vault 阿里云密钥引擎生成的动态 AccessKey
+type vaultLease struct {
+	id        string
+	path      string
+	duration  time.Duration
+	renewable bool
+}
+
+// 解析配置中的 vault: 和 kms: 引用，替换为读取到的值，同一个 Vault 路径只读取一次。
+// 返回需要续期的租约
+func (c *Config) resolveSecretRefs(ctx context.Context) ([]vaultLease, error) {
+	var vault *vaultClient
+	var kmsClient *kms.Client
+	secrets := make(map[string]map[string]interface{})
+	var leases []vaultLease
+	for _, field := range c.secretRefFields() {
+		value := *field.value
+		switch {
+		case strings.HasPrefix(value, vaultRefPrefix):
+			path, key, ok := strings.Cut(strings.TrimPrefix(value, vaultRefPrefix), "#")
+			if !ok || path == "" || key == "" {
+				return nil, fmt.Errorf("%s: invalid Vault reference %q, expected vault:path#key", field.name, value)
+			}
+			if vault == nil {
+				var err error
+				if vault, err = newVaultClient(); err != nil {
+					return nil, err
+				}
+			}
+			data, read := secrets[path]
+			if !read {
+				secret, err := vault.read(ctx, path)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %v", field.name, err)
+				}
+				data, secrets[path] = secret.data, secret.data
+				if secret.LeaseID != "" {
+					leases = append(leases, vaultLease{id: secret.LeaseID, path: path, duration: time.Duration(secret.LeaseDuration) * time.Second, renewable: secret.Renewable})
+				}
+			}
+			s, ok := data[key].(string)
+			if !ok {
+				return nil, fmt.Errorf("%s: Vault secret %s has no string value %q", field.name, path, key)
+			}
+			*field.value = s
+		case strings.HasPrefix(value, kmsRefPrefix):
+			if kmsClient == nil {
+				var err error
+				if kmsClient, err = newKMSClient(*c); err != nil {
+					return nil, fmt.Errorf("failed to create Aliyun KMS client: %v", err)
+				}
+			}
+			plaintext, err := kmsDecrypt(ctx, kmsClient, strings.TrimPrefix(value, kmsRefPrefix))
+			if err != nil {
+				return nil, fmt.Errorf("%s: KMS decrypt failed: %v", field.name, err)
+			}
+			*field.value = plaintext
+		}
+	}
+	return leases, nil
+}
+
+// 读取需要调用 API 时才使用的凭证：系统钥匙串、Vault 和 KMS 中的密钥，最后应用环境变量中的 AccessKey。
+// 运行、重新加载配置和 config validate 时调用，status 等子命令不需要
+func (c *Config) loadCredentials(ctx context.Context) ([]vaultLease, error) {
+	if err := c.loadKeyringCredentials(); err != nil {
+		return nil, err
+	}
+	leases, err := c.resolveSecretRefs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.applyEnvCredentials()
+	return leases, nil
+}
+
+// Vault 的 HTTP API 客户端，使用与 vault 命令相同的环境变量：
+// VAULT_ADDR、VAULT_TOKEN（未设置时读取 ~/.vault-token，通常由 Vault Agent 写入）、VAULT_NAMESPACE 和 VAULT_CACERT
+type vaultClient struct {
+	addr      string
+	token     string
+	namespace string
+	client    *http.Client
+}
+
+func newVaultClient() (*vaultClient, error) {
+	addr := strings.TrimSuffix(os.Getenv("VAULT_ADDR"), "/")
+	if addr == "" {
+		return nil, errors.New("VAULT_ADDR is not set")
+	}
+	token := os.Getenv("VAULT_TOKEN")
+	if token == "" {
+		home, _ := os.UserHomeDir()
+		data, err := os.ReadFile(filepath.Join(home, ".vault-token"))
+		if err != nil {
+			return nil, errors.New("VAULT_TOKEN is not set and ~/.vault-token cannot be read")
+		}
+		token = strings.TrimSpace(string(data))
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if caFile := os.Getenv("VAULT_CACERT"); caFile != "" {
+		ca, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in VAULT_CACERT %s", caFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+	return &vaultClient{
+		addr:      addr,
+		token:     token,
+		namespace: os.Getenv("VAULT_NAMESPACE"),
+		client:    &http.Client{Timeout: 30 * time.Second, Transport: transport},
+	}, nil
+}
+
+// Vault API 的响应
+type vaultSecret struct {
+	LeaseID       string          `json:"lease_id"`
+	LeaseDuration int             `json:"lease_duration"`
+	Renewable     bool            `json:"renewable"`
+	Data          json.RawMessage `json:"data"`
+	Errors        []string        `json:"errors"`
+
+	data map[string]interface{} // 密钥的键值，KV v2 中为 data.data
+}
+
+// 调用 Vault API，body 不为空时以 JSON 发送
+func (v *vaultClient) do(ctx context.Context, method, path string, body interface{}) (*vaultSecret, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = strings.NewReader(string(data))
+	}
+	req, err := http.NewRequestWithContext(ctx, method, v.addr+"/v1/"+strings.TrimPrefix(path, "/"), reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", v.token)
+	if v.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.namespace)
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var secret vaultSecret
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&secret); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("invalid Vault response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		message := fmt.Sprintf("Vault %s %s failed with %s", method, path, resp.Status)
+		if len(secret.Errors) > 0 {
+			message += ": " + strings.Join(secret.Errors, "; ")
+		}
+		return nil, &apiError{
+			message:   message,
+			retryable: resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests,
+			auth:      resp.StatusCode == http.StatusForbidden,
+		}
+	}
+	return &secret, nil
+}
+
+// 读取密钥。path 位于 KV v2 引擎时与 vault kv get 相同，自动改为 挂载点/data/路径，因此 kv/ddns 和 kv/data/ddns 都可以使用
+func (v *vaultClient) read(ctx context.Context, path string) (*vaultSecret, error) {
+	path = strings.Trim(path, "/")
+	apiPath, kvV2 := path, false
+	if mount, err := v.do(ctx, http.MethodGet, "sys/internal/ui/mounts/"+path, nil); err == nil {
+		var info struct {
+			Path    string `json:"path"`
+			Type    string `json:"type"`
+			Options struct {
+				Version string `json:"version"`
+			} `json:"options"`
+		}
+		if json.Unmarshal(mount.Data, &info) == nil && info.Path != "" && info.Options.Version == "2" {
+			kvV2 = true
+			if rest := strings.TrimPrefix(path, info.Path); !strings.HasPrefix(rest, "data/") {
+				apiPath = info.Path + "data/" + rest
+			}
+		}
+	}
+
+	secret, err := v.do(ctx, http.MethodGet, apiPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	if kvV2 {
+		var nested struct {
+			Data map[string]interface{} `json:"data"`
+		}
+		if err := json.Unmarshal(secret.Data, &nested); err != nil {
+			return nil, fmt.Errorf("invalid Vault secret %s: %v", path, err)
+		}
+		secret.data = nested.Data
+	} else if err := json.Unmarshal(secret.Data, &secret.data); err != nil {
+		return nil, fmt.Errorf("invalid Vault secret %s: %v", path, err)
+	}
+	if secret.data == nil {
+		return nil, fmt.Errorf("Vault secret %s not found", path)
+	}
+	return secret, nil
+}
+
+// 续期租约，返回新的租约时长
+func (v *vaultClient) renew(ctx context.Context, lease vaultLease) (time.Duration, error) {
+	secret, err := v.do(ctx, http.MethodPut, "sys/leases/renew", map[string]interface{}{
+		"lease_id":  lease.id,
+		"increment": int(lease.duration.Seconds()),
+	})
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(secret.LeaseDuration) * time.Second, nil
+}
+
+// 续期 Vault 租约：租约过了三分之二时续期，不能续期、续期失败或者剩余时长不足时通过 refresh 触发重新加载配置，
+// 重新读取密钥后由新的配置继续续期；重新加载失败时每分钟再触发一次。返回停止续期的函数
+func watchVaultLeases(ctx context.Context, leases []vaultLease, refresh chan<- os.Signal, logger *slog.Logger) context.CancelFunc {
+	ctx, cancel := context.WithCancel(ctx)
+	if len(leases) == 0 {
+		return cancel
+	}
+	vault, err := newVaultClient()
+	if err != nil {
+		logger.Error("Failed to renew Vault leases", "error", err)
+		return cancel
+	}
+	for _, lease := range leases {
+		go func(lease vaultLease) {
+			wait := lease.duration * 2 / 3
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(wait):
+				}
+				if lease.renewable {
+					duration, err := vault.renew(ctx, lease)
+					if ctx.Err() != nil {
+						return
+					}
+					if err == nil && duration >= vaultMinLease {
+						logger.Debug("Vault lease renewed", "path", lease.path, "ttl", duration.String())
+						lease.duration, wait = duration, duration*2/3
+						continue
+					}
+					if err != nil {
+						logger.Warn("Failed to renew Vault lease", "path", lease.path, "error", err)
+					}
+					lease.renewable = false
+				}
+				logger.Info("Vault lease is expiring, reloading the configuration to read the secret again", "path", lease.path)
+				select {
+				case refresh <- syscall.SIGHUP:
+				default:
+				}
+				wait = vaultMinLease
+			}
+		}(lease)
+	}
+	return cancel
+}
+
+// KMS 客户端使用不依赖配置中密钥的凭证：credentials 为 ecs_ram_role、profile 或 chain 时使用该凭证，
+// 否则依次尝试 ALIBABA_CLOUD_ACCESS_KEY_ID 等环境变量、凭证文件和 ECS 实例角色
+func newKMSClient(config Config) (*kms.Client, error) {
+	credentials := &CredentialConfig{Type: "chain"}
+	if config.Credentials != nil {
+		switch config.Credentials.Type {
+		case "ecs_ram_role", "profile", "chain":
+			credentials = config.Credentials
+		}
+	}
+	settings, err := config.httpSettings()
+	if err != nil {
+		return nil, err
+	}
+	client := &kms.Client{}
+	if err := initSDKClient(&client.Client, Config{Credentials: credentials}, config.regionID()); err != nil {
+		return nil, err
+	}
+	configureSDKClient(&client.Client, settings)
+	return client, nil
+}
+
+// 通过 KMS 的 Decrypt 接口解密 kms encrypt 生成的 CiphertextBlob
+func kmsDecrypt(ctx context.Context, client *kms.Client, ciphertext string) (string, error) {
+	request := kms.CreateDecryptRequest()
+	request.Scheme = "https"
+	request.CiphertextBlob = ciphertext
+	if err := withDeadline(ctx, client, request); err != nil {
+		return "", err
+	}
+	response, err := client.Decrypt(request)
+	if err != nil {
+		return "", err
+	}
+	return response.Plaintext, nil
+}
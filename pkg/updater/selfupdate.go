@@ -0,0 +1,197 @@
+package updater
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// 发布的每个平台的可执行文件名，例如 DDns_go_linux_arm64，Windows 为 DDns_go_windows_amd64.exe
+func releaseAssetName() string {
+	name := "DDns_go_" + runtime.GOOS + "_" + runtime.GOARCH
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// 发布中同时提供的 SHA-256 校验文件，格式与 sha256sum 的输出相同
+const releaseChecksumsName = "checksums.txt"
+
+// 下载可执行文件的最长时间
+const selfUpdateTimeout = 5 * time.Minute
+
+// 下载最新版本并替换当前的可执行文件，下载的文件必须与 checksums.txt 中的 SHA-256 一致。
+// 只替换文件，正在运行的服务需要手动重启
+func selfUpdateCommand(args []string) {
+	flags := flag.NewFlagSet("selfupdate", flag.ExitOnError)
+	configFilePath := flags.String("config", "config.json", "Path to the configuration file, used for the proxy and TLS settings if it exists")
+	configFormat := flags.String("format", "", "Configuration file format: json, yaml or toml (default: detected from the file extension)")
+	check := flags.Bool("check", false, "Only check whether a newer release exists, do not download it")
+	force := flags.Bool("force", false, "Install the latest release even if it is not newer, for example on a development build")
+	flags.Parse(args)
+
+	settings := defaultHTTPSettings
+	if _, err := os.Stat(*configFilePath); err == nil || envConfigured() {
+		config, err := loadConfig(*configFilePath, *configFormat)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to load configuration:", err)
+			os.Exit(exitConfig)
+		}
+		if settings, err = config.httpSettings(); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", *configFilePath, err)
+			os.Exit(exitConfig)
+		}
+	}
+	client := &http.Client{Timeout: selfUpdateTimeout, Transport: settings.newTransport("", settings.ipProxy)}
+	ctx := context.Background()
+
+	release, err := latestRelease(ctx, client)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to check for updates:", err)
+		os.Exit(1)
+	}
+	newer := newerVersion(version, release.TagName)
+	if *check {
+		if newer {
+			fmt.Printf("A newer release %s is available (current %s): %s\n", release.TagName, version, release.HTMLURL)
+		} else {
+			fmt.Printf("Already up to date (current %s, latest %s)\n", version, release.TagName)
+		}
+		return
+	}
+	if !newer && !*force {
+		fmt.Printf("Already up to date (current %s, latest %s), use -force to reinstall\n", version, release.TagName)
+		return
+	}
+
+	assets := make(map[string]string)
+	for _, asset := range release.Assets {
+		assets[asset.Name] = asset.BrowserDownloadURL
+	}
+	name := releaseAssetName()
+	if assets[name] == "" {
+		fmt.Fprintf(os.Stderr, "Release %s has no %s for this platform\n", release.TagName, name)
+		os.Exit(1)
+	}
+	if assets[releaseChecksumsName] == "" {
+		fmt.Fprintf(os.Stderr, "Release %s has no %s, refusing to install an unverified file\n", release.TagName, releaseChecksumsName)
+		os.Exit(1)
+	}
+
+	executable, err := os.Executable()
+	if err == nil {
+		executable, err = filepath.EvalSymlinks(executable)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to locate the executable:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Downloading %s %s...\n", release.TagName, name)
+	if err := installRelease(ctx, client, assets[name], assets[releaseChecksumsName], name, executable); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to update:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Updated %s from %s to %s, restart the running instance or service to use it.\n", executable, version, release.TagName)
+}
+
+// 下载 url 指向的文件并校验 SHA-256，通过后替换 executable。先写入同一目录下的临时文件再重命名，
+// Windows 上正在运行的文件不能覆盖，先把旧文件重命名为 .old
+func installRelease(ctx context.Context, client *http.Client, url, checksumsURL, name, executable string) error {
+	checksums, err := download(ctx, client, checksumsURL)
+	if err != nil {
+		return err
+	}
+	defer checksums.Close()
+	want, err := findChecksum(checksums, name)
+	if err != nil {
+		return err
+	}
+
+	body, err := download(ctx, client, url)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(executable), filepath.Base(executable)+".new")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, hash), body); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if got := hex.EncodeToString(hash.Sum(nil)); got != want {
+		return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", name, want, got)
+	}
+
+	info, err := os.Stat(executable)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()|0o111); err != nil {
+		return err
+	}
+	if runtime.GOOS == "windows" {
+		old := executable + ".old"
+		os.Remove(old)
+		if err := os.Rename(executable, old); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp.Name(), executable); err != nil {
+			os.Rename(old, executable)
+			return err
+		}
+		return nil
+	}
+	return os.Rename(tmp.Name(), executable)
+}
+
+// 下载文件，返回的 Body 需要由调用方关闭
+func download(ctx context.Context, client *http.Client, url string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "DDns_go/"+version)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("download %s failed with status: %s", url, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// 在 sha256sum 格式的校验文件中查找 name 的 SHA-256
+func findChecksum(checksums io.Reader, name string) (string, error) {
+	scanner := bufio.NewScanner(checksums)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("%s is not listed in %s", name, releaseChecksumsName)
+}
@@ -0,0 +1,37 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+)
+
+// 启动内置的 HTTP 服务，监听失败时直接返回错误
+func startHTTPServer(listen string, handler http.Handler, logger *slog.Logger) (*http.Server, error) {
+	listener, err := net.Listen("tcp", listen)
+	if err != nil {
+		return nil, err
+	}
+
+	server := &http.Server{Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("HTTP server stopped", "listen", listen, "error", err)
+		}
+	}()
+	logger.Info("HTTP server listening", "listen", listener.Addr().String())
+	return server, nil
+}
+
+// 关闭 HTTP 服务，等待正在处理的请求完成
+func stopHTTPServer(server *http.Server) {
+	if server == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	server.Shutdown(ctx)
+}
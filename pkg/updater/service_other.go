@@ -0,0 +1,16 @@
+//go:build !windows
+
+package updater
+
+import "errors"
+
+// 只有 Windows 支持服务模式
+func isWindowsService() bool {
+	return false
+}
+
+func runService(args []string) {}
+
+func controlService(action, configFilePath, configFormat string) error {
+	return errors.New("service mode is only supported on Windows, use systemd or another init system instead")
+}
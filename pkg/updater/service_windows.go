@@ -0,0 +1,126 @@
+//go:build windows
+
+package updater
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// Windows 服务的名称
+const serviceName = "DDns_go"
+
+// 判断当前进程是否由服务控制管理器启动
+func isWindowsService() bool {
+	ok, err := svc.IsWindowsService()
+	return err == nil && ok
+}
+
+// 服务控制管理器调用的服务实现
+type ddnsService struct {
+	args []string
+}
+
+func (s *ddnsService) Execute(_ []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.StartPending}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		runDDNS(ctx, "run", s.args, false)
+		close(done)
+	}()
+
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for {
+		select {
+		case <-done:
+			return false, 0
+		case request := <-requests:
+			switch request.Cmd {
+			case svc.Interrogate:
+				status <- request.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				// 等待正在进行的更新完成后再退出
+				status <- svc.Status{State: svc.StopPending}
+				cancel()
+				<-done
+				return false, 0
+			}
+		}
+	}
+}
+
+// 作为 Windows 服务运行，服务的工作目录为 System32，因此切换到程序所在的目录
+func runService(args []string) {
+	if exe, err := os.Executable(); err == nil {
+		os.Chdir(filepath.Dir(exe))
+	}
+	if err := svc.Run(serviceName, &ddnsService{args: args}); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to run as Windows service:", err)
+		os.Exit(1)
+	}
+}
+
+// 安装、删除、启动或停止 Windows 服务
+func controlService(action, configFilePath, configFormat string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+
+	if action == "install" {
+		exe, err := os.Executable()
+		if err != nil {
+			return err
+		}
+		configFilePath, err = filepath.Abs(configFilePath)
+		if err != nil {
+			return err
+		}
+		if s, err := m.OpenService(serviceName); err == nil {
+			s.Close()
+			return fmt.Errorf("service %s already exists", serviceName)
+		}
+
+		args := []string{"run", "-config", configFilePath}
+		if configFormat != "" {
+			args = append(args, "-format", configFormat)
+		}
+		s, err := m.CreateService(serviceName, exe, mgr.Config{
+			DisplayName: "Aliyun DDNS",
+			Description: "Keeps Aliyun DNS records pointing to the public IP of this machine",
+			StartType:   mgr.StartAutomatic,
+		}, args...)
+		if err != nil {
+			return err
+		}
+		s.Close()
+		return nil
+	}
+
+	s, err := m.OpenService(serviceName)
+	if err != nil {
+		return fmt.Errorf("service %s is not installed: %v", serviceName, err)
+	}
+	defer s.Close()
+
+	switch action {
+	case "remove":
+		return s.Delete()
+	case "start":
+		return s.Start()
+	case "stop":
+		_, err := s.Control(svc.Stop)
+		return err
+	default:
+		return fmt.Errorf("unknown service action %q, expected install, remove, start or stop", action)
+	}
+}
@@ -0,0 +1,99 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"DDns_go/pkg/provider/alidns"
+)
+
+// 首次运行的配置向导：输入 AccessKey，从账号下的域名中选择，并在写入配置前试运行一次
+func runSetup(configFilePath, configFormat string) error {
+	prompt := newPrompter()
+	if !prompt.interactive {
+		return fmt.Errorf("setup wizard requires an interactive terminal, use 'config init' with flags instead")
+	}
+
+	config := defaultConfig
+	config.AccessKey = prompt.value("AccessKey ID", firstEnv(accessKeyEnvs), "")
+	config.AccessSecret = prompt.secret("AccessKey secret", firstEnv(accessSecretEnvs))
+	if config.AccessKey == "" || config.AccessSecret == "" {
+		return fmt.Errorf("AccessKey ID and secret are required")
+	}
+
+	logger, _ := newLogger(os.Stdout, "text", "info", config.secrets())
+	client, err := newConfiguredClient(config, logger)
+	if err != nil {
+		return err
+	}
+	domains, err := alidns.DescribeDomains(context.Background(), client)
+	if err != nil {
+		return fmt.Errorf("failed to list domains, please check the credentials: %s", newRedactor(config.secrets()).redact(err.Error()))
+	}
+	if len(domains) == 0 {
+		return fmt.Errorf("no domain found in this Aliyun account")
+	}
+
+	fmt.Println("Domains in this account:")
+	for i, domain := range domains {
+		fmt.Printf("  %d) %s\n", i+1, domain.DomainName)
+	}
+	choice := prompt.value("Domain", "", "1")
+	if index, err := strconv.Atoi(choice); err == nil && index >= 1 && index <= len(domains) {
+		config.DomainName = domains[index-1].DomainName
+	} else {
+		config.DomainName = choice
+	}
+
+	config.RR = prompt.value("Host record", "", defaultConfig.RR)
+	config.RecordType = strings.ToUpper(prompt.value("Record type (A or AAAA)", "", defaultConfig.RecordType))
+	if config.RecordType == "AAAA" {
+		config.IPMode = "ipv6"
+	}
+	if err := config.validate(); err != nil {
+		return err
+	}
+
+	settings, err := config.httpSettings()
+	if err != nil {
+		return err
+	}
+	configureIPHTTPClients(config, settings, logger)
+
+	// 试运行：获取公网 IP 并查询现有记录，只显示将要执行的操作，不修改解析
+	fmt.Println("Testing the configuration (dry run)...")
+	ip, provider, err := detectPublicIP(context.Background(), config.familyFor(config.RecordType), logger)
+	if err != nil {
+		return fmt.Errorf("failed to get public IP: %v", err)
+	}
+	records, err := alidns.DescribeDomainRecords(context.Background(), client, config.DomainName)
+	if err != nil {
+		return fmt.Errorf("failed to get DNS records of %s: %s", config.DomainName, newRedactor(config.secrets()).redact(err.Error()))
+	}
+
+	fqdn := config.RR + "." + config.DomainName
+	action := fmt.Sprintf("%s record %s does not exist and would be created with %s", config.RecordType, fqdn, ip)
+	for _, record := range records {
+		if record.Type == config.RecordType && record.RR == config.RR {
+			if record.Value == ip {
+				action = fmt.Sprintf("%s record %s already points to %s", config.RecordType, fqdn, ip)
+			} else {
+				action = fmt.Sprintf("%s record %s would be updated from %s to %s", config.RecordType, fqdn, record.Value, ip)
+			}
+			break
+		}
+	}
+	fmt.Printf("  Public IP %s (from %s)\n  %s\n", ip, provider, action)
+
+	if answer := prompt.value("Write configuration to "+configFilePath+"? (y/n)", "", "y"); !strings.HasPrefix(strings.ToLower(answer), "y") {
+		return fmt.Errorf("setup cancelled")
+	}
+	if err := saveConfig(configFilePath, configFormat, config); err != nil {
+		return err
+	}
+	fmt.Printf("Configuration file '%s' created.\n", configFilePath)
+	return nil
+}
@@ -0,0 +1,29 @@
+package updater
+
+import (
+	"context"
+	"net/http"
+)
+
+// Slack Incoming Webhook
+type SlackConfig struct {
+	Webhook string `json:"webhook"` // https://hooks.slack.com/services/...
+	ChatConfig
+}
+
+type slackNotifier struct {
+	config  SlackConfig
+	message *chatMessage
+}
+
+func (n *slackNotifier) send(ctx context.Context, client *http.Client, event notifyEvent) error {
+	title, text, err := n.message.render(event)
+	if err != nil {
+		return err
+	}
+	payload := map[string]interface{}{
+		"text": "*" + title + "*\n" + text,
+	}
+	_, err = postNotifyJSON(ctx, client, n.config.Webhook, payload)
+	return err
+}
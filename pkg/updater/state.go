@@ -0,0 +1,145 @@
+package updater
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// 默认的强制核对间隔，超过该时间即使 IP 未变化也会重新查询解析记录
+const defaultVerifyInterval = time.Hour
+
+// 一条记录最近一次推送到阿里云的状态
+type recordState struct {
+	RecordID string    `json:"recordId"`
+	Value    string    `json:"value"`
+	Verified time.Time `json:"verified"`          // 最近一次与阿里云核对的时间
+	Changed  time.Time `json:"changed,omitempty"` // 最近一次由本程序修改的时间，用于 cooldown
+}
+
+// 保存在状态文件中的内容，用来跳过不必要的 DescribeDomainRecords 调用，或者按 RecordId 只查询需要的记录
+type ddnsState struct {
+	mu      sync.Mutex // 并发更新记录时保护 Records
+	path    string
+	dirty   bool
+	Records map[string]recordState `json:"records"`
+}
+
+// 状态中记录的键
+func stateKey(domainName, rr, recordType string) string {
+	return recordType + " " + rr + "." + domainName
+}
+
+// 域名下一条记录在状态中的键，非默认的解析线路和阿里云内网区域单独保存
+func (d DomainConfig) recordKey(rr, recordType string) string {
+	key := stateKey(d.DomainName, rr, recordType)
+	if d.ZoneType == zoneTypePrivate {
+		key += " " + zoneTypePrivate
+	}
+	if d.Line != "" && d.Line != aliyunDefaultLine && d.Line != dnspodDefaultLine {
+		key += " " + d.Line
+	}
+	return key
+}
+
+// 加载状态文件，path 为空时不启用缓存，文件不存在时返回空状态
+func loadState(path string) (*ddnsState, error) {
+	state := &ddnsState{path: path, Records: make(map[string]recordState)}
+	if path == "" {
+		return state, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, err
+	}
+	if state.Records == nil {
+		state.Records = make(map[string]recordState)
+	}
+	return state, nil
+}
+
+// 判断记录是否已经是该值并且核对时间没有超过 maxAge
+func (s *ddnsState) fresh(key, value string, maxAge time.Duration) bool {
+	if s.path == "" {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, ok := s.Records[key]
+	return ok && record.Value == value && time.Since(record.Verified) < maxAge
+}
+
+// 记录与阿里云核对后的状态，changed 表示本次修改或新建了记录
+func (s *ddnsState) set(key, recordID, value string, changed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	record := recordState{RecordID: recordID, Value: value, Verified: now, Changed: s.Records[key].Changed}
+	if changed {
+		record.Changed = now
+	}
+	s.Records[key] = record
+	s.dirty = true
+}
+
+// 返回状态中记录的 RecordId，未启用状态文件或没有该记录时为空
+func (s *ddnsState) recordID(key string) string {
+	if s.path == "" {
+		return ""
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Records[key].RecordID
+}
+
+// 返回记录最近一次由本程序修改的时间，未修改过时为零值
+func (s *ddnsState) changedAt(key string) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Records[key].Changed
+}
+
+// 有变化时写入状态文件，先写临时文件再重命名，避免写入一半时被中断
+func (s *ddnsState) save() error {
+	if s.path == "" {
+		return nil
+	}
+	// dirty 由 set 在持有锁时修改，需要在锁内检查
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.dirty {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(s, "", "    ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return err
+	}
+	s.dirty = false
+	return nil
+}
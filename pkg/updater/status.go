@@ -0,0 +1,284 @@
+package updater
+
+import (
+	"sync"
+	"time"
+)
+
+// 保留的历史事件数
+const historyLimit = 100
+
+// 一个协议族当前的公网 IP
+type ipStatus struct {
+	IP        string    `json:"ip,omitempty"`
+	Provider  string    `json:"provider,omitempty"`
+	CheckedAt time.Time `json:"checkedAt"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// 一条解析记录最近一次同步的结果
+type recordStatus struct {
+	Domain    string     `json:"domain"`
+	RR        string     `json:"rr"`
+	Type      string     `json:"type"`
+	Line      string     `json:"line,omitempty"` // 阿里云和 DNSPod 的解析线路
+	Value     string     `json:"value,omitempty"`
+	Result    string     `json:"result"` // pending、updated、unchanged、disabled 或 failed
+	CheckedAt *time.Time `json:"checkedAt,omitempty"`
+	ChangedAt *time.Time `json:"changedAt,omitempty"`
+	RequestID string     `json:"requestId,omitempty"` // 最近一次同步时服务商返回的请求 ID，失败时为失败的请求
+	Error     string     `json:"error,omitempty"`
+}
+
+// 记录发生变化、同步失败或公网 IP 变化的事件
+type historyEvent struct {
+	Time      time.Time `json:"time"`
+	Domain    string    `json:"domain,omitempty"`
+	RR        string    `json:"rr,omitempty"`
+	Type      string    `json:"type,omitempty"`
+	Line      string    `json:"line,omitempty"`
+	Family    string    `json:"family,omitempty"` // 公网 IP 变化的事件：IPv4 或 IPv6
+	OldIP     string    `json:"oldIp,omitempty"`
+	NewIP     string    `json:"newIp,omitempty"`
+	Result    string    `json:"result"`              // updated、failed、disabled 或 ip_changed
+	RequestID string    `json:"requestId,omitempty"` // 修改记录时服务商返回的请求 ID
+	Geo       string    `json:"geo,omitempty"`       // 公网 IP 变化的事件：配置了 geo 时新地址的 ASN、运营商和地区
+	Error     string    `json:"error,omitempty"`
+}
+
+// 运行状态，供 REST API 查询
+type statusTracker struct {
+	mu        sync.Mutex
+	publicIPs map[string]ipStatus
+	records   map[string]recordStatus
+	usedIPs   map[string]string // 按协议族索引，每轮实际使用的公网 IP
+	order     []string          // 记录第一次出现的顺序
+	lastError string
+	history   []historyEvent
+	lastCycle *cycleSummary
+	nextCheck time.Time
+	store     *historyStore // 设置 historyFile 时同时把事件写入文件
+}
+
+// 进程内共享的运行状态
+var status = &statusTracker{publicIPs: make(map[string]ipStatus), records: make(map[string]recordStatus), usedIPs: make(map[string]string)}
+
+// 按配置的域名重新排列记录，尚未同步的记录显示为 pending，已删除的记录不再显示。
+// 状态文件中缓存的值作为当前值
+func (s *statusTracker) configure(domains []DomainConfig, state *ddnsState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records := make(map[string]recordStatus)
+	s.order = nil
+	for _, domain := range domains {
+		for _, rr := range domain.RRs {
+			for _, recordType := range domain.RecordTypes {
+				key := domain.recordKey(rr, recordType)
+				current, exists := s.records[key]
+				if !exists {
+					current = recordStatus{Domain: domain.DomainName, RR: rr, Type: recordType, Line: domain.Line, Result: "pending"}
+					if cached, ok := state.Records[key]; ok {
+						current.Value = cached.Value
+					}
+				}
+				records[key] = current
+				s.order = append(s.order, key)
+			}
+		}
+	}
+	s.records = records
+}
+
+// 设置保存历史的文件，为 nil 时只在内存中保留最近的事件
+func (s *statusTracker) persist(store *historyStore) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.store = store
+}
+
+// 记录获取公网 IP 的结果
+func (s *statusTracker) publicIP(family, ip, provider string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current := s.publicIPs[family]
+	current.CheckedAt = time.Now()
+	current.Error = ""
+	if err != nil {
+		current.Error = err.Error()
+		s.lastError = "get public " + family + ": " + err.Error()
+	} else {
+		current.IP = ip
+		current.Provider = provider
+	}
+	s.publicIPs[family] = current
+}
+
+// 记录本轮使用的公网 IP，与上次使用的不同时写入历史，geo 为新地址的归属。设置了 historyFile 时与文件中最后的 IP 比较，
+// 重启后第一次检测到的 IP 也能判断是否变化
+func (s *statusTracker) ipUsed(family, ip, geo string) {
+	s.mu.Lock()
+	previous, known := s.usedIPs[family]
+	s.usedIPs[family] = ip
+	store := s.store
+	s.mu.Unlock()
+
+	if store != nil {
+		previous, known = store.lastIP(family), true
+	}
+	if !known || ip == previous {
+		return
+	}
+	s.addHistory(historyEvent{Time: time.Now(), Family: family, OldIP: previous, NewIP: ip, Result: resultIPChanged, Geo: geo})
+}
+
+// 记录一条解析记录同步的结果，记录发生变化或失败时写入历史
+func (s *statusTracker) record(domain DomainConfig, rr, recordType, oldIP, newIP, requestID, result string, err error) {
+	if event, changed := s.setRecord(domain, rr, recordType, oldIP, newIP, requestID, result, err); changed {
+		s.addHistory(event)
+	}
+}
+
+// 更新记录的状态，需要写入历史时返回对应的事件
+func (s *statusTracker) setRecord(domain DomainConfig, rr, recordType, oldIP, newIP, requestID, result string, err error) (historyEvent, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	key := domain.recordKey(rr, recordType)
+	current, exists := s.records[key]
+	if !exists {
+		s.order = append(s.order, key)
+	}
+	previous := current.Result
+	current.Domain, current.RR, current.Type, current.Line = domain.DomainName, rr, recordType, domain.Line
+	current.Result = result
+	current.CheckedAt = &now
+	current.RequestID = requestID
+	current.Error = ""
+	if err != nil {
+		current.Error = err.Error()
+		s.lastError = rr + "." + domain.DomainName + " " + recordType + ": " + err.Error()
+	} else {
+		current.Value = newIP
+	}
+	if result == "updated" {
+		current.ChangedAt = &now
+	}
+	s.records[key] = current
+
+	// 暂停的记录每轮都会跳过，只在第一次跳过时写入历史
+	if result == "unchanged" || result == "disabled" && previous == "disabled" {
+		return historyEvent{}, false
+	}
+	event := historyEvent{Time: now, Domain: domain.DomainName, RR: rr, Type: recordType, Line: domain.Line, OldIP: oldIP, NewIP: newIP, Result: result, RequestID: requestID, Error: current.Error}
+	return event, true
+}
+
+// 把事件加入内存中的历史，设置了 historyFile 时同时写入文件
+func (s *statusTracker) addHistory(event historyEvent) {
+	s.mu.Lock()
+	s.history = append(s.history, event)
+	if len(s.history) > historyLimit {
+		s.history = s.history[len(s.history)-historyLimit:]
+	}
+	store := s.store
+	s.mu.Unlock()
+
+	if store != nil {
+		store.add(event)
+	}
+}
+
+// 保存最近一轮的汇总
+func (s *statusTracker) cycleFinished(summary cycleSummary) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastCycle = &summary
+}
+
+// 保存下一次检测的时间
+func (s *statusTracker) scheduled(next time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextCheck = next
+}
+
+// 最后一次错误，未脱敏
+func (s *statusTracker) lastErrorText() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastError
+}
+
+// GET /status 返回的内容
+type statusReport struct {
+	PublicIPs map[string]ipStatus `json:"publicIps"`
+	Records   []recordStatus      `json:"records"`
+	LastError string              `json:"lastError,omitempty"`
+	LastCycle *cycleSummary       `json:"lastCycle,omitempty"` // 最近一轮的汇总，第一轮结束前为空
+	NextCheck *time.Time          `json:"nextCheck,omitempty"`
+}
+
+// 错误信息中可能包含签名后的请求地址，返回前使用 r 去掉其中的密钥
+func (s *statusTracker) report(r *redactor) statusReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	report := statusReport{PublicIPs: make(map[string]ipStatus), Records: []recordStatus{}, LastError: r.redact(s.lastError)}
+	for family, ip := range s.publicIPs {
+		ip.Error = r.redact(ip.Error)
+		report.PublicIPs[family] = ip
+	}
+	for _, key := range s.order {
+		record := s.records[key]
+		record.Error = r.redact(record.Error)
+		report.Records = append(report.Records, record)
+	}
+	if !s.nextCheck.IsZero() {
+		next := s.nextCheck
+		report.NextCheck = &next
+	}
+	if s.lastCycle != nil {
+		cycle := *s.lastCycle
+		cycle.PublicIPs = make([]ipSummary, len(s.lastCycle.PublicIPs))
+		for i, ip := range s.lastCycle.PublicIPs {
+			ip.Error = r.redact(ip.Error)
+			cycle.PublicIPs[i] = ip
+		}
+		cycle.Records = make([]recordSummary, len(s.lastCycle.Records))
+		for i, record := range s.lastCycle.Records {
+			record.Error = r.redact(record.Error)
+			cycle.Records[i] = record
+		}
+		report.LastCycle = &cycle
+	}
+	return report
+}
+
+// 返回历史事件，最新的在前。设置了 historyFile 时从文件中按 filter 查询，否则返回内存中最近的事件
+func (s *statusTracker) recentHistory(r *redactor, filter historyFilter) ([]historyEvent, error) {
+	s.mu.Lock()
+	store := s.store
+	s.mu.Unlock()
+	if store != nil {
+		return store.query(filter)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	events := []historyEvent{}
+	for i := len(s.history) - 1; i >= 0; i-- {
+		event := s.history[i]
+		if !filter.since.IsZero() && event.Time.Before(filter.since) || !filter.matches(event) {
+			continue
+		}
+		event.Error = r.redact(event.Error)
+		events = append(events, event)
+		if filter.limit > 0 && len(events) >= filter.limit {
+			break
+		}
+	}
+	return events, nil
+}
@@ -0,0 +1,162 @@
+package updater
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// 获取公网 IP 的结果
+const (
+	ipUsed    = "used"
+	ipPending = "pending" // 新 IP 等待 confirmations 次确认
+	ipSkipped = "skipped" // 位于运营商级 NAT 之后并且 cgnat 为 skip
+	ipFailed  = "failed"
+)
+
+// 一轮检测和更新的汇总，结束时输出一条日志并通过 GET /status 返回
+type cycleSummary struct {
+	StartedAt time.Time       `json:"startedAt"`
+	Duration  string          `json:"duration"`
+	OK        bool            `json:"ok"`
+	PublicIPs []ipSummary     `json:"publicIps"`
+	Records   []recordSummary `json:"records"`
+	Updated   int             `json:"updated"`
+	Unchanged int             `json:"unchanged"` // 包括与本地状态一致、没有查询解析记录的记录
+	Failed    int             `json:"failed"`
+	Skipped   int             `json:"skipped"`
+	Pruned    int             `json:"pruned,omitempty"`
+}
+
+// 一个协议族本轮获取公网 IP 的结果
+type ipSummary struct {
+	Family   string `json:"family"`
+	IP       string `json:"ip,omitempty"`
+	Provider string `json:"provider,omitempty"`
+	Result   string `json:"result"` // used、pending、skipped 或 failed
+	Duration string `json:"duration"`
+	Error    string `json:"error,omitempty"`
+}
+
+// 一条记录本轮同步的结果
+type recordSummary struct {
+	Domain    string `json:"domain"`
+	RR        string `json:"rr"`
+	Type      string `json:"type"`
+	Result    string `json:"result"` // updated、unchanged、cached、failed 或 skipped
+	OldValue  string `json:"oldValue,omitempty"`
+	NewValue  string `json:"newValue,omitempty"`
+	RequestID string `json:"requestId,omitempty"`
+	Duration  string `json:"duration,omitempty"` // 只有实际比较或更新过的记录才有
+	Error     string `json:"error,omitempty"`
+}
+
+// 汇总中的时长保留到毫秒
+func summaryDuration(d time.Duration) string {
+	return d.Round(time.Millisecond).String()
+}
+
+// 加入本轮全部记录的结果并统计各种结果的数量
+func (s *cycleSummary) addRecords(results []recordResult) {
+	for _, result := range results {
+		record := recordSummary{
+			Domain:    result.domain,
+			RR:        result.rr,
+			Type:      result.recordType,
+			Result:    result.result,
+			OldValue:  result.oldValue,
+			NewValue:  result.newValue,
+			RequestID: result.requestID,
+		}
+		if result.duration > 0 {
+			record.Duration = summaryDuration(result.duration)
+		}
+		if result.err != nil {
+			record.Error = result.err.Error()
+		}
+		s.Records = append(s.Records, record)
+
+		switch result.result {
+		case resultUpdated:
+			s.Updated++
+		case resultUnchanged, resultCached:
+			s.Unchanged++
+		case resultFailed:
+			s.Failed++
+		default:
+			s.Skipped++
+		}
+	}
+}
+
+// 汇总日志的字段：数量为顶层字段方便过滤，每个协议族的 IP 和每条记录的结果分别放在 ip、record 和 error 组中，
+// 例如 ip.IPv4=203.0.113.7 record.www.example.com/A=updated
+func (s *cycleSummary) logAttrs() []any {
+	attrs := []any{
+		"ok", s.OK,
+		"records", len(s.Records),
+		"updated", s.Updated,
+		"unchanged", s.Unchanged,
+		"failed", s.Failed,
+		"skipped", s.Skipped,
+		"duration", s.Duration,
+	}
+	if s.Pruned > 0 {
+		attrs = append(attrs, "pruned", s.Pruned)
+	}
+
+	var ips, records, errs []any
+	for _, ip := range s.PublicIPs {
+		value := ip.IP
+		if ip.Result != ipUsed {
+			value = strings.TrimSpace(ip.Result + " " + ip.IP)
+		}
+		ips = append(ips, slog.String(ip.Family, value))
+		if ip.Error != "" {
+			errs = append(errs, slog.String(ip.Family, ip.Error))
+		}
+	}
+	var failed []string
+	for _, record := range s.Records {
+		key := record.RR + "." + record.Domain + "/" + record.Type
+		value := record.Result
+		if record.Result == resultUpdated {
+			value = fmt.Sprintf("%s %s -> %s", record.Result, record.OldValue, record.NewValue)
+		}
+		records = append(records, slog.String(key, value))
+		if record.Error != "" {
+			errs = append(errs, slog.String(key, record.Error))
+			failed = append(failed, record.Type+" "+record.RR+"."+record.Domain)
+		}
+	}
+	if len(failed) > 0 {
+		attrs = append(attrs, "failed_records", strings.Join(failed, " "))
+	}
+	if len(ips) > 0 {
+		attrs = append(attrs, slog.Group("ip", ips...))
+	}
+	if len(records) > 0 {
+		attrs = append(attrs, slog.Group("record", records...))
+	}
+	if len(errs) > 0 {
+		attrs = append(attrs, slog.Group("error", errs...))
+	}
+	return attrs
+}
+
+// 控制台上每轮输出的一行，例如 "Public IPv4: 203.0.113.7, records: 1 updated, 3 unchanged, 0 failed, 0 skipped"
+func (s *cycleSummary) consoleLine() string {
+	var parts []string
+	for _, ip := range s.PublicIPs {
+		value := ip.IP
+		if ip.Result == ipFailed {
+			value = "failed"
+		} else if ip.Result != ipUsed {
+			value += " (" + ip.Result + ")"
+		}
+		parts = append(parts, fmt.Sprintf("Public %s: %s", ip.Family, value))
+	}
+	parts = append(parts, fmt.Sprintf("records: %d updated, %d unchanged, %d failed, %d skipped", s.Updated, s.Unchanged, s.Failed, s.Skipped))
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,24 @@
+//go:build windows || plan9
+
+package updater
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// 当前平台不支持 syslog
+type syslogLineWriter struct{}
+
+func newSyslogLineWriter(config *SyslogConfig) (*syslogLineWriter, error) {
+	return nil, errors.New("syslog is not supported on this platform")
+}
+
+func (s *syslogLineWriter) writeLine(slog.Level, time.Time, []byte) error {
+	return nil
+}
+
+func (s *syslogLineWriter) Close() error {
+	return nil
+}
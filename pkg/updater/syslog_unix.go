@@ -0,0 +1,74 @@
+//go:build !windows && !plan9
+
+package updater
+
+import (
+	"fmt"
+	"log/slog"
+	"log/syslog"
+	"time"
+)
+
+// syslog 的 facility 名称
+var syslogFacilities = map[string]syslog.Priority{
+	"kern":     syslog.LOG_KERN,
+	"user":     syslog.LOG_USER,
+	"mail":     syslog.LOG_MAIL,
+	"daemon":   syslog.LOG_DAEMON,
+	"auth":     syslog.LOG_AUTH,
+	"syslog":   syslog.LOG_SYSLOG,
+	"lpr":      syslog.LOG_LPR,
+	"news":     syslog.LOG_NEWS,
+	"uucp":     syslog.LOG_UUCP,
+	"cron":     syslog.LOG_CRON,
+	"authpriv": syslog.LOG_AUTHPRIV,
+	"ftp":      syslog.LOG_FTP,
+	"local0":   syslog.LOG_LOCAL0,
+	"local1":   syslog.LOG_LOCAL1,
+	"local2":   syslog.LOG_LOCAL2,
+	"local3":   syslog.LOG_LOCAL3,
+	"local4":   syslog.LOG_LOCAL4,
+	"local5":   syslog.LOG_LOCAL5,
+	"local6":   syslog.LOG_LOCAL6,
+	"local7":   syslog.LOG_LOCAL7,
+}
+
+// 写入 syslog 的日志，按级别使用对应的优先级
+type syslogLineWriter struct {
+	w *syslog.Writer
+}
+
+// 连接本机或远程的 syslog
+func newSyslogLineWriter(config *SyslogConfig) (*syslogLineWriter, error) {
+	var c SyslogConfig
+	if config != nil {
+		c = *config
+	}
+	facility, ok := syslogFacilities[c.facility()]
+	if !ok {
+		return nil, fmt.Errorf("unknown syslog facility: %s", c.Facility)
+	}
+
+	w, err := syslog.Dial(c.Network, c.Address, facility|syslog.LOG_INFO, c.tag())
+	if err != nil {
+		return nil, err
+	}
+	return &syslogLineWriter{w: w}, nil
+}
+
+func (s *syslogLineWriter) writeLine(level slog.Level, _ time.Time, line []byte) error {
+	switch syslogPriority(level) {
+	case 3:
+		return s.w.Err(string(line))
+	case 4:
+		return s.w.Warning(string(line))
+	case 6:
+		return s.w.Info(string(line))
+	default:
+		return s.w.Debug(string(line))
+	}
+}
+
+func (s *syslogLineWriter) Close() error {
+	return s.w.Close()
+}
@@ -0,0 +1,41 @@
+package updater
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// 向 systemd 发送状态通知（sd_notify），未在 systemd 下以 Type=notify 运行时什么也不做
+func sdNotify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	// 以 @ 开头的是抽象命名空间的套接字
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// 返回发送 WATCHDOG=1 的间隔，为 systemd 配置的 WatchdogSec 的一半，未开启看门狗时返回 0
+func sdWatchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	// WATCHDOG_PID 不是当前进程时说明看门狗是给其它进程的
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond / 2
+}
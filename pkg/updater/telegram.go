@@ -0,0 +1,44 @@
+package updater
+
+import (
+	"context"
+	"html"
+	"net/http"
+	"strings"
+)
+
+// Telegram 机器人
+type TelegramConfig struct {
+	BotToken string `json:"botToken"`         // 从 @BotFather 获取的令牌
+	ChatID   string `json:"chatId"`           // 用户、群组或频道的 ID，频道也可以使用 @name
+	APIURL   string `json:"apiURL,omitempty"` // Bot API 地址，默认 https://api.telegram.org，可以改为自建的反向代理
+	ChatConfig
+}
+
+// 默认的 Telegram Bot API 地址
+const defaultTelegramAPIURL = "https://api.telegram.org"
+
+type telegramNotifier struct {
+	config  TelegramConfig
+	message *chatMessage
+}
+
+func (n *telegramNotifier) send(ctx context.Context, client *http.Client, event notifyEvent) error {
+	title, text, err := n.message.render(event)
+	if err != nil {
+		return err
+	}
+	apiURL := strings.TrimSuffix(n.config.APIURL, "/")
+	if apiURL == "" {
+		apiURL = defaultTelegramAPIURL
+	}
+	// Telegram 的 Markdown 需要转义大量字符，使用 HTML 只需转义 <、> 和 &
+	payload := map[string]interface{}{
+		"chat_id":                  n.config.ChatID,
+		"text":                     "<b>" + html.EscapeString(title) + "</b>\n" + html.EscapeString(text),
+		"parse_mode":               "HTML",
+		"disable_web_page_preview": true,
+	}
+	_, err = postNotifyJSON(ctx, client, apiURL+"/bot"+n.config.BotToken+"/sendMessage", payload)
+	return err
+}
@@ -0,0 +1,8 @@
+//go:build windows || plan9
+
+package updater
+
+import "os"
+
+// 当前平台没有 SIGUSR1，只能通过 REST API 触发检测
+var triggerSignals []os.Signal
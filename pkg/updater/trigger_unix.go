@@ -0,0 +1,11 @@
+//go:build !windows && !plan9
+
+package updater
+
+import (
+	"os"
+	"syscall"
+)
+
+// 收到这些信号时立即检测一次
+var triggerSignals = []os.Signal{syscall.SIGUSR1}
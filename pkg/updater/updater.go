@@ -0,0 +1,931 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"text/template"
+	"time"
+
+	"DDns_go/pkg/ipdetect"
+)
+
+// 执行检测和更新所需的全部状态
+type updater struct {
+	providers      map[string]dnsProvider // 按服务商名称索引
+	config         Config
+	domains        []DomainConfig
+	families       []ipFamily
+	state          *ddnsState
+	schedule       schedule
+	intervals      []time.Duration // 按 domains 的下标，域名自己的检测间隔，0 表示按 schedule 检测
+	due            []time.Time     // 按 domains 的下标，下一次同步该域名的时间，零值表示下一轮同步
+	verifyInterval time.Duration
+	cooldown       time.Duration // 同一条记录两次修改之间的最短间隔
+	retry          retryPolicy
+	notify         *notifyDispatcher
+	mu             sync.Mutex                    // 保护 throttles，记录是并发更新的
+	throttles      map[string]*throttleState     // 按服务商名称索引，只包含正在限流的服务商
+	values         map[string]*template.Template // 按模板内容索引的记录值模板
+	seenIPs        map[string]seenIP             // 按协议族索引，供记录值模板的 {{.Time}} 使用
+	pendingIPs     map[string]pendingIP          // 按协议族索引，等待 confirmations 次确认的新 IP
+	behindCGNAT    bool                          // 上一轮是否检测到运营商级 NAT，只在状态变化时记录日志和通知
+	geo            *geoLocator                   // 查询公网 IP 的归属，未配置 geo 时为 nil
+	churn          map[string]*ipChurn           // 按协议族索引，最近的公网 IP 变化，见 churn
+	force          bool                          // 本轮忽略本地状态，值相同的记录也重新提交，见 forceUpdateOnStart
+	leases         []vaultLease                  // 加载配置时读取的 Vault 密钥的租约，由主循环续期
+	quiet          bool                          // 不在控制台输出每轮的汇总，由 New 创建、嵌入其它程序时使用
+	logger         *slog.Logger
+}
+
+// 服务商限流后暂停调用的最短和最长时间，连续被限流时每次翻倍
+const (
+	minThrottlePause = time.Minute
+	maxThrottlePause = 30 * time.Minute
+)
+
+// 服务商的限流状态
+type throttleState struct {
+	until time.Time     // 暂停到该时间
+	pause time.Duration // 本次暂停的时长
+}
+
+// 按配置创建 updater，配置有误或 TTL 低于域名版本的限制时返回错误
+func newUpdater(config Config, state *ddnsState, logger *slog.Logger) (*updater, error) {
+	// 需要更新的域名以及需要检测的协议族
+	domains, err := config.domainList()
+	if err != nil {
+		return nil, err
+	}
+
+	// 每个用到的服务商（或账号）创建一个客户端，同一个账号的域名共用
+	providers := make(map[string]dnsProvider)
+	for _, domain := range providerDomains(domains) {
+		provider, err := newDomainProvider(domain, config, logger)
+		if err != nil {
+			return nil, err
+		}
+		providers[domain.providerKey()] = provider
+	}
+	// 启动和重新加载配置时的查询只受 HTTP 超时限制
+	ctx := context.Background()
+	if domains, err = splitNames(ctx, domains, providers); err != nil {
+		return nil, err
+	}
+
+	// 检查 TTL 是否满足域名所在版本的限制，查询失败时只记录日志
+	for _, domain := range domains {
+		checker, canCheckTTL := providers[domain.providerKey()].(minTTLProvider)
+		if domain.TTL == 0 || !canCheckTTL {
+			continue
+		}
+		minTTL, err := checker.minTTL(ctx, domain.DomainName)
+		if err != nil {
+			logger.Warn("Failed to check ttl", "domain", domain.DomainName, "error", err)
+			continue
+		}
+		if domain.TTL < minTTL {
+			return nil, fmt.Errorf("ttl %d of %s is below the minimum %d allowed for its edition", domain.TTL, domain.DomainName, minTTL)
+		}
+	}
+
+	// 使用插件获取 IP 时确认插件存在并且支持 detect
+	for _, source := range config.pluginSources() {
+		if _, err := checkPlugin(ctx, config.PluginDir, source.Plugin, pluginDetect); err != nil {
+			return nil, err
+		}
+	}
+
+	schedule, err := config.pollSchedule()
+	if err != nil {
+		return nil, err
+	}
+	intervals := make([]time.Duration, len(domains))
+	for i, domain := range domains {
+		if intervals[i], err = domain.pollInterval(); err != nil {
+			return nil, fmt.Errorf("%s: %v", domain.DomainName, err)
+		}
+	}
+	verifyInterval, err := config.verifyInterval()
+	if err != nil {
+		return nil, err
+	}
+	cooldown, err := config.cooldown()
+	if err != nil {
+		return nil, err
+	}
+	retry, err := config.retryPolicy()
+	if err != nil {
+		return nil, err
+	}
+	settings, err := config.httpSettings()
+	if err != nil {
+		return nil, err
+	}
+	notify, err := newNotifyDispatcher(config, settings, logger)
+	if err != nil {
+		return nil, err
+	}
+	geo, err := newGeoLocator(config, settings)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]*template.Template)
+	for _, domain := range domains {
+		if domain.Value == "" || values[domain.Value] != nil {
+			continue
+		}
+		tmpl, err := parseValueTemplate(domain.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value template of %s: %v", domain.DomainName, err)
+		}
+		values[domain.Value] = tmpl
+	}
+	status.configure(domains, state)
+	// 配置全部校验通过后才替换，重新加载失败时继续使用原来的客户端
+	configureIPHTTPClients(config, settings, logger)
+
+	return &updater{
+		providers:      providers,
+		config:         config,
+		domains:        domains,
+		families:       config.families(domains),
+		state:          state,
+		schedule:       schedule,
+		intervals:      intervals,
+		due:            make([]time.Time, len(domains)),
+		verifyInterval: verifyInterval,
+		cooldown:       cooldown,
+		retry:          retry,
+		notify:         notify,
+		geo:            geo,
+		throttles:      make(map[string]*throttleState),
+		values:         values,
+		seenIPs:        make(map[string]seenIP),
+		pendingIPs:     make(map[string]pendingIP),
+		churn:          make(map[string]*ipChurn),
+		logger:         logger,
+	}, nil
+}
+
+// 读取每个服务商（或账号）第一个域名的解析记录确认凭证可用，网络未就绪时按重试策略重试
+func (u *updater) checkCredentials(ctx context.Context) error {
+	for _, domain := range providerDomains(u.domains) {
+		key := domain.providerKey()
+		err := u.retry.do(ctx, u.logger, "verify "+key+" credentials", func(ctx context.Context) error {
+			_, err := u.providers[key].listRecords(ctx, domain.DomainName)
+			return err
+		})
+		// 被限流不代表凭证有误，暂停调用该服务商后继续启动
+		u.observeThrottle(key, err)
+		if err != nil && !throttled(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// 执行一次完整的检测和更新，全部成功（包括无需更新）时返回 nil，否则返回决定退出码的错误，见 cycleError。
+// ctx 取消后不再处理剩余的记录，已完成的更新仍会写入状态文件
+func (u *updater) runCycle(ctx context.Context) error {
+	ok, detected := true, true
+	summary := cycleSummary{StartedAt: time.Now()}
+	due := u.dueDomains(summary.StartedAt)
+	ctx, cycleSpan := startSpan(ctx, "ddns.cycle")
+	var failures []error
+
+	// 每个协议族只检测一次，供所有域名使用
+	publicIPs := make(map[string]string)
+	for _, family := range u.families {
+		started := time.Now()
+		var publicIP, provider string
+		detectCtx, detectSpan := startSpan(ctx, "ddns.detect", "ddns.family", family.name, "ddns.source", family.source.Source)
+		err := u.retry.do(detectCtx, u.logger, "get public "+family.name, func(ctx context.Context) error {
+			var err error
+			publicIP, provider, err = detectPublicIP(ctx, family, u.logger)
+			return err
+		})
+		ip := ipSummary{Family: family.name, IP: publicIP, Provider: provider, Result: ipUsed, Duration: summaryDuration(time.Since(started))}
+		detectSpan.set("ddns.ip", publicIP, "ddns.ip_provider", provider)
+		detectSpan.end(err)
+
+		status.publicIP(family.name, publicIP, provider, err)
+		if err != nil {
+			u.logger.Error("Failed to get public IP", "family", family.name, "error", err)
+			ok, detected = false, false
+			ip.Result, ip.Error = ipFailed, err.Error()
+			failures = append(failures, ipDetectionError(family.name, err))
+			summary.PublicIPs = append(summary.PublicIPs, ip)
+			continue
+		}
+		u.logger.Info("Public IP detected", "family", family.name, "ip", publicIP, "provider", provider)
+		metrics.publicIP(family.name, publicIP)
+		if !u.confirmIP(family.name, publicIP) {
+			ip.Result = ipPending
+			summary.PublicIPs = append(summary.PublicIPs, ip)
+			continue
+		}
+		summary.PublicIPs = append(summary.PublicIPs, ip)
+		publicIPs[family.name] = publicIP
+		if previous := u.seenIPs[family.name].ip; previous != publicIP {
+			u.seenIPs[family.name] = seenIP{ip: publicIP, since: time.Now(), geo: u.locate(ctx, family.name, publicIP)}
+			if previous != "" {
+				u.noteIPChange(family.name, publicIP, previous)
+			}
+		}
+		status.ipUsed(family.name, publicIP, u.seenIPs[family.name].geo.String())
+		// 频繁变化的地址可能是检测有误，确认之前不更新该协议族的记录
+		if u.churnPaused(family.name) {
+			u.logger.Warn("Updates are paused because the public IP changes too frequently, confirm with POST /update?confirm=true or DDns_go update -confirm", "family", family.name, "ip", publicIP)
+			delete(publicIPs, family.name)
+		}
+	}
+
+	u.checkCGNAT(ctx, publicIPs)
+	for i, ip := range summary.PublicIPs {
+		if _, used := publicIPs[ip.Family]; ip.Result == ipUsed && !used {
+			summary.PublicIPs[i].Result = ipSkipped
+		}
+	}
+	results, pruned, synced := u.syncRecords(ctx, publicIPs, due)
+	u.reschedule(due, time.Now())
+	if !synced {
+		ok = false
+	}
+
+	// 整轮的结果汇总为一条日志，同时通过 GET /status 返回，控制台只输出一行
+	summary.OK = ok
+	summary.Pruned = pruned
+	summary.addRecords(results)
+	summary.Duration = summaryDuration(time.Since(summary.StartedAt))
+	u.logger.Info("Cycle summary", summary.logAttrs()...)
+	status.cycleFinished(summary)
+	if !u.quiet {
+		fmt.Println(summary.consoleLine())
+	}
+
+	if err := u.state.save(); err != nil {
+		u.logger.Error("Failed to save state file", "error", err)
+	}
+	// 记录同步失败按记录分别计数，整轮的计数只统计获取公网 IP 失败
+	// 未到期的域名的记录按跳过计入，保留原来的连续失败次数
+	failing := u.notify.recordsFinished(append(results, u.notDueResults(due)...))
+	metrics.cycleFinished(ok)
+	health.cycleFinished(ok, failing)
+	u.notify.cycleFinished(detected, status.lastErrorText())
+	cycleSpan.set("ddns.updated", summary.Updated, "ddns.unchanged", summary.Unchanged, "ddns.failed", summary.Failed, "ddns.pruned", pruned)
+	if ok {
+		cycleSpan.end(nil)
+		return nil
+	}
+	for _, result := range results {
+		if result.err != nil {
+			failures = append(failures, result.err)
+		}
+	}
+	err := cycleError(ctx, summary, failures)
+	cycleSpan.end(err)
+	return err
+}
+
+// 一轮未能全部成功时的错误：部分记录更新成功时为 exitPartial，否则按 classifyFailures 选出的失败原因分类
+func cycleError(ctx context.Context, summary cycleSummary, failures []error) error {
+	if len(failures) == 0 {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("cycle was interrupted: %w", err)
+		}
+		return errors.New("cycle did not complete")
+	}
+	if summary.Updated+summary.Unchanged > 0 {
+		return &exitError{code: exitPartial, err: fmt.Errorf("partially failed (%d records succeeded, %d failed): %w", summary.Updated+summary.Unchanged, summary.Failed, classifyFailures(failures))}
+	}
+	return classifyFailures(failures)
+}
+
+// 等待确认的新 IP 以及已经连续检测到的次数
+type pendingIP struct {
+	ip    string
+	count int
+}
+
+// 与上次使用的 IP 不同的地址需要连续检测到 confirmations 次才使用，返回本轮是否使用该地址。
+// 启动后第一次检测到的地址直接使用
+func (u *updater) confirmIP(family, ip string) bool {
+	needed := u.config.Confirmations
+	current := u.seenIPs[family].ip
+	if needed <= 1 || current == "" || current == ip {
+		delete(u.pendingIPs, family)
+		return true
+	}
+
+	pending := u.pendingIPs[family]
+	if pending.ip != ip {
+		pending = pendingIP{ip: ip}
+	}
+	pending.count++
+	if pending.count >= needed {
+		delete(u.pendingIPs, family)
+		u.logger.Info("New public IP confirmed", "family", family, "ip", ip, "old_ip", current, "confirmations", needed)
+		return true
+	}
+	u.pendingIPs[family] = pending
+	u.logger.Info("New public IP is waiting for confirmation", "family", family, "ip", ip, "old_ip", current, "confirmations", fmt.Sprintf("%d/%d", pending.count, needed))
+	return false
+}
+
+// 检测公网 IPv4 是否位于运营商级 NAT 之后：地址属于 100.64.0.0/10，或者与 wanSource 读取的路由器 WAN 口地址不同。
+// 状态变化时记录日志并发送通知，cgnat 为 skip 时本轮不再更新 IPv4 记录
+func (u *updater) checkCGNAT(ctx context.Context, publicIPs map[string]string) {
+	publicIP, detected := publicIPs["IPv4"]
+	if !detected {
+		return
+	}
+
+	var wanIP, reason string
+	if ipdetect.InCGNATRange(publicIP) {
+		reason = fmt.Sprintf("public IP %s is in the carrier-grade NAT range %s", publicIP, ipdetect.CGNATPrefix)
+	} else if u.config.WANSource != nil {
+		family := ipFamily{name: "WAN", network: "tcp4", source: resolveSource(u.config.WANSource, nil), allowPrivate: true, pluginDir: u.config.PluginDir}
+		wanCtx, cancel := context.WithTimeout(ctx, u.retry.timeout)
+		ip, _, err := detectPublicIP(wanCtx, family, u.logger)
+		cancel()
+		if err != nil {
+			u.logger.Warn("Failed to get router WAN IP, skipping CGNAT check", "error", err)
+			return
+		}
+		wanIP = ip
+		if ipdetect.InCGNATRange(ip) {
+			reason = fmt.Sprintf("router WAN IP %s is in the carrier-grade NAT range %s", ip, ipdetect.CGNATPrefix)
+		} else if ip != publicIP {
+			reason = fmt.Sprintf("router WAN IP %s differs from the public IP %s", ip, publicIP)
+		}
+	}
+
+	behind := reason != ""
+	if behind {
+		metrics.set("ddns_cgnat", 1)
+	} else {
+		metrics.set("ddns_cgnat", 0)
+	}
+	if behind != u.behindCGNAT {
+		u.behindCGNAT = behind
+		if behind {
+			u.logger.Warn("Behind carrier-grade NAT, the public IPv4 address is not reachable from the internet", "ip", publicIP, "wan_ip", wanIP, "reason", reason)
+			u.notify.emit(notifyEvent{Event: eventCGNAT, PublicIP: publicIP, WANIP: wanIP, Error: reason})
+		} else {
+			u.logger.Info("No longer behind carrier-grade NAT", "ip", publicIP, "wan_ip", wanIP)
+		}
+	}
+	if behind && u.config.CGNAT == cgnatSkip {
+		u.logger.Info("Skipping IPv4 records behind carrier-grade NAT", "ip", publicIP)
+		delete(publicIPs, "IPv4")
+	}
+}
+
+// 判断域名下的记录是否都与本地状态一致，一致时不需要查询解析记录
+func (u *updater) cachedFresh(domain DomainConfig, publicIPs map[string]string) bool {
+	checked := 0
+	for _, rr := range domain.RRs {
+		for _, recordType := range domain.RecordTypes {
+			publicIP, detected := publicIPs[u.config.domainFamily(domain, recordType).name]
+			if !detected {
+				continue
+			}
+			value, err := u.recordValue(domain, rr, recordType, publicIP, publicIPs)
+			if err != nil || !u.state.fresh(domain.recordKey(rr, recordType), value, u.verifyInterval) {
+				return false
+			}
+			checked++
+		}
+	}
+	return checked > 0
+}
+
+// 一条需要同步的解析记录
+type recordTask struct {
+	domain     DomainConfig
+	rr         string
+	recordType string
+	records    []dnsRecord // 域名下的全部解析记录
+	publicIP   string
+}
+
+// 记录同步的结果
+const (
+	resultUpdated   = "updated"
+	resultUnchanged = "unchanged"
+	resultFailed    = "failed"
+	resultSkipped   = "skipped" // 未检测到对应协议族的 IP、记录已暂停、服务商限流或正在退出
+	resultCached    = "cached"  // 与本地状态一致，没有查询解析记录
+)
+
+// 一条记录的同步结果，用于每轮的汇总和统计连续失败的次数
+type recordResult struct {
+	domain     string
+	rr         string
+	recordType string
+	result     string
+	oldValue   string
+	newValue   string
+	requestID  string        // 修改记录时服务商返回的请求 ID
+	duration   time.Duration // 比较和更新记录所用的时间，不包括查询解析记录
+	err        error         // 失败的原因
+}
+
+func newRecordResult(domain DomainConfig, rr, recordType, result string) recordResult {
+	return recordResult{domain: domain.DomainName, rr: rr, recordType: recordType, result: result}
+}
+
+// 日志和通知中显示的名称，例如 "A www.example.com"
+func (r recordResult) name() string {
+	return r.recordType + " " + r.rr + "." + r.domain
+}
+
+// 同步到期的域名（due 中为 true 的下标）的记录：先并发查询每个域名的解析记录，再并发更新每条记录，
+// 同时进行的请求数不超过 concurrency。返回每条记录的结果和清理的记录数，有记录同步失败或正在退出时返回 false
+func (u *updater) syncRecords(ctx context.Context, publicIPs map[string]string, due []bool) ([]recordResult, int, bool) {
+	concurrency := u.config.concurrency()
+
+	listed := make([][]dnsRecord, len(u.domains))
+	tasks := make([][]recordTask, len(u.domains))
+	domainResults := make([][]recordResult, len(u.domains))
+	forEachLimited(concurrency, len(u.domains), func(i int) {
+		if due[i] {
+			listed[i], tasks[i], domainResults[i] = u.describeDomain(ctx, u.domains[i], publicIPs)
+		}
+	})
+
+	var all []recordTask
+	var results []recordResult
+	for i := range u.domains {
+		all = append(all, tasks[i]...)
+		results = append(results, domainResults[i]...)
+	}
+	recordResults := make([]recordResult, len(all))
+	forEachLimited(concurrency, len(all), func(i int) {
+		recordResults[i] = u.syncRecord(ctx, all[i], publicIPs)
+	})
+	results = append(results, recordResults...)
+	pruned := u.pruneRecords(ctx, listed)
+
+	ok := ctx.Err() == nil
+	for _, result := range results {
+		if result.result == resultFailed {
+			ok = false
+		}
+	}
+	return results, pruned, ok
+}
+
+// 查询一个域名的解析记录，返回查询到的记录和需要逐条同步的记录；与本地状态一致、服务商限流或查询失败时直接返回每条记录的结果
+func (u *updater) describeDomain(ctx context.Context, domain DomainConfig, publicIPs map[string]string) ([]dnsRecord, []recordTask, []recordResult) {
+	allResults := func(result string, err error) []recordResult {
+		var results []recordResult
+		for _, rr := range domain.RRs {
+			for _, recordType := range domain.RecordTypes {
+				r := newRecordResult(domain, rr, recordType, result)
+				r.err = err
+				results = append(results, r)
+			}
+		}
+		return results
+	}
+	if ctx.Err() != nil {
+		u.logger.Warn("Shutting down, skipping remaining records", "domain", domain.DomainName)
+		return nil, nil, allResults(resultSkipped, nil)
+	}
+	// 先与本地状态核对，再按 RecordId 查询，都不能确定时才查询全部记录
+	verifyCtx, verifySpan := startSpan(ctx, "ddns.verify", "ddns.domain", domain.DomainName, "ddns.provider", domain.providerKey())
+	if !u.force && u.cachedFresh(domain, publicIPs) {
+		u.logger.Info("Records match the cached state, skipping describe", "domain", domain.DomainName)
+		verifySpan.set("ddns.result", resultCached)
+		verifySpan.end(nil)
+		return nil, nil, allResults(resultCached, nil)
+	}
+	if until, paused := u.throttledUntil(domain.providerKey()); paused {
+		err := &exitError{code: exitThrottled, err: fmt.Errorf("%s API calls are paused until %s because of rate limits", domain.providerKey(), until.Format(time.RFC3339))}
+		u.logger.Info("DNS provider is throttled, skipping records", "domain", domain.DomainName, "provider", domain.providerKey(), "until", until.Format(time.RFC3339))
+		for _, rr := range domain.RRs {
+			for _, recordType := range domain.RecordTypes {
+				status.record(domain, rr, recordType, "", "", "", "failed", err)
+			}
+		}
+		verifySpan.end(err)
+		return nil, nil, allResults(resultFailed, err)
+	}
+
+	// 状态中有每条记录的 RecordId 时只查询这些记录，不需要列出整个域名
+	if records, ok := u.cachedRecords(verifyCtx, domain, publicIPs); ok {
+		u.logger.Debug("Described records by cached RecordId", "domain", domain.DomainName, "record_ids", recordIDs(records))
+		verifySpan.set("ddns.result", "record_id", "ddns.record_ids", recordIDs(records))
+		verifySpan.end(nil)
+		tasks, skipped := u.recordTasks(domain, records, publicIPs)
+		return nil, tasks, skipped
+	}
+	verifySpan.set("ddns.result", "describe")
+	verifySpan.end(nil)
+
+	// 一次获取全部解析记录，再逐个处理配置的主机记录
+	var records []dnsRecord
+	describeCtx, describeSpan := startSpan(ctx, "ddns.describe", "ddns.domain", domain.DomainName, "ddns.provider", domain.providerKey())
+	describeCtx, requestIDs := withRequestIDs(describeCtx)
+	err := u.retry.do(describeCtx, u.logger, "describe DNS records of "+domain.DomainName, func(ctx context.Context) error {
+		var err error
+		records, err = u.providers[domain.providerKey()].listRecords(ctx, domain.DomainName)
+		return err
+	})
+	u.observeThrottle(domain.providerKey(), err)
+	describeSpan.set("ddns.records", len(records), "ddns.request_id", requestIDs.Last())
+	describeSpan.end(err)
+	if err != nil && ctx.Err() != nil {
+		u.logger.Warn("Shutting down, describe DNS records was interrupted", "domain", domain.DomainName)
+		return nil, nil, allResults(resultSkipped, nil)
+	}
+	if err != nil {
+		requestID := requestIDs.Last()
+		u.logger.Error("Failed to describe DNS records", "domain", domain.DomainName, "request_id", requestID, "error", err)
+		for _, rr := range domain.RRs {
+			for _, recordType := range domain.RecordTypes {
+				metrics.inc("ddns_update_attempts_total", "domain", domain.DomainName, "rr", rr, "type", recordType)
+				metrics.inc("ddns_update_failures_total", "domain", domain.DomainName, "rr", rr, "type", recordType)
+				metrics.failedRequest(domain.DomainName, rr, recordType, requestID)
+				status.record(domain, rr, recordType, "", "", requestID, "failed", err)
+			}
+		}
+		results := allResults(resultFailed, err)
+		for i := range results {
+			results[i].requestID = requestID
+		}
+		return nil, nil, results
+	}
+
+	tasks, skipped := u.recordTasks(domain, records, publicIPs)
+	return records, tasks, skipped
+}
+
+// 为域名的每条记录创建同步任务，未检测到对应协议族的 IP 的记录直接返回跳过的结果
+func (u *updater) recordTasks(domain DomainConfig, records []dnsRecord, publicIPs map[string]string) ([]recordTask, []recordResult) {
+	var tasks []recordTask
+	var skipped []recordResult
+	for _, rr := range domain.RRs {
+		for _, recordType := range domain.RecordTypes {
+			publicIP, detected := publicIPs[u.config.domainFamily(domain, recordType).name]
+			if !detected {
+				skipped = append(skipped, newRecordResult(domain, rr, recordType, resultSkipped))
+				continue
+			}
+			tasks = append(tasks, recordTask{domain: domain, rr: rr, recordType: recordType, records: records, publicIP: publicIP})
+		}
+	}
+	return tasks, skipped
+}
+
+// 按状态中保存的 RecordId 逐条查询域名的记录（阿里云 DescribeDomainRecordInfo）。
+// 服务商不支持、有记录没有 RecordId、需要比较同名的全部记录（extraValues、line 为 *、multipleRecords 为 all）
+// 或者开启了 prune 时返回 false；RecordId 已失效或指向了其它记录时同样返回 false，改为查询全部记录
+func (u *updater) cachedRecords(ctx context.Context, domain DomainConfig, publicIPs map[string]string) ([]dnsRecord, bool) {
+	getter, ok := u.providers[domain.providerKey()].(recordGetter)
+	if !ok || u.force || u.config.Prune != "" || domain.Line == anyLine || u.config.multipleRecords() == multipleRecordsAll {
+		return nil, false
+	}
+	var records []dnsRecord
+	for _, rr := range domain.RRs {
+		for _, recordType := range domain.RecordTypes {
+			if _, detected := publicIPs[u.config.domainFamily(domain, recordType).name]; !detected {
+				continue
+			}
+			recordID := u.state.recordID(domain.recordKey(rr, recordType))
+			if recordID == "" || len(domain.extraValuesFor(recordType)) > 0 {
+				return nil, false
+			}
+			getCtx, requestIDs := withRequestIDs(ctx)
+			record, err := getter.getRecord(getCtx, domain.DomainName, recordID)
+			u.observeThrottle(domain.providerKey(), err)
+			if err == nil && !(recordSpec{RR: rr, Type: recordType, Line: domain.recordLine()}).matches(record) {
+				err = fmt.Errorf("record %s is now %s %s", recordID, record.Type, record.RR)
+			}
+			if err != nil {
+				if ctx.Err() == nil {
+					u.logger.Info("Cached RecordId is no longer valid, describing all records", "domain", domain.DomainName, "rr", rr, "type", recordType, "record_id", recordID, "request_id", requestIDs.Last(), "error", err)
+				}
+				return nil, false
+			}
+			records = append(records, record)
+		}
+	}
+	return records, len(records) > 0
+}
+
+// 比较并更新一条解析记录
+func (u *updater) syncRecord(ctx context.Context, task recordTask, publicIPs map[string]string) (result recordResult) {
+	domain, rr, recordType := task.domain, task.rr, task.recordType
+	result = newRecordResult(domain, rr, recordType, resultSkipped)
+	started := time.Now()
+	ctx, updateSpan := startSpan(ctx, "ddns.update", "ddns.domain", domain.DomainName, "ddns.rr", rr, "ddns.type", recordType)
+	defer func() {
+		result.duration = time.Since(started)
+		updateSpan.set("ddns.result", result.result, "ddns.old_value", result.oldValue, "ddns.new_value", result.newValue, "ddns.request_id", result.requestID)
+		updateSpan.end(result.err)
+	}()
+	if _, paused := u.throttledUntil(domain.providerKey()); paused || ctx.Err() != nil {
+		return result
+	}
+
+	labels := []string{"domain", domain.DomainName, "rr", rr, "type", recordType}
+	metrics.inc("ddns_update_attempts_total", labels...)
+	value, err := u.recordValue(domain, rr, recordType, task.publicIP, publicIPs)
+	if err != nil {
+		u.logger.Error("Failed to render record value", "domain", domain.DomainName, "rr", rr, "type", recordType, "error", err)
+		metrics.inc("ddns_update_failures_total", labels...)
+		status.record(domain, rr, recordType, "", "", "", "failed", err)
+		result.result, result.err = resultFailed, err
+		return result
+	}
+	spec := recordSpec{
+		DomainName: domain.DomainName,
+		RR:         rr,
+		Type:       recordType,
+		Value:      value,
+		TTL:        domain.TTL,
+		Line:       domain.recordLine(),
+		AutoCreate: u.config.autoCreate(),
+		Force:      u.force,
+		Enable:     u.config.disabledRecords() == disabledRecordsEnable,
+		Extra:      domain.extraValuesFor(recordType),
+		Multiple:   u.config.multipleRecords(),
+	}
+	// Cloudflare 只能代理 A、AAAA 和 CNAME 记录
+	if addressRecordType(recordType) || recordType == "CNAME" {
+		spec.Proxied = domain.Proxied
+	}
+	// 距离上次修改不到 cooldown 时暂不修改已有的记录，下一轮再检查
+	if until, cooling := u.coolingDown(domain, spec, task.records); cooling {
+		u.logger.Info("Record changed recently, waiting for cooldown", "domain", domain.DomainName, "rr", rr, "type", recordType, "ip", value, "until", until.Format(time.RFC3339))
+		metrics.inc("ddns_update_successes_total", labels...)
+		return result
+	}
+	// 列出参与比较的全部记录，方便在控制台核对被修改的是哪一条
+	if matched := spec.matching(task.records); len(matched) > 1 && len(spec.Extra) == 0 && spec.Multiple != multipleRecordsFail {
+		updated := matched
+		if spec.Multiple == multipleRecordsFirst {
+			updated = matched[:1]
+		}
+		u.logger.Warn("Multiple DNS records match", "domain", domain.DomainName, "rr", rr, "type", recordType, "policy", spec.Multiple, "record_ids", recordIDs(matched), "updating", recordIDs(updated))
+	}
+	var recordID, oldIP string
+	updateCtx, requestIDs := withRequestIDs(ctx)
+	err = u.retry.do(updateCtx, u.logger, "update "+recordType+" record "+rr+"."+domain.DomainName, func(ctx context.Context) error {
+		var err error
+		recordID, oldIP, err = updateDNSRecord(ctx, u.providers[domain.providerKey()], task.records, spec)
+		return err
+	})
+	u.observeThrottle(domain.providerKey(), err)
+	requestID := requestIDs.Last()
+	result.oldValue, result.newValue, result.requestID = oldIP, value, requestID
+	switch {
+	case err == ErrNoUpdateNeeded:
+		u.logger.Info("No update needed", "domain", domain.DomainName, "rr", rr, "type", recordType, "ip", value)
+		metrics.inc("ddns_update_successes_total", labels...)
+		metrics.failedRequest(domain.DomainName, rr, recordType, "")
+		status.record(domain, rr, recordType, oldIP, value, requestID, "unchanged", nil)
+		u.state.set(domain.recordKey(rr, recordType), recordID, value, false)
+		u.tagRecord(ctx, task, spec, recordID)
+		result.result = resultUnchanged
+	case err == ErrRecordDisabled:
+		u.logger.Warn("DNS record is disabled, skipping", "domain", domain.DomainName, "rr", rr, "type", recordType, "ip", oldIP)
+		metrics.inc("ddns_update_successes_total", labels...)
+		status.record(domain, rr, recordType, oldIP, oldIP, requestID, "disabled", nil)
+	case err != nil && ctx.Err() != nil:
+		u.logger.Warn("Shutting down, DNS record update was interrupted", "domain", domain.DomainName, "rr", rr, "type", recordType)
+	case err != nil:
+		u.logger.Error("Failed to update DNS record", "domain", domain.DomainName, "rr", rr, "type", recordType, "old_ip", oldIP, "new_ip", value, "request_id", requestID, "error", err)
+		metrics.inc("ddns_update_failures_total", labels...)
+		metrics.failedRequest(domain.DomainName, rr, recordType, requestID)
+		status.record(domain, rr, recordType, oldIP, value, requestID, "failed", err)
+		result.result, result.err = resultFailed, err
+	case spec.Force && oldIP == value:
+		// 强制更新时值没有变化，不发送 IP 变化的通知
+		u.logger.Info("DNS record re-submitted", "domain", domain.DomainName, "rr", rr, "type", recordType, "ip", value, "request_id", requestID)
+		metrics.inc("ddns_update_successes_total", labels...)
+		metrics.failedRequest(domain.DomainName, rr, recordType, "")
+		status.record(domain, rr, recordType, oldIP, value, requestID, "updated", nil)
+		u.state.set(domain.recordKey(rr, recordType), recordID, value, false)
+		u.tagRecord(ctx, task, spec, recordID)
+		result.result = resultUpdated
+	default:
+		u.logger.Info("DNS record updated", "domain", domain.DomainName, "rr", rr, "type", recordType, "old_ip", oldIP, "new_ip", value, "request_id", requestID)
+		metrics.inc("ddns_update_successes_total", labels...)
+		metrics.failedRequest(domain.DomainName, rr, recordType, "")
+		metrics.set("ddns_last_change_timestamp_seconds", float64(time.Now().Unix()), labels...)
+		status.record(domain, rr, recordType, oldIP, value, requestID, "updated", nil)
+		u.notify.emit(notifyEvent{Event: eventIPChanged, Domain: domain.DomainName, RR: rr, Type: recordType, OldIP: oldIP, NewIP: value, RequestID: requestID, Geo: u.recordGeo(domain, recordType)})
+		u.state.set(domain.recordKey(rr, recordType), recordID, value, true)
+		u.tagRecord(ctx, task, spec, recordID)
+		result.result = resultUpdated
+	}
+	return result
+}
+
+// 已有的记录需要修改并且距离上次修改不到 cooldown 时返回可以再次修改的时间
+func (u *updater) coolingDown(domain DomainConfig, spec recordSpec, records []dnsRecord) (time.Time, bool) {
+	if u.cooldown <= 0 {
+		return time.Time{}, false
+	}
+	changed := u.state.changedAt(domain.recordKey(spec.RR, spec.Type))
+	until := changed.Add(u.cooldown)
+	if changed.IsZero() || time.Now().After(until) {
+		return time.Time{}, false
+	}
+	for _, record := range records {
+		if spec.matches(record) && !slices.Contains(spec.values(), record.Value) {
+			return until, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// 开启 tagRecords 时把记录的备注设置为 managedRemarkPrefix 开头的内容，失败时只记录日志
+func (u *updater) tagRecord(ctx context.Context, task recordTask, spec recordSpec, recordID string) {
+	remark := u.config.recordRemark()
+	tagger, canTag := u.providers[task.domain.providerKey()].(remarkProvider)
+	if remark == "" || !canTag {
+		return
+	}
+	record := dnsRecord{ID: recordID, RR: spec.RR, Type: spec.Type, Value: spec.Value, Line: spec.Line}
+	for _, existing := range task.records {
+		if existing.ID == recordID && spec.matches(existing) {
+			record = existing
+			break
+		}
+	}
+	if record.Remark == remark {
+		return
+	}
+	remarkCtx, requestIDs := withRequestIDs(ctx)
+	err := u.retry.do(remarkCtx, u.logger, "set remark of "+spec.Type+" record "+spec.RR+"."+spec.DomainName, func(ctx context.Context) error {
+		return tagger.setRemark(ctx, spec.DomainName, record, remark)
+	})
+	u.observeThrottle(task.domain.providerKey(), err)
+	if err != nil {
+		u.logger.Warn("Failed to set record remark", "domain", spec.DomainName, "rr", spec.RR, "type", spec.Type, "request_id", requestIDs.Last(), "error", err)
+		return
+	}
+	u.logger.Info("Record remark set", "domain", spec.DomainName, "rr", spec.RR, "type", spec.Type, "remark", remark)
+}
+
+// 删除或暂停本机标记过（备注为 recordRemark）但已不在配置中的记录，返回处理的记录数。
+// 只处理本轮查询过的域名，同一个域名只处理一次；失败时只记录日志
+func (u *updater) pruneRecords(ctx context.Context, listed [][]dnsRecord) int {
+	remark := u.config.recordRemark()
+	mode := u.config.Prune
+	if mode == "" || remark == "" {
+		return 0
+	}
+
+	pruned := 0
+	done := make(map[string]bool)
+	for i, domain := range u.domains {
+		zone := domain.providerKey() + " " + domain.DomainName
+		if listed[i] == nil || done[zone] || ctx.Err() != nil {
+			continue
+		}
+		done[zone] = true
+		provider := u.providers[domain.providerKey()]
+
+		// 同一个域名可能出现在多项配置中
+		var wanted []recordSpec
+		for _, configured := range u.domains {
+			if configured.providerKey() != domain.providerKey() || configured.DomainName != domain.DomainName {
+				continue
+			}
+			for _, rr := range configured.RRs {
+				for _, recordType := range configured.RecordTypes {
+					wanted = append(wanted, recordSpec{RR: rr, Type: recordType, Line: configured.recordLine()})
+				}
+			}
+		}
+
+		for _, record := range listed[i] {
+			if record.Remark != remark || mode == pruneDisable && record.Disabled || matchesAny(wanted, record) {
+				continue
+			}
+			pruneCtx, requestIDs := withRequestIDs(ctx)
+			err := u.retry.do(pruneCtx, u.logger, "prune "+record.Type+" record "+record.RR+"."+domain.DomainName, func(ctx context.Context) error {
+				if mode == pruneDisable {
+					setter, ok := provider.(recordStatusSetter)
+					if !ok {
+						return fmt.Errorf("DNS provider %s cannot disable records", domain.Provider)
+					}
+					return setter.setRecordEnabled(ctx, domain.DomainName, record, false)
+				}
+				deleter, ok := provider.(recordDeleter)
+				if !ok {
+					return fmt.Errorf("DNS provider %s cannot delete records", domain.Provider)
+				}
+				return deleter.deleteRecord(ctx, domain.DomainName, record)
+			})
+			u.observeThrottle(domain.providerKey(), err)
+			if err != nil {
+				u.logger.Error("Failed to prune stale record", "domain", domain.DomainName, "rr", record.RR, "type", record.Type, "ip", record.Value, "mode", mode, "request_id", requestIDs.Last(), "error", err)
+				continue
+			}
+			u.logger.Info("Stale record pruned", "domain", domain.DomainName, "rr", record.RR, "type", record.Type, "ip", record.Value, "mode", mode, "request_id", requestIDs.Last())
+			pruned++
+		}
+	}
+	return pruned
+}
+
+// 判断记录是否对应其中一项配置
+func matchesAny(specs []recordSpec, record dnsRecord) bool {
+	for _, spec := range specs {
+		if spec.matches(record) {
+			return true
+		}
+	}
+	return false
+}
+
+// 对 0 到 count-1 执行 fn，最多同时运行 limit 个
+func forEachLimited(limit, count int, fn func(i int)) {
+	slots := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for i := 0; i < count; i++ {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// 服务商仍处于限流暂停期时返回恢复调用的时间
+func (u *updater) throttledUntil(provider string) (time.Time, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	throttle := u.throttles[provider]
+	if throttle == nil || !time.Now().Before(throttle.until) {
+		return time.Time{}, false
+	}
+	return throttle.until, true
+}
+
+// 根据调用结果更新服务商的限流状态：被限流时暂停调用该服务商，暂停时间逐次翻倍；
+// 再次调用没有被限流时恢复
+func (u *updater) observeThrottle(provider string, err error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	throttle := u.throttles[provider]
+	if !throttled(err) {
+		if throttle != nil {
+			delete(u.throttles, provider)
+			metrics.set("ddns_api_throttled", 0, "provider", provider)
+			u.logger.Info("DNS provider is no longer throttling requests", "provider", provider)
+		}
+		return
+	}
+
+	// 并发的请求同时被限流时只暂停一次
+	if throttle != nil && time.Now().Before(throttle.until) {
+		return
+	}
+	pause := minThrottlePause
+	if throttle != nil {
+		pause = min(throttle.pause*2, maxThrottlePause)
+	}
+	u.throttles[provider] = &throttleState{until: time.Now().Add(pause), pause: pause}
+	metrics.inc("ddns_api_throttled_total", "provider", provider)
+	metrics.set("ddns_api_throttled", 1, "provider", provider)
+	u.logger.Warn("DNS provider is throttling requests, pausing API calls", "provider", provider, "pause", pause.String(), "error", err)
+	// 同一次限流只通知一次
+	if throttle == nil {
+		u.notify.emit(notifyEvent{Event: eventThrottled, Provider: provider, Error: err.Error(), Pause: pause.String()})
+	}
+}
+
+// 返回记录应有的值：未配置 value 时为公网 IP（ipv6Hosts 中的主机为拼接后的地址），否则为模板的结果，{{.IP}} 同样为拼接后的地址
+func (u *updater) recordValue(domain DomainConfig, rr, recordType, publicIP string, publicIPs map[string]string) (string, error) {
+	publicIP, err := domain.hostAddress(rr, recordType, publicIP)
+	if err != nil {
+		return "", err
+	}
+	if domain.Value == "" {
+		return publicIP, nil
+	}
+	family := u.config.domainFamily(domain, recordType).name
+	return renderValueTemplate(u.values[domain.Value], recordValueData{
+		IP:     publicIP,
+		IPv4:   publicIPs[u.config.domainFamily(domain, "A").name],
+		IPv6:   publicIPs[u.config.domainFamily(domain, "AAAA").name],
+		Domain: domain.DomainName,
+		RR:     rr,
+		Type:   recordType,
+		Time:   valueTime{u.seenIPs[family].since},
+	})
+}
@@ -0,0 +1,416 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	sdk "github.com/aliyun/alibaba-cloud-sdk-go/services/alidns"
+
+	"DDns_go/pkg/provider/alidns"
+)
+
+// 返回固定公网 IP 的检测地址，count 为请求次数
+func newIPServer(t testing.TB, ip string) (*httptest.Server, *atomic.Int64) {
+	t.Helper()
+	var count atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		count.Add(1)
+		fmt.Fprintln(w, ip)
+	}))
+	t.Cleanup(server.Close)
+	return server, &count
+}
+
+// 使用 fake 作为阿里云客户端、从 ipURL 获取公网 IP 的 updater，rrs 为 example.com 下需要更新的 A 记录
+func newTestUpdater(t testing.TB, fake alidnsAPI, ipURL string, rrs ...string) *updater {
+	t.Helper()
+	config := defaultConfig
+	config.AccessKey = "testAccessKeyID"
+	config.AccessSecret = "testAccessKeySecret"
+	config.DomainName = "example.com"
+	config.RR = ""
+	config.RRs = rrs
+	config.APIURLs = []string{ipURL}
+	config.LogFileName = ""
+	state, err := loadState("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := newUpdater(config, state, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("newUpdater() error = %v", err)
+	}
+	u.providers[providerAliyun] = &aliyunProvider{dns: alidns.New(fake)}
+	return u
+}
+
+func TestRunCycleCreatesMissingRecord(t *testing.T) {
+	server, _ := newIPServer(t, "1.2.3.4")
+	fake := newFakeAlidns(sdk.Record{RecordId: "1", DomainName: "example.com", RR: "www", Type: "A", Value: "1.2.3.4", Line: aliyunDefaultLine, Status: "ENABLE"})
+	u := newTestUpdater(t, fake, server.URL, "www", "nas")
+
+	if err := u.runCycle(context.Background()); err != nil {
+		t.Fatalf("runCycle() error = %v", err)
+	}
+	if len(fake.adds) != 1 {
+		t.Fatalf("AddDomainRecord called %d times, want 1", len(fake.adds))
+	}
+	if add := fake.adds[0]; add.DomainName != "example.com" || add.RR != "nas" || add.Type != "A" || add.Value != "1.2.3.4" {
+		t.Errorf("unexpected AddDomainRecord request %+v", add)
+	}
+	if len(fake.updates) != 0 {
+		t.Errorf("UpdateDomainRecord called %d times, want 0", len(fake.updates))
+	}
+}
+
+func TestRunCycleUpdatesChangedRecord(t *testing.T) {
+	server, _ := newIPServer(t, "1.2.3.5")
+	fake := newFakeAlidns(sdk.Record{RecordId: "1", DomainName: "example.com", RR: "www", Type: "A", Value: "1.2.3.4", Line: aliyunDefaultLine, Status: "ENABLE"})
+	u := newTestUpdater(t, fake, server.URL, "www")
+
+	if err := u.runCycle(context.Background()); err != nil {
+		t.Fatalf("runCycle() error = %v", err)
+	}
+	if len(fake.updates) != 1 {
+		t.Fatalf("UpdateDomainRecord called %d times, want 1", len(fake.updates))
+	}
+	if update := fake.updates[0]; update.RecordId != "1" || update.RR != "www" || update.Value != "1.2.3.5" {
+		t.Errorf("unexpected UpdateDomainRecord request %+v", update)
+	}
+	if len(fake.adds) != 0 {
+		t.Errorf("AddDomainRecord called %d times, want 0", len(fake.adds))
+	}
+}
+
+func TestRunCycleUnchangedIP(t *testing.T) {
+	server, requests := newIPServer(t, "1.2.3.4")
+	fake := newFakeAlidns(
+		sdk.Record{RecordId: "1", DomainName: "example.com", RR: "www", Type: "A", Value: "1.2.3.4", Line: aliyunDefaultLine, Status: "ENABLE"},
+		sdk.Record{RecordId: "2", DomainName: "example.com", RR: "nas", Type: "A", Value: "1.2.3.4", Line: aliyunDefaultLine, Status: "ENABLE"},
+	)
+	u := newTestUpdater(t, fake, server.URL, "www", "nas")
+
+	// 第二轮同样检测公网 IP，但记录已经是该值，不应再修改
+	for cycle := 1; cycle <= 2; cycle++ {
+		u.dueNow()
+		if err := u.runCycle(context.Background()); err != nil {
+			t.Fatalf("runCycle() #%d error = %v", cycle, err)
+		}
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("public IP requested %d times, want 2", got)
+	}
+	if len(fake.updates) != 0 || len(fake.adds) != 0 {
+		t.Errorf("got %d updates and %d adds, want none", len(fake.updates), len(fake.adds))
+	}
+}
+
+// 每轮检测的汇总会输出到控制台，基准测试中丢弃
+func discardStdout(tb testing.TB) {
+	tb.Helper()
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = devNull
+	tb.Cleanup(func() {
+		os.Stdout = stdout
+		devNull.Close()
+	})
+}
+
+// 稳定运行时的 updater：count 条记录都已经是检测到的公网 IP
+func newSteadyUpdater(tb testing.TB, count int) (*updater, *fakeAlidns) {
+	tb.Helper()
+	server, _ := newIPServer(tb, "1.2.3.4")
+	fake := newFakeAlidns()
+	rrs := make([]string, count)
+	for i := range rrs {
+		rrs[i] = "host" + strconv.Itoa(i)
+		fake.records = append(fake.records, sdk.Record{RecordId: strconv.Itoa(i + 1), DomainName: "example.com", RR: rrs[i], Type: "A", Value: "1.2.3.4", Line: aliyunDefaultLine, Status: "ENABLE"})
+	}
+	discardStdout(tb)
+	return newTestUpdater(tb, fake, server.URL, rrs...), fake
+}
+
+func BenchmarkRunCycle(b *testing.B) {
+	u, _ := newSteadyUpdater(b, 10)
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		u.dueNow()
+		if err := u.runCycle(ctx); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSyncRecords(b *testing.B) {
+	u, _ := newSteadyUpdater(b, 10)
+	ctx := context.Background()
+	publicIPs := map[string]string{"IPv4": "1.2.3.4"}
+	due := make([]bool, len(u.domains))
+	for i := range due {
+		due[i] = true
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, synced := u.syncRecords(ctx, publicIPs, due); !synced {
+			b.Fatal("syncRecords failed")
+		}
+	}
+}
+
+// 路由器上长期运行时堆内存不应随检测次数增长，稳定后应在 10MB 以内
+func TestSteadyStateHeap(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping in short mode")
+	}
+	const (
+		warmup  = 20
+		cycles  = 500
+		maxHeap = 10 << 20
+	)
+	u, fake := newSteadyUpdater(t, 10)
+	ctx := context.Background()
+	run := func(n int) {
+		for i := 0; i < n; i++ {
+			u.dueNow()
+			if err := u.runCycle(ctx); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	heapInuse := func() uint64 {
+		runtime.GC()
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		return stats.HeapInuse
+	}
+
+	run(warmup)
+	before := heapInuse()
+	run(cycles)
+	after := heapInuse()
+	t.Logf("HeapInuse after %d cycles: %d KB, after %d more cycles: %d KB", warmup, before>>10, cycles, after>>10)
+	if after > maxHeap {
+		t.Errorf("HeapInuse = %d bytes after %d cycles, want at most %d", after, warmup+cycles, maxHeap)
+	}
+	// 允许少量的波动，持续增长说明每轮检测有没有释放的内存
+	if after > before+1<<20 {
+		t.Errorf("HeapInuse grew from %d to %d bytes over %d cycles", before, after, cycles)
+	}
+	if len(fake.updates) != 0 || len(fake.adds) != 0 {
+		t.Errorf("got %d updates and %d adds in steady state, want none", len(fake.updates), len(fake.adds))
+	}
+}
+
+// 模拟 Cloudflare API：example.com 下只有一条 www 的 A 记录，patches 为修改记录时提交的内容
+type fakeCloudflare struct {
+	mu      sync.Mutex
+	patches []map[string]interface{}
+}
+
+func (f *fakeCloudflare) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var result interface{}
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/zones":
+		result = []map[string]string{{"id": "zone1"}}
+	case r.Method == http.MethodGet && r.URL.Path == "/zones/zone1/dns_records":
+		result = []map[string]interface{}{{"id": "record1", "type": "A", "name": "www.example.com", "content": "1.2.3.3", "ttl": cloudflareAutoTTL}}
+	case r.Method == http.MethodPatch && r.URL.Path == "/zones/zone1/dns_records/record1":
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		f.patches = append(f.patches, body)
+		result = map[string]string{"id": "record1"}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"success":false,"errors":[{"code":7003,"message":"not found"}]}`)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "result": result, "result_info": map[string]int{"page": 1, "total_pages": 1}})
+}
+
+// 只使用 Cloudflare 的 updater，fake 模拟 Cloudflare API，logger 为空时丢弃日志
+func newCloudflareTestUpdater(t testing.TB, config Config, fake *fakeCloudflare, logger *slog.Logger) *updater {
+	t.Helper()
+	cloudflareServer := httptest.NewServer(fake)
+	t.Cleanup(cloudflareServer.Close)
+	t.Cleanup(func() { replaceIPHTTPClients(newIPHTTPClients(defaultHTTPSettings)) })
+
+	config.Provider = providerCloudflare
+	config.Cloudflare = &CloudflareConfig{APIToken: "testCloudflareToken"}
+	config.DomainName = "example.com"
+	config.RR = ""
+	config.RRs = []string{"www"}
+	config.LogFileName = ""
+	if err := config.validate(); err != nil {
+		t.Fatalf("validate() error = %v", err)
+	}
+	state, err := loadState("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	u, err := newUpdater(config, state, logger)
+	if err != nil {
+		t.Fatalf("newUpdater() error = %v", err)
+	}
+	u.providers[providerCloudflare].(*cloudflareProvider).baseURL = cloudflareServer.URL
+	return u
+}
+
+// 只使用 Cloudflare 时 ipv4Source 的 bindAddress 同样生效
+func TestCloudflareOnlyIPBinding(t *testing.T) {
+	// 127.0.0.0/8 中的其它地址只在 Linux 上默认可用
+	if runtime.GOOS != "linux" {
+		t.Skip("binding to 127.0.0.2 requires Linux")
+	}
+	const bindAddress = "127.0.0.2"
+	var remoteHost atomic.Value
+	ipServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, _ := net.SplitHostPort(r.RemoteAddr)
+		remoteHost.Store(host)
+		fmt.Fprintln(w, "1.2.3.4")
+	}))
+	t.Cleanup(ipServer.Close)
+
+	config := defaultConfig
+	config.IPv4Source = &SourceConfig{Source: "http", URLs: []string{ipServer.URL}, BindAddress: bindAddress}
+	fake := &fakeCloudflare{}
+	u := newCloudflareTestUpdater(t, config, fake, nil)
+
+	if err := u.runCycle(context.Background()); err != nil {
+		t.Fatalf("runCycle() error = %v", err)
+	}
+	if got, _ := remoteHost.Load().(string); got != bindAddress {
+		t.Errorf("public IP requested from %q, want %q", got, bindAddress)
+	}
+	if len(fake.patches) != 1 || fake.patches[0]["content"] != "1.2.3.4" {
+		t.Errorf("got Cloudflare updates %v, want one update to 1.2.3.4", fake.patches)
+	}
+}
+
+// 只使用 Cloudflare 时 http.timeout 和 proxy.ip 同样用于获取 IP 的请求
+func TestCloudflareOnlyIPHTTPSettings(t *testing.T) {
+	// 检测地址无法解析，只有经过代理才能获取到 IP
+	const ipURL = "http://ip.invalid/"
+	var proxied atomic.Value
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied.Store(r.URL.String())
+		fmt.Fprintln(w, "1.2.3.4")
+	}))
+	t.Cleanup(proxy.Close)
+
+	config := defaultConfig
+	config.APIURLs = []string{ipURL}
+	config.HTTP = &HTTPConfig{Timeout: "3s"}
+	config.Proxy = &ProxyConfig{IP: proxy.URL, Aliyun: "direct"}
+	fake := &fakeCloudflare{}
+	u := newCloudflareTestUpdater(t, config, fake, nil)
+
+	if got := ipHTTPClients["tcp4"].Timeout; got != 3*time.Second {
+		t.Errorf("IP client timeout = %s, want 3s", got)
+	}
+	if err := u.runCycle(context.Background()); err != nil {
+		t.Fatalf("runCycle() error = %v", err)
+	}
+	if got, _ := proxied.Load().(string); got != ipURL {
+		t.Errorf("proxy received %q, want %q", got, ipURL)
+	}
+	if len(fake.patches) != 1 || fake.patches[0]["content"] != "1.2.3.4" {
+		t.Errorf("got Cloudflare updates %v, want one update to 1.2.3.4", fake.patches)
+	}
+}
+
+// 多个阿里云账号共用同一组获取 IP 的客户端，创建各账号的客户端时不再替换
+func TestIPHTTPClientsWithAccounts(t *testing.T) {
+	t.Cleanup(func() { replaceIPHTTPClients(newIPHTTPClients(defaultHTTPSettings)) })
+	config := defaultConfig
+	config.AccessKey = "testAccessKeyID"
+	config.AccessSecret = "testAccessKeySecret"
+	config.Accounts = []AccountConfig{{Name: "work", AccessKey: "workAccessKeyID", AccessSecret: "workAccessKeySecret"}}
+	config.Domains = []DomainConfig{
+		{DomainName: "example.com", RRs: []string{"www"}},
+		{DomainName: "example.net", RRs: []string{"www"}, Account: "work"},
+	}
+	config.IPv4Source = &SourceConfig{Source: "http", BindInterface: "wan2"}
+	config.LogFileName = ""
+	if err := config.validate(); err != nil {
+		t.Fatalf("validate() error = %v", err)
+	}
+	state, err := loadState("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := newUpdater(config, state, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("newUpdater() error = %v", err)
+	}
+	if len(u.providers) != 2 {
+		t.Fatalf("got %d providers, want 2", len(u.providers))
+	}
+	clients := ipHTTPClients
+	if clients["tcp4%wan2"] == nil {
+		t.Fatalf("no IP client for the bound interface, got %v", clients)
+	}
+
+	for _, domain := range providerDomains(u.domains) {
+		if _, err := newDomainProvider(domain, config, u.logger); err != nil {
+			t.Fatalf("newDomainProvider(%s) error = %v", domain.DomainName, err)
+		}
+	}
+	for key, client := range clients {
+		if ipHTTPClients[key] != client {
+			t.Errorf("IP client %s was replaced while creating the account clients", key)
+		}
+	}
+}
+
+// debug 级别时绑定地址的客户端同样记录获取 IP 的请求，与使用的服务商无关
+func TestIPHTTPClientsDebugTransport(t *testing.T) {
+	server, _ := newIPServer(t, "1.2.3.4")
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	config := defaultConfig
+	config.IPv4Source = &SourceConfig{Source: "http", URLs: []string{server.URL}, BindAddress: "127.0.0.1"}
+	u := newCloudflareTestUpdater(t, config, &fakeCloudflare{}, logger)
+
+	for key, client := range ipHTTPClients {
+		if _, ok := client.Transport.(*debugTransport); !ok {
+			t.Errorf("IP client %s is not wrapped for debug logging", key)
+		}
+	}
+	if ipHTTPClients["tcp4@127.0.0.1"] == nil {
+		t.Fatalf("no IP client for the bound address")
+	}
+	if err := u.runCycle(context.Background()); err != nil {
+		t.Fatalf("runCycle() error = %v", err)
+	}
+	if want := `msg="HTTP response" method=GET url=` + server.URL; !strings.Contains(buf.String(), want) {
+		t.Errorf("debug log does not contain %q:\n%s", want, buf.String())
+	}
+}
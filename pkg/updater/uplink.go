@@ -0,0 +1,66 @@
+package updater
+
+// 多线路（多 WAN）路由器上的一条线路，绑定到该线路的域名使用这里的获取方式检测公网 IP，
+// 例如通过 interface 方式读取 pppoe-wan2 的地址，或者通过 http 方式从该线路的 bindAddress 访问 API
+type UplinkConfig struct {
+	Name       string        `json:"name"`
+	IPv4Source *SourceConfig `json:"ipv4Source,omitempty"` // 该线路 IPv4 地址的获取方式，绑定的域名有 A 记录时必须设置
+	IPv6Source *SourceConfig `json:"ipv6Source,omitempty"` // 该线路 IPv6 地址的获取方式，绑定的域名有 AAAA 记录时必须设置
+}
+
+// 返回 uplinks 中该名称的线路
+func (c Config) uplink(name string) (UplinkConfig, bool) {
+	for _, uplink := range c.Uplinks {
+		if uplink.Name == name {
+			return uplink, true
+		}
+	}
+	return UplinkConfig{}, false
+}
+
+// 线路上对应协议族的获取方式，未设置时为 nil
+func (u UplinkConfig) source(network string) *SourceConfig {
+	if network == "tcp6" {
+		return u.IPv6Source
+	}
+	return u.IPv4Source
+}
+
+// 记录类型在域名绑定的线路上对应的协议族。每条线路的每个协议族分别检测、确认和计入状态，名称为 IPv4/wan2 这样的形式
+func (c Config) domainFamily(domain DomainConfig, recordType string) ipFamily {
+	family := c.familyFor(recordType)
+	if domain.Uplink == "" {
+		return family
+	}
+	uplink, _ := c.uplink(domain.Uplink)
+	apiURLs := apiURLList(c.APIURLs, c.APIURL, defaultConfig.APIURLs)
+	if family.network == "tcp6" {
+		apiURLs = apiURLList(c.APIURLsV6, c.APIURLv6, defaultConfig.APIURLsV6)
+	}
+	family.name += "/" + uplink.Name
+	family.source = resolveSource(uplink.source(family.network), apiURLs)
+	family.uplink = uplink.Name
+	return family
+}
+
+// http 方式使用的全部 bindAddress 和 bindInterface，每个绑定单独创建 HTTP 客户端
+func (c Config) ipBindings() []ipBinding {
+	var bindings []ipBinding
+	add := func(source *SourceConfig, network string) {
+		if source != nil && (source.BindAddress != "" || source.BindInterface != "") {
+			bindings = append(bindings, source.binding(network))
+		}
+	}
+	add(c.IPv4Source, "tcp4")
+	add(c.IPv6Source, "tcp6")
+	for _, uplink := range c.Uplinks {
+		add(uplink.IPv4Source, "tcp4")
+		add(uplink.IPv6Source, "tcp6")
+	}
+	return bindings
+}
+
+// 通过 network 发出请求时使用的绑定
+func (s SourceConfig) binding(network string) ipBinding {
+	return ipBinding{network: network, address: s.BindAddress, iface: s.BindInterface}
+}
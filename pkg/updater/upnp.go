@@ -0,0 +1,234 @@
+package updater
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// UPnP 与 NAT-PMP 请求的超时
+const (
+	ssdpTimeout   = 3 * time.Second
+	upnpTimeout   = 5 * time.Second
+	natpmpTimeout = 2 * time.Second
+)
+
+// 支持查询外网地址的 UPnP 服务类型
+var upnpWANServices = []string{
+	"urn:schemas-upnp-org:service:WANIPConnection:2",
+	"urn:schemas-upnp-org:service:WANIPConnection:1",
+	"urn:schemas-upnp-org:service:WANPPPConnection:1",
+}
+
+// 返回 timeout 之后的时间，ctx 更早到期时返回 ctx 的截止时间
+func readDeadline(ctx context.Context, timeout time.Duration) time.Time {
+	deadline := time.Now().Add(timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		return ctxDeadline
+	}
+	return deadline
+}
+
+// 通过 SSDP 发现局域网中的 UPnP 网关，返回设备描述文件的地址
+func discoverUPnPGateways(ctx context.Context) ([]string, error) {
+	conn, err := net.ListenPacket("udp4", ":0")
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	ssdpAddr := &net.UDPAddr{IP: net.IPv4(239, 255, 255, 250), Port: 1900}
+	search := "M-SEARCH * HTTP/1.1\r\n" +
+		"HOST: 239.255.255.250:1900\r\n" +
+		"MAN: \"ssdp:discover\"\r\n" +
+		"MX: 2\r\n" +
+		"ST: urn:schemas-upnp-org:device:InternetGatewayDevice:1\r\n\r\n"
+	if _, err := conn.WriteTo([]byte(search), ssdpAddr); err != nil {
+		return nil, err
+	}
+
+	var locations []string
+	seen := make(map[string]bool)
+	buf := make([]byte, 2048)
+	conn.SetReadDeadline(readDeadline(ctx, ssdpTimeout))
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			// 超时表示搜索结束
+			break
+		}
+
+		resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(buf[:n])), nil)
+		if err != nil {
+			continue
+		}
+		location := resp.Header.Get("Location")
+		if location != "" && !seen[location] {
+			seen[location] = true
+			locations = append(locations, location)
+		}
+	}
+
+	if len(locations) == 0 {
+		return nil, errors.New("no UPnP gateway found")
+	}
+	return locations, nil
+}
+
+// UPnP 设备描述文件中用到的部分
+type upnpDevice struct {
+	Services []struct {
+		ServiceType string `xml:"serviceType"`
+		ControlURL  string `xml:"controlURL"`
+	} `xml:"serviceList>service"`
+	Devices []upnpDevice `xml:"deviceList>device"`
+}
+
+// 在设备树中查找 WAN 连接服务，返回服务类型和控制地址
+func (d upnpDevice) findWANService() (string, string) {
+	for _, serviceType := range upnpWANServices {
+		for _, service := range d.Services {
+			if service.ServiceType == serviceType {
+				return service.ServiceType, service.ControlURL
+			}
+		}
+	}
+	for _, device := range d.Devices {
+		if serviceType, controlURL := device.findWANService(); controlURL != "" {
+			return serviceType, controlURL
+		}
+	}
+	return "", ""
+}
+
+// 通过 UPnP IGD 的 GetExternalIPAddress 读取路由器的外网地址，location 为设备描述文件地址
+func getPublicIPByUPnP(ctx context.Context, location, network string) (string, error) {
+	if network != "tcp4" {
+		return "", errors.New("UPnP only reports IPv4 addresses")
+	}
+
+	client := &http.Client{Timeout: upnpTimeout}
+	descReq, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(descReq)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var root struct {
+		URLBase string     `xml:"URLBase"`
+		Device  upnpDevice `xml:"device"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&root); err != nil {
+		return "", fmt.Errorf("invalid device description: %v", err)
+	}
+
+	serviceType, controlURL := root.Device.findWANService()
+	if controlURL == "" {
+		return "", errors.New("WAN connection service not found")
+	}
+
+	// 控制地址可能是相对地址
+	base := root.URLBase
+	if base == "" {
+		base = location
+	}
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	controlRef, err := url.Parse(controlURL)
+	if err != nil {
+		return "", err
+	}
+
+	body := `<?xml version="1.0"?>` +
+		`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">` +
+		`<s:Body><u:GetExternalIPAddress xmlns:u="` + serviceType + `"/></s:Body></s:Envelope>`
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, baseURL.ResolveReference(controlRef).String(), strings.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+	req.Header.Set("SOAPAction", `"`+serviceType+`#GetExternalIPAddress"`)
+
+	soapResp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer soapResp.Body.Close()
+	if soapResp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("GetExternalIPAddress failed with status: %s", soapResp.Status)
+	}
+
+	// 只需要响应中的 NewExternalIPAddress 元素
+	decoder := xml.NewDecoder(soapResp.Body)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return "", errors.New("NewExternalIPAddress not found in response")
+		}
+		if err != nil {
+			return "", err
+		}
+		if start, ok := token.(xml.StartElement); ok && start.Name.Local == "NewExternalIPAddress" {
+			var ip string
+			if err := decoder.DecodeElement(&ip, &start); err != nil {
+				return "", err
+			}
+			return strings.TrimSpace(ip), nil
+		}
+	}
+}
+
+// 通过 NAT-PMP（RFC 6886）向网关查询外网地址，gateway 为网关地址
+func getPublicIPByNATPMP(ctx context.Context, gateway, network string) (string, error) {
+	if network != "tcp4" {
+		return "", errors.New("NAT-PMP only reports IPv4 addresses")
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp4", net.JoinHostPort(gateway, "5351"))
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	// 版本 0，操作码 0 表示查询外网地址
+	response := make([]byte, 16)
+	for timeout := natpmpTimeout; timeout <= 4*natpmpTimeout; timeout *= 2 {
+		if _, err := conn.Write([]byte{0, 0}); err != nil {
+			return "", err
+		}
+
+		conn.SetReadDeadline(readDeadline(ctx, timeout))
+		n, err := conn.Read(response)
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() && ctx.Err() == nil {
+				continue
+			}
+			return "", err
+		}
+		if n < 12 || response[0] != 0 || response[1] != 128 {
+			return "", errors.New("invalid NAT-PMP response")
+		}
+		if code := binary.BigEndian.Uint16(response[2:4]); code != 0 {
+			return "", fmt.Errorf("NAT-PMP request failed with result code %d", code)
+		}
+		return net.IP(response[8:12]).String(), nil
+	}
+	return "", errors.New("no response from NAT-PMP gateway")
+}
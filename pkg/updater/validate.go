@@ -0,0 +1,787 @@
+package updater
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// 配置校验发现的问题，每一项包含字段路径和原因
+type validationErrors []string
+
+func (e validationErrors) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e, "\n  - ")
+}
+
+// 记录一个字段的问题
+func (e *validationErrors) add(field, format string, args ...interface{}) {
+	*e = append(*e, field+": "+fmt.Sprintf(format, args...))
+}
+
+// 默认配置中的占位值，未修改时给出提示而不是等到调用 API 时才失败
+var placeholderValues = map[string]bool{
+	"your_access_key":    true,
+	"your_access_secret": true,
+	"your_domain_name":   true,
+}
+
+// 校验加载后的配置，返回全部有问题的字段
+func (c Config) validate() error {
+	var errs validationErrors
+
+	// 只有用到的服务商才需要填写凭证
+	checkProvider(&errs, "provider", c.Provider)
+	providers := map[string]bool{c.provider(): len(c.Domains) == 0}
+	accounts := make(map[string]bool)
+	for i, account := range c.Accounts {
+		field := fmt.Sprintf("accounts[%d]", i)
+		checkRequired(&errs, field+".name", account.Name)
+		if accounts[account.Name] {
+			errs.add(field+".name", "duplicate account %q", account.Name)
+		}
+		accounts[account.Name] = true
+		checkCredentials(&errs, field+".", account.AccessKey, account.AccessSecret, account.Credentials, true)
+		checkEndpoint(&errs, field+".endpoint", account.Endpoint)
+	}
+	privateZones := make(map[string]string)
+	for i, domain := range c.Domains {
+		field := fmt.Sprintf("domains[%d]", i)
+		checkProvider(&errs, field+".provider", domain.Provider)
+		provider := domain.Provider
+		if provider == "" {
+			provider = c.provider()
+		}
+		// 使用其它账号的域名不需要顶层的凭证
+		switch {
+		case domain.Account == "":
+			providers[provider] = true
+		case provider != providerAliyun:
+			errs.add(field+".account", "is only supported by the aliyun provider")
+		case !accounts[domain.Account]:
+			errs.add(field+".account", "unknown account %q, expected the name of one of accounts", domain.Account)
+		}
+		if domain.Proxied != nil && provider != providerCloudflare {
+			errs.add(field+".proxied", "is only supported by the cloudflare provider")
+		}
+		if domain.Line != "" && (provider != providerAliyun && provider != providerDNSPod || domain.ZoneType == zoneTypePrivate) {
+			errs.add(field+".line", "is only supported by aliyun public zones and the dnspod provider")
+		}
+		switch domain.ZoneType {
+		case "", zoneTypePublic:
+			if domain.ZoneID != "" {
+				errs.add(field+".zoneId", "is only used with zoneType private")
+			}
+		case zoneTypePrivate:
+			if provider != providerAliyun {
+				errs.add(field+".zoneType", "private zones are only supported by the aliyun provider")
+			}
+			checkRequired(&errs, field+".zoneId", domain.ZoneID)
+			if len(domain.Names) > 0 {
+				errs.add(field+".names", "is not supported for private zones, use domainName and rrs")
+			}
+			// 同一个域名只能对应一个 PrivateZone
+			if zoneID, ok := privateZones[domain.DomainName]; ok && zoneID != domain.ZoneID {
+				errs.add(field+".zoneId", "conflicts with zoneId %q configured for %s", zoneID, domain.DomainName)
+			}
+			privateZones[domain.DomainName] = domain.ZoneID
+		default:
+			errs.add(field+".zoneType", "unknown zone type %q, expected public or private", domain.ZoneType)
+		}
+	}
+
+	checkCredentials(&errs, "", c.AccessKey, c.AccessSecret, c.Credentials, providers[providerAliyun])
+	if providers[providerCloudflare] {
+		if c.Cloudflare == nil {
+			errs.add("cloudflare.apiToken", "is required for cloudflare domains")
+		} else {
+			checkRequired(&errs, "cloudflare.apiToken", c.Cloudflare.APIToken)
+		}
+	}
+	if providers[providerDNSPod] {
+		if c.DNSPod == nil {
+			errs.add("dnspod.secretId", "is required for dnspod domains")
+		} else {
+			checkRequired(&errs, "dnspod.secretId", c.DNSPod.SecretID)
+			checkRequired(&errs, "dnspod.secretKey", c.DNSPod.SecretKey)
+		}
+	}
+	if providers[providerHuawei] {
+		huawei := HuaweiConfig{}
+		if c.Huawei != nil {
+			huawei = *c.Huawei
+		}
+		switch huawei.auth() {
+		case huaweiAuthAKSK:
+			checkRequired(&errs, "huawei.accessKey", huawei.AccessKey)
+			checkRequired(&errs, "huawei.secretKey", huawei.SecretKey)
+		case huaweiAuthAgency:
+		default:
+			errs.add("huawei.auth", "unknown auth type %q, expected aksk or agency", huawei.Auth)
+		}
+	}
+
+	if c.RecordType != "" {
+		checkRecordType(&errs, "recordType", c.RecordType)
+	}
+	switch c.IPMode {
+	case "", "ipv4", "ipv6", "dual":
+	default:
+		errs.add("ipMode", "unknown ip mode %q, expected ipv4, ipv6 or dual", c.IPMode)
+	}
+	checkTTL(&errs, "ttl", c.TTL)
+	if c.Concurrency < 0 {
+		errs.add("concurrency", "must not be negative")
+	}
+	switch c.DisabledRecords {
+	case "", disabledRecordsSkip, disabledRecordsEnable:
+	default:
+		errs.add("disabledRecords", "unknown value %q, expected skip or enable", c.DisabledRecords)
+	}
+	switch c.MultipleRecords {
+	case "", multipleRecordsFail, multipleRecordsFirst, multipleRecordsAll:
+	default:
+		errs.add("multipleRecords", "unknown value %q, expected fail, first or all", c.MultipleRecords)
+	}
+	switch c.Prune {
+	case "":
+	case pruneDelete, pruneDisable:
+		// 只有带本机备注的记录才会被处理，未开启 tagRecords 时无法区分手动添加的记录
+		if !c.TagRecords {
+			errs.add("prune", "requires tagRecords")
+		}
+	default:
+		errs.add("prune", "unknown value %q, expected delete or disable", c.Prune)
+	}
+
+	// 未配置 domains 或某个域名未设置 rrs 时使用顶层的 rr/rrs
+	usesTopLevelRRs := len(c.Domains) == 0
+	if len(c.Domains) == 0 {
+		checkRequired(&errs, "domainName", c.DomainName)
+	}
+	for i, domain := range c.Domains {
+		field := fmt.Sprintf("domains[%d]", i)
+		switch {
+		case len(domain.Names) == 0:
+			checkRequired(&errs, field+".domainName", domain.DomainName)
+			if len(domain.RRs) == 0 && len(domain.IPv6Hosts) == 0 {
+				usesTopLevelRRs = true
+			}
+		case domain.DomainName != "" || len(domain.RRs) > 0:
+			errs.add(field+".names", "cannot be used together with domainName and rrs")
+		}
+		for j, name := range domain.Names {
+			if strings.Trim(name, ".") == "" {
+				errs.add(fmt.Sprintf("%s.names[%d]", field, j), "is empty")
+			}
+		}
+		checkRRs(&errs, field+".rrs", domain.RRs)
+		for j, recordType := range domain.RecordTypes {
+			checkDomainRecordType(&errs, fmt.Sprintf("%s.recordTypes[%d]", field, j), recordType, domain.Value)
+		}
+		if domain.Value != "" {
+			if _, err := parseValueTemplate(domain.Value); err != nil {
+				errs.add(field+".value", "invalid template: %v", err)
+			}
+		}
+		checkTTL(&errs, field+".ttl", domain.TTL)
+		if _, err := domain.pollInterval(); err != nil {
+			errs.add(field+".interval", "%v", err)
+		}
+		checkIPv6Hosts(&errs, field, domain)
+		c.checkDomainUplink(&errs, field, domain)
+		c.checkExtraValues(&errs, field, domain)
+	}
+	if usesTopLevelRRs && len(c.RRs) > 0 {
+		checkRRs(&errs, "rrs", c.RRs)
+	} else if usesTopLevelRRs && c.RR == "" {
+		errs.add("rr", "host record is empty, use @ for the root domain")
+	}
+
+	checkURLs(&errs, "apiURLs", c.APIURLs)
+	checkURLs(&errs, "apiURLsV6", c.APIURLsV6)
+	if c.APIURL != "" {
+		checkURLs(&errs, "apiURL", []string{c.APIURL})
+	}
+	if c.APIURLv6 != "" {
+		checkURLs(&errs, "apiURLv6", []string{c.APIURLv6})
+	}
+	checkSource(&errs, "ipv4Source", c.IPv4Source)
+	checkSource(&errs, "ipv6Source", c.IPv6Source)
+	checkBindAddress(&errs, "ipv4Source", c.IPv4Source, "tcp4")
+	checkBindAddress(&errs, "ipv6Source", c.IPv6Source, "tcp6")
+	uplinks := make(map[string]bool)
+	for i, uplink := range c.Uplinks {
+		field := fmt.Sprintf("uplinks[%d]", i)
+		checkRequired(&errs, field+".name", uplink.Name)
+		if strings.Contains(uplink.Name, "/") {
+			errs.add(field+".name", "must not contain /")
+		}
+		if uplinks[uplink.Name] {
+			errs.add(field+".name", "duplicate uplink name %q", uplink.Name)
+		}
+		uplinks[uplink.Name] = true
+		if uplink.IPv4Source == nil && uplink.IPv6Source == nil {
+			errs.add(field, "needs ipv4Source or ipv6Source")
+		}
+		checkSource(&errs, field+".ipv4Source", uplink.IPv4Source)
+		checkSource(&errs, field+".ipv6Source", uplink.IPv6Source)
+		checkBindAddress(&errs, field+".ipv4Source", uplink.IPv4Source, "tcp4")
+		checkBindAddress(&errs, field+".ipv6Source", uplink.IPv6Source, "tcp6")
+	}
+	if c.WANSource != nil {
+		// WAN 口地址需要从路由器或本机读取，http、stun 和 dns 方式得到的都是 NAT 之后的地址
+		switch c.WANSource.Source {
+		case "upnp", "natpmp", "interface", "command", "file", "plugin":
+			checkSource(&errs, "wanSource", c.WANSource)
+		default:
+			errs.add("wanSource.source", "unknown WAN source %q, expected upnp, natpmp, interface, command, file or plugin", c.WANSource.Source)
+		}
+	}
+	if c.PluginDir == "" && (len(c.pluginSources()) > 0 || c.Notify != nil && len(c.Notify.Plugins) > 0) {
+		errs.add("pluginDir", "is required when a plugin source or notify.plugins is configured")
+	}
+	if c.Geo != nil {
+		if len(c.Geo.MMDB) == 0 && c.Geo.URL == "" {
+			errs.add("geo", "requires mmdb or url to be set")
+		}
+		if c.Geo.URL != "" {
+			checkURLs(&errs, "geo.url", []string{strings.ReplaceAll(c.Geo.URL, "{ip}", "192.0.2.1")})
+		}
+	}
+	if c.Churn != nil {
+		if c.Churn.MaxChanges <= 0 {
+			errs.add("churn.maxChanges", "must be greater than 0")
+		}
+		if _, err := c.Churn.window(); err != nil {
+			errs = append(errs, err.Error())
+		}
+		switch c.Churn.Action {
+		case "", churnWarn, churnPause:
+		default:
+			errs.add("churn.action", "unknown value %q, expected warn or pause", c.Churn.Action)
+		}
+	}
+	switch c.CGNAT {
+	case "", cgnatWarn, cgnatSkip:
+	default:
+		errs.add("cgnat", "unknown value %q, expected warn or skip", c.CGNAT)
+	}
+
+	checkEndpoint(&errs, "endpoint", c.Endpoint)
+
+	switch c.LogFormat {
+	case "", "text", "json":
+	default:
+		errs.add("logFormat", "unknown log format %q, expected text or json", c.LogFormat)
+	}
+	switch c.LogTarget {
+	case "file", "both":
+		checkRequired(&errs, "logFileName", c.LogFileName)
+	case "", "stdout", "stderr", "journald", "syslog":
+	default:
+		errs.add("logTarget", "unknown log target %q, expected file, stdout, stderr, both, journald or syslog", c.LogTarget)
+	}
+	if c.Syslog != nil {
+		switch c.Syslog.Network {
+		case "", "udp", "tcp":
+		default:
+			errs.add("syslog.network", "unknown network %q, expected udp or tcp", c.Syslog.Network)
+		}
+		if c.Syslog.Network != "" && c.Syslog.Address == "" {
+			errs.add("syslog.address", "is required for remote syslog")
+		}
+	}
+	if _, err := parseLogLevel(c.LogLevel); err != nil {
+		errs.add("logLevel", "unknown log level %q, expected debug, info, warn or error", c.LogLevel)
+	}
+	if c.LogRotate != nil {
+		if c.LogRotate.MaxSize < 0 {
+			errs.add("logRotate.maxSize", "must not be negative")
+		}
+		if c.LogRotate.MaxBackups < 0 {
+			errs.add("logRotate.maxBackups", "must not be negative")
+		}
+		if c.LogRotate.MaxAge < 0 {
+			errs.add("logRotate.maxAge", "must not be negative")
+		}
+	}
+
+	if c.Notify != nil {
+		checkNotify(&errs, c.Notify)
+	}
+
+	// 以下字段沿用各自的解析函数，错误信息中已包含字段名
+	if _, err := c.pollSchedule(); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if _, err := c.verifyInterval(); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if _, err := c.cooldown(); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if _, err := c.historyRetention(); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if c.Confirmations < 0 {
+		errs.add("confirmations", "must not be negative")
+	}
+	if _, err := c.retryPolicy(); err != nil {
+		errs = append(errs, err.Error())
+	}
+	// 未设置 notify.retry 时使用 retry，上面已经检查过
+	if c.Notify != nil && c.Notify.Retry != nil {
+		if _, err := c.notifyRetryPolicy(); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if c.APIToken != "" && c.MetricsListen == "" {
+		errs.add("apiToken", "requires metricsListen to be set")
+	}
+	// pprof 会暴露命令行参数和内存中的内容，只能和 REST API 一起使用
+	if c.Debug && (c.MetricsListen == "" || c.APIToken == "") {
+		errs.add("debug", "requires metricsListen and apiToken to be set")
+	}
+	if c.MemoryLimit < 0 {
+		errs.add("memoryLimit", "must not be negative")
+	}
+	if c.ControlSocket != "" {
+		if err := checkControlSocket(c.ControlSocket); err != nil {
+			errs.add("controlSocket", "%v", err)
+		}
+	}
+	if c.OTel != nil {
+		if c.OTel.Endpoint != "" {
+			checkURLs(&errs, "otel.endpoint", []string{c.OTel.Endpoint})
+		}
+		if _, err := c.OTel.metricsInterval(); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if c.DynDNS != nil {
+		checkRequired(&errs, "dyndns.listen", c.DynDNS.Listen)
+		checkRequired(&errs, "dyndns.username", c.DynDNS.Username)
+		checkRequired(&errs, "dyndns.password", c.DynDNS.Password)
+		if (c.DynDNS.CertFile == "") != (c.DynDNS.KeyFile == "") {
+			errs.add("dyndns.certFile", "certFile and keyFile must be set together")
+		}
+	}
+	if schedule, err := c.pollSchedule(); err == nil {
+		if _, err := c.healthThreshold(schedule.period()); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if _, err := c.httpSettings(); err != nil {
+		errs = append(errs, err.Error())
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// 服务商只能是 aliyun、cloudflare、dnspod 或 huawei，为空时使用默认值
+func checkProvider(errs *validationErrors, field, provider string) {
+	switch provider {
+	case "", providerAliyun, providerCloudflare, providerDNSPod, providerHuawei:
+	default:
+		errs.add(field, "unknown DNS provider %q, expected aliyun, cloudflare, dnspod or huawei", provider)
+	}
+}
+
+// 必填字段不能为空，也不能是默认配置中的占位值
+func checkRequired(errs *validationErrors, field, value string) {
+	if value == "" {
+		errs.add(field, "is required")
+	} else if placeholderValues[value] {
+		errs.add(field, "still has the placeholder value %q, please replace it", value)
+	}
+}
+
+// 检查阿里云凭证，prefix 为字段名的前缀，例如 accounts[0].。required 为 false 时不检查 AccessKey（没有用到这些凭证的域名）
+func checkCredentials(errs *validationErrors, prefix, accessKey, accessSecret string, credentials *CredentialConfig, required bool) {
+	credentialType := "access_key"
+	if credentials != nil && credentials.Type != "" {
+		credentialType = credentials.Type
+	}
+	switch credentialType {
+	case "access_key", "sts":
+		if required {
+			checkRequired(errs, prefix+"accessKey", accessKey)
+			checkRequired(errs, prefix+"accessSecret", accessSecret)
+		}
+	case "ecs_ram_role", "profile", "chain", "keyring":
+	default:
+		errs.add(prefix+"credentials.type", "unknown credential type %q, expected access_key, sts, ecs_ram_role, profile, chain or keyring", credentialType)
+	}
+}
+
+// endpoint 只能是主机名
+func checkEndpoint(errs *validationErrors, field, endpoint string) {
+	if endpoint != "" && strings.Contains(endpoint, "/") {
+		errs.add(field, "expected a host name such as alidns.ap-southeast-1.aliyuncs.com, got %q", endpoint)
+	}
+}
+
+// 只支持根据公网 IP 更新 A 和 AAAA 记录
+func checkRecordType(errs *validationErrors, field, recordType string) {
+	if recordType != "A" && recordType != "AAAA" {
+		errs.add(field, "unsupported record type %q, expected A or AAAA", recordType)
+	}
+}
+
+// 域名可以使用任意记录类型，A 和 AAAA 以外的类型需要配置 value
+func checkDomainRecordType(errs *validationErrors, field, recordType, value string) {
+	if !recordTypePattern.MatchString(recordType) {
+		errs.add(field, "invalid record type %q, expected an upper case type such as A, AAAA, CNAME or TXT", recordType)
+		return
+	}
+	if !addressRecordType(recordType) && value == "" {
+		errs.add(field, "%s records need a value template", recordType)
+	}
+}
+
+// 检查 ipv6Hosts 的接口标识和 prefixLength，主机记录需要在 rrs 中
+func checkIPv6Hosts(errs *validationErrors, field string, domain DomainConfig) {
+	if domain.PrefixLength != 0 && (domain.PrefixLength < 1 || domain.PrefixLength > 127) {
+		errs.add(field+".prefixLength", "%d is out of range 1-127", domain.PrefixLength)
+	}
+	if len(domain.IPv6Hosts) == 0 {
+		return
+	}
+	if len(domain.Names) > 0 {
+		errs.add(field+".ipv6Hosts", "cannot be used together with names")
+	}
+	if len(domain.RecordTypes) > 0 && !slices.Contains(domain.RecordTypes, "AAAA") {
+		errs.add(field+".ipv6Hosts", "requires AAAA in recordTypes")
+	}
+	for _, rr := range domain.ipv6HostRRs() {
+		hostField := fmt.Sprintf("%s.ipv6Hosts[%s]", field, rr)
+		if len(domain.RRs) > 0 && !slices.Contains(domain.RRs, rr) {
+			errs.add(hostField, "host record %q is not in rrs", rr)
+		}
+		value := domain.IPv6Hosts[rr]
+		if _, err := parseInterfaceID(value); err != nil {
+			errs.add(hostField, "%v", err)
+		} else if mac, err := net.ParseMAC(value); err == nil && len(mac) == 6 && domain.prefixLength() > 64 {
+			errs.add(hostField, "MAC addresses need a prefixLength of at most 64")
+		}
+	}
+}
+
+// 域名需要更新的记录类型，与 domainList 补全的结果相同，ipMode 无效时由 ipMode 的检查报错
+func (c Config) configuredRecordTypes(domain DomainConfig) []string {
+	if len(domain.RecordTypes) > 0 {
+		return domain.RecordTypes
+	}
+	if len(domain.IPv6Hosts) > 0 {
+		return []string{"AAAA"}
+	}
+	recordTypes, _ := c.defaultRecordTypes()
+	return recordTypes
+}
+
+// 检查 extraValues：只有 A 和 AAAA 记录时每个值都需要是其中一种记录的 IP 地址
+func (c Config) checkExtraValues(errs *validationErrors, field string, domain DomainConfig) {
+	recordTypes := c.configuredRecordTypes(domain)
+	onlyAddresses := len(recordTypes) > 0
+	for _, recordType := range recordTypes {
+		onlyAddresses = onlyAddresses && addressRecordType(recordType)
+	}
+	for i, value := range domain.ExtraValues {
+		valueField := fmt.Sprintf("%s.extraValues[%d]", field, i)
+		if value == "" {
+			errs.add(valueField, "is empty")
+			continue
+		}
+		if !onlyAddresses {
+			continue
+		}
+		ip := net.ParseIP(value)
+		if ip == nil {
+			errs.add(valueField, "%q is not an IP address", value)
+			continue
+		}
+		recordType := "AAAA"
+		if ip.To4() != nil {
+			recordType = "A"
+		}
+		if !slices.Contains(recordTypes, recordType) {
+			errs.add(valueField, "%s needs %s in recordTypes", value, recordType)
+		}
+	}
+}
+
+// 检查域名绑定的线路存在，并且设置了域名的记录类型需要的协议族的获取方式
+func (c Config) checkDomainUplink(errs *validationErrors, field string, domain DomainConfig) {
+	if domain.Uplink == "" {
+		return
+	}
+	uplink, ok := c.uplink(domain.Uplink)
+	if !ok {
+		errs.add(field+".uplink", "unknown uplink %q", domain.Uplink)
+		return
+	}
+	for _, recordType := range c.configuredRecordTypes(domain) {
+		family := c.familyFor(recordType)
+		if uplink.source(family.network) == nil {
+			errs.add(field+".uplink", "uplink %s has no %s source for %s records", uplink.Name, family.name, recordType)
+		}
+	}
+}
+
+// bindAddress 和 bindInterface 只用于 http 方式，bindAddress 需要是 network 对应协议族的地址
+func checkBindAddress(errs *validationErrors, field string, source *SourceConfig, network string) {
+	if source == nil || source.BindAddress == "" && source.BindInterface == "" {
+		return
+	}
+	if source.Source != "" && source.Source != "http" {
+		if source.BindAddress != "" {
+			errs.add(field+".bindAddress", "is only supported by the http source")
+		}
+		if source.BindInterface != "" {
+			errs.add(field+".bindInterface", "is only supported by the http source")
+		}
+		return
+	}
+	if source.BindInterface != "" {
+		if source.BindAddress != "" {
+			errs.add(field+".bindInterface", "cannot be used together with bindAddress")
+		}
+		return
+	}
+	family := "IPv6"
+	if network == "tcp4" {
+		family = "IPv4"
+	}
+	ip := net.ParseIP(source.BindAddress)
+	if ip == nil || (ip.To4() != nil) != (network == "tcp4") {
+		errs.add(field+".bindAddress", "%q is not an %s address", source.BindAddress, family)
+	}
+}
+
+// 记录类型由大写字母、数字和下划线组成，例如 TXT、REDIRECT_URL
+var recordTypePattern = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+
+// TTL 为 0 时使用阿里云默认值
+func checkTTL(errs *validationErrors, field string, ttl int) {
+	if ttl < 0 || ttl > maxTTL {
+		errs.add(field, "ttl %d is out of range 1-%d", ttl, maxTTL)
+	}
+}
+
+// 主机记录不能为空
+func checkRRs(errs *validationErrors, field string, rrs []string) {
+	for i, rr := range rrs {
+		if rr == "" {
+			errs.add(fmt.Sprintf("%s[%d]", field, i), "host record is empty, use @ for the root domain")
+		}
+	}
+}
+
+// API 地址必须是完整的 http 或 https 地址
+func checkURLs(errs *validationErrors, field string, urls []string) {
+	for i, raw := range urls {
+		parsed, err := url.Parse(raw)
+		if err != nil {
+			errs.add(fmt.Sprintf("%s[%d]", field, i), "%v", err)
+			continue
+		}
+		if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			errs.add(fmt.Sprintf("%s[%d]", field, i), "expected an http or https URL, got %q", raw)
+		}
+	}
+}
+
+// 检查通知渠道的地址、事件和消息模板
+func checkNotify(errs *validationErrors, notify *NotifyConfig) {
+	if notify.FailureThreshold < 0 {
+		errs.add("notify.failureThreshold", "must not be negative")
+	}
+	for i, webhook := range notify.Webhooks {
+		field := fmt.Sprintf("notify.webhooks[%d]", i)
+		if webhook.URL == "" {
+			errs.add(field+".url", "is required")
+		} else {
+			checkURLs(errs, field+".url", []string{webhook.URL})
+		}
+		switch strings.ToUpper(webhook.Method) {
+		case "", http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch:
+		default:
+			errs.add(field+".method", "unsupported method %q, expected GET, POST, PUT or PATCH", webhook.Method)
+		}
+		checkChannel(errs, field, webhook.ChannelConfig)
+		if _, err := parseNotifyTemplate(webhook.Body); err != nil {
+			errs.add(field+".body", "invalid template: %v", err)
+		}
+	}
+	for i, robot := range notify.DingTalk {
+		checkChat(errs, fmt.Sprintf("notify.dingtalk[%d]", i), robot.Webhook, robot.ChatConfig)
+	}
+	for i, robot := range notify.WeCom {
+		checkChat(errs, fmt.Sprintf("notify.wecom[%d]", i), robot.Webhook, robot.ChatConfig)
+	}
+	for i, robot := range notify.Feishu {
+		checkChat(errs, fmt.Sprintf("notify.feishu[%d]", i), robot.Webhook, robot.ChatConfig)
+	}
+	for i, robot := range notify.Telegram {
+		field := fmt.Sprintf("notify.telegram[%d]", i)
+		checkRequired(errs, field+".botToken", robot.BotToken)
+		checkRequired(errs, field+".chatId", robot.ChatID)
+		if robot.APIURL != "" {
+			checkURLs(errs, field+".apiURL", []string{robot.APIURL})
+		}
+		checkChatConfig(errs, field, robot.ChatConfig)
+	}
+	for i, robot := range notify.Slack {
+		checkChat(errs, fmt.Sprintf("notify.slack[%d]", i), robot.Webhook, robot.ChatConfig)
+	}
+	for i, robot := range notify.Discord {
+		checkChat(errs, fmt.Sprintf("notify.discord[%d]", i), robot.Webhook, robot.ChatConfig)
+	}
+	for i, push := range notify.Bark {
+		field := fmt.Sprintf("notify.bark[%d]", i)
+		checkRequired(errs, field+".deviceKey", push.DeviceKey)
+		if push.Server != "" {
+			checkURLs(errs, field+".server", []string{push.Server})
+		}
+		checkChatConfig(errs, field, push.ChatConfig)
+	}
+	for i, push := range notify.Gotify {
+		field := fmt.Sprintf("notify.gotify[%d]", i)
+		if push.Server == "" {
+			errs.add(field+".server", "is required")
+		} else {
+			checkURLs(errs, field+".server", []string{push.Server})
+		}
+		checkRequired(errs, field+".token", push.Token)
+		if push.Priority < 0 || push.Priority > 10 {
+			errs.add(field+".priority", "priority %d is out of range 0-10", push.Priority)
+		}
+		checkChatConfig(errs, field, push.ChatConfig)
+	}
+	for i, push := range notify.Ntfy {
+		field := fmt.Sprintf("notify.ntfy[%d]", i)
+		checkRequired(errs, field+".topic", push.Topic)
+		if push.Server != "" {
+			checkURLs(errs, field+".server", []string{push.Server})
+		}
+		if push.Priority < 0 || push.Priority > 5 {
+			errs.add(field+".priority", "priority %d is out of range 1-5", push.Priority)
+		}
+		checkChatConfig(errs, field, push.ChatConfig)
+	}
+	for i, plugin := range notify.Plugins {
+		field := fmt.Sprintf("notify.plugins[%d]", i)
+		checkPluginName(errs, field+".plugin", plugin.Plugin)
+		if plugin.Timeout != "" {
+			if timeout, err := time.ParseDuration(plugin.Timeout); err != nil || timeout <= 0 {
+				errs.add(field+".timeout", "invalid duration %q", plugin.Timeout)
+			}
+		}
+		checkChannel(errs, field, plugin.ChannelConfig)
+	}
+}
+
+// 插件名称是 pluginDir 中的文件名，不能包含路径
+func checkPluginName(errs *validationErrors, field, name string) {
+	switch {
+	case name == "":
+		errs.add(field, "is required")
+	case strings.ContainsAny(name, `/\`) || name == "." || name == "..":
+		errs.add(field, "plugin name %q must be a file name in pluginDir, not a path", name)
+	}
+}
+
+// 聊天机器人需要 webhook 地址
+func checkChat(errs *validationErrors, field, webhook string, chat ChatConfig) {
+	if webhook == "" {
+		errs.add(field+".webhook", "is required")
+	} else {
+		checkURLs(errs, field+".webhook", []string{webhook})
+	}
+	checkChatConfig(errs, field, chat)
+}
+
+// 检查机器人的事件、语言和消息模板
+func checkChatConfig(errs *validationErrors, field string, chat ChatConfig) {
+	checkChannel(errs, field, chat.ChannelConfig)
+	switch chat.Language {
+	case "", "zh", "en":
+	default:
+		errs.add(field+".language", "unknown language %q, expected zh or en", chat.Language)
+	}
+	if _, err := parseNotifyTemplate(chat.Message); err != nil {
+		errs.add(field+".message", "invalid template: %v", err)
+	}
+}
+
+// 检查通知渠道订阅的事件和限流间隔
+func checkChannel(errs *validationErrors, field string, channel ChannelConfig) {
+	for i, event := range channel.Events {
+		if notifyEventName(event) == "" {
+			errs.add(fmt.Sprintf("%s.events[%d]", field, i), "unknown event %q, expected %s or %s", event,
+				strings.Join(notifyEvents[:len(notifyEvents)-1], ", "), notifyEvents[len(notifyEvents)-1])
+		}
+	}
+	if channel.MinInterval != "" {
+		if interval, err := time.ParseDuration(channel.MinInterval); err != nil || interval < 0 {
+			errs.add(field+".minInterval", "invalid duration %q", channel.MinInterval)
+		}
+	}
+}
+
+// 检查 IP 获取方式及其必填参数
+func checkSource(errs *validationErrors, field string, source *SourceConfig) {
+	if source == nil {
+		return
+	}
+	switch source.Source {
+	case "", "http", "upnp":
+		checkURLs(errs, field+".urls", source.URLs)
+	case "stun", "dns", "natpmp":
+	case "interface":
+		if source.Name == "" {
+			errs.add(field+".name", "interface name is required for interface source")
+		}
+	case "command":
+		if len(source.Command) == 0 {
+			errs.add(field+".command", "command is required for command source")
+		}
+	case "file":
+		if source.Path == "" {
+			errs.add(field+".path", "path is required for file source")
+		}
+	case "plugin":
+		checkPluginName(errs, field+".plugin", source.Plugin)
+	case "kubernetes":
+		checkURLs(errs, field+".urls", source.URLs)
+		if _, _, err := kubernetesObjectPath(*source); err != nil {
+			errs.add(field+".node", "%v", err)
+		}
+	default:
+		errs.add(field+".source", "unknown ip source %q, expected http, stun, dns, interface, command, file, upnp, natpmp, plugin or kubernetes", source.Source)
+	}
+	if source.Quorum < 0 {
+		errs.add(field+".quorum", "must not be negative")
+	}
+	switch {
+	case source.Quorum > 1 && (source.Source == "interface" || source.Source == "command" || source.Source == "file" || source.Source == "plugin" || source.Source == "kubernetes"):
+		errs.add(field+".quorum", "is not supported by the %s source, which has a single source", source.Source)
+	case source.Quorum > 1 && (source.Source == "" || source.Source == "http" || source.Source == "upnp") && len(source.URLs) > 0 && source.Quorum > len(source.URLs):
+		errs.add(field+".quorum", "%d is larger than the number of urls (%d)", source.Quorum, len(source.URLs))
+	case source.Quorum > 1 && (source.Source == "stun" || source.Source == "dns" || source.Source == "natpmp") && len(source.Servers) > 0 && source.Quorum > len(source.Servers):
+		errs.add(field+".quorum", "%d is larger than the number of servers (%d)", source.Quorum, len(source.Servers))
+	}
+	if source.Timeout != "" {
+		if _, err := time.ParseDuration(source.Timeout); err != nil {
+			errs.add(field+".timeout", "invalid duration %q", source.Timeout)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package updater
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// 可以通过校验的最小配置
+func validTestConfig() Config {
+	config := defaultConfig
+	config.AccessKey = "testAccessKeyID"
+	config.AccessSecret = "testAccessKeySecret"
+	config.DomainName = "example.com"
+	return config
+}
+
+func TestValidateValidConfig(t *testing.T) {
+	if err := validTestConfig().validate(); err != nil {
+		t.Fatalf("validate() error = %v", err)
+	}
+}
+
+func TestValidateInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		want   []string // 按顺序返回的全部问题
+	}{
+		{
+			name:   "placeholders of the default config",
+			modify: func(c *Config) { *c = defaultConfig },
+			want: []string{
+				`accessKey: still has the placeholder value "your_access_key", please replace it`,
+				`accessSecret: still has the placeholder value "your_access_secret", please replace it`,
+				`domainName: still has the placeholder value "your_domain_name", please replace it`,
+			},
+		},
+		{
+			name:   "missing access key",
+			modify: func(c *Config) { c.AccessKey = "" },
+			want:   []string{"accessKey: is required"},
+		},
+		{
+			name:   "unknown provider",
+			modify: func(c *Config) { c.Provider = "route53" },
+			want:   []string{`provider: unknown DNS provider "route53", expected aliyun, cloudflare, dnspod or huawei`},
+		},
+		{
+			name:   "cloudflare without token",
+			modify: func(c *Config) { c.Provider = providerCloudflare },
+			want:   []string{"cloudflare.apiToken: is required for cloudflare domains"},
+		},
+		{
+			name:   "unknown ip mode",
+			modify: func(c *Config) { c.IPMode = "ipv5" },
+			want:   []string{`ipMode: unknown ip mode "ipv5", expected ipv4, ipv6 or dual`},
+		},
+		{
+			name:   "empty host record",
+			modify: func(c *Config) { c.RR = "" },
+			want:   []string{"rr: host record is empty, use @ for the root domain"},
+		},
+		{
+			name:   "ttl out of range",
+			modify: func(c *Config) { c.TTL = 700000 },
+			want:   []string{"ttl: ttl 700000 is out of range 1-86400"},
+		},
+		{
+			name:   "negative concurrency",
+			modify: func(c *Config) { c.Concurrency = -1 },
+			want:   []string{"concurrency: must not be negative"},
+		},
+		{
+			name:   "prune without tagRecords",
+			modify: func(c *Config) { c.Prune = "delete" },
+			want:   []string{"prune: requires tagRecords"},
+		},
+		{
+			name:   "unknown account",
+			modify: func(c *Config) { c.Domains = []DomainConfig{{DomainName: "example.com", Account: "work"}} },
+			want:   []string{`domains[0].account: unknown account "work", expected the name of one of accounts`},
+		},
+		{
+			name: "proxied on aliyun",
+			modify: func(c *Config) {
+				proxied := true
+				c.Domains = []DomainConfig{{DomainName: "example.com", Proxied: &proxied}}
+			},
+			want: []string{"domains[0].proxied: is only supported by the cloudflare provider"},
+		},
+		{
+			name:   "invalid value template",
+			modify: func(c *Config) { c.Domains = []DomainConfig{{DomainName: "example.com", Value: "{{.IP"}} },
+			want:   []string{`domains[0].value: invalid template: template: value:1: unclosed action`},
+		},
+		{
+			name:   "non-http api url",
+			modify: func(c *Config) { c.APIURLs = []string{"https://api.ipify.org", "ftp://example.com/ip"} },
+			want:   []string{`apiURLs[1]: expected an http or https URL, got "ftp://example.com/ip"`},
+		},
+		{
+			name:   "unknown ip source",
+			modify: func(c *Config) { c.IPv4Source = &SourceConfig{Source: "pigeon"} },
+			want:   []string{`ipv4Source.source: unknown ip source "pigeon", expected http, stun, dns, interface, command, file, upnp, natpmp, plugin or kubernetes`},
+		},
+		{
+			name: "quorum larger than urls",
+			modify: func(c *Config) {
+				c.IPv4Source = &SourceConfig{Source: "http", URLs: []string{"https://api.ipify.org"}, Quorum: 2}
+			},
+			want: []string{"ipv4Source.quorum: 2 is larger than the number of urls (1)"},
+		},
+		{
+			name: "bindAddress and bindInterface together",
+			modify: func(c *Config) {
+				c.IPv4Source = &SourceConfig{Source: "http", BindAddress: "192.0.2.10", BindInterface: "pppoe-wan2"}
+			},
+			want: []string{"ipv4Source.bindInterface: cannot be used together with bindAddress"},
+		},
+		{
+			name:   "bindAddress of the wrong family",
+			modify: func(c *Config) { c.IPv4Source = &SourceConfig{Source: "http", BindAddress: "2001:db8::1"} },
+			want:   []string{`ipv4Source.bindAddress: "2001:db8::1" is not an IPv4 address`},
+		},
+		{
+			name:   "bindAddress with stun",
+			modify: func(c *Config) { c.IPv4Source = &SourceConfig{Source: "stun", BindAddress: "192.0.2.10"} },
+			want:   []string{"ipv4Source.bindAddress: is only supported by the http source"},
+		},
+		{
+			name:   "unknown uplink",
+			modify: func(c *Config) { c.Domains = []DomainConfig{{DomainName: "example.com", Uplink: "wan2"}} },
+			want:   []string{`domains[0].uplink: unknown uplink "wan2"`},
+		},
+		{
+			name:   "plugin source without pluginDir",
+			modify: func(c *Config) { c.IPv4Source = &SourceConfig{Source: "plugin", Plugin: "router"} },
+			want:   []string{"pluginDir: is required when a plugin source or notify.plugins is configured"},
+		},
+		{
+			name:   "churn without changes",
+			modify: func(c *Config) { c.Churn = &ChurnConfig{} },
+			want:   []string{"churn.maxChanges: must be greater than 0"},
+		},
+		{
+			name:   "unknown log level",
+			modify: func(c *Config) { c.LogLevel = "verbose" },
+			want:   []string{`logLevel: unknown log level "verbose", expected debug, info, warn or error`},
+		},
+		{
+			name:   "apiToken without metricsListen",
+			modify: func(c *Config) { c.APIToken = "testAPIToken" },
+			want:   []string{"apiToken: requires metricsListen to be set"},
+		},
+		{
+			name:   "webhook without url",
+			modify: func(c *Config) { c.Notify = &NotifyConfig{Webhooks: []WebhookConfig{{}}} },
+			want:   []string{"notify.webhooks[0].url: is required"},
+		},
+		{
+			name: "unknown notify event",
+			modify: func(c *Config) {
+				c.Notify = &NotifyConfig{Webhooks: []WebhookConfig{{URL: "https://example.com/hook", ChannelConfig: ChannelConfig{Events: []string{"ip_changed", "reboot"}}}}}
+			},
+			want: []string{`notify.webhooks[0].events[1]: unknown event "reboot", expected ` + strings.Join(notifyEvents[:len(notifyEvents)-1], ", ") + " or " + notifyEvents[len(notifyEvents)-1]},
+		},
+		{
+			name:   "invalid schedule",
+			modify: func(c *Config) { c.Schedule = "0 0 31 2 *" },
+			want:   []string{`invalid schedule "0 0 31 2 *": never matches a date`},
+		},
+		{
+			name:   "retry maxDelay below initialDelay",
+			modify: func(c *Config) { c.Retry = &RetryConfig{InitialDelay: "10s", MaxDelay: "1s"} },
+			want:   []string{"invalid retry: maxDelay 1s is less than initialDelay 10s"},
+		},
+		{
+			name:   "invalid notify.retry",
+			modify: func(c *Config) { c.Notify = &NotifyConfig{Retry: &RetryConfig{MaxAttempts: -1}} },
+			want:   []string{"invalid notify.retry.maxAttempts -1"},
+		},
+		{
+			name: "several problems",
+			modify: func(c *Config) {
+				c.AccessSecret = ""
+				c.IPMode = "both"
+				c.LogFormat = "xml"
+			},
+			want: []string{
+				"accessSecret: is required",
+				`ipMode: unknown ip mode "both", expected ipv4, ipv6 or dual`,
+				`logFormat: unknown log format "xml", expected text or json`,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := validTestConfig()
+			test.modify(&config)
+			err := config.validate()
+			var errs validationErrors
+			if !errors.As(err, &errs) {
+				t.Fatalf("validate() error = %v, want %q", err, test.want)
+			}
+			if strings.Join(errs, "\n") != strings.Join(test.want, "\n") {
+				t.Errorf("validate() returned\n  %s\nwant\n  %s", strings.Join(errs, "\n  "), strings.Join(test.want, "\n  "))
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package updater
+
+import (
+	"bytes"
+	"text/template"
+	"time"
+)
+
+// 记录值模板中可以使用的字段，例如 "last-ip={{.IP}} updated={{.Time}}"
+type recordValueData struct {
+	IP     string    // 记录对应协议族的公网 IP
+	IPv4   string    // 本次检测到的 IPv4 地址，未检测时为空
+	IPv6   string    // 本次检测到的 IPv6 地址，未检测时为空
+	Domain string    // 主域名
+	RR     string    // 主机记录
+	Type   string    // 记录类型
+	Time   valueTime // 第一次检测到当前 IP 的时间，IP 不变时记录值也不变
+}
+
+// 模板中直接输出时使用 RFC 3339 格式，也可以调用 {{.Time.Format "2006-01-02"}}
+type valueTime struct {
+	time.Time
+}
+
+func (t valueTime) String() string {
+	return t.Format(time.RFC3339)
+}
+
+// 一个协议族当前的公网 IP 以及第一次检测到的时间
+type seenIP struct {
+	ip    string
+	since time.Time
+	geo   ipGeo // 配置了 geo 时该地址的归属
+}
+
+// 只有 A 和 AAAA 记录的值默认为公网 IP，其它类型需要配置 value
+func addressRecordType(recordType string) bool {
+	return recordType == "A" || recordType == "AAAA"
+}
+
+// 解析记录值模板，引用不存在的字段时报错
+func parseValueTemplate(text string) (*template.Template, error) {
+	return template.New("value").Option("missingkey=error").Parse(text)
+}
+
+func renderValueTemplate(tmpl *template.Template, data recordValueData) (string, error) {
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,227 @@
+package updater
+
+import (
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderValueTemplate(t *testing.T) {
+	data := recordValueData{
+		IP:     "2400:3200:1:2::1a2b",
+		IPv4:   "1.2.3.4",
+		IPv6:   "2400:3200:1:2:aaaa:bbbb:cccc:dddd",
+		Domain: "example.com",
+		RR:     "nas",
+		Type:   "TXT",
+		Time:   valueTime{time.Date(2026, 10, 14, 10, 0, 0, 0, time.UTC)},
+	}
+	tests := []struct {
+		name    string
+		text    string
+		want    string
+		wantErr string // 解析或执行时的错误中应包含的内容
+	}{
+		{name: "ip", text: "{{.IP}}", want: "2400:3200:1:2::1a2b"},
+		{name: "both families", text: "v=spf1 ip4:{{.IPv4}} ip6:{{.IPv6}} -all", want: "v=spf1 ip4:1.2.3.4 ip6:2400:3200:1:2:aaaa:bbbb:cccc:dddd -all"},
+		{name: "record", text: "{{.RR}}.{{.Domain}} {{.Type}}", want: "nas.example.com TXT"},
+		{name: "time", text: "updated={{.Time}}", want: "updated=2026-10-14T10:00:00Z"},
+		{name: "time format", text: `{{.Time.Format "2006-01-02"}}`, want: "2026-10-14"},
+		{name: "plain text", text: "managed by DDns_go", want: "managed by DDns_go"},
+		{name: "unclosed action", text: "{{.IP", wantErr: "unclosed action"},
+		{name: "undefined function", text: "{{.IP | upper}}", wantErr: `function "upper" not defined`},
+		{name: "unexpected end", text: "{{end}}", wantErr: "unexpected {{end}}"},
+		{name: "unknown field", text: "{{.Address}}", wantErr: "can't evaluate field Address"},
+		{name: "unknown method of time", text: "{{.Time.Unix2}}", wantErr: "can't evaluate field Unix2"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tmpl, err := parseValueTemplate(test.text)
+			var got string
+			if err == nil {
+				got, err = renderValueTemplate(tmpl, data)
+			}
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("template %q = %q, %v, want error %q", test.text, got, err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("template %q error = %v", test.text, err)
+			}
+			if got != test.want {
+				t.Errorf("template %q = %q, want %q", test.text, got, test.want)
+			}
+		})
+	}
+}
+
+func TestWithInterfaceID(t *testing.T) {
+	tests := []struct {
+		name    string
+		prefix  string
+		id      string
+		bits    int
+		want    string
+		wantErr string
+	}{
+		{name: "MAC address", prefix: "2400:3200:1:2:aaaa:bbbb:cccc:dddd", id: "00:11:22:33:44:55", bits: 64, want: "2400:3200:1:2:211:22ff:fe33:4455"},
+		{name: "MAC address with dashes", prefix: "2400:3200:1:2::1", id: "02-11-22-33-44-55", bits: 64, want: "2400:3200:1:2:11:22ff:fe33:4455"},
+		{name: "interface identifier", prefix: "2400:3200:1:2:aaaa:bbbb:cccc:dddd", id: "::1a2b", bits: 64, want: "2400:3200:1:2::1a2b"},
+		{name: "full SLAAC address", prefix: "2400:3200:1:2::1", id: "240e:9:8:7:4:5:6:7", bits: 64, want: "2400:3200:1:2:4:5:6:7"},
+		{name: "prefix length 56", prefix: "2400:3200:1:2ff::1", id: "0:0:0:34::1", bits: 56, want: "2400:3200:1:234::1"},
+		{name: "prefix length 60", prefix: "2400:3200:1:2ff::1", id: "0:0:0:34::1", bits: 60, want: "2400:3200:1:2f4::1"},
+		{name: "prefix length 48", prefix: "2400:3200:1:2ff::1", id: "0:0:0:34::1", bits: 48, want: "2400:3200:1:34::1"},
+		{name: "IPv4 detected address", prefix: "1.2.3.4", id: "::1a2b", bits: 64, wantErr: `detected address "1.2.3.4" is not an IPv6 address`},
+		{name: "invalid detected address", prefix: "", id: "::1a2b", bits: 64, wantErr: `detected address "" is not an IPv6 address`},
+		{name: "EUI-64 MAC address", prefix: "2400:3200:1:2::1", id: "00:11:22:ff:fe:33:44:55", bits: 64, wantErr: "is not a 48-bit MAC address"},
+		{name: "IPv4 identifier", prefix: "2400:3200:1:2::1", id: "1.2.3.4", bits: 64, wantErr: "neither a MAC address nor an IPv6 interface identifier"},
+		{name: "IPv4-mapped identifier", prefix: "2400:3200:1:2::1", id: "::ffff:1.2.3.4", bits: 64, wantErr: "neither a MAC address nor an IPv6 interface identifier"},
+		{name: "identifier with zone", prefix: "2400:3200:1:2::1", id: "fe80::1a2b%eth0", bits: 64, wantErr: "neither a MAC address nor an IPv6 interface identifier"},
+		{name: "invalid identifier", prefix: "2400:3200:1:2::1", id: "nas", bits: 64, wantErr: "neither a MAC address nor an IPv6 interface identifier"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := withInterfaceID(test.prefix, test.id, test.bits)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("withInterfaceID(%q, %q, %d) = %q, %v, want error %q", test.prefix, test.id, test.bits, got, err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("withInterfaceID(%q, %q, %d) error = %v", test.prefix, test.id, test.bits, err)
+			}
+			if got != test.want {
+				t.Errorf("withInterfaceID(%q, %q, %d) = %q, want %q", test.prefix, test.id, test.bits, got, test.want)
+			}
+		})
+	}
+}
+
+func TestRecordValue(t *testing.T) {
+	u := newTestUpdater(t, newFakeAlidns(), "http://ip.invalid/", "www")
+	since := time.Date(2026, 10, 14, 10, 0, 0, 0, time.UTC)
+	u.seenIPs["IPv6"] = seenIP{ip: "2400:3200:1:2:aaaa:bbbb:cccc:dddd", since: since}
+	publicIPs := map[string]string{"IPv4": "1.2.3.4", "IPv6": "2400:3200:1:2:aaaa:bbbb:cccc:dddd"}
+	hosts := map[string]string{"nas": "00:11:22:33:44:55"}
+
+	tests := []struct {
+		name       string
+		domain     DomainConfig
+		rr         string
+		recordType string
+		publicIP   string
+		want       string
+		wantErr    string
+	}{
+		{
+			name:       "public IP",
+			domain:     DomainConfig{DomainName: "example.com"},
+			rr:         "www",
+			recordType: "AAAA",
+			publicIP:   publicIPs["IPv6"],
+			want:       "2400:3200:1:2:aaaa:bbbb:cccc:dddd",
+		},
+		{
+			name:       "ipv6Hosts",
+			domain:     DomainConfig{DomainName: "example.com", IPv6Hosts: hosts},
+			rr:         "nas",
+			recordType: "AAAA",
+			publicIP:   publicIPs["IPv6"],
+			want:       "2400:3200:1:2:211:22ff:fe33:4455",
+		},
+		{
+			// ipv6Hosts 只用于 AAAA 记录
+			name:       "ipv6Hosts with an A record",
+			domain:     DomainConfig{DomainName: "example.com", IPv6Hosts: hosts},
+			rr:         "nas",
+			recordType: "A",
+			publicIP:   publicIPs["IPv4"],
+			want:       "1.2.3.4",
+		},
+		{
+			name:       "ipv6Hosts with prefixLength",
+			domain:     DomainConfig{DomainName: "example.com", IPv6Hosts: map[string]string{"nas": "0:0:0:34::1"}, PrefixLength: 56},
+			rr:         "nas",
+			recordType: "AAAA",
+			publicIP:   "2400:3200:1:2ff::1",
+			want:       "2400:3200:1:234::1",
+		},
+		{
+			// {{.IP}} 为拼接后的地址，{{.IPv6}} 为检测到的地址
+			name:       "template with ipv6Hosts",
+			domain:     DomainConfig{DomainName: "example.com", IPv6Hosts: hosts, Value: "{{.IP}} {{.IPv6}} {{.Time}}"},
+			rr:         "nas",
+			recordType: "AAAA",
+			publicIP:   publicIPs["IPv6"],
+			want:       "2400:3200:1:2:211:22ff:fe33:4455 2400:3200:1:2:aaaa:bbbb:cccc:dddd 2026-10-14T10:00:00Z",
+		},
+		{
+			name:       "TXT template",
+			domain:     DomainConfig{DomainName: "example.com", Value: "v=spf1 ip4:{{.IPv4}} ip6:{{.IPv6}} -all"},
+			rr:         "@",
+			recordType: "TXT",
+			publicIP:   publicIPs["IPv4"],
+			want:       "v=spf1 ip4:1.2.3.4 ip6:2400:3200:1:2:aaaa:bbbb:cccc:dddd -all",
+		},
+		{
+			name:       "ipv6Hosts with an IPv4 address",
+			domain:     DomainConfig{DomainName: "example.com", IPv6Hosts: hosts},
+			rr:         "nas",
+			recordType: "AAAA",
+			publicIP:   "1.2.3.4",
+			wantErr:    `detected address "1.2.3.4" is not an IPv6 address`,
+		},
+		{
+			name:       "template referring to an unknown field",
+			domain:     DomainConfig{DomainName: "example.com", Value: "{{.Address}}"},
+			rr:         "www",
+			recordType: "TXT",
+			publicIP:   publicIPs["IPv4"],
+			wantErr:    "can't evaluate field Address",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// 与 newUpdater 相同，按模板内容缓存解析后的模板
+			if test.domain.Value != "" {
+				tmpl, err := parseValueTemplate(test.domain.Value)
+				if err != nil {
+					t.Fatal(err)
+				}
+				u.values[test.domain.Value] = tmpl
+			}
+			got, err := u.recordValue(test.domain, test.rr, test.recordType, test.publicIP, publicIPs)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("recordValue() = %q, %v, want error %q", got, err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("recordValue() error = %v", err)
+			}
+			if got != test.want {
+				t.Errorf("recordValue() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+// 配置了无效模板时 newUpdater 返回错误，不会在更新时才发现
+func TestNewUpdaterInvalidValueTemplate(t *testing.T) {
+	config := validTestConfig()
+	config.LogFileName = ""
+	config.Domains = []DomainConfig{{DomainName: "example.com", RRs: []string{"@"}, RecordTypes: []string{"TXT"}, Value: "{{.IP"}}
+	state, err := loadState("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newUpdater(config, state, slog.New(slog.NewTextHandler(io.Discard, nil))); err == nil || !strings.Contains(err.Error(), "unclosed action") {
+		t.Errorf("newUpdater() error = %v, want an invalid template error", err)
+	}
+}
@@ -0,0 +1,185 @@
+package updater
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 版本信息，发布时通过 -ldflags 写入，例如
+// go build -ldflags "-X DDns_go/pkg/updater.version=v1.2.0 -X DDns_go/pkg/updater.commit=$(git rev-parse --short HEAD) -X DDns_go/pkg/updater.buildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)" ./cmd/DDns_go
+var (
+	version   = "dev"
+	commit    = ""
+	buildDate = ""
+)
+
+// Go 模块的伪版本，例如 v0.0.0-20260518031209-1a2b3c4d5e6f，可能带有 +dirty 后缀
+var pseudoVersion = regexp.MustCompile(`\d{14}-[0-9a-f]{12}(\+dirty)?$`)
+
+// 未通过 -ldflags 设置时，使用 go install 或 go build 记录的模块版本和 git 信息
+func init() {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return
+	}
+	// go build 在 git 仓库中记录的 v0.0.0-20260518031209-1a2b3c4d5e6f 等伪版本不是发布版本，仍视为开发版本
+	if v := info.Main.Version; version == "dev" && v != "" && v != "(devel)" && !pseudoVersion.MatchString(v) {
+		version = v
+	}
+	for _, setting := range info.Settings {
+		switch {
+		case setting.Key == "vcs.revision" && commit == "":
+			commit = setting.Value
+			if len(commit) > 12 {
+				commit = commit[:12]
+			}
+		case setting.Key == "vcs.time" && buildDate == "":
+			buildDate = setting.Value
+		}
+	}
+}
+
+// -version 输出的内容，例如 "DDns_go v1.2.0 (commit 1a2b3c4, built 2026-05-18T03:12:09Z, go1.22.3 linux/arm64)"
+func versionString() string {
+	details := []string{}
+	if commit != "" {
+		details = append(details, "commit "+commit)
+	}
+	if buildDate != "" {
+		details = append(details, "built "+buildDate)
+	}
+	details = append(details, runtime.Version()+" "+runtime.GOOS+"/"+runtime.GOARCH)
+	return "DDns_go " + version + " (" + strings.Join(details, ", ") + ")"
+}
+
+// 发布版本所在的 GitHub 仓库
+const releaseRepository = "kumu7y/ailiyunDDns"
+
+// 查询最新版本的 API 地址
+var releaseAPIURL = "https://api.github.com/repos/" + releaseRepository + "/releases/latest"
+
+// 开启 updateCheck 时检查新版本的间隔
+const updateCheckInterval = 24 * time.Hour
+
+// GitHub 返回的版本信息
+type githubRelease struct {
+	TagName string `json:"tag_name"`
+	HTMLURL string `json:"html_url"`
+	Assets  []struct {
+		Name               string `json:"name"`
+		BrowserDownloadURL string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+// 查询 GitHub 上最新的正式版本，不包括草稿和预发布版本
+func latestRelease(ctx context.Context, client *http.Client) (githubRelease, error) {
+	var release githubRelease
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, releaseAPIURL, nil)
+	if err != nil {
+		return release, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("User-Agent", "DDns_go/"+version)
+	resp, err := client.Do(req)
+	if err != nil {
+		return release, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return release, fmt.Errorf("GitHub releases API failed with status: %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return release, fmt.Errorf("invalid GitHub release: %v", err)
+	}
+	if release.TagName == "" {
+		return release, fmt.Errorf("GitHub release has no tag")
+	}
+	return release, nil
+}
+
+// 按数字比较 v1.2.3 形式的版本号，latest 较新时返回 true。current 不是版本号（例如 dev）时返回 false
+func newerVersion(current, latest string) bool {
+	currentParts, ok := parseVersion(current)
+	if !ok {
+		return false
+	}
+	latestParts, ok := parseVersion(latest)
+	if !ok {
+		return false
+	}
+	for i := range latestParts {
+		if latestParts[i] != currentParts[i] {
+			return latestParts[i] > currentParts[i]
+		}
+	}
+	return false
+}
+
+// 解析 v1.2.3 或 1.2 形式的版本号，忽略 -rc1 等后缀
+func parseVersion(v string) ([3]int, bool) {
+	var parts [3]int
+	v = strings.TrimPrefix(v, "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+	fields := strings.Split(v, ".")
+	if len(fields) > len(parts) {
+		return parts, false
+	}
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 {
+			return parts, false
+		}
+		parts[i] = n
+	}
+	return parts, true
+}
+
+// 开启 updateCheck 时启动后每 24 小时检查一次新版本，有新版本时只记录日志，不会自动安装。
+// 开发版本不检查，ctx 取消后停止
+func startUpdateCheck(ctx context.Context, config Config, logger *slog.Logger) {
+	if !config.UpdateCheck {
+		return
+	}
+	if _, ok := parseVersion(version); !ok {
+		logger.Info("Skipping update check for a development build", "version", version)
+		return
+	}
+	settings, err := config.httpSettings()
+	if err != nil {
+		return
+	}
+	client := &http.Client{Timeout: settings.timeout, Transport: settings.newTransport("", settings.ipProxy)}
+
+	go func() {
+		notified := ""
+		for {
+			release, err := latestRelease(ctx, client)
+			switch {
+			case err != nil && ctx.Err() == nil:
+				logger.Warn("Failed to check for updates", "error", err)
+			case err == nil && newerVersion(version, release.TagName) && release.TagName != notified:
+				notified = release.TagName
+				logger.Info("A newer release is available, run the selfupdate command or download it manually", "version", version, "latest", release.TagName, "url", release.HTMLURL)
+			case err == nil:
+				logger.Debug("No newer release available", "version", version, "latest", release.TagName)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(updateCheckInterval):
+			}
+		}
+	}()
+}
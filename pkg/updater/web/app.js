@@ -0,0 +1,102 @@
+// 令牌保存在浏览器本地，接口返回 401 时重新输入
+const tokenKey = "ddns-api-token";
+
+async function api(path, options = {}) {
+  let token = localStorage.getItem(tokenKey) || "";
+  for (;;) {
+    const response = await fetch(path, {
+      ...options,
+      headers: { Authorization: "Bearer " + token },
+    });
+    if (response.status !== 401) {
+      return response.json();
+    }
+    token = prompt("请输入 apiToken");
+    if (token === null) {
+      throw new Error("需要 apiToken 才能查看状态");
+    }
+    localStorage.setItem(tokenKey, token);
+  }
+}
+
+function formatTime(value) {
+  return value ? new Date(value).toLocaleString() : "";
+}
+
+function cell(text, className) {
+  const td = document.createElement("td");
+  td.textContent = text || "";
+  if (className) {
+    td.className = className;
+  }
+  return td;
+}
+
+function fillTable(id, rows) {
+  const body = document.getElementById(id);
+  body.replaceChildren(...rows.map((cells) => {
+    const tr = document.createElement("tr");
+    tr.append(...cells);
+    return tr;
+  }));
+}
+
+const resultNames = {
+  pending: "等待同步",
+  updated: "已更新",
+  unchanged: "无需更新",
+  disabled: "已暂停",
+  failed: "失败",
+};
+
+async function refresh() {
+  const status = await api("status");
+  fillTable("ips", Object.entries(status.publicIps).map(([family, ip]) => [
+    cell(family),
+    cell(ip.ip),
+    cell(ip.provider),
+    cell(formatTime(ip.checkedAt)),
+    cell(ip.error, "error"),
+  ]));
+  fillTable("records", status.records.map((record) => [
+    cell(record.rr + "." + record.domain),
+    cell(record.type),
+    cell(record.value),
+    cell(resultNames[record.result] || record.result, record.result),
+    cell(formatTime(record.changedAt)),
+    cell(formatTime(record.checkedAt)),
+    cell(record.error, "error"),
+  ]));
+  document.getElementById("last-error").textContent = status.lastError ? "最后一次错误：" + status.lastError : "";
+
+  const logs = await api("logs");
+  document.getElementById("logs").textContent = logs
+    .map((entry) => formatTime(entry.time) + " " + entry.level + " " + entry.message)
+    .join("\n");
+}
+
+function showMessage(text) {
+  const message = document.getElementById("message");
+  message.textContent = text;
+  message.hidden = !text;
+}
+
+document.getElementById("update").addEventListener("click", async (event) => {
+  event.target.disabled = true;
+  try {
+    await api("update", { method: "POST" });
+    showMessage("已触发更新，稍后刷新结果");
+    setTimeout(refresh, 3000);
+  } catch (err) {
+    showMessage(err.message);
+  } finally {
+    event.target.disabled = false;
+  }
+});
+
+function start() {
+  refresh().then(() => showMessage(""), (err) => showMessage(err.message));
+}
+
+start();
+setInterval(start, 10000);
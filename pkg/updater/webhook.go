@@ -0,0 +1,91 @@
+package updater
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+)
+
+// 自定义 Webhook，body 为消息模板，未设置时发送 JSON 格式的事件
+type WebhookConfig struct {
+	URL     string            `json:"url"`
+	Method  string            `json:"method,omitempty"`  // 默认 POST
+	Headers map[string]string `json:"headers,omitempty"` // 额外的请求头，例如 Authorization
+	Body    string            `json:"body,omitempty"`    // 消息模板，例如 {"text": "{{.Domain}} {{.OldIP}} -> {{.NewIP}}"}
+	ChannelConfig
+}
+
+type webhookNotifier struct {
+	config WebhookConfig
+	body   *template.Template // 为空时发送 JSON 格式的事件
+}
+
+func newWebhookNotifier(config WebhookConfig) (*webhookNotifier, error) {
+	n := &webhookNotifier{config: config}
+	n.config.Method = strings.ToUpper(config.Method)
+	if n.config.Method == "" {
+		n.config.Method = http.MethodPost
+	}
+	if config.Body != "" {
+		body, err := parseNotifyTemplate(config.Body)
+		if err != nil {
+			return nil, fmt.Errorf("invalid body template: %v", err)
+		}
+		n.body = body
+	}
+	return n, nil
+}
+
+func (n *webhookNotifier) send(ctx context.Context, client *http.Client, event notifyEvent) error {
+	var body string
+	contentType := "application/json"
+	if n.body != nil {
+		rendered, err := renderNotifyTemplate(n.body, event)
+		if err != nil {
+			return err
+		}
+		body = rendered
+		// 模板生成的内容不一定是 JSON
+		if !json.Valid([]byte(body)) {
+			contentType = "text/plain; charset=utf-8"
+		}
+	} else {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		body = string(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, n.config.Method, n.config.URL, strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	for key, value := range n.config.Headers {
+		req.Header.Set(key, value)
+	}
+	_, err = doNotifyRequest(client, req)
+	return err
+}
+
+// 发送通知请求并返回响应内容，非 2xx 的响应视为失败
+func doNotifyRequest(client *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, &apiError{
+			message:   fmt.Sprintf("unexpected status %s: %s", resp.Status, strings.TrimSpace(string(data))),
+			retryable: resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests,
+		}
+	}
+	return data, nil
+}
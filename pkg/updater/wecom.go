@@ -0,0 +1,35 @@
+package updater
+
+import (
+	"context"
+	"net/http"
+)
+
+// 企业微信群机器人
+type WeComConfig struct {
+	Webhook string `json:"webhook"` // https://qyapi.weixin.qq.com/cgi-bin/webhook/send?key=...
+	ChatConfig
+}
+
+type weComNotifier struct {
+	config  WeComConfig
+	message *chatMessage
+}
+
+func (n *weComNotifier) send(ctx context.Context, client *http.Client, event notifyEvent) error {
+	title, text, err := n.message.render(event)
+	if err != nil {
+		return err
+	}
+	payload := map[string]interface{}{
+		"msgtype": "markdown",
+		"markdown": map[string]string{
+			"content": "**" + title + "**\n" + text,
+		},
+	}
+	data, err := postNotifyJSON(ctx, client, n.config.Webhook, payload)
+	if err != nil {
+		return err
+	}
+	return checkRobotResponse(data)
+}